@@ -0,0 +1,314 @@
+package avisha
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+)
+
+// Archiving hides a record from the lists while keeping it, and the history
+// that refers to it, on file. Archived records can be restored at any time.
+//
+// Deleting removes a record for good, and is refused while other records
+// depend on it: a tenant or site with leases, a site with meters, a lease with
+// invoices or payments, and an invoice with payments or issued before
+// another. Such records can be archived instead.
+
+// IsArchived reports whether the tenant has been archived.
+func (t Tenant) IsArchived() bool {
+	return !t.Archived.IsZero()
+}
+
+// IsArchived reports whether the site has been archived.
+func (s Site) IsArchived() bool {
+	return !s.Archived.IsZero()
+}
+
+// ArchivedBy reports whether the site had been archived by the given time.
+func (s Site) ArchivedBy(t time.Time) bool {
+	return s.IsArchived() && !t.Before(s.Archived)
+}
+
+// IsArchived reports whether the lease has been archived.
+func (l Lease) IsArchived() bool {
+	return !l.Archived.IsZero()
+}
+
+// IsArchived reports whether the invoice has been archived.
+func (inv Invoice) IsArchived() bool {
+	return !inv.Archived.IsZero()
+}
+
+// ArchiveTenant archives a tenant that has no lease in force.
+func (app App) ArchiveTenant(tenantID int) error {
+	return app.Transaction(func(tx App) error {
+		var t Tenant
+		if err := tx.One("ID", tenantID, &t); err != nil {
+			return fmt.Errorf("finding tenant: %w", err)
+		}
+		if l, err := tx.inForce(q.Eq("Tenant", tenantID)); err != nil {
+			return err
+		} else if l != nil {
+			return fmt.Errorf("tenant %s has lease %d in force: end it first", t.Name, l.ID)
+		}
+		return tx.archive(&t, "Tenant", t.ID, time.Now())
+	})
+}
+
+// RestoreTenant restores an archived tenant.
+func (app App) RestoreTenant(tenantID int) error {
+	return app.archive(&Tenant{ID: tenantID}, "Tenant", tenantID, time.Time{})
+}
+
+// DeleteTenant deletes a tenant that has never leased a site.
+func (app App) DeleteTenant(tenantID int) error {
+	return app.Transaction(func(tx App) error {
+		var t Tenant
+		if err := tx.One("ID", tenantID, &t); err != nil {
+			return fmt.Errorf("finding tenant: %w", err)
+		}
+		if n, err := tx.Select(q.Eq("Tenant", tenantID)).Count(&Lease{}); err != nil {
+			return fmt.Errorf("counting leases: %w", err)
+		} else if n > 0 {
+			return fmt.Errorf("tenant %s has %s: archive it instead", t.Name, plural(n, "lease"))
+		}
+		return tx.delete(&t, "Tenant", t.ID)
+	})
+}
+
+// ArchiveSite archives a site that has no lease in force.
+func (app App) ArchiveSite(siteID int) error {
+	return app.Transaction(func(tx App) error {
+		var s Site
+		if err := tx.One("ID", siteID, &s); err != nil {
+			return fmt.Errorf("finding site: %w", err)
+		}
+		if l, err := tx.inForce(q.Eq("Site", siteID)); err != nil {
+			return err
+		} else if l != nil {
+			return fmt.Errorf("site %s has lease %d in force: end it first", s.Number, l.ID)
+		}
+		return tx.archive(&s, "Site", s.ID, time.Now())
+	})
+}
+
+// RestoreSite restores an archived site.
+func (app App) RestoreSite(siteID int) error {
+	return app.archive(&Site{ID: siteID}, "Site", siteID, time.Time{})
+}
+
+// DeleteSite deletes a site that has never been leased or metered.
+func (app App) DeleteSite(siteID int) error {
+	return app.Transaction(func(tx App) error {
+		var s Site
+		if err := tx.One("ID", siteID, &s); err != nil {
+			return fmt.Errorf("finding site: %w", err)
+		}
+		leases, err := tx.Select(q.Eq("Site", siteID)).Count(&Lease{})
+		if err != nil {
+			return fmt.Errorf("counting leases: %w", err)
+		}
+		meters, err := tx.Select(q.Eq("Site", siteID)).Count(&Meter{})
+		if err != nil {
+			return fmt.Errorf("counting meters: %w", err)
+		}
+		switch {
+		case leases > 0:
+			return fmt.Errorf("site %s has %s: archive it instead", s.Number, plural(leases, "lease"))
+		case meters > 0:
+			return fmt.Errorf("site %s has %s: archive it instead", s.Number, plural(meters, "meter"))
+		}
+		return tx.delete(&s, "Site", s.ID)
+	})
+}
+
+// ArchiveLease archives a lease that is no longer in force.
+func (app App) ArchiveLease(leaseID int) error {
+	return app.Transaction(func(tx App) error {
+		var l Lease
+		if err := tx.One("ID", leaseID, &l); err != nil {
+			return fmt.Errorf("finding lease: %w", err)
+		}
+		if l.IsCurrent(time.Now()) {
+			return fmt.Errorf("lease %d is in force: end it first", l.ID)
+		}
+		return tx.archive(&l, "Lease", l.ID, time.Now())
+	})
+}
+
+// RestoreLease restores an archived lease.
+func (app App) RestoreLease(leaseID int) error {
+	return app.archive(&Lease{ID: leaseID}, "Lease", leaseID, time.Time{})
+}
+
+// DeleteLease deletes a lease that has no invoices and nothing billed or paid
+// to any of its services, such as a draft entered by mistake.
+func (app App) DeleteLease(leaseID int) error {
+	return app.Transaction(func(tx App) error {
+		var l Lease
+		if err := tx.One("ID", leaseID, &l); err != nil {
+			return fmt.Errorf("finding lease: %w", err)
+		}
+		var invoices int
+		for _, record := range []interface{}{&RentInvoice{}, &UtilityInvoice{}} {
+			n, err := tx.Select(q.Eq("Lease", leaseID)).Count(record)
+			if err != nil {
+				return fmt.Errorf("counting invoices: %w", err)
+			}
+			invoices += n
+		}
+		if invoices > 0 {
+			return fmt.Errorf("lease %d has %s: archive it instead", l.ID, plural(invoices, "invoice"))
+		}
+		for key, s := range l.Services {
+			if len(s.Ledger.Credits) > 0 || len(s.Ledger.Debits) > 0 {
+				return fmt.Errorf("lease %d has been billed or paid for %s: archive it instead", l.ID, key)
+			}
+		}
+		return tx.delete(&l, "Lease", l.ID)
+	})
+}
+
+// ArchiveInvoice archives an invoice of a service of a lease.
+// Archived invoices are still owed, and count towards arrears, until paid.
+func (app App) ArchiveInvoice(key ServiceKey, invoiceID int) error {
+	return app.Transaction(func(tx App) error {
+		record, entity, _, err := tx.invoice(key, invoiceID)
+		if err != nil {
+			return err
+		}
+		return tx.archive(record, entity, invoiceID, time.Now())
+	})
+}
+
+// RestoreInvoice restores an archived invoice.
+func (app App) RestoreInvoice(key ServiceKey, invoiceID int) error {
+	return app.Transaction(func(tx App) error {
+		record, entity, _, err := tx.invoice(key, invoiceID)
+		if err != nil {
+			return err
+		}
+		return tx.archive(record, entity, invoiceID, time.Time{})
+	})
+}
+
+// DeleteInvoice deletes an invoice issued in error and takes the bill back
+// off the ledger of the service.
+// Only the last invoice of a service can be deleted, and only while nothing
+// has been paid against it.
+func (app App) DeleteInvoice(key ServiceKey, invoiceID int) error {
+	return app.Transaction(func(tx App) error {
+		record, entity, inv, err := tx.invoice(key, invoiceID)
+		if err != nil {
+			return err
+		}
+		if len(inv.Balance.Credits) > 0 || inv.IsPaid() {
+			return fmt.Errorf("invoice %d has payments against it: archive it instead", inv.ID)
+		}
+		last, err := tx.lastInvoice(inv.Lease, key)
+		if err != nil {
+			return err
+		}
+		if last != inv.ID {
+			return fmt.Errorf("invoice %d has been followed by invoice %d: archive it instead", inv.ID, last)
+		}
+		if err := tx.delete(record, entity, inv.ID); err != nil {
+			return err
+		}
+		if err := tx.ReverseBill(inv.Lease, key, inv.Bill, time.Now(), fmt.Sprintf("Invoice %d deleted", inv.ID)); err != nil {
+			return fmt.Errorf("reversing bill: %w", err)
+		}
+		return nil
+	})
+}
+
+// invoice loads the invoice of the service, returning the record along with
+// its name and the invoice it embeds.
+// It is an error for the invoice to be of another service.
+func (app App) invoice(key ServiceKey, invoiceID int) (record interface{}, entity string, inv *Invoice, err error) {
+	if key == ServiceRent {
+		var rent RentInvoice
+		if err := app.One("ID", invoiceID, &rent); err != nil {
+			return nil, "", nil, fmt.Errorf("finding invoice: %w", err)
+		}
+		return &rent, "RentInvoice", &rent.Invoice, nil
+	}
+	var utility UtilityInvoice
+	if err := app.One("ID", invoiceID, &utility); err != nil {
+		return nil, "", nil, fmt.Errorf("finding invoice: %w", err)
+	}
+	if utility.Service != key {
+		return nil, "", nil, fmt.Errorf("invoice %d is for %s, not %s", utility.ID, utility.Service, key)
+	}
+	return &utility, "UtilityInvoice", &utility.Invoice, nil
+}
+
+// lastInvoice returns the ID of the last invoice issued for the service of the
+// lease, zero if none have been.
+func (app App) lastInvoice(leaseID int, key ServiceKey) (int, error) {
+	if key != ServiceRent {
+		invoices, err := app.UtilityInvoices(leaseID, key)
+		if err != nil {
+			return 0, fmt.Errorf("loading invoices: %w", err)
+		}
+		if len(invoices) == 0 {
+			return 0, nil
+		}
+		return invoices[0].ID, nil
+	}
+	var invoices []*RentInvoice
+	if err := app.Select(q.Eq("Lease", leaseID)).OrderBy("ID").Reverse().Limit(1).Find(&invoices); err != nil {
+		if err == storm.ErrNotFound {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("loading invoices: %w", err)
+	}
+	return invoices[0].ID, nil
+}
+
+// inForce returns the first of the leases matched that is in force, nil if
+// none are.
+func (app App) inForce(matcher q.Matcher) (*Lease, error) {
+	var leases []*Lease
+	if err := app.Select(matcher).Find(&leases); err != nil && err != storm.ErrNotFound {
+		return nil, fmt.Errorf("loading leases: %w", err)
+	}
+	for _, l := range leases {
+		if l.IsCurrent(time.Now()) {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+// archive sets when the record was archived, restoring it if zero.
+func (app App) archive(record interface{}, entity string, id int, archived time.Time) error {
+	if err := app.UpdateField(record, "Archived", archived); err != nil {
+		return fmt.Errorf("archiving %s: %w", entity, err)
+	}
+	if archived.IsZero() {
+		app.emit(RecordRestored{Entity: entity, ID: id})
+	} else {
+		app.emit(RecordArchived{Entity: entity, ID: id})
+	}
+	return nil
+}
+
+// delete deletes the record for good.
+func (app App) delete(record interface{}, entity string, id int) error {
+	if err := app.DeleteStruct(record); err != nil {
+		return fmt.Errorf("deleting %s: %w", entity, err)
+	}
+	app.emit(RecordDeleted{Entity: entity, ID: id})
+	return nil
+}
+
+// plural counts n of the thing, such as "1 lease" or "2 leases".
+func plural(n int, thing string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", thing)
+	}
+	return fmt.Sprintf("%d %ss", n, thing)
+}
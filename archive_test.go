@@ -0,0 +1,233 @@
+package avisha
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestDeleteInvoiceReverses checks that deleting an invoice records a reversal
+// of its bill rather than a negative bill.
+func TestDeleteInvoiceReverses(t *testing.T) {
+	start := date(2023, time.January, 1)
+	tests := []struct {
+		key   ServiceKey
+		issue func(app App, l Lease) (int, error)
+	}{
+		{
+			ServiceRent,
+			func(app App, l Lease) (int, error) {
+				inv, err := app.InvoiceRent(l.ID, Term{Start: start, Duration: 14 * 24 * time.Hour}, start)
+				return inv.ID, err
+			},
+		},
+		{
+			ServiceElectricity,
+			func(app App, l Lease) (int, error) {
+				inv := UtilityInvoice{
+					Invoice: Invoice{Lease: l.ID, Bill: 100, Issued: start},
+					Service: ServiceElectricity,
+				}
+				inv.Balance.Debit(Payment{Amount: inv.Bill, Time: inv.Issued})
+				if err := app.Save(&inv); err != nil {
+					return 0, err
+				}
+				return inv.ID, app.BillService(l.ID, ServiceElectricity, inv.Bill, inv.Issued)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.key), func(t *testing.T) {
+			app := open(t)
+			l := Lease{
+				Term:     Term{Start: start, Duration: 365 * 24 * time.Hour},
+				Rent:     700,
+				Services: map[ServiceKey]Service{tt.key: {}},
+			}
+			if err := app.Save(&l); err != nil {
+				t.Fatal(err)
+			}
+			id, err := tt.issue(app, l)
+			if err != nil {
+				t.Fatalf("issuing: %v", err)
+			}
+			if err := app.DeleteInvoice(tt.key, id); err != nil {
+				t.Fatalf("deleting: %v", err)
+			}
+			if err := app.One("ID", l.ID, &l); err != nil {
+				t.Fatal(err)
+			}
+			ledger := l.Services[tt.key].Ledger
+			if len(ledger.Debits) != 1 || ledger.Debits[0].Amount <= 0 {
+				t.Errorf("got debits %v, want the bill alone", ledger.Debits)
+			}
+			want := fmt.Sprintf("Invoice %d deleted", id)
+			if len(ledger.Reversals) != 1 || ledger.Reversals[0].Reason != want || ledger.Reversals[0].Amount != ledger.Debits[0].Amount {
+				t.Errorf("got reversals %+v, want the bill reversed as %q", ledger.Reversals, want)
+			}
+			if balance := ledger.Balance(); balance != 0 {
+				t.Errorf("got balance %v, want 0", balance)
+			}
+		})
+	}
+}
+
+// TestArchiveGuards checks that records are only archived once they are done
+// with, and only deleted while nothing depends on them.
+func TestArchiveGuards(t *testing.T) {
+	start := time.Now().AddDate(0, -1, 0)
+	rent := func(app App, l Lease, days int) (RentInvoice, error) {
+		return app.InvoiceRent(l.ID, Term{Start: start.AddDate(0, 0, days), Duration: 14 * 24 * time.Hour}, start)
+	}
+	tests := []struct {
+		name string
+		// act archives or deletes something of the lease, tenant or site.
+		act func(app App, l Lease) error
+		err bool
+	}{
+		{
+			"archive tenant with a lease in force",
+			func(app App, l Lease) error { return app.ArchiveTenant(l.Tenant) },
+			true,
+		},
+		{
+			"archive site with a lease in force",
+			func(app App, l Lease) error { return app.ArchiveSite(l.Site) },
+			true,
+		},
+		{
+			"archive lease in force",
+			func(app App, l Lease) error { return app.ArchiveLease(l.ID) },
+			true,
+		},
+		{
+			"archive ended lease",
+			func(app App, l Lease) error {
+				if err := app.GiveNotice(l.ID, start, 7*24*time.Hour); err != nil {
+					return err
+				}
+				if err := app.ArchiveLease(l.ID); err != nil {
+					return err
+				}
+				return app.ArchiveTenant(l.Tenant)
+			},
+			false,
+		},
+		{
+			"delete tenant with a lease",
+			func(app App, l Lease) error { return app.DeleteTenant(l.Tenant) },
+			true,
+		},
+		{
+			"delete site with a lease",
+			func(app App, l Lease) error { return app.DeleteSite(l.Site) },
+			true,
+		},
+		{
+			"delete lease",
+			func(app App, l Lease) error { return app.DeleteLease(l.ID) },
+			false,
+		},
+		{
+			"delete invoiced lease",
+			func(app App, l Lease) error {
+				if _, err := rent(app, l, 0); err != nil {
+					return err
+				}
+				return app.DeleteLease(l.ID)
+			},
+			true,
+		},
+		{
+			"delete paid lease",
+			func(app App, l Lease) error {
+				if err := app.PayService(l.ID, ServiceRent, 100, start); err != nil {
+					return err
+				}
+				return app.DeleteLease(l.ID)
+			},
+			true,
+		},
+		{
+			"delete paid invoice",
+			func(app App, l Lease) error {
+				inv, err := rent(app, l, 0)
+				if err != nil {
+					return err
+				}
+				if err := app.PayService(l.ID, ServiceRent, inv.Bill, start); err != nil {
+					return err
+				}
+				return app.DeleteInvoice(ServiceRent, inv.ID)
+			},
+			true,
+		},
+		{
+			"delete followed invoice",
+			func(app App, l Lease) error {
+				inv, err := rent(app, l, 0)
+				if err != nil {
+					return err
+				}
+				if _, err := rent(app, l, 14); err != nil {
+					return err
+				}
+				return app.DeleteInvoice(ServiceRent, inv.ID)
+			},
+			true,
+		},
+		{
+			"delete invoice of another service",
+			func(app App, l Lease) error {
+				inv, err := rent(app, l, 0)
+				if err != nil {
+					return err
+				}
+				return app.DeleteInvoice(ServiceElectricity, inv.ID)
+			},
+			true,
+		},
+		{
+			"delete last invoice",
+			func(app App, l Lease) error {
+				if _, err := rent(app, l, 0); err != nil {
+					return err
+				}
+				inv, err := rent(app, l, 14)
+				if err != nil {
+					return err
+				}
+				return app.DeleteInvoice(ServiceRent, inv.ID)
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := open(t)
+			var (
+				tenant Tenant
+				site   Site
+			)
+			if err := app.Save(&tenant); err != nil {
+				t.Fatal(err)
+			}
+			if err := app.Save(&site); err != nil {
+				t.Fatal(err)
+			}
+			l := Lease{
+				Tenant:   tenant.ID,
+				Site:     site.ID,
+				Term:     Term{Start: start, Duration: 365 * 24 * time.Hour},
+				Rent:     700,
+				Services: map[ServiceKey]Service{ServiceRent: {}, ServiceElectricity: {}},
+			}
+			if err := app.Save(&l); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.act(app, l); (err != nil) != tt.err {
+				t.Errorf("got error %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
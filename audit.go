@@ -0,0 +1,366 @@
+package avisha
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+)
+
+// AuditOperation is the kind of change an audit entry records.
+type AuditOperation int
+
+const (
+	AuditCreate AuditOperation = iota
+	AuditUpdate
+	AuditDelete
+)
+
+func (op AuditOperation) String() string {
+	switch op {
+	case AuditCreate:
+		return "Created"
+	case AuditUpdate:
+		return "Updated"
+	case AuditDelete:
+		return "Deleted"
+	default:
+		return "Unknown"
+	}
+}
+
+// AuditEntry records a change made to a record: when, by whom, and the value
+// of each field that changed before and after.
+//
+// Entries are only ever appended, in the same transaction as the change, so
+// the audit log is a complete history of the records.
+type AuditEntry struct {
+	ID    ID        `storm:"id,increment"`
+	Time  time.Time `storm:"index"`
+	Actor string
+	// Entity is the kind of record changed, such as "Lease" or "RentInvoice".
+	Entity string `storm:"index"`
+	// Record is the ID of the record changed, zero for settings.
+	Record    int `storm:"index"`
+	Operation AuditOperation
+	// Lease the record belongs to, if any, so that the history of a lease
+	// includes the history of its invoices.
+	Lease   int `storm:"index"`
+	Changes []FieldChange
+}
+
+// FieldChange is the value of a field before and after a change.
+// Nested fields are named by path, such as "Services.rent.Ledger.Credits.0".
+// Empty values are blank.
+type FieldChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// Subject describes the record changed, such as "Lease #4".
+func (e AuditEntry) Subject() string {
+	if e.Record == 0 {
+		return e.Entity
+	}
+	return fmt.Sprintf("%s #%d", e.Entity, e.Record)
+}
+
+// Audited returns the app with every write made through it recorded in the
+// audit log as the actor.
+func (app App) Audited(actor string) App {
+	app.Node = auditedNode{Node: app.Node, actor: actor}
+	return app
+}
+
+// AuditTrail loads the audit entries of a record, oldest first.
+// The trail of a lease includes the entries of the invoices issued for it.
+func (app App) AuditTrail(entity string, id int) ([]AuditEntry, error) {
+	matcher := q.And(q.Eq("Entity", entity), q.Eq("Record", id))
+	if entity == "Lease" {
+		matcher = q.Or(matcher, q.Eq("Lease", id))
+	}
+	var entries []AuditEntry
+	if err := app.Select(matcher).OrderBy("ID").Find(&entries); err != nil && err != storm.ErrNotFound {
+		return nil, fmt.Errorf("loading audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// AuditLog loads the audit entries made over the period, oldest first.
+func (app App) AuditLog(period Term) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	if err := app.Select(
+		q.Gte("Time", period.Start),
+		q.Lt("Time", period.End()),
+	).OrderBy("ID").Find(&entries); err != nil && err != storm.ErrNotFound {
+		return nil, fmt.Errorf("loading audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// auditedNode records an audit entry for every record written through it.
+// Writes made outside a transaction are made in one along with their entry.
+type auditedNode struct {
+	storm.Node
+	actor string
+	// tx is set for nodes bound to a transaction.
+	tx bool
+}
+
+func (n auditedNode) Begin(writable bool) (storm.Node, error) {
+	node, err := n.Node.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return auditedNode{Node: node, actor: n.actor, tx: true}, nil
+}
+
+// write runs fn in a transaction, if not already in one, so that a record and
+// its audit entry are written together.
+func (n auditedNode) write(fn func(node storm.Node) error) error {
+	if n.tx {
+		return fn(n.Node)
+	}
+	node, err := n.Node.Begin(true)
+	if err != nil {
+		return err
+	}
+	if err := fn(node); err != nil {
+		if rerr := node.Rollback(); rerr != nil {
+			return fmt.Errorf("%w (rolling back: %v)", err, rerr)
+		}
+		return err
+	}
+	return node.Commit()
+}
+
+// record writes a record and audits it: op writes data, which is loaded
+// before and after to tell what changed.
+func (n auditedNode) record(data interface{}, op func(node storm.Node) error) error {
+	return n.write(func(node storm.Node) error {
+		var (
+			entity      = reflect.Indirect(reflect.ValueOf(data)).Type()
+			before, err = load(node, entity, recordID(data))
+		)
+		if err != nil {
+			return err
+		}
+		if err := op(node); err != nil {
+			return err
+		}
+		after, err := load(node, entity, recordID(data))
+		if err != nil {
+			return err
+		}
+		return n.audit(node, entity.Name(), recordID(data), before, after)
+	})
+}
+
+// audit appends an entry for the change of a record from before to after,
+// either of which is nil when the record didn't exist.
+func (n auditedNode) audit(node storm.Node, entity string, id int, before, after interface{}) error {
+	entry := AuditEntry{
+		Time:      time.Now(),
+		Actor:     n.actor,
+		Entity:    entity,
+		Record:    id,
+		Operation: AuditUpdate,
+		Changes:   diff(before, after),
+	}
+	switch {
+	case before == nil && after == nil:
+		return nil
+	case before == nil:
+		entry.Operation = AuditCreate
+	case after == nil:
+		entry.Operation = AuditDelete
+	case len(entry.Changes) == 0:
+		return nil
+	}
+	for _, v := range []interface{}{after, before} {
+		if lease, ok := leaseOf(v); ok {
+			entry.Lease = lease
+			break
+		}
+	}
+	if err := node.Save(&entry); err != nil {
+		return fmt.Errorf("recording audit entry: %w", err)
+	}
+	return nil
+}
+
+func (n auditedNode) Save(data interface{}) error {
+	return n.record(data, func(node storm.Node) error { return node.Save(data) })
+}
+
+func (n auditedNode) Update(data interface{}) error {
+	return n.record(data, func(node storm.Node) error { return node.Update(data) })
+}
+
+func (n auditedNode) UpdateField(data interface{}, fieldName string, value interface{}) error {
+	return n.record(data, func(node storm.Node) error { return node.UpdateField(data, fieldName, value) })
+}
+
+func (n auditedNode) DeleteStruct(data interface{}) error {
+	return n.record(data, func(node storm.Node) error { return node.DeleteStruct(data) })
+}
+
+func (n auditedNode) Set(bucketName string, key interface{}, value interface{}) error {
+	return n.write(func(node storm.Node) error {
+		before := reflect.New(reflect.Indirect(reflect.ValueOf(value)).Type()).Interface()
+		if err := node.Get(bucketName, key, before); err == storm.ErrNotFound {
+			before = nil
+		} else if err != nil {
+			return err
+		}
+		if err := node.Set(bucketName, key, value); err != nil {
+			return err
+		}
+		return n.audit(node, fmt.Sprintf("%s/%v", bucketName, key), 0, before, value)
+	})
+}
+
+func (n auditedNode) Delete(bucketName string, key interface{}) error {
+	return n.write(func(node storm.Node) error {
+		before, err := node.GetBytes(bucketName, key)
+		if err != nil {
+			return err
+		}
+		if err := node.Delete(bucketName, key); err != nil {
+			return err
+		}
+		return n.audit(node, fmt.Sprintf("%s/%v", bucketName, key), 0, string(before), nil)
+	})
+}
+
+// load the record of the type with the ID, nil if there is none.
+func load(node storm.Node, entity reflect.Type, id int) (interface{}, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	v := reflect.New(entity).Interface()
+	if err := node.One("ID", id, v); err == storm.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("loading %s %d: %w", entity.Name(), id, err)
+	}
+	return v, nil
+}
+
+// recordID returns the ID of the record, zero if it has none yet.
+func recordID(data interface{}) int {
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Struct {
+		return 0
+	}
+	if id := v.FieldByName("ID"); id.IsValid() && id.Kind() == reflect.Int {
+		return int(id.Int())
+	}
+	return 0
+}
+
+// leaseOf returns the lease a record belongs to: a lease itself, or any record
+// with a Lease field.
+func leaseOf(data interface{}) (int, bool) {
+	if data == nil {
+		return 0, false
+	}
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Struct {
+		return 0, false
+	}
+	if v.Type() == reflect.TypeOf(Lease{}) {
+		return recordID(data), true
+	}
+	if lease := v.FieldByName("Lease"); lease.IsValid() && lease.Kind() == reflect.Int && lease.Int() != 0 {
+		return int(lease.Int()), true
+	}
+	return 0, false
+}
+
+// diff lists the fields that differ between two values of a record, by path.
+func diff(before, after interface{}) (changes []FieldChange) {
+	var (
+		was = make(map[string]string)
+		now = make(map[string]string)
+	)
+	if before != nil {
+		flatten("", reflect.ValueOf(before), was)
+	}
+	if after != nil {
+		flatten("", reflect.ValueOf(after), now)
+	}
+	for field, value := range was {
+		if now[field] != value {
+			changes = append(changes, FieldChange{Field: field, Before: value, After: now[field]})
+		}
+	}
+	for field, value := range now {
+		if _, ok := was[field]; !ok {
+			changes = append(changes, FieldChange{Field: field, After: value})
+		}
+	}
+	sort.Slice(changes, func(ii, jj int) bool {
+		return changes[ii].Field < changes[jj].Field
+	})
+	return changes
+}
+
+// flatten writes the non-zero fields of the value into out, keyed by path.
+// Times and values that describe themselves, like amounts, are written as
+// text; structs are broken down field by field.
+func flatten(path string, v reflect.Value, out map[string]string) {
+	join := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.IsZero() {
+		return
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		out[path] = t.Format("02/01/2006 15:04:05")
+		return
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok && v.Kind() != reflect.Struct {
+		out[path] = s.String()
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for ii := 0; ii < v.NumField(); ii++ {
+			field := v.Type().Field(ii)
+			if field.PkgPath != "" {
+				continue
+			}
+			if field.Anonymous {
+				flatten(path, v.Field(ii), out)
+				continue
+			}
+			flatten(join(field.Name), v.Field(ii), out)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		for _, key := range keys {
+			flatten(join(fmt.Sprint(key.Interface())), v.MapIndex(key), out)
+		}
+	case reflect.Slice, reflect.Array:
+		for ii := 0; ii < v.Len(); ii++ {
+			flatten(join(fmt.Sprint(ii)), v.Index(ii), out)
+		}
+	default:
+		out[path] = strings.TrimSpace(fmt.Sprint(v.Interface()))
+	}
+}
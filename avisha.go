@@ -2,6 +2,7 @@ package avisha
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/asdine/storm/v3"
@@ -18,6 +19,8 @@ type Tenant struct {
 	Name    string `storm:"unique"`
 	Contact string
 	Address Address
+	// Archived is when the tenant was archived, zero if they weren't.
+	Archived time.Time
 }
 
 // Address represents a location.
@@ -42,6 +45,24 @@ type Site struct {
 	ID       ID     `storm:"id,increment"`
 	Number   string `storm:"unique"`
 	Dwelling Dwelling
+	// FloorArea in square metres, used to share supplier bills between
+	// sites.
+	FloorArea float64
+	// Archived is when the site was archived, zero if it wasn't.
+	Archived time.Time
+}
+
+// PaymentReference generates the reference a tenant at a site pays with, using
+// the first three letters of the tenant's last name.
+func PaymentReference(tenant Tenant, site Site, suffix string) string {
+	var name string
+	if fields := strings.Fields(tenant.Name); len(fields) > 0 {
+		name = fields[len(fields)-1]
+		if len(name) > 3 {
+			name = name[0:3]
+		}
+	}
+	return fmt.Sprintf("%s-S.%s %s", strings.ToUpper(name), strings.ToUpper(site.Number), suffix)
 }
 
 // Dwelling is where a Tenant lives.
@@ -56,6 +77,9 @@ const (
 	House
 )
 
+// Dwellings lists every dwelling type.
+var Dwellings = []Dwelling{Cabin, Flat, House}
+
 func (d Dwelling) String() string {
 	switch d {
 	case Flat:
@@ -93,21 +117,53 @@ type Lease struct {
 	Site   int
 
 	Term Term
+	// Rent is the weekly rent at the start of the lease.
 	Rent currency.Currency
+	// Rents schedules changes to the weekly rent, ordered by effective date.
+	Rents []RentChange
 
-	// Services is a map of named services like rent and utilities.
-	Services map[string]Service
+	// Status of the lease in its lifecycle.
+	Status LeaseStatus
+	// Notice given to end the lease, if any.
+	Notice Notice
+	// Terminated is when the lease was ended.
+	Terminated time.Time
+	// History contains the previous terms of a renewed lease, oldest first.
+	History []Term
+	// Archived is when the lease was archived, zero if it wasn't.
+	Archived time.Time
+
+	// Services the lease subscribes to, keyed by the catalogue service.
+	Services map[ServiceKey]Service
 }
 
 // Service is a billable for a lease.
 type Service struct {
 	Ledger Ledger
+	// Closed is when the service stopped being provided.
+	Closed time.Time
+	// Final reports whether the service is owed a final invoice.
+	Final bool
+	// Charges recur for as long as the service is provided.
+	Charges []RecurringCharge
 }
 
 func (s Service) Balance() currency.Currency {
 	return s.Ledger.Balance()
 }
 
+// ClosedBy reports whether the service has stopped being provided by the given
+// time. Services of a lease given notice close when the notice takes effect.
+func (s Service) ClosedBy(t time.Time) bool {
+	return !s.Closed.IsZero() && !t.Before(s.Closed)
+}
+
+// FinalOver reports whether an invoice over the period is the final invoice
+// of the service: one is owed and the service closes by the end of the period.
+func (s Service) FinalOver(period Term) bool {
+	return s.Final && !s.Closed.After(period.End())
+}
+
 // Payment tracks currency transfer.
 type Payment struct {
 	Time   time.Time
@@ -118,6 +174,16 @@ type Payment struct {
 type Ledger struct {
 	Credits []Payment
 	Debits  []Payment
+	// Reversals withdraw debits billed in error.
+	Reversals []Reversal
+}
+
+// Reversal withdraws an amount debited in error, such as the bill of an
+// invoice deleted or of a billing run reversed.
+type Reversal struct {
+	Payment
+	// Reason describes what was reversed.
+	Reason string
 }
 
 // Credit record a credit of currency.currency.
@@ -130,6 +196,11 @@ func (l *Ledger) Debit(p Payment) {
 	l.Debits = append(l.Debits, p)
 }
 
+// Reverse records a reversal of an amount debited in error.
+func (l *Ledger) Reverse(r Reversal) {
+	l.Reversals = append(l.Reversals, r)
+}
+
 // Balance calculates the Balance of the Service.
 func (l Ledger) Balance() currency.Currency {
 	credits := currency.Currency(0)
@@ -140,6 +211,9 @@ func (l Ledger) Balance() currency.Currency {
 	for _, d := range l.Debits {
 		debits += d.Amount
 	}
+	for _, r := range l.Reversals {
+		debits -= r.Amount
+	}
 	return credits - debits
 }
 
@@ -166,6 +240,10 @@ type Invoice struct {
 	Paid time.Time
 	// Period over which the invoice applies.
 	Period Term
+	// Final marks the last invoice issued for a service.
+	Final bool
+	// Archived is when the invoice was archived, zero if it wasn't.
+	Archived time.Time
 }
 
 // IsPaid reports whether the invoice has been paid.
@@ -180,7 +258,6 @@ func (inv Invoice) IsPaid() bool {
 // @Todo overpayment policy: store up credit for this service, which automatically
 // pays down the next invoice.
 func (inv *Invoice) Pay(p Payment) error {
-	fmt.Printf("paying invoice: %v\n", p)
 	if p.Amount < 0 {
 		return fmt.Errorf("invoice payment must be a positive value, got %s", p.Amount)
 	}
@@ -196,15 +273,41 @@ func (inv *Invoice) Pay(p Payment) error {
 	return nil
 }
 
-// UtilityInvoice is a document requesting payment for utility consumption.
+// UtilityInvoice is a document requesting payment for utility consumption,
+// or for the recurring charges of a fixed service.
 type UtilityInvoice struct {
 	Invoice `storm:"inline"`
-	// UnitCost is the cost per unit of power.
+	// Service is the metered or fixed service being invoiced.
+	Service ServiceKey
+	// UnitCost is the cost per unit consumed.
 	UnitCost currency.Currency
-	// UnitsConsumed is the amount of units to charge for.
+	// UnitsConsumed is the amount of units to charge for, excluding off-peak
+	// units of time-of-use meters.
 	UnitsConsumed int
+	// PreviousReading is the units read off the meter when last invoiced.
+	PreviousReading int
 	// Reading is the units read off the meter.
 	Reading int
+	// Readings identifies the meter readings consumption is calculated
+	// between.
+	Readings struct {
+		Previous ID
+		Current  ID
+	}
+	// OffPeak records the off-peak register of time-of-use meters.
+	OffPeak struct {
+		PreviousReading int
+		Reading         int
+		UnitsConsumed   int
+	}
+	// Tariff records the tariff in effect when the invoice was generated.
+	// Zero when the units are charged at the unit cost.
+	Tariff Tariff
+	// Lines itemise the charges of the tariff.
+	Lines []LineItem
+	// Recurring itemises the recurring charges of the service that fell due
+	// over the period.
+	Recurring []LineItem
 	// GST records the GST used at the time the invoice was generated.
 	GST float64
 	// Charges contains all the constituent parts of the total bill.
@@ -213,9 +316,12 @@ type UtilityInvoice struct {
 		LateFee currency.Currency
 		// LineCharge fee.
 		LineCharge currency.Currency
+		// Recurring is the total of the recurring charges.
+		Recurring currency.Currency
 		// GST calculated based on percentage.
 		GST currency.Currency
-		// Activity charge is "units-consumed * unit-cost".
+		// Activity charge is the total of the tariff lines, or
+		// "units-consumed * unit-cost" without a tariff, off-peak included.
 		Activity currency.Currency
 	}
 }
@@ -225,6 +331,8 @@ type Settings struct {
 	Landlord Landlord
 	Bank     Bank
 	Defaults Defaults
+	// Services is the catalogue of services leases can subscribe to.
+	Services []ServiceDefinition
 }
 
 // Landlord details.
@@ -247,8 +355,14 @@ type Defaults struct {
 	UnitCost   currency.Currency
 	RentCycle  time.Duration
 	InvoiceNet time.Duration
+	// NoticePeriod is the default notice given to end a lease.
+	NoticePeriod time.Duration
+	// RentIncreaseNotice is the minimum notice required to increase rent.
+	RentIncreaseNotice time.Duration
 	// GST stored as a percentage.
 	GST float64
+	// Proration is how rent is charged for partial periods.
+	Proration ProrationMethod
 	// Address is the default for Tenants.
 	// Note: for the moment, all tenants have the same billable address so this
 	// is here to reduce tedium.
@@ -260,12 +374,61 @@ func (d *Defaults) Default() {
 	d.UnitCost = 1
 	d.RentCycle = time.Hour * 24 * 14
 	d.InvoiceNet = time.Hour * 24 * 14
+	d.NoticePeriod = time.Hour * 24 * 21
+	d.RentIncreaseNotice = time.Hour * 24 * 60
+}
+
+// fill defaults the notice periods on their own, since settings saved before
+// they existed have them zero.
+func (d *Defaults) fill() {
+	var sane Defaults
+	sane.Default()
+	if d.NoticePeriod <= 0 {
+		d.NoticePeriod = sane.NoticePeriod
+	}
+	if d.RentIncreaseNotice <= 0 {
+		d.RentIncreaseNotice = sane.RentIncreaseNotice
+	}
 }
 
 // App implements use cases.
 type App struct {
-	*storm.DB
+	storm.Node
 	notify.Notifier
+	// Index searches the records, if the app is indexed.
+	Index *Index
+	// Events publishes the changes made through the app, if set.
+	Events *Bus
+	// pending holds the events of a transaction until it commits.
+	pending *[]Event
+}
+
+// Transaction runs fn with an App bound to a single read-write transaction.
+// The transaction is committed if fn succeeds and rolled back otherwise, so
+// either all of the changes made by fn are kept or none are.
+// Transactions cannot be nested: fn must use the App it is given.
+// Events are published once the transaction commits.
+func (app App) Transaction(fn func(tx App) error) error {
+	node, err := app.Begin(true)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	var (
+		tx      = app
+		pending []Event
+	)
+	tx.Node, tx.pending = node, &pending
+	if err := fn(tx); err != nil {
+		if rerr := node.Rollback(); rerr != nil {
+			return fmt.Errorf("%w (rolling back: %v)", err, rerr)
+		}
+		return err
+	}
+	if err := node.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	app.Events.publish(pending...)
+	return nil
 }
 
 // LoadSettings loads global settings.
@@ -276,6 +439,11 @@ func (app App) LoadSettings() (s Settings, err error) {
 	if s.Defaults == (Defaults{}) {
 		s.Defaults.Default()
 	}
+	s.Defaults.fill()
+	if len(s.Services) == 0 {
+		s.Services = DefaultServices(s.Defaults)
+	}
+	s.fill()
 	return s, nil
 }
 
@@ -284,7 +452,12 @@ func (app App) SaveSettings(s Settings) (err error) {
 	if s.Defaults == (Defaults{}) {
 		s.Defaults.Default()
 	}
-	return app.Set("settings", "global", &s)
+	s.Defaults.fill()
+	if err := app.Set("settings", "global", &s); err != nil {
+		return err
+	}
+	app.emit(SettingsSaved{Settings: s})
+	return nil
 }
 
 // CreateLease creates a new lease.
@@ -295,7 +468,70 @@ func (app App) CreateLease(l *Lease) error {
 	if l.Site == 0 {
 		return fmt.Errorf("lease must have a valid site")
 	}
-	return app.Save(l)
+	var (
+		t Tenant
+		s Site
+	)
+	if err := app.One("ID", l.Tenant, &t); err != nil {
+		return fmt.Errorf("finding tenant: %w", err)
+	}
+	if err := app.One("ID", l.Site, &s); err != nil {
+		return fmt.Errorf("finding site: %w", err)
+	}
+	if t.IsArchived() {
+		return fmt.Errorf("tenant %s is archived", t.Name)
+	}
+	if s.IsArchived() {
+		return fmt.Errorf("site %s is archived", s.Number)
+	}
+	if l.Services == nil {
+		settings, err := app.LoadSettings()
+		if err != nil {
+			return fmt.Errorf("loading settings: %w", err)
+		}
+		l.Services = make(map[ServiceKey]Service)
+		for _, def := range settings.Services {
+			if def.Default {
+				l.Services[def.Key] = Service{}
+			}
+		}
+	}
+	if err := app.Save(l); err != nil {
+		return err
+	}
+	app.emit(LeaseCreated{Lease: *l})
+	return nil
+}
+
+// UpdateLease saves changes to the details of a lease: its tenant, site, term
+// and starting rent.
+// The rest of the lease is kept as saved, so that changes made to it since
+// the details were loaded, such as payments, aren't lost.
+// The starting rent cannot change once rent has been invoiced, the rent is
+// increased by scheduling a rent increase instead.
+func (app App) UpdateLease(l *Lease) error {
+	return app.Transaction(func(tx App) error {
+		var saved Lease
+		if err := tx.One("ID", l.ID, &saved); err != nil {
+			return fmt.Errorf("finding lease: %w", err)
+		}
+		if l.Rent != saved.Rent {
+			n, err := tx.Select(q.Eq("Lease", int(l.ID))).Count(&RentInvoice{})
+			if err != nil {
+				return fmt.Errorf("counting rent invoices: %w", err)
+			}
+			if n > 0 {
+				return fmt.Errorf("lease %d has been invoiced for rent: schedule a rent increase instead", l.ID)
+			}
+		}
+		saved.Tenant, saved.Site, saved.Term, saved.Rent = l.Tenant, l.Site, l.Term, l.Rent
+		if err := tx.Save(&saved); err != nil {
+			return err
+		}
+		*l = saved
+		tx.emit(LeaseUpdated{Lease: saved})
+		return nil
+	})
 }
 
 // ListSite enters a new, unqiue, leaseable Site.
@@ -303,7 +539,23 @@ func (app App) ListSite(s *Site) error {
 	if len(s.Number) < 1 {
 		return fmt.Errorf("number required")
 	}
-	return app.Save(s)
+	if err := app.Save(s); err != nil {
+		return err
+	}
+	app.emit(SiteListed{Site: *s})
+	return nil
+}
+
+// UpdateSite saves changes to the details of a site.
+func (app App) UpdateSite(s *Site) error {
+	if len(s.Number) < 1 {
+		return fmt.Errorf("number required")
+	}
+	if err := app.Update(s); err != nil {
+		return err
+	}
+	app.emit(SiteUpdated{Site: *s})
+	return nil
 }
 
 // RegisterTenant enters a new, unique Tenant.
@@ -311,90 +563,191 @@ func (app App) RegisterTenant(t *Tenant) error {
 	if len(t.Name) < 1 {
 		return fmt.Errorf("name required")
 	}
-	return app.Save(t)
+	if err := app.Save(t); err != nil {
+		return err
+	}
+	app.emit(TenantRegistered{Tenant: *t})
+	return nil
+}
+
+// UpdateTenant saves changes to the details of a tenant.
+func (app App) UpdateTenant(t *Tenant) error {
+	if len(t.Name) < 1 {
+		return fmt.Errorf("name required")
+	}
+	if err := app.Update(t); err != nil {
+		return err
+	}
+	// Update skips zero fields, so the contact is set on its own to allow it
+	// to be cleared.
+	if err := app.UpdateField(&Tenant{ID: t.ID}, "Contact", t.Contact); err != nil {
+		return err
+	}
+	app.emit(TenantUpdated{Tenant: *t})
+	return nil
 }
 
-// PayService records a payment for some service on a lease.
+// PayService records a payment for some service on a lease, made at the given
+// time.
 //
 // @Refactor When paying a service we want to pay a specific invoice of that service.
 // Otherwise, if no invoice is specified, we want to pay the oldest invoice first
 // and store as credits any overpayment.
-func (app App) PayService(leaseID int, service string, amount currency.Currency) error {
-	fmt.Printf("PayService: %v\n", amount)
+func (app App) PayService(leaseID int, service ServiceKey, amount currency.Currency, at time.Time) error {
+	return app.Transaction(func(tx App) error {
+		var l Lease
+		if err := tx.One("ID", leaseID, &l); err != nil {
+			return fmt.Errorf("finding lease: %w", err)
+		}
+		if l.Services == nil {
+			l.Services = make(map[ServiceKey]Service)
+		}
+		var (
+			s       = l.Services[service]
+			payment = Payment{
+				Amount: amount,
+				Time:   at,
+			}
+		)
+		s.Ledger.Credit(payment)
+		l.Services[service] = s
+		if err := tx.Update(&l); err != nil {
+			return err
+		}
+		tx.emit(PaymentRecorded{Lease: leaseID, Service: service, Payment: payment})
+		if err := tx.markInvoices(leaseID, service, s, at); err != nil {
+			return fmt.Errorf("marking invoices: %w", err)
+		}
+		return nil
+	})
+}
+
+// BillService records a debt for some service on a lease, owed from the given
+// time.
+func (app App) BillService(leaseID int, service ServiceKey, amount currency.Currency, at time.Time) error {
 	var l Lease
 	if err := app.One("ID", leaseID, &l); err != nil {
 		return fmt.Errorf("finding lease: %w", err)
 	}
 	if l.Services == nil {
-		l.Services = make(map[string]Service)
+		l.Services = make(map[ServiceKey]Service)
 	}
-	s := l.Services[service]
-	s.Ledger.Credit(Payment{
-		Amount: amount,
-		Time:   time.Now(),
-	})
+	var (
+		s     = l.Services[service]
+		debit = Payment{
+			Amount: amount,
+			Time:   at,
+		}
+	)
+	s.Ledger.Debit(debit)
 	l.Services[service] = s
-	if err := app.markInvoices(leaseID, s); err != nil {
+	if err := app.markInvoices(leaseID, service, s, at); err != nil {
 		return fmt.Errorf("marking invoices: %w", err)
 	}
-	return app.Update(&l)
+	if err := app.Update(&l); err != nil {
+		return err
+	}
+	app.emit(ServiceBilled{Lease: leaseID, Service: service, Debit: debit})
+	return nil
 }
 
-// BillService records a debt for some service on a lease.
-func (app App) BillService(leaseID int, service string, amount currency.Currency) error {
+// ReverseBill withdraws an amount billed to some service on a lease in error,
+// as of the given time.
+// The reversal is recorded on the ledger of the service alongside the bill,
+// rather than as a negative bill, with the reason for it.
+func (app App) ReverseBill(leaseID int, service ServiceKey, amount currency.Currency, at time.Time, reason string) error {
+	if amount < 0 {
+		return fmt.Errorf("reversal must be a positive amount, got %s", amount)
+	}
 	var l Lease
 	if err := app.One("ID", leaseID, &l); err != nil {
 		return fmt.Errorf("finding lease: %w", err)
 	}
-	if l.Services == nil {
-		l.Services = make(map[string]Service)
+	s, ok := l.Services[service]
+	if !ok {
+		return fmt.Errorf("lease is not subscribed to %q", service)
 	}
-	s := l.Services[service]
-	s.Ledger.Debit(Payment{
-		Amount: amount,
-		Time:   time.Now(),
-	})
+	r := Reversal{
+		Payment: Payment{
+			Amount: amount,
+			Time:   at,
+		},
+		Reason: reason,
+	}
+	s.Ledger.Reverse(r)
 	l.Services[service] = s
-	if err := app.markInvoices(leaseID, s); err != nil {
-		return fmt.Errorf("marking invoices: %w", err)
+	if err := app.Update(&l); err != nil {
+		return err
 	}
-	return app.Update(&l)
+	app.emit(BillReversed{Lease: leaseID, Service: service, Reversal: r})
+	return nil
 }
 
 // markInvoices marks invoices for a given service as paid, starting from oldest
-// first.
-func (app App) markInvoices(leaseID int, service Service) error {
+// first, as of the given time.
+func (app App) markInvoices(leaseID int, key ServiceKey, service Service, at time.Time) error {
 	var (
 		total    int
-		invoices []*UtilityInvoice
+		invoices []*Invoice
+		records  []interface{}
+		paid     []ID
 	)
-	if err := app.Select(q.Eq("Lease", leaseID)).OrderBy("ID").Find(&invoices); err != nil {
-		return fmt.Errorf("loading invoices: %w", err)
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	// @Todo invoice bucket per service.
+	switch def, _ := settings.Service(key); def.Kind {
+	case Rental:
+		var rent []*RentInvoice
+		if err := app.Select(q.Eq("Lease", leaseID)).OrderBy("ID").Find(&rent); err != nil && err != storm.ErrNotFound {
+			return fmt.Errorf("loading invoices: %w", err)
+		}
+		for _, inv := range rent {
+			invoices = append(invoices, &inv.Invoice)
+			records = append(records, inv)
+		}
+	default:
+		var utilities []*UtilityInvoice
+		if err := app.Select(q.Eq("Lease", leaseID), q.Eq("Service", key)).OrderBy("ID").Find(&utilities); err != nil && err != storm.ErrNotFound {
+			return fmt.Errorf("loading invoices: %w", err)
+		}
+		for _, inv := range utilities {
+			invoices = append(invoices, &inv.Invoice)
+			records = append(records, inv)
+		}
 	}
 	for _, credit := range service.Ledger.Credits {
 		total += int(credit.Amount)
 	}
-	// Pay all the invoices we can, marking them paid as of now if they weren't
-	// marked already.
+	// Pay all the invoices we can, marking them paid as of the time if they
+	// weren't marked already.
 	//
 	// @Note this is valid for utilties, but rent needs to be payable out-of-order.
 	for _, inv := range invoices {
 		if total < int(inv.Bill) {
 			break
 		}
+		total -= int(inv.Bill)
+		if inv.IsPaid() {
+			continue
+		}
 		if err := inv.Pay(Payment{
 			Amount: inv.Bill,
-			Time:   time.Now(),
+			Time:   at,
 		}); err != nil {
 			return fmt.Errorf("paying invoice: %v", err)
 		}
-		total -= int(inv.Bill)
+		paid = append(paid, inv.ID)
 	}
-	for _, inv := range invoices {
-		if err := app.Update(inv); err != nil {
+	for _, record := range records {
+		if err := app.Update(record); err != nil {
 			return fmt.Errorf("update: %w", err)
 		}
 	}
+	for _, id := range paid {
+		app.emit(InvoicePaid{Lease: leaseID, Service: key, Invoice: id})
+	}
 	return nil
 }
 
@@ -0,0 +1,207 @@
+package avisha
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// TestPayServiceDated checks that a payment, and the invoices it pays, are
+// dated when the payment was made rather than when it was recorded.
+func TestPayServiceDated(t *testing.T) {
+	app := open(t)
+	l := Lease{
+		Term:     Term{Start: date(2023, time.January, 1), Duration: 365 * 24 * time.Hour},
+		Services: map[ServiceKey]Service{ServiceElectricity: {}},
+	}
+	if err := app.Save(&l); err != nil {
+		t.Fatal(err)
+	}
+	inv := UtilityInvoice{
+		Invoice: Invoice{
+			Lease:  int(l.ID),
+			Bill:   100,
+			Issued: date(2023, time.February, 1),
+			Due:    date(2023, time.February, 15),
+		},
+		Service: ServiceElectricity,
+	}
+	inv.Balance.Debit(Payment{Amount: inv.Bill, Time: inv.Issued})
+	if err := app.Save(&inv); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.BillService(int(l.ID), ServiceElectricity, inv.Bill, inv.Issued); err != nil {
+		t.Fatalf("billing: %v", err)
+	}
+	tests := []struct {
+		amount int
+		paid   time.Time
+		// settled is when the invoice should be paid after the payment, if
+		// at all.
+		settled time.Time
+	}{
+		{60, date(2023, time.February, 10), time.Time{}},
+		{40, date(2023, time.February, 12), date(2023, time.February, 12)},
+	}
+	for _, tt := range tests {
+		if err := app.PayService(int(l.ID), ServiceElectricity, currency.Currency(tt.amount), tt.paid); err != nil {
+			t.Fatalf("paying: %v", err)
+		}
+		if err := app.One("ID", l.ID, &l); err != nil {
+			t.Fatal(err)
+		}
+		credits := l.Services[ServiceElectricity].Ledger.Credits
+		if last := credits[len(credits)-1]; !last.Time.Equal(tt.paid) {
+			t.Errorf("got payment dated %v, want %v", last.Time, tt.paid)
+		}
+		var got UtilityInvoice
+		if err := app.One("ID", inv.ID, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Paid.Equal(tt.settled) {
+			t.Errorf("after paying %d: got invoice paid %v, want %v", tt.amount, got.Paid, tt.settled)
+		}
+	}
+}
+
+// TestLoadSettingsDefaults checks that defaults added after settings were
+// saved are filled in on their own, keeping those already set.
+func TestLoadSettingsDefaults(t *testing.T) {
+	var sane Defaults
+	sane.Default()
+	tests := []struct {
+		name  string
+		saved Defaults
+		want  Defaults
+	}{
+		{"none saved", Defaults{}, sane},
+		{
+			"saved before notice periods",
+			Defaults{UnitCost: 30, RentCycle: 7 * 24 * time.Hour, InvoiceNet: 7 * 24 * time.Hour},
+			Defaults{
+				UnitCost:           30,
+				RentCycle:          7 * 24 * time.Hour,
+				InvoiceNet:         7 * 24 * time.Hour,
+				NoticePeriod:       sane.NoticePeriod,
+				RentIncreaseNotice: sane.RentIncreaseNotice,
+			},
+		},
+		{
+			"notice periods set",
+			Defaults{UnitCost: 30, NoticePeriod: 28 * 24 * time.Hour, RentIncreaseNotice: 90 * 24 * time.Hour},
+			Defaults{UnitCost: 30, NoticePeriod: 28 * 24 * time.Hour, RentIncreaseNotice: 90 * 24 * time.Hour},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := open(t)
+			if err := app.Set("settings", "global", &Settings{Defaults: tt.saved}); err != nil {
+				t.Fatal(err)
+			}
+			s, err := app.LoadSettings()
+			if err != nil {
+				t.Fatalf("loading settings: %v", err)
+			}
+			if s.Defaults != tt.want {
+				t.Errorf("got %+v, want %+v", s.Defaults, tt.want)
+			}
+		})
+	}
+}
+
+// TestUpdateLease checks that editing the details of a lease keeps changes
+// made to it since it was loaded, and locks the starting rent once invoiced.
+func TestUpdateLease(t *testing.T) {
+	app := open(t)
+	l := Lease{
+		Tenant:   1,
+		Site:     1,
+		Term:     Term{Start: date(2023, time.January, 1), Duration: 365 * 24 * time.Hour},
+		Rent:     500,
+		Services: map[ServiceKey]Service{ServiceRent: {}},
+	}
+	if err := app.Save(&l); err != nil {
+		t.Fatal(err)
+	}
+	// The form holds the lease as loaded while a payment is made.
+	edit := l
+	if err := app.PayService(int(l.ID), ServiceRent, 100, date(2023, time.January, 5)); err != nil {
+		t.Fatal(err)
+	}
+	edit.Site, edit.Rent = 2, 550
+	if err := app.UpdateLease(&edit); err != nil {
+		t.Fatalf("updating lease: %v", err)
+	}
+	if err := app.One("ID", l.ID, &l); err != nil {
+		t.Fatal(err)
+	}
+	if l.Site != 2 || l.Rent != 550 {
+		t.Errorf("got site %d rent %s, want site 2 rent $5.50", l.Site, l.Rent)
+	}
+	if got := l.Services[ServiceRent].Ledger.Credits; len(got) != 1 {
+		t.Errorf("got %d payments, want the payment made while editing kept", len(got))
+	}
+	if err := app.Save(&RentInvoice{Invoice: Invoice{Lease: int(l.ID), Bill: 550}}); err != nil {
+		t.Fatal(err)
+	}
+	edit = l
+	edit.Rent = 600
+	if err := app.UpdateLease(&edit); err == nil {
+		t.Errorf("changed the starting rent of an invoiced lease")
+	}
+	edit.Rent = l.Rent
+	edit.Term.Duration = 730 * 24 * time.Hour
+	if err := app.UpdateLease(&edit); err != nil {
+		t.Errorf("updating the term of an invoiced lease: %v", err)
+	}
+}
+
+// TestPayServiceEvents checks that paying a service publishes the payment
+// before the invoices it pays, and only once both have been saved.
+func TestPayServiceEvents(t *testing.T) {
+	app := open(t)
+	l := Lease{
+		Term:     Term{Start: date(2023, time.January, 1), Duration: 365 * 24 * time.Hour},
+		Services: map[ServiceKey]Service{ServiceElectricity: {}},
+	}
+	if err := app.Save(&l); err != nil {
+		t.Fatal(err)
+	}
+	inv := UtilityInvoice{
+		Invoice: Invoice{Lease: int(l.ID), Bill: 100, Issued: date(2023, time.February, 1)},
+		Service: ServiceElectricity,
+	}
+	inv.Balance.Debit(Payment{Amount: inv.Bill, Time: inv.Issued})
+	if err := app.Save(&inv); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.BillService(int(l.ID), ServiceElectricity, inv.Bill, inv.Issued); err != nil {
+		t.Fatalf("billing: %v", err)
+	}
+	var got []string
+	app.Events.Subscribe(func(e Event) {
+		var (
+			saved Lease
+			paid  UtilityInvoice
+		)
+		if err := app.One("ID", l.ID, &saved); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.One("ID", inv.ID, &paid); err != nil {
+			t.Fatal(err)
+		}
+		if saved.Services[ServiceElectricity].Balance() != 0 || !paid.IsPaid() {
+			t.Errorf("%T published before the payment was saved", e)
+		}
+		got = append(got, fmt.Sprintf("%T", e))
+	})
+	if err := app.PayService(int(l.ID), ServiceElectricity, 100, date(2023, time.February, 10)); err != nil {
+		t.Fatalf("paying: %v", err)
+	}
+	want := []string{"avisha.PaymentRecorded", "avisha.InvoicePaid"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,294 @@
+package avisha
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// BillingRun bills a metered service for every current lease at once, from
+// meter readings taken on the same date.
+type BillingRun struct {
+	ID      ID `storm:"id,increment"`
+	Service ServiceKey
+	// Date the meters were read and the invoices issued.
+	Date time.Time
+	// UnitCost charged to every lease in the run when the service has no
+	// tariff in effect.
+	UnitCost currency.Currency
+	// Lines bill each lease in the run.
+	Lines []BillingLine
+	// Committed is when the invoices were issued.
+	Committed time.Time
+	// Reversed is when the invoices were withdrawn, zero if they stand.
+	Reversed time.Time
+}
+
+// BillingLine bills a single lease within a billing run.
+type BillingLine struct {
+	Lease int
+	Site  int
+	Meter int
+	// Baseline is the reading on the meter consumption is calculated from.
+	Baseline Reading
+	// Carried is the units consumed on replaced meters since the previous
+	// invoice, which are billed along with those read off the meter.
+	Carried int
+	// Reading is the value read off the meter for the run.
+	Reading int
+	// OffPeak is the value read off the off-peak register of a time-of-use
+	// meter.
+	OffPeak int
+	// Estimated marks the reading as an estimate.
+	Estimated bool
+	// Skip excludes the lease from the run.
+	Skip bool
+	// Invoice previewed, or once committed, issued for the lease.
+	Invoice UtilityInvoice
+}
+
+// Total returns the total billed by the run, and the GST included in it.
+func (run BillingRun) Total() (bill, gst currency.Currency) {
+	for _, line := range run.Lines {
+		if line.Skip {
+			continue
+		}
+		bill += line.Invoice.Bill
+		gst += line.Invoice.Charges.GST
+	}
+	return bill, gst
+}
+
+// IsCommitted reports whether the invoices of the run have been issued.
+func (run BillingRun) IsCommitted() bool {
+	return !run.Committed.IsZero()
+}
+
+// IsReversed reports whether the invoices of the run have been withdrawn.
+func (run BillingRun) IsReversed() bool {
+	return !run.Reversed.IsZero()
+}
+
+// NewBillingRun prepares a billing run for a metered service, with a line for
+// every lease current on the date that subscribes to it.
+// Leases without a meter in service are skipped.
+func (app App) NewBillingRun(key ServiceKey, date time.Time) (run BillingRun, err error) {
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return run, fmt.Errorf("loading settings: %w", err)
+	}
+	def, _ := settings.Service(key)
+	if def.Kind != Metered {
+		return run, fmt.Errorf("service %q is not metered", key)
+	}
+	run = BillingRun{
+		Service:  key,
+		Date:     date,
+		UnitCost: def.Price,
+	}
+	var leases []*Lease
+	if err := app.All(&leases); err != nil {
+		return run, fmt.Errorf("loading leases: %w", err)
+	}
+	for _, l := range leases {
+		s, ok := l.Services[key]
+		if !ok || s.ClosedBy(date) || !l.IsCurrent(date) {
+			continue
+		}
+		line := BillingLine{Lease: l.ID, Site: l.Site}
+		meter, baseline, carried, err := app.MeterBaseline(l.ID, key)
+		if errors.Is(err, storm.ErrNotFound) {
+			line.Skip = true
+		} else if err != nil {
+			return run, fmt.Errorf("lease %d: %w", l.ID, err)
+		} else {
+			line.Meter = meter.ID
+			line.Baseline = baseline
+			line.Carried = carried
+			line.Reading = baseline.Value
+			line.OffPeak = baseline.OffPeak
+		}
+		run.Lines = append(run.Lines, line)
+	}
+	sort.SliceStable(run.Lines, func(ii, jj int) bool {
+		return run.Lines[ii].Site < run.Lines[jj].Site
+	})
+	return run, nil
+}
+
+// PreviewBillingRun calculates the invoice of each line from its reading
+// without issuing anything.
+func (app App) PreviewBillingRun(run *BillingRun) error {
+	if run.IsCommitted() {
+		return fmt.Errorf("billing run has already been committed")
+	}
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	def, _ := settings.Service(run.Service)
+	for ii := range run.Lines {
+		line := &run.Lines[ii]
+		if line.Skip {
+			continue
+		}
+		var meter Meter
+		if err := app.One("ID", line.Meter, &meter); err != nil {
+			return fmt.Errorf("lease %d: finding meter: %w", line.Lease, err)
+		}
+		if run.Date.Before(line.Baseline.Date) {
+			return fmt.Errorf("lease %d: reading must not be before %s", line.Lease, line.Baseline.Date.Format("02/01/2006"))
+		}
+		units, err := meter.Consumption(line.Baseline.Value, line.Reading)
+		if err != nil {
+			return fmt.Errorf("lease %d: %w", line.Lease, err)
+		}
+		var offPeak int
+		if meter.TimeOfUse {
+			if offPeak, err = meter.Consumption(line.Baseline.OffPeak, line.OffPeak); err != nil {
+				return fmt.Errorf("lease %d: off-peak: %w", line.Lease, err)
+			}
+		}
+		line.Invoice = UtilityInvoice{
+			Invoice: Invoice{
+				Lease:  line.Lease,
+				Issued: run.Date,
+				Due:    run.Date.Add(settings.Defaults.InvoiceNet),
+				Period: Term{
+					Start:    line.Baseline.Date,
+					Duration: run.Date.Sub(line.Baseline.Date),
+				},
+			},
+			Service:         run.Service,
+			UnitCost:        run.UnitCost,
+			UnitsConsumed:   units + line.Carried,
+			PreviousReading: line.Baseline.Value,
+			Reading:         line.Reading,
+			GST:             def.Tax.Rate(settings.Defaults.GST),
+		}
+		line.Invoice.OffPeak.PreviousReading = line.Baseline.OffPeak
+		line.Invoice.OffPeak.Reading = line.OffPeak
+		line.Invoice.OffPeak.UnitsConsumed = offPeak
+		line.Invoice.Tariff, _ = def.TariffAt(run.Date)
+		var l Lease
+		if err := app.One("ID", line.Lease, &l); err != nil {
+			return fmt.Errorf("lease %d: finding lease: %w", line.Lease, err)
+		}
+		line.Invoice.Recurring = l.Services[run.Service].ChargeLines(line.Invoice.Period)
+		line.Invoice.Calculate()
+	}
+	return nil
+}
+
+// CommitBillingRun records the reading and issues the invoice of each line,
+// then saves the run so it can be reviewed or reversed.
+// The run is committed in a single transaction: if any line fails nothing is
+// issued.
+func (app App) CommitBillingRun(run *BillingRun) error {
+	return app.Transaction(func(tx App) error {
+		if err := tx.PreviewBillingRun(run); err != nil {
+			return err
+		}
+		for ii := range run.Lines {
+			line := &run.Lines[ii]
+			if line.Skip {
+				continue
+			}
+			reading := Reading{
+				Meter:     line.Meter,
+				Date:      run.Date,
+				Value:     line.Reading,
+				OffPeak:   line.OffPeak,
+				Estimated: line.Estimated,
+				Note:      "Billing run",
+			}
+			if err := tx.RecordReading(&reading); err != nil {
+				return fmt.Errorf("lease %d: recording reading: %w", line.Lease, err)
+			}
+			line.Invoice.Readings.Current = reading.ID
+			if err := tx.issueUtilityInvoice(line.Lease, run.Service, &line.Invoice); err != nil {
+				return fmt.Errorf("lease %d: issuing invoice: %w", line.Lease, err)
+			}
+		}
+		run.Committed = time.Now()
+		if err := tx.Save(run); err != nil {
+			return fmt.Errorf("saving billing run: %w", err)
+		}
+		tx.emit(BillingRunCommitted{Run: run.ID})
+		return nil
+	})
+}
+
+// ReverseBillingRun withdraws the invoices issued by a billing run, along with
+// the readings taken for it, and reverses the amounts billed.
+// A run can only be reversed while none of its invoices have been paid and
+// nothing has been invoiced or read since.
+func (app App) ReverseBillingRun(runID int) error {
+	return app.Transaction(func(tx App) error {
+		var run BillingRun
+		if err := tx.One("ID", runID, &run); err != nil {
+			return fmt.Errorf("finding billing run: %w", err)
+		}
+		if run.IsReversed() {
+			return fmt.Errorf("billing run has already been reversed")
+		}
+		for _, line := range run.Lines {
+			if line.Skip {
+				continue
+			}
+			var inv UtilityInvoice
+			if err := tx.One("ID", line.Invoice.ID, &inv); err != nil {
+				return fmt.Errorf("lease %d: finding invoice: %w", line.Lease, err)
+			}
+			if len(inv.Balance.Credits) > 0 {
+				return fmt.Errorf("lease %d: invoice %d has payments against it", line.Lease, inv.ID)
+			}
+			invoices, err := tx.UtilityInvoices(line.Lease, run.Service)
+			if err != nil {
+				return fmt.Errorf("lease %d: loading invoices: %w", line.Lease, err)
+			}
+			if len(invoices) > 0 && invoices[0].ID != inv.ID {
+				return fmt.Errorf("lease %d: invoice %d has been issued since", line.Lease, invoices[0].ID)
+			}
+			readings, err := tx.Readings(line.Meter)
+			if err != nil {
+				return fmt.Errorf("lease %d: loading readings: %w", line.Lease, err)
+			}
+			if n := len(readings); n > 0 && readings[n-1].ID != inv.Readings.Current {
+				return fmt.Errorf("lease %d: the meter has been read since", line.Lease)
+			}
+			if err := tx.DeleteStruct(&inv); err != nil {
+				return fmt.Errorf("lease %d: deleting invoice: %w", line.Lease, err)
+			}
+			var reading Reading
+			if err := tx.One("ID", inv.Readings.Current, &reading); err != nil {
+				return fmt.Errorf("lease %d: finding reading: %w", line.Lease, err)
+			}
+			if err := tx.DeleteStruct(&reading); err != nil {
+				return fmt.Errorf("lease %d: deleting reading: %w", line.Lease, err)
+			}
+			if err := tx.ReverseBill(line.Lease, run.Service, inv.Bill, time.Now(), fmt.Sprintf("Billing run %d reversed", run.ID)); err != nil {
+				return fmt.Errorf("lease %d: reversing bill: %w", line.Lease, err)
+			}
+		}
+		run.Reversed = time.Now()
+		if err := tx.Save(&run); err != nil {
+			return err
+		}
+		tx.emit(BillingRunReversed{Run: run.ID})
+		return nil
+	})
+}
+
+// BillingRuns loads every billing run, most recent first.
+func (app App) BillingRuns() ([]*BillingRun, error) {
+	var runs []*BillingRun
+	if err := app.AllByIndex("ID", &runs, storm.Reverse()); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return runs, nil
+}
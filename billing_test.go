@@ -0,0 +1,117 @@
+package avisha
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBillingRun checks that a billing run bills the units read off each meter,
+// and can be reversed while nothing has been paid, read or reversed since.
+func TestBillingRun(t *testing.T) {
+	var (
+		start = date(2023, time.January, 1)
+		read  = date(2023, time.February, 1)
+	)
+	tests := []struct {
+		name string
+		// since changes the records after the run is committed, if not nil.
+		since func(app App, l Lease, run BillingRun) error
+		err   bool
+	}{
+		{"reversed", nil, false},
+		{
+			"paid since",
+			func(app App, l Lease, run BillingRun) error {
+				return app.PayService(l.ID, ServiceElectricity, run.Lines[0].Invoice.Bill, read)
+			},
+			true,
+		},
+		{
+			"read since",
+			func(app App, l Lease, run BillingRun) error {
+				return app.RecordReading(&Reading{Meter: run.Lines[0].Meter, Date: date(2023, time.March, 1), Value: 200})
+			},
+			true,
+		},
+		{
+			"reversed already",
+			func(app App, l Lease, run BillingRun) error {
+				return app.ReverseBillingRun(run.ID)
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := open(t)
+			site := Site{Number: "1"}
+			if err := app.Save(&site); err != nil {
+				t.Fatal(err)
+			}
+			l := Lease{
+				Site:     site.ID,
+				Term:     Term{Start: start, Duration: 365 * day},
+				Services: map[ServiceKey]Service{ServiceElectricity: {}},
+			}
+			if err := app.Save(&l); err != nil {
+				t.Fatal(err)
+			}
+			meter := Meter{Site: site.ID, Service: ServiceElectricity, Installed: start}
+			if err := app.InstallMeter(&meter, Reading{Value: 100}); err != nil {
+				t.Fatalf("installing: %v", err)
+			}
+			run, err := app.NewBillingRun(ServiceElectricity, read)
+			if err != nil {
+				t.Fatalf("preparing: %v", err)
+			}
+			if len(run.Lines) != 1 || run.Lines[0].Skip || run.Lines[0].Reading != 100 {
+				t.Fatalf("got lines %+v, want the lease read from 100", run.Lines)
+			}
+			run.Lines[0].Reading = 150
+			if err := app.CommitBillingRun(&run); err != nil {
+				t.Fatalf("committing: %v", err)
+			}
+			inv := run.Lines[0].Invoice
+			if inv.ID == 0 || inv.UnitsConsumed != 50 {
+				t.Errorf("got invoice %d for %d units, want 50 units invoiced", inv.ID, inv.UnitsConsumed)
+			}
+			if err := app.One("ID", l.ID, &l); err != nil {
+				t.Fatal(err)
+			}
+			if got := -l.Services[ServiceElectricity].Balance(); got != inv.Bill {
+				t.Errorf("got ledger billed %v, want %v", got, inv.Bill)
+			}
+			if tt.since != nil {
+				if err := tt.since(app, l, run); err != nil {
+					t.Fatalf("changing: %v", err)
+				}
+			}
+			if err := app.ReverseBillingRun(run.ID); (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			invoices, err := app.UtilityInvoices(l.ID, ServiceElectricity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(invoices) != 0 {
+				t.Errorf("got %d invoices, want none", len(invoices))
+			}
+			readings, err := app.Readings(meter.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(readings) != 1 || readings[0].Value != 100 {
+				t.Errorf("got readings %+v, want the opening reading alone", readings)
+			}
+			if err := app.One("ID", l.ID, &l); err != nil {
+				t.Fatal(err)
+			}
+			if balance := l.Services[ServiceElectricity].Balance(); balance != 0 {
+				t.Errorf("got balance %v, want 0", balance)
+			}
+		})
+	}
+}
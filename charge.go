@@ -0,0 +1,207 @@
+package avisha
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// LineItem is a charge itemised on an invoice.
+type LineItem struct {
+	Description string
+	// Quantity is the days, units or occurrences charged for.
+	Quantity int
+	Rate     currency.Currency
+	Amount   currency.Currency
+}
+
+func lineItem(description string, quantity int, rate currency.Currency) LineItem {
+	return LineItem{
+		Description: description,
+		Quantity:    quantity,
+		Rate:        rate,
+		Amount:      rate * currency.Currency(quantity),
+	}
+}
+
+// Frequency is how often a recurring charge falls due.
+type Frequency int
+
+const (
+	// EachInvoice charges once on every invoice issued while the charge is
+	// current, whatever the period of the invoice.
+	EachInvoice Frequency = iota
+	Weekly
+	Fortnightly
+	Monthly
+)
+
+func (f Frequency) String() string {
+	switch f {
+	case EachInvoice:
+		return "Each Invoice"
+	case Weekly:
+		return "Weekly"
+	case Fortnightly:
+		return "Fortnightly"
+	case Monthly:
+		return "Monthly"
+	default:
+		return "Unknown"
+	}
+}
+
+// RecurringCharge is a fixed amount charged to a lease service at a regular
+// frequency, such as line rental, parking or storage.
+type RecurringCharge struct {
+	// ID identifies the charge within the service.
+	ID          int
+	Description string
+	// Amount charged each time the charge falls due.
+	Amount    currency.Currency
+	Frequency Frequency
+	// Start is when the charge first falls due.
+	Start time.Time
+	// End is when the charge stops, zero if it continues until the service
+	// is closed.
+	End time.Time
+}
+
+// Validate the charge can be billed.
+func (c RecurringCharge) Validate() error {
+	if c.Description == "" {
+		return fmt.Errorf("charge must have a description")
+	}
+	if c.Amount <= 0 {
+		return fmt.Errorf("charge must have a positive amount")
+	}
+	if c.Start.IsZero() {
+		return fmt.Errorf("charge must have a start date")
+	}
+	if !c.End.IsZero() && !c.End.After(c.Start) {
+		return fmt.Errorf("charge must end after it starts")
+	}
+	return nil
+}
+
+// Occurrences counts the times the charge falls due within the period.
+// Charges made on each invoice fall due once if they are current at any point
+// over the period.
+func (c RecurringCharge) Occurrences(period Term) (n int) {
+	end := period.End()
+	if !c.End.IsZero() && c.End.Before(end) {
+		end = c.End
+	}
+	if c.Frequency == EachInvoice {
+		if c.Start.Before(end) && end.After(period.Start) {
+			return 1
+		}
+		return 0
+	}
+	// Due dates are counted from the start rather than from each other, and
+	// monthly ones are held to the last day of months too short for the day
+	// they start on, so that a charge starting on the 31st falls due once in
+	// every month rather than twice in some and not at all in others.
+	for ii := 0; ; ii++ {
+		due := c.due(ii)
+		if !due.Before(end) {
+			break
+		}
+		if !due.Before(period.Start) {
+			n++
+		}
+	}
+	return n
+}
+
+// due returns the date of the nth occurrence of the charge.
+func (c RecurringCharge) due(n int) time.Time {
+	switch c.Frequency {
+	case Weekly:
+		return c.Start.AddDate(0, 0, 7*n)
+	case Fortnightly:
+		return c.Start.AddDate(0, 0, 14*n)
+	default:
+		var (
+			first = time.Date(c.Start.Year(), c.Start.Month()+time.Month(n), 1, 0, 0, 0, 0, c.Start.Location())
+			last  = first.AddDate(0, 1, -1).Day()
+			day   = c.Start.Day()
+		)
+		if day > last {
+			day = last
+		}
+		return time.Date(
+			first.Year(), first.Month(), day,
+			c.Start.Hour(), c.Start.Minute(), c.Start.Second(), c.Start.Nanosecond(),
+			c.Start.Location(),
+		)
+	}
+}
+
+// ChargeLines itemises the recurring charges that fall due over the period.
+// Charges stop when the service is closed.
+func (s Service) ChargeLines(period Term) (lines []LineItem) {
+	for _, c := range s.Charges {
+		if !s.Closed.IsZero() && (c.End.IsZero() || s.Closed.Before(c.End)) {
+			c.End = s.Closed
+		}
+		if n := c.Occurrences(period); n > 0 {
+			lines = append(lines, lineItem(c.Description, n, c.Amount))
+		}
+	}
+	return lines
+}
+
+// AddRecurringCharge attaches a recurring charge to a service the lease is
+// subscribed to, to be billed on the invoices of the service.
+// Charges of metered services are billed with their utility invoices, others
+// on invoices of their own issued with the rent.
+func (app App) AddRecurringCharge(leaseID int, key ServiceKey, c RecurringCharge) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return fmt.Errorf("finding lease: %w", err)
+	}
+	s, ok := l.Services[key]
+	if !ok || s.ClosedBy(time.Now()) {
+		return fmt.Errorf("lease is not subscribed to %q", key)
+	}
+	c.ID = 1
+	for _, existing := range s.Charges {
+		if existing.ID >= c.ID {
+			c.ID = existing.ID + 1
+		}
+	}
+	s.Charges = append(s.Charges, c)
+	l.Services[key] = s
+	if err := app.Save(&l); err != nil {
+		return err
+	}
+	app.emit(RecurringChargeAdded{Lease: l.ID, Service: key, Charge: c})
+	return nil
+}
+
+// RemoveRecurringCharge detaches a recurring charge from a service.
+// Invoices already issued keep the charge.
+func (app App) RemoveRecurringCharge(leaseID int, key ServiceKey, chargeID int) error {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return fmt.Errorf("finding lease: %w", err)
+	}
+	s := l.Services[key]
+	for ii, c := range s.Charges {
+		if c.ID == chargeID {
+			s.Charges = append(s.Charges[:ii], s.Charges[ii+1:]...)
+			l.Services[key] = s
+			if err := app.Save(&l); err != nil {
+				return err
+			}
+			app.emit(RecurringChargeRemoved{Lease: l.ID, Service: key, Charge: chargeID})
+			return nil
+		}
+	}
+	return fmt.Errorf("no charge %d on %q", chargeID, key)
+}
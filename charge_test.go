@@ -0,0 +1,116 @@
+package avisha
+
+import (
+	"testing"
+	"time"
+)
+
+// month returns the term of the month in the year.
+func month(year int, m time.Month) Term {
+	start := time.Date(year, m, 1, 0, 0, 0, 0, time.Local)
+	return Term{Start: start, Duration: start.AddDate(0, 1, 0).Sub(start)}
+}
+
+func date(year int, m time.Month, day int) time.Time {
+	return time.Date(year, m, day, 0, 0, 0, 0, time.Local)
+}
+
+// TestMonthlyChargeEndOfMonth checks that a monthly charge starting late in a
+// month falls due exactly once in every following month, short or not.
+func TestMonthlyChargeEndOfMonth(t *testing.T) {
+	tests := []struct {
+		name  string
+		start time.Time
+		// want is the day the charge falls due in each month of the year
+		// after the start, from February.
+		want []int
+	}{
+		{"29th leap year", date(2024, time.January, 29), []int{29, 29, 29, 29, 29, 29, 29, 29, 29, 29, 29}},
+		{"30th leap year", date(2024, time.January, 30), []int{29, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30}},
+		{"31st leap year", date(2024, time.January, 31), []int{29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}},
+		{"29th", date(2023, time.January, 29), []int{28, 29, 29, 29, 29, 29, 29, 29, 29, 29, 29}},
+		{"30th", date(2023, time.January, 30), []int{28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30}},
+		{"31st", date(2023, time.January, 31), []int{28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := RecurringCharge{Amount: 100, Frequency: Monthly, Start: tt.start}
+			for ii, want := range tt.want {
+				m := time.February + time.Month(ii)
+				if n := c.Occurrences(month(tt.start.Year(), m)); n != 1 {
+					t.Errorf("%s: got %d occurrences, want 1", m, n)
+				}
+				if due := c.due(ii + 1); due.Month() != m || due.Day() != want {
+					t.Errorf("occurrence %d: got %s, want %d %s", ii+1, due.Format("2 Jan"), want, m)
+				}
+			}
+		})
+	}
+}
+
+func TestChargeOccurrences(t *testing.T) {
+	start := date(2023, time.March, 1)
+	tests := []struct {
+		name   string
+		charge RecurringCharge
+		period Term
+		want   int
+	}{
+		{
+			"weekly over a month",
+			RecurringCharge{Frequency: Weekly, Start: start},
+			month(2023, time.March),
+			5,
+		},
+		{
+			"fortnightly over a month",
+			RecurringCharge{Frequency: Fortnightly, Start: start},
+			month(2023, time.March),
+			3,
+		},
+		{
+			"monthly before it starts",
+			RecurringCharge{Frequency: Monthly, Start: start},
+			month(2023, time.February),
+			0,
+		},
+		{
+			"monthly after it ends",
+			RecurringCharge{Frequency: Monthly, Start: start, End: date(2023, time.May, 1)},
+			month(2023, time.May),
+			0,
+		},
+		{
+			"each invoice while current",
+			RecurringCharge{Frequency: EachInvoice, Start: start},
+			Term{Start: date(2023, time.June, 1), Duration: 24 * time.Hour},
+			1,
+		},
+		{
+			"each invoice once ended",
+			RecurringCharge{Frequency: EachInvoice, Start: start, End: date(2023, time.April, 1)},
+			month(2023, time.June),
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.charge.Occurrences(tt.period); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChargeLinesStopWhenClosed(t *testing.T) {
+	s := Service{
+		Charges: []RecurringCharge{
+			{Description: "Line rental", Amount: 500, Frequency: Weekly, Start: date(2023, time.March, 1)},
+		},
+		Closed: date(2023, time.March, 15),
+	}
+	lines := s.ChargeLines(month(2023, time.March))
+	if len(lines) != 1 || lines[0].Quantity != 2 || lines[0].Amount != 1000 {
+		t.Fatalf("got %+v, want 2 weeks of line rental", lines)
+	}
+}
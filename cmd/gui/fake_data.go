@@ -95,10 +95,12 @@ func LoadFakeData(db *storm.DB) error {
 			Account: "123345567",
 		},
 		Defaults: avisha.Defaults{
-			UnitCost:   currency.Dollar,
-			RentCycle:  14 * 24 * time.Hour,
-			InvoiceNet: 14 * 24 * time.Hour,
-			GST:        10,
+			UnitCost:           currency.Dollar,
+			RentCycle:          14 * 24 * time.Hour,
+			InvoiceNet:         14 * 24 * time.Hour,
+			NoticePeriod:       21 * 24 * time.Hour,
+			RentIncreaseNotice: 60 * 24 * time.Hour,
+			GST:                10,
 		},
 	}
 	leases := []avisha.Lease{}
@@ -113,6 +115,31 @@ func LoadFakeData(db *storm.DB) error {
 				Duration: time.Duration(rand.Intn(365+50)) * 24 * time.Hour,
 			},
 			Rent: currency.Dollar * currency.Currency(rand.Intn(200+50)),
+			Services: map[avisha.ServiceKey]avisha.Service{
+				avisha.ServiceRent:        {},
+				avisha.ServiceElectricity: {},
+			},
+		})
+	}
+	var (
+		meters   []avisha.Meter
+		readings []avisha.Reading
+	)
+	for _, site := range sites {
+		meters = append(meters, avisha.Meter{
+			ID:        site.ID,
+			Site:      site.ID,
+			Service:   avisha.ServiceElectricity,
+			Serial:    fmt.Sprintf("E%05d", rand.Intn(100000)),
+			Digits:    5,
+			Installed: time.Now(),
+		})
+		readings = append(readings, avisha.Reading{
+			ID:    site.ID,
+			Meter: site.ID,
+			Date:  time.Now(),
+			Value: rand.Intn(10000),
+			Note:  "Opening reading",
 		})
 	}
 	for _, s := range sites {
@@ -120,6 +147,16 @@ func LoadFakeData(db *storm.DB) error {
 			return err
 		}
 	}
+	for _, m := range meters {
+		if err := db.Save(&m); err != nil {
+			return err
+		}
+	}
+	for _, r := range readings {
+		if err := db.Save(&r); err != nil {
+			return err
+		}
+	}
 	for _, t := range tenants {
 		if err := db.Save(&t); err != nil {
 			return err
@@ -104,3 +104,73 @@ var Description *widget.Icon = func() *widget.Icon {
 	icon, _ := widget.NewIcon(icons.ActionDescription)
 	return icon
 }()
+
+var Receipt *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.ActionReceipt)
+	return icon
+}()
+
+var AccountBalance *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.ActionAccountBalance)
+	return icon
+}()
+
+var Warning *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.AlertWarning)
+	return icon
+}()
+
+var Assessment *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.ActionAssessment)
+	return icon
+}()
+
+var Dashboard *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.ActionDashboard)
+	return icon
+}()
+
+var Search *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.ActionSearch)
+	return icon
+}()
+
+var History *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.ActionHistory)
+	return icon
+}()
+
+var Archive *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.ContentArchive)
+	return icon
+}()
+
+var Unarchive *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.ContentUnarchive)
+	return icon
+}()
+
+var Delete *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.ActionDelete)
+	return icon
+}()
+
+var Error *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.AlertError)
+	return icon
+}()
+
+var Calendar *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.ActionDateRange)
+	return icon
+}()
+
+var ChevronLeft *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.NavigationChevronLeft)
+	return icon
+}()
+
+var ChevronRight *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.NavigationChevronRight)
+	return icon
+}()
@@ -5,6 +5,7 @@ import (
 	"image/color"
 	"log"
 	"os"
+	"os/user"
 	"path/filepath"
 
 	"github.com/asdine/storm/v3"
@@ -20,9 +21,11 @@ import (
 	"github.com/jackmordaunt/avisha.go/notify"
 
 	"gioui.org/app"
+	"gioui.org/io/key"
 	"gioui.org/io/system"
 	"gioui.org/layout"
 	"gioui.org/op"
+	"gioui.org/widget/material"
 )
 
 var (
@@ -89,6 +92,24 @@ func main() {
 		if err := db.Init(&avisha.UtilityInvoice{}); err != nil {
 			return nil, err
 		}
+		if err := db.Init(&avisha.RentInvoice{}); err != nil {
+			return nil, err
+		}
+		if err := db.Init(&avisha.Meter{}); err != nil {
+			return nil, err
+		}
+		if err := db.Init(&avisha.Reading{}); err != nil {
+			return nil, err
+		}
+		if err := db.Init(&avisha.BillingRun{}); err != nil {
+			return nil, err
+		}
+		if err := db.Init(&avisha.SupplierBill{}); err != nil {
+			return nil, err
+		}
+		if err := db.Init(&avisha.AuditEntry{}); err != nil {
+			return nil, err
+		}
 		if develop {
 			if err := LoadFakeData(db); err != nil {
 				return nil, fmt.Errorf("loading fake data: %v", err)
@@ -101,30 +122,69 @@ func main() {
 	}
 	defer db.Close()
 	api := avisha.App{
-		DB:       db,
+		Node:     db,
 		Notifier: &notify.Console{},
+		Events:   &avisha.Bus{},
+	}.Audited(actor()).Indexed()
+	if develop {
+		api.Events.Subscribe(func(e avisha.Event) {
+			log.Printf("event: %T %+v", e, e)
+		})
+	}
+	if err := api.Migrate(); err != nil {
+		log.Fatalf("error: migrating database: %v", err)
 	}
 	w := app.NewWindow(app.Title("Avisha"), app.MinSize(unit.Dp(400), unit.Dp(400)))
+	// Redraw on every change so that views load the fresh records.
+	api.Events.Subscribe(func(avisha.Event) {
+		w.Invalidate()
+	})
 	th := style.NewTheme(style.BootstrapPalette)
+	alerts := &views.Alerts{Th: th, Invalidate: w.Invalidate}
+	undo := &views.Undo{
+		App:     &api,
+		Alerts:  alerts,
+		History: avisha.History{Limit: 100},
+	}
 	ui := &UI{
 		Window: w,
 		Th:     th,
+		Alerts: alerts,
+		Undo:   undo,
 		Router: nav.Router{
 			Routes: map[string]nav.View{
-				views.RouteLease:      &views.LeaseList{App: &api, Th: th},
-				views.RouteTenants:    &views.Tenants{App: &api, Th: th},
-				views.RouteSites:      &views.Sites{App: &api, Th: th},
-				views.RouteLeasePage:  &views.LeasePage{App: &api, Th: th},
-				views.RouteTenantForm: &views.TenantForm{App: &api, Th: th},
-				views.RouteSiteForm:   &views.SiteForm{App: &api, Th: th},
-				views.RouteSettings:   &views.SettingsPage{App: &api, Th: th},
+				views.RouteDashboard:  &views.Dashboard{App: &api, Th: th, Invalidate: w.Invalidate, Alerts: alerts},
+				views.RouteLease:      &views.LeaseList{App: &api, Th: th, Invalidate: w.Invalidate, Alerts: alerts},
+				views.RouteTenants:    &views.Tenants{App: &api, Th: th, Invalidate: w.Invalidate, Alerts: alerts},
+				views.RouteSites:      &views.Sites{App: &api, Th: th, Invalidate: w.Invalidate, Alerts: alerts},
+				views.RouteLeasePage:  &views.LeasePage{App: &api, Th: th, Invalidate: w.Invalidate, Undo: undo, Alerts: alerts},
+				views.RouteTenantForm: &views.TenantForm{App: &api, Th: th, Alerts: alerts},
+				views.RouteSiteForm:   &views.SiteForm{App: &api, Th: th, Invalidate: w.Invalidate, Alerts: alerts},
+				views.RouteSettings:   &views.SettingsPage{App: &api, Th: th, Alerts: alerts},
+				views.RouteBilling:    &views.BillingRunPage{App: &api, Th: th, Invalidate: w.Invalidate, Alerts: alerts},
+				views.RouteSuppliers:  &views.SupplierBillsPage{App: &api, Th: th, Invalidate: w.Invalidate, Alerts: alerts},
+				views.RouteArrears:    &views.ArrearsPage{App: &api, Th: th, Invalidate: w.Invalidate, Alerts: alerts},
+				views.RouteIncome:     &views.IncomePage{App: &api, Th: th, Alerts: alerts},
+				views.RouteReports:    &views.ReportsPage{App: &api, Th: th, Alerts: alerts},
+				views.RouteSearch:     &views.SearchPage{App: &api, Th: th, Alerts: alerts},
+				views.RouteAudit:      &views.AuditPage{App: &api, Th: th, Invalidate: w.Invalidate, Alerts: alerts},
 			},
-			Stack: []string{views.RouteLease},
+			Stack: []string{views.RouteDashboard},
 		},
 		Rail: style.NavRail{
 			Th:    th,
 			Width: unit.Dp(80),
 			Destinations: []style.Destination{
+				{
+					Label: "Home",
+					Route: views.RouteDashboard,
+					Icon:  icons.Dashboard,
+				},
+				{
+					Label: "Search",
+					Route: views.RouteSearch,
+					Icon:  icons.Search,
+				},
 				{
 					Label: "Leases",
 					Route: views.RouteLease,
@@ -140,6 +200,16 @@ func main() {
 					Route: views.RouteSites,
 					Icon:  icons.Home,
 				},
+				{
+					Label: "Billing",
+					Route: views.RouteBilling,
+					Icon:  icons.Receipt,
+				},
+				{
+					Label: "Reports",
+					Route: views.RouteReports,
+					Icon:  icons.Assessment,
+				},
 				{
 					Label: "Settings",
 					Route: views.RouteSettings,
@@ -157,6 +227,14 @@ func main() {
 	app.Main()
 }
 
+// actor names the user making changes, for the audit log.
+func actor() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
 // UI is the high level object that contains all global state.
 // Anything that needs to integrate with the external system is allocated on
 // this object.
@@ -166,6 +244,10 @@ type UI struct {
 	Router nav.Router
 	Rail   style.NavRail
 	Modal  layout.Widget
+	// Alerts tells the user what happened, errors especially.
+	Alerts *views.Alerts
+	// Undo undoes the actions taken this session.
+	Undo *views.Undo
 }
 
 func (ui *UI) Loop() error {
@@ -178,6 +260,20 @@ func (ui *UI) Loop() error {
 			if event.Type == system.CommandBack {
 				ui.Router.Pop()
 			}
+		case key.Event:
+			// Search is reachable from anywhere with the shortcut.
+			if event.State == key.Press && event.Name == "K" && event.Modifiers.Contain(key.ModShortcut) {
+				ui.Router.Push(views.RouteSearch, nil)
+				ui.Invalidate()
+			}
+			// Undo with the shortcut, redo with shift as well.
+			if event.State == key.Press && event.Name == "Z" && event.Modifiers.Contain(key.ModShortcut) {
+				if event.Modifiers.Contain(key.ModShift) {
+					ui.Undo.Redo()
+				} else {
+					ui.Undo.Undo()
+				}
+			}
 		case system.FrameEvent:
 			gtx := layout.NewContext(&ops, event)
 			ui.Layout(gtx)
@@ -217,11 +313,17 @@ func (ui *UI) Layout(gtx C) D {
 					}
 					return ""
 				}(),
-				func() []layout.Widget {
+				func() (list []layout.Widget) {
 					if contexter, ok := ui.Router.Active().(nav.Contexter); ok {
-						return contexter.Context()
+						list = contexter.Context()
+					}
+					// The errors of the session stay to hand until cleared.
+					if len(ui.Alerts.Errors()) > 0 {
+						list = append(list, func(gtx C) D {
+							return material.IconButton(ui.Th.Danger(), &ui.Alerts.Panel, icons.Error).Layout(gtx)
+						})
 					}
-					return nil
+					return list
 				}()...)
 		}),
 		layout.Flexed(1, func(gtx C) D {
@@ -251,6 +353,12 @@ func (ui *UI) Layout(gtx C) D {
 							}
 							return ui.Modal(gtx)
 						}),
+						layout.Expanded(func(gtx C) D {
+							return ui.Alerts.Layout(gtx)
+						}),
+						layout.Expanded(func(gtx C) D {
+							return ui.Alerts.LayoutPanel(gtx)
+						}),
 					)
 				}),
 			)
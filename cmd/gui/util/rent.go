@@ -0,0 +1,271 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go"
+)
+
+// RentInvoiceDocument renders rent invoices to an html document.
+type RentInvoiceDocument struct {
+	Invoice avisha.RentInvoice
+	// Position is how far the rent is paid, if known.
+	Position *avisha.RentPosition
+
+	Lease    avisha.Lease
+	Tenant   avisha.Tenant
+	Site     avisha.Site
+	Settings avisha.Settings
+}
+
+// Render the document into a buffer.
+func (doc RentInvoiceDocument) Render() (*bytes.Buffer, error) {
+	return render("rent-invoice-document", RentInvoiceTemplateLiteral, template.FuncMap{
+		"date": formatLongDate,
+		"days": func(t avisha.Term) int {
+			return t.Days()
+		},
+		"generateReference": func() string {
+			return Reference(doc.Tenant, doc.Site, "RENT")
+		},
+	}, doc)
+}
+
+// RentIncreaseNoticeDocument renders notice of a rent increase to an html
+// document.
+type RentIncreaseNoticeDocument struct {
+	Change avisha.RentChange
+	// Current is the weekly rent before the increase.
+	Current avisha.RentChange
+
+	Lease    avisha.Lease
+	Tenant   avisha.Tenant
+	Site     avisha.Site
+	Settings avisha.Settings
+}
+
+// Render the document into a buffer.
+func (doc RentIncreaseNoticeDocument) Render() (*bytes.Buffer, error) {
+	return render("rent-increase-notice-document", RentIncreaseNoticeTemplateLiteral, template.FuncMap{
+		"date": formatLongDate,
+		"increase": func() string {
+			return (doc.Change.Amount - doc.Current.Amount).String()
+		},
+	}, doc)
+}
+
+// render executes the template literal against the data.
+func render(name, literal string, funcs template.FuncMap, data interface{}) (*bytes.Buffer, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(strings.TrimSpace(literal))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+	by := new(bytes.Buffer)
+	if err := tmpl.Execute(by, data); err != nil {
+		return nil, fmt.Errorf("executing template: %w", err)
+	}
+	return by, nil
+}
+
+func formatLongDate(t time.Time) string {
+	return t.Format("Monday, 2 January 2006")
+}
+
+// RentInvoiceTemplateLiteral contains the literal html used to generate a rent
+// invoice.
+var RentInvoiceTemplateLiteral = `
+<!doctype html>
+<html lang="en">
+	<head>
+		<meta charset="utf-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1.0">
+		<link rel="stylesheet" href="https://vanillacss.com/vanilla.css" media="all">
+		<title>Invoice {{.Invoice.ID}}</title>
+		<style>
+			body{
+				margin: 0 auto;
+				max-width: 50rem;
+			}
+			table,tbody {
+				text-align: center;
+			}
+			table caption {
+				margin: 0;
+				padding: 0.25rem;
+				text-align: left;
+				font-weight: bold;
+			}
+		</style>
+	</head>
+	<body id="top" role="document">
+		<article id="preamble">
+			<header><h1>Tax Invoice {{.Invoice.ID}}{{if .Invoice.Final}} (Final){{end}}</h1></header>
+			<p>
+				<b>AVISHA GROUP LTD</b>
+				</br>
+				Property Management Services
+				</br>
+				{{.Settings.Landlord.Address}}
+			</p>
+			<p>
+				Issued {{date .Invoice.Issued}}
+				</br>
+				Site {{.Site.Number}} ({{.Site.Dwelling}})
+				</br>
+				Period: <var>{{.Invoice.Period}}</var>
+				</br>
+				Service: <b>Rent</b>
+			</p>
+			<p>
+				<b>Bill To</b>
+				</br>
+				{{.Tenant.Name}}
+				</br>
+				{{.Tenant.Address}}
+				</br>
+				{{.Tenant.Contact}}
+			</p>
+		</article>
+		<article id="activity">
+			<table>
+				<caption>Rent (prorated by {{.Invoice.Proration}})</caption>
+				<thead>
+					<tr>
+						<th>Period</th>
+						<th>Days</th>
+						<th>Weekly Rent</th>
+						<th>Daily Rate</th>
+						<th>Amount</th>
+					</tr>
+				</thead>
+				<tbody>
+					{{range $line := .Invoice.Lines}}
+					<tr>
+						<td><var>{{$line.Period}}</var>{{if $line.Prorated}} <small>(prorated)</small>{{end}}</td>
+						<td><var>{{days $line.Period}}</var></td>
+						<td><var>{{$line.Rate}}</var></td>
+						<td>{{if $line.DailyRate}}<var>{{$line.DailyRate}}</var>{{end}}</td>
+						<td><var>{{$line.Amount}}</var></td>
+					</tr>
+					{{end}}
+				</tbody>
+			</table>
+			{{if .Invoice.Recurring}}
+			<table>
+				<caption>Recurring Charges</caption>
+				<thead>
+					<tr>
+						<th>Charge</th>
+						<th>Occurrences</th>
+						<th>Rate</th>
+						<th>Amount</th>
+					</tr>
+				</thead>
+				<tbody>
+					{{range $line := .Invoice.Recurring}}
+					<tr>
+						<td>{{$line.Description}}</td>
+						<td><var>{{$line.Quantity}}</var></td>
+						<td><var>{{$line.Rate}}</var></td>
+						<td><var>{{$line.Amount}}</var></td>
+					</tr>
+					{{end}}
+				</tbody>
+			</table>
+			{{end}}
+			{{if or .Invoice.Charges.GST .Invoice.Recurring}}
+			<p>
+				Rent <var>{{.Invoice.Charges.Rent}}</var>
+				</br>
+				{{if .Invoice.Recurring}}
+				Recurring Charges <var>{{.Invoice.Charges.Recurring}}</var>
+				</br>
+				GST <var>{{.Invoice.Charges.GST}}</var>
+				{{else}}
+				GST ({{.Invoice.GST}}%) <var>{{.Invoice.Charges.GST}}</var>
+				{{end}}
+			</p>
+			{{end}}
+			<blockquote>
+				<p>
+					Total Amount Due by <time>{{date .Invoice.Due}}</time> <var>{{.Invoice.Bill}}</var>
+					{{if .Position}}
+					</br>
+					<small>{{.Position}}</small>
+					{{end}}
+				</p>
+			</blockquote>
+			<p>
+				<b>Bank Acc:</b> {{.Settings.Bank.Name}} <var>{{.Settings.Bank.Account}}</var>
+				</br>
+				<b>Reference:</b> {{generateReference}}
+				</br>
+				<b>Email:</b> {{.Settings.Landlord.Email}}
+				</br>
+				<b>Phone:</b> {{.Settings.Landlord.Phone}}
+			</p>
+		</article>
+	</body>
+</html>
+`
+
+// RentIncreaseNoticeTemplateLiteral contains the literal html used to generate
+// notice of a rent increase.
+var RentIncreaseNoticeTemplateLiteral = `
+<!doctype html>
+<html lang="en">
+	<head>
+		<meta charset="utf-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1.0">
+		<link rel="stylesheet" href="https://vanillacss.com/vanilla.css" media="all">
+		<title>Notice of Rent Increase</title>
+		<style>
+			body{
+				margin: 0 auto;
+				max-width: 50rem;
+			}
+		</style>
+	</head>
+	<body id="top" role="document">
+		<article>
+			<header><h1>Notice of Rent Increase</h1></header>
+			<p>
+				{{date .Change.Notified}}
+			</p>
+			<p>
+				{{.Tenant.Name}}
+				</br>
+				{{.Tenant.Address}}
+			</p>
+			<p>
+				Dear {{.Tenant.Name}},
+			</p>
+			<p>
+				This letter is notice that the rent for Site {{.Site.Number}}
+				({{.Site.Dwelling}}) will increase from <var>{{.Current.Amount}}</var>
+				to <var>{{.Change.Amount}}</var> per week, an increase of
+				<var>{{increase}}</var> per week.
+			</p>
+			<p>
+				The new rent takes effect from <b>{{date .Change.Effective}}</b>.
+			</p>
+			<p>
+				If you have any questions please contact us.
+				</br>
+				<b>Email:</b> {{.Settings.Landlord.Email}}
+				</br>
+				<b>Phone:</b> {{.Settings.Landlord.Phone}}
+			</p>
+			<p>
+				{{.Settings.Landlord.Name}}
+				</br>
+				{{.Settings.Landlord.Address}}
+			</p>
+		</article>
+	</body>
+</html>
+`
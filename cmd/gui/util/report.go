@@ -0,0 +1,98 @@
+package util
+
+import (
+	"bytes"
+	"html/template"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/report"
+)
+
+// ReportDocument renders report tables to a printable html document.
+type ReportDocument struct {
+	Title  string
+	Tables []report.Table
+
+	Settings avisha.Settings
+}
+
+// Render the document into a buffer.
+func (doc ReportDocument) Render() (*bytes.Buffer, error) {
+	return render("report-document", ReportTemplateLiteral, template.FuncMap{
+		"date": formatLongDate,
+		"now":  time.Now,
+	}, doc)
+}
+
+// ReportTemplateLiteral contains the literal html used to generate a report.
+var ReportTemplateLiteral = `
+<!doctype html>
+<html lang="en">
+	<head>
+		<meta charset="utf-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1.0">
+		<link rel="stylesheet" href="https://vanillacss.com/vanilla.css" media="all">
+		<title>{{.Title}}</title>
+		<style>
+			body{
+				margin: 0 auto;
+				max-width: 70rem;
+			}
+			table,tbody {
+				text-align: center;
+			}
+			table caption {
+				margin: 0;
+				padding: 0.25rem;
+				text-align: left;
+				font-weight: bold;
+			}
+			tfoot {
+				font-weight: bold;
+			}
+		</style>
+	</head>
+	<body id="top" role="document">
+		<article id="preamble">
+			<header><h1>{{.Title}}</h1></header>
+			<p>
+				<b>AVISHA GROUP LTD</b>
+				</br>
+				Property Management Services
+				</br>
+				{{.Settings.Landlord.Address}}
+			</p>
+			<p>
+				Generated {{date now}}
+			</p>
+		</article>
+		{{range $table := .Tables}}
+		<article>
+			<table>
+				{{if $table.Title}}<caption>{{$table.Title}}</caption>{{end}}
+				<thead>
+					<tr>
+						{{range $cell := $table.Header}}<th>{{$cell}}</th>{{end}}
+					</tr>
+				</thead>
+				<tbody>
+					{{range $row := $table.Rows}}
+					<tr>
+						{{range $cell := $row}}<td>{{$cell}}</td>{{end}}
+					</tr>
+					{{end}}
+				</tbody>
+				{{if $table.Footer}}
+				<tfoot>
+					<tr>
+						{{range $cell := $table.Footer}}<td>{{$cell}}</td>{{end}}
+					</tr>
+				</tfoot>
+				{{end}}
+			</table>
+		</article>
+		{{end}}
+	</body>
+</html>
+`
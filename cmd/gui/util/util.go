@@ -174,11 +174,15 @@ func FlexStrategy(weight float32, flex, actual layout.Axis, w layout.Widget) lay
 	return layout.Rigid(w)
 }
 
+// Reference generates a payment reference for a tenant at a site.
+func Reference(tenant avisha.Tenant, site avisha.Site, suffix string) string {
+	return avisha.PaymentReference(tenant, site, suffix)
+}
+
 // UtilityInvoiceDocument renders utility invoices to an html document.
 type UtilityInvoiceDocument struct {
-	History  []*avisha.UtilityInvoice
-	Previous avisha.UtilityInvoice
-	Invoice  avisha.UtilityInvoice
+	History []*avisha.UtilityInvoice
+	Invoice avisha.UtilityInvoice
 
 	Lease    avisha.Lease
 	Tenant   avisha.Tenant
@@ -186,6 +190,18 @@ type UtilityInvoiceDocument struct {
 	Settings avisha.Settings
 }
 
+// Service returns the catalogue definition of the invoiced service.
+func (doc UtilityInvoiceDocument) Service() avisha.ServiceDefinition {
+	def, _ := doc.Settings.Service(doc.Invoice.Service)
+	return def
+}
+
+// OffPeak reports whether the invoice was read off a time-of-use meter.
+func (doc UtilityInvoiceDocument) OffPeak() bool {
+	off := doc.Invoice.OffPeak
+	return off.PreviousReading != 0 || off.Reading != 0 || off.UnitsConsumed != 0
+}
+
 // Render the document into a buffer.
 func (doc UtilityInvoiceDocument) Render() (*bytes.Buffer, error) {
 	tmpl, err := template.
@@ -195,11 +211,7 @@ func (doc UtilityInvoiceDocument) Render() (*bytes.Buffer, error) {
 				return t.Format("Monday, 2 January 2006")
 			},
 			"generateReference": func() string {
-				// Get the first three letters of the last name.
-				fields := strings.Fields(doc.Tenant.Name)
-				name := strings.ToUpper(fields[len(fields)-1][0:3])
-				site := strings.ToUpper(doc.Site.Number)
-				return fmt.Sprintf("%s-S.%s POWR", name, site)
+				return Reference(doc.Tenant, doc.Site, doc.Service().Reference)
 			},
 			"abs": func(c currency.Currency) string {
 				if c < 0 {
@@ -347,10 +359,9 @@ var UtilityInvoiceTemplateLiteral = `
 						</br>
 						Period: <var>{{.Invoice.Period}}</var>
 						</br>
-						Service: <b>Electricity</b>
+						Service: <b>{{.Service.Name}}</b>
 					</p>
 				</card>
-				<!-- @Todo polymorph the service? -->
 				<card>
 					<header>Bill To</header>
 					<p>
@@ -404,28 +415,91 @@ var UtilityInvoiceTemplateLiteral = `
 				<caption>Current Activity</caption>
 				<thead>
 					<tr>
-						<th>Unit Cost</th>
+						{{if .OffPeak}}<th>Register</th>{{end}}
+						{{if not .Invoice.Lines}}<th>Cost per {{.Service.Units}}</th>{{end}}
 						<th>Previous Reading</th>
 						<th>Current Reading</th>
-						<th>Units Used</th>
-						<th>Activity Charge</th>
+						<th>{{.Service.Units}} Used</th>
+						{{if not .Invoice.Lines}}<th>Activity Charge</th>{{end}}
 					</tr>
 				</thead>
 				<tbody>
 					<tr>
-						<td><var>{{.Invoice.UnitCost}}</var></td>
-						<td><var>{{.Previous.Reading}}</var></td>
+						{{if .OffPeak}}<td>Peak</td>{{end}}
+						{{if not .Invoice.Lines}}<td><var>{{.Invoice.UnitCost}}</var></td>{{end}}
+						<td><var>{{.Invoice.PreviousReading}}</var></td>
 						<td><var>{{.Invoice.Reading}}</var></td>
 						<td><var>{{.Invoice.UnitsConsumed}}</var></td>
 						<!-- @Todo utilities cost, not total bill -->
-						<td><var>{{.Invoice.Charges.Activity}}</var></td>
+						{{if not .Invoice.Lines}}<td><var>{{.Invoice.Charges.Activity}}</var></td>{{end}}
+					</tr>
+					{{if .OffPeak}}
+					<tr>
+						<td>Off-peak</td>
+						{{if not .Invoice.Lines}}<td></td>{{end}}
+						<td><var>{{.Invoice.OffPeak.PreviousReading}}</var></td>
+						<td><var>{{.Invoice.OffPeak.Reading}}</var></td>
+						<td><var>{{.Invoice.OffPeak.UnitsConsumed}}</var></td>
+						{{if not .Invoice.Lines}}<td></td>{{end}}
 					</tr>
+					{{end}}
 				</tbody>
 			</table>
+			{{if .Invoice.Lines}}
+				<table>
+					<caption>Tariff Charges ({{.Service.Units}})</caption>
+					<thead>
+						<tr>
+							<th>Charge</th>
+							<th>Quantity</th>
+							<th>Rate</th>
+							<th>Amount</th>
+						</tr>
+					</thead>
+					<tbody>
+						{{range $line := .Invoice.Lines}}
+						<tr>
+							<td>{{$line.Description}}</td>
+							<td><var>{{$line.Quantity}}</var></td>
+							<td><var>{{$line.Rate}}</var></td>
+							<td><var>{{$line.Amount}}</var></td>
+						</tr>
+						{{end}}
+						<tr>
+							<td colspan="3"><b>Activity Charge</b></td>
+							<td><var>{{.Invoice.Charges.Activity}}</var></td>
+						</tr>
+					</tbody>
+				</table>
+			{{end}}
+			{{if .Invoice.Recurring}}
+				<table>
+					<caption>Recurring Charges</caption>
+					<thead>
+						<tr>
+							<th>Charge</th>
+							<th>Occurrences</th>
+							<th>Rate</th>
+							<th>Amount</th>
+						</tr>
+					</thead>
+					<tbody>
+						{{range $line := .Invoice.Recurring}}
+						<tr>
+							<td>{{$line.Description}}</td>
+							<td><var>{{$line.Quantity}}</var></td>
+							<td><var>{{$line.Rate}}</var></td>
+							<td><var>{{$line.Amount}}</var></td>
+						</tr>
+						{{end}}
+					</tbody>
+				</table>
+			{{end}}
 			<table>
 				<caption>Charges</caption>
 				<thead>
 					<tr>
+						{{if .Invoice.Recurring}}<th>Recurring</th>{{end}}
 						<th>Line Charge</th>
 						<th>Late Fee</th>
 						<!-- @Todo pull gst from settings -->
@@ -435,6 +509,7 @@ var UtilityInvoiceTemplateLiteral = `
 				</thead>
 				<tbody>
 					<tr>
+						{{if .Invoice.Recurring}}<td><var>{{.Invoice.Charges.Recurring}}</var></td>{{end}}
 						<td><var>{{.Invoice.Charges.LineCharge}}</var></td>
 						<td><var>{{.Invoice.Charges.LateFee}}</var></td>
 						<td><var>{{.Invoice.Charges.GST}}</var></td>
@@ -0,0 +1,343 @@
+package views
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"sync"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+)
+
+// Severity ranks an alert.
+type Severity int
+
+const (
+	Info Severity = iota
+	Success
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "Info"
+	case Success:
+		return "Success"
+	case Warning:
+		return "Warning"
+	case Error:
+		return "Error"
+	default:
+		return "Unknown"
+	}
+}
+
+// duration is how long a toast of the severity is shown for.
+func (s Severity) duration() time.Duration {
+	switch s {
+	case Warning:
+		return 6 * time.Second
+	case Error:
+		return 8 * time.Second
+	default:
+		return 4 * time.Second
+	}
+}
+
+// Alert is a message for the user.
+type Alert struct {
+	Severity Severity
+	Message  string
+	Time     time.Time
+}
+
+// toast shows an alert along the bottom of the window until it expires.
+type toast struct {
+	Alert
+	// label and do offer an action on the alert, such as to undo it.
+	label string
+	do    func()
+	until time.Time
+	click widget.Clickable
+}
+
+// Alerts tells the user what happened, so that nothing fails silently.
+//
+// Every alert is shown as a toast that dismisses itself. Errors are also kept
+// in the error panel until cleared, for the detail of what went wrong.
+// Alerts can be posted from any goroutine; a nil Alerts logs them instead.
+type Alerts struct {
+	Th *style.Theme
+	// Invalidate requests a frame when an alert is posted.
+	Invalidate func()
+
+	// Panel opens the error panel, Clear empties it and Close closes it.
+	Panel widget.Clickable
+	Clear widget.Clickable
+	Close widget.Clickable
+
+	mu     sync.Mutex
+	toasts []*toast
+	errors []Alert
+	open   bool
+	list   layout.List
+}
+
+// maxToasts is the most toasts shown at once, the oldest giving way.
+const maxToasts = 3
+
+// Post an alert of the severity.
+func (a *Alerts) Post(s Severity, message string) {
+	a.post(Alert{Severity: s, Message: message}, "", nil)
+}
+
+// Info posts an informational alert.
+func (a *Alerts) Info(format string, v ...interface{}) {
+	a.Post(Info, fmt.Sprintf(format, v...))
+}
+
+// Success posts an alert that something was done.
+func (a *Alerts) Success(format string, v ...interface{}) {
+	a.Post(Success, fmt.Sprintf(format, v...))
+}
+
+// Warning posts an alert that something needs attention.
+func (a *Alerts) Warning(format string, v ...interface{}) {
+	a.Post(Warning, fmt.Sprintf(format, v...))
+}
+
+// Error posts the error, keeping it in the error panel.
+// A nil error posts nothing.
+func (a *Alerts) Error(err error) {
+	if err == nil {
+		return
+	}
+	a.Post(Error, err.Error())
+}
+
+// Act posts an alert that offers an action labelled by label, such as "Undo".
+func (a *Alerts) Act(s Severity, message, label string, do func()) {
+	a.post(Alert{Severity: s, Message: message}, label, do)
+}
+
+func (a *Alerts) post(alert Alert, label string, do func()) {
+	alert.Time = time.Now()
+	if a == nil {
+		log.Printf("%s: %s", alert.Severity, alert.Message)
+		return
+	}
+	t := &toast{
+		Alert: alert,
+		label: label,
+		do:    do,
+		until: alert.Time.Add(alert.Severity.duration()),
+	}
+	if alert.Severity == Error && do == nil {
+		t.label, t.do = "Details", a.openPanel
+	}
+	a.mu.Lock()
+	// An alert repeating the latest, such as a failure met every frame, keeps
+	// the latest showing rather than stacking up.
+	if n := len(a.toasts); n > 0 && do == nil && a.toasts[n-1].Severity == alert.Severity && a.toasts[n-1].Message == alert.Message {
+		a.toasts[n-1].until = t.until
+		a.mu.Unlock()
+		return
+	}
+	if alert.Severity == Error {
+		log.Printf("%s: %s", alert.Severity, alert.Message)
+	}
+	a.toasts = append(a.toasts, t)
+	if len(a.toasts) > maxToasts {
+		a.toasts = a.toasts[len(a.toasts)-maxToasts:]
+	}
+	if alert.Severity == Error {
+		a.errors = append(a.errors, alert)
+	}
+	a.mu.Unlock()
+	if a.Invalidate != nil {
+		a.Invalidate()
+	}
+}
+
+// Errors returns the errors kept in the panel, oldest first.
+func (a *Alerts) Errors() []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Alert(nil), a.errors...)
+}
+
+func (a *Alerts) openPanel() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.open = true
+}
+
+// Update handles the actions of the toasts and the panel.
+func (a *Alerts) Update(gtx C) {
+	if a.Panel.Clicked() {
+		a.openPanel()
+	}
+	a.mu.Lock()
+	if a.Close.Clicked() {
+		a.open = false
+	}
+	if a.Clear.Clicked() {
+		a.errors, a.open = nil, false
+	}
+	var (
+		actions []func()
+		live    = a.toasts[:0]
+	)
+	for _, t := range a.toasts {
+		if t.click.Clicked() && t.do != nil {
+			actions = append(actions, t.do)
+			// Acting on a toast dismisses it.
+			continue
+		}
+		if gtx.Now.Before(t.until) {
+			live = append(live, t)
+		}
+	}
+	a.toasts = live
+	a.mu.Unlock()
+	for _, do := range actions {
+		do()
+	}
+}
+
+// Layout the toasts along the bottom of the window, newest last.
+func (a *Alerts) Layout(gtx C) D {
+	a.Update(gtx)
+	a.mu.Lock()
+	toasts := append([]*toast(nil), a.toasts...)
+	a.mu.Unlock()
+	if len(toasts) == 0 {
+		return D{}
+	}
+	next := toasts[0].until
+	children := make([]layout.FlexChild, len(toasts))
+	for ii, t := range toasts {
+		t := t
+		if t.until.Before(next) {
+			next = t.until
+		}
+		children[ii] = layout.Rigid(func(gtx C) D {
+			var action *widget.Clickable
+			if t.label != "" {
+				action = &t.click
+			}
+			snackbar := style.Snackbar(a.theme(t.Severity), t.Message, action, t.label)
+			snackbar.ActionColor = a.Th.Light().Fg
+			snackbar.Inset = layout.Inset{
+				Top:   unit.Dp(5),
+				Left:  unit.Dp(20),
+				Right: unit.Dp(20),
+			}
+			return snackbar.Layout(gtx)
+		})
+	}
+	op.InvalidateOp{At: next}.Add(gtx.Ops)
+	return layout.S.Layout(gtx, func(gtx C) D {
+		return layout.Inset{Bottom: unit.Dp(20)}.Layout(gtx, func(gtx C) D {
+			return layout.Flex{
+				Axis:      layout.Vertical,
+				Alignment: layout.Middle,
+			}.Layout(gtx, children...)
+		})
+	})
+}
+
+// theme colours a toast by its severity.
+func (a *Alerts) theme(s Severity) *material.Theme {
+	switch s {
+	case Success:
+		return a.Th.Success()
+	case Warning:
+		return a.Th.Warning()
+	case Error:
+		return a.Th.Danger()
+	default:
+		return a.Th.Dark()
+	}
+}
+
+// LayoutPanel renders the error panel over the window, while open.
+func (a *Alerts) LayoutPanel(gtx C) D {
+	a.mu.Lock()
+	var (
+		open   = a.open
+		errors = append([]Alert(nil), a.errors...)
+	)
+	a.mu.Unlock()
+	if !open {
+		return D{}
+	}
+	a.list.Axis = layout.Vertical
+	return style.ModalDialog(gtx, a.Th, unit.Dp(700), "Errors", func(gtx C) D {
+		return layout.Flex{
+			Axis: layout.Vertical,
+		}.Layout(
+			gtx,
+			layout.Rigid(func(gtx C) D {
+				if len(errors) == 0 {
+					return material.Body1(a.Th.Muted(), "No errors.").Layout(gtx)
+				}
+				gtx.Constraints.Max.Y = gtx.Px(unit.Dp(400))
+				return a.list.Layout(gtx, len(errors), func(gtx C, ii int) D {
+					// Newest first.
+					e := errors[len(errors)-1-ii]
+					return layout.Inset{Bottom: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+						return layout.Flex{
+							Axis: layout.Vertical,
+						}.Layout(
+							gtx,
+							layout.Rigid(func(gtx C) D {
+								return material.Caption(
+									a.Th.Muted(),
+									fmt.Sprintf("%s %s", util.FormatTime(e.Time), e.Time.Format("15:04:05")),
+								).Layout(gtx)
+							}),
+							layout.Rigid(func(gtx C) D {
+								return material.Body1(a.Th.Danger(), e.Message).Layout(gtx)
+							}),
+						)
+					})
+				})
+			}),
+			layout.Rigid(func(gtx C) D {
+				return D{Size: image.Point{Y: gtx.Px(unit.Dp(10))}}
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{
+					Axis: layout.Horizontal,
+				}.Layout(
+					gtx,
+					layout.Flexed(1, func(gtx C) D {
+						return D{Size: gtx.Constraints.Min}
+					}),
+					layout.Rigid(func(gtx C) D {
+						btn := material.Button(a.Th.Secondary(), &a.Clear, "Clear")
+						btn.Color = btn.Background
+						btn.Background = style.WithAlpha(btn.Background, 0)
+						return btn.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+					}),
+					layout.Rigid(func(gtx C) D {
+						return material.Button(a.Th.Primary(), &a.Close, "Close").Layout(gtx)
+					}),
+				)
+			}),
+		)
+	})
+}
@@ -0,0 +1,22 @@
+package views
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAlertsCollapseRepeats(t *testing.T) {
+	var a Alerts
+	for ii := 0; ii < 10; ii++ {
+		a.Error(fmt.Errorf("loading settings: failed"))
+	}
+	a.Error(fmt.Errorf("loading leases: failed"))
+	a.Success("Saved settings")
+	a.Success("Saved settings")
+	if got := len(a.toasts); got != 3 {
+		t.Errorf("got %d toasts, want 3", got)
+	}
+	if got := len(a.Errors()); got != 2 {
+		t.Errorf("got %d errors kept, want 2", got)
+	}
+}
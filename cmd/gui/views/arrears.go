@@ -0,0 +1,273 @@
+package views
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+	"unsafe"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+	"github.com/jackmordaunt/avisha.go/report"
+)
+
+// ArrearsPage reports who owes what across the leases, aged by how long it
+// has been owed, with each line expanding to the invoices owed on.
+type ArrearsPage struct {
+	nav.Route
+	App *avisha.App
+	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+	// Invalidate requests a frame once the report has been built.
+	Invalidate func()
+
+	// Filter selects the invoices owed on.
+	// Defaults to showing all invoices.
+	Filter widget.Enum
+
+	ExportCSV widget.Clickable
+	ExportPDF widget.Clickable
+
+	report report.ArrearsReport
+	// expanded lines, keyed by lease and service.
+	expanded map[string]bool
+	lines    States
+	invoices States
+	err      string
+	scroll   layout.List
+	loader   Loader
+}
+
+// Arrears filters, which can be passed to the page when routing to it.
+const (
+	ArrearsAll     = "all"
+	ArrearsOverdue = "overdue"
+	ArrearsDueSoon = "due-soon"
+)
+
+// filter reports whether the invoice should be listed under the active filter.
+func (p *ArrearsPage) filter(inv report.Receivable) bool {
+	switch p.Filter.Value {
+	case ArrearsOverdue:
+		return inv.IsOverdue()
+	case ArrearsDueSoon:
+		return inv.DueWithin(p.report.At, 7)
+	}
+	return true
+}
+
+func (p *ArrearsPage) Title() string {
+	return "Arrears"
+}
+
+func (p *ArrearsPage) Receive(data interface{}) {
+	p.err = ""
+	p.expanded = make(map[string]bool)
+	if filter, ok := data.(string); ok {
+		p.Filter.Value = filter
+	}
+	if p.Filter.Value == "" {
+		p.Filter.Value = ArrearsAll
+	}
+}
+
+func (p *ArrearsPage) Update(gtx C) {
+	data, err := p.loader.Load(p.App, p.Invalidate, "", func(app avisha.App) (interface{}, error) {
+		return report.Arrears(app, time.Now())
+	})
+	if err != nil {
+		p.err = fmt.Sprintf("building report: %v", err)
+	} else {
+		p.err = ""
+	}
+	r, _ := data.(report.ArrearsReport)
+	p.report = r
+	if p.Filter.Value != ArrearsAll {
+		p.report = r.Filter(p.filter)
+	}
+	for _, state := range p.lines.List() {
+		if state.Item.Clicked() {
+			key := arrearsKey(*(*report.ArrearsLine)(state.Data))
+			p.expanded[key] = !p.expanded[key]
+		}
+	}
+	for _, state := range p.invoices.List() {
+		if state.Item.Clicked() {
+			lease := (*report.ArrearsLine)(state.Data).Lease
+			p.Route.To(RouteLeasePage, &lease)
+		}
+	}
+	if p.ExportCSV.Clicked() {
+		if err := p.export("csv"); err != nil {
+			p.Alerts.Error(err)
+		}
+	}
+	if p.ExportPDF.Clicked() {
+		if err := p.export("pdf"); err != nil {
+			p.Alerts.Error(err)
+		}
+	}
+}
+
+// export writes the report in the given format and opens it.
+func (p *ArrearsPage) export(format string) error {
+	var (
+		buffer bytes.Buffer
+		err    error
+	)
+	switch format {
+	case "csv":
+		err = p.report.Table().WriteCSV(&buffer)
+	case "pdf":
+		err = report.WritePDF(&buffer, "Arrears", p.report.Table(), p.report.InvoiceTable())
+	}
+	if err != nil {
+		return fmt.Errorf("exporting report: %w", err)
+	}
+	return openDocument(
+		"reports",
+		fmt.Sprintf("arrears-%s.%s", p.report.At.Format("20060102"), format),
+		&buffer,
+	)
+}
+
+func (p *ArrearsPage) Layout(gtx C) D {
+	if p.expanded == nil {
+		p.expanded = make(map[string]bool)
+	}
+	p.Update(gtx)
+	p.lines.Begin()
+	p.invoices.Begin()
+	p.scroll.Axis = layout.Vertical
+	rows := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				layout.Flexed(1, func(gtx C) D {
+					return material.Label(p.Th.Dark(), unit.Dp(20), fmt.Sprintf(
+						"Arrears as of %s",
+						util.FormatTime(p.report.At),
+					)).Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return material.Button(p.Th.Secondary(), &p.ExportCSV, "Export CSV").Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Inset{Left: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+						return material.Button(p.Th.Secondary(), &p.ExportPDF, "Export PDF").Layout(gtx)
+					})
+				}),
+			)
+		}),
+		layout.Rigid(p.LayoutFilters),
+		layout.Rigid(func(gtx C) D {
+			if p.err == "" {
+				return D{}
+			}
+			return material.Body1(p.Th.Danger(), p.err).Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return tableRow(gtx, p.Th, true, p.report.Table().Header...)
+		}),
+	}
+	if len(p.report.Lines) == 0 {
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return material.Body1(p.Th.Muted(), "Nothing is owed").Layout(gtx)
+		}))
+	}
+	for ii := range p.report.Lines {
+		var (
+			line  = &p.report.Lines[ii]
+			state = p.lines.Next(unsafe.Pointer(line))
+		)
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return style.ListItem(
+				gtx,
+				p.Th.Dark(),
+				&state.Item,
+				&state.Hover,
+				p.expanded[arrearsKey(*line)],
+				func(gtx C) D {
+					return tableRow(gtx, p.Th, false, append(
+						[]string{line.Site.Number, line.Tenant.Name, line.Service.Name},
+						line.Aged.Strings()...,
+					)...)
+				},
+			)
+		}))
+		if p.expanded[arrearsKey(*line)] {
+			for jj := range line.Invoices {
+				var (
+					inv   = line.Invoices[jj]
+					state = p.invoices.Next(unsafe.Pointer(line))
+				)
+				rows = append(rows, layout.Rigid(func(gtx C) D {
+					return layout.Inset{Left: unit.Dp(20)}.Layout(gtx, func(gtx C) D {
+						return style.ListItem(
+							gtx,
+							p.Th.Dark(),
+							&state.Item,
+							&state.Hover,
+							false,
+							func(gtx C) D {
+								return layout.UniformInset(unit.Dp(5)).Layout(gtx, func(gtx C) D {
+									return material.Body2(p.Th.Dark(), fmt.Sprintf(
+										"#%d issued %s, due %s (%d days overdue): %s of %s outstanding",
+										inv.Invoice.ID,
+										util.FormatTime(inv.Invoice.Issued),
+										util.FormatTime(inv.Invoice.Due),
+										inv.Overdue,
+										inv.Outstanding,
+										inv.Invoice.Bill,
+									)).Layout(gtx)
+								})
+							},
+						)
+					})
+				}))
+			}
+		}
+	}
+	rows = append(rows, layout.Rigid(func(gtx C) D {
+		return tableRow(gtx, p.Th, true, append([]string{"Total", "", ""}, p.report.Totals.Strings()...)...)
+	}))
+	return p.scroll.Layout(gtx, 1, func(gtx C, _ int) D {
+		return layout.UniformInset(unit.Dp(20)).Layout(gtx, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+		})
+	})
+}
+
+// LayoutFilters renders the invoice filter options.
+func (p *ArrearsPage) LayoutFilters(gtx C) D {
+	option := func(key, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return material.RadioButton(p.Th.Dark(), &p.Filter, key, label).Layout(gtx)
+		})
+	}
+	return layout.Flex{
+		Axis:      layout.Horizontal,
+		Alignment: layout.Middle,
+	}.Layout(
+		gtx,
+		option(ArrearsAll, "All"),
+		option(ArrearsOverdue, "Overdue"),
+		option(ArrearsDueSoon, "Due This Week"),
+	)
+}
+
+// arrearsKey identifies the line by its lease and service.
+func arrearsKey(line report.ArrearsLine) string {
+	return strconv.Itoa(line.Lease.ID) + "/" + string(line.Service.Key)
+}
@@ -0,0 +1,191 @@
+package views
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+	"github.com/jackmordaunt/avisha.go/report"
+)
+
+// AuditSubject is the record an AuditPage shows the history of.
+type AuditSubject struct {
+	// Entity is the kind of record, such as "Lease".
+	Entity string
+	ID     int
+	// Title describes the record to the user.
+	Title string
+}
+
+// AuditPage shows every change made to a record, who made it and when, with
+// the value of each field before and after.
+type AuditPage struct {
+	nav.Route
+	App *avisha.App
+	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+	// Invalidate requests a frame once the history has loaded.
+	Invalidate func()
+
+	ExportCSV widget.Clickable
+	ExportPDF widget.Clickable
+
+	subject AuditSubject
+	report  report.AuditReport
+	err     string
+	loader  Loader
+	scroll  layout.List
+}
+
+func (p *AuditPage) Title() string {
+	return "History"
+}
+
+// Receive the subject to show the history of, keeping the current subject
+// when returning to the page.
+func (p *AuditPage) Receive(data interface{}) {
+	if subject, ok := data.(*AuditSubject); ok && subject != nil {
+		p.subject = *subject
+		p.report = report.AuditReport{Subject: subject.Title}
+		p.err = ""
+	}
+}
+
+func (p *AuditPage) Update(gtx C) {
+	subject := p.subject
+	data, err := p.loader.Load(p.App, p.Invalidate, subject.Entity+strconv.Itoa(subject.ID), func(app avisha.App) (interface{}, error) {
+		r, err := report.Audit(app, subject.Title, subject.Entity, subject.ID)
+		if err != nil {
+			p.Alerts.Error(fmt.Errorf("loading history: %w", err))
+		}
+		return r, err
+	})
+	if r, ok := data.(report.AuditReport); ok && r.Subject == subject.Title {
+		p.report = r
+	}
+	if err != nil {
+		p.err = err.Error()
+	}
+	if p.ExportCSV.Clicked() {
+		if err := p.export("csv"); err != nil {
+			p.Alerts.Error(err)
+		}
+	}
+	if p.ExportPDF.Clicked() {
+		if err := p.export("pdf"); err != nil {
+			p.Alerts.Error(err)
+		}
+	}
+}
+
+// export writes the history in the given format and opens it.
+func (p *AuditPage) export(format string) error {
+	var (
+		buffer bytes.Buffer
+		err    error
+	)
+	switch format {
+	case "csv":
+		err = p.report.Table().WriteCSV(&buffer)
+	case "pdf":
+		err = report.WritePDF(&buffer, fmt.Sprintf("History of %s", p.subject.Title), p.report.Table())
+	}
+	if err != nil {
+		return fmt.Errorf("exporting history: %w", err)
+	}
+	return openDocument(
+		"reports",
+		fmt.Sprintf("history-%s-%d-%s.%s", p.subject.Entity, p.subject.ID, today().Format("20060102"), format),
+		&buffer,
+	)
+}
+
+func (p *AuditPage) Layout(gtx C) D {
+	p.Update(gtx)
+	p.scroll.Axis = layout.Vertical
+	rows := []layout.Widget{
+		func(gtx C) D {
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				layout.Flexed(1, func(gtx C) D {
+					return material.Label(p.Th.Dark(), unit.Dp(20), fmt.Sprintf("History of %s", p.subject.Title)).Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return material.Button(p.Th.Secondary(), &p.ExportCSV, "Export CSV").Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Inset{Left: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+						return material.Button(p.Th.Secondary(), &p.ExportPDF, "Export PDF").Layout(gtx)
+					})
+				}),
+			)
+		},
+		func(gtx C) D {
+			if p.err == "" {
+				return D{}
+			}
+			return material.Body1(p.Th.Danger(), p.err).Layout(gtx)
+		},
+	}
+	if len(p.report.Entries) == 0 && !p.loader.Loading() {
+		rows = append(rows, func(gtx C) D {
+			return material.Body1(p.Th.Muted(), "No changes have been recorded").Layout(gtx)
+		})
+	}
+	// Most recent changes first.
+	for ii := len(p.report.Entries) - 1; ii >= 0; ii-- {
+		entry := p.report.Entries[ii]
+		rows = append(rows, func(gtx C) D {
+			return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+				return style.Card{
+					Content: []layout.Widget{
+						func(gtx C) D {
+							return material.Body1(p.Th.Dark(), fmt.Sprintf(
+								"%s %s",
+								entry.Operation,
+								entry.Subject(),
+							)).Layout(gtx)
+						},
+						func(gtx C) D {
+							return material.Body2(p.Th.Muted(), fmt.Sprintf(
+								"%s at %s by %s",
+								util.FormatTime(entry.Time),
+								entry.Time.Format("15:04:05"),
+								entry.Actor,
+							)).Layout(gtx)
+						},
+						func(gtx C) D {
+							children := []layout.FlexChild{
+								layout.Rigid(func(gtx C) D {
+									return tableRow(gtx, p.Th, true, "Field", "Before", "After")
+								}),
+							}
+							for _, c := range entry.Changes {
+								c := c
+								children = append(children, layout.Rigid(func(gtx C) D {
+									return tableRow(gtx, p.Th, false, c.Field, c.Before, c.After)
+								}))
+							}
+							return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+						},
+					},
+				}.Layout(gtx, p.Th.Dark())
+			})
+		})
+	}
+	return p.scroll.Layout(gtx, len(rows), func(gtx C, index int) D {
+		return layout.Inset{Left: unit.Dp(15), Right: unit.Dp(15)}.Layout(gtx, rows[index])
+	})
+}
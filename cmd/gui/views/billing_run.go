@@ -0,0 +1,590 @@
+package views
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"git.sr.ht/~whereswaldon/materials"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/icons"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+)
+
+// BillingRunPage bills a metered service for every current lease at once.
+// Readings are entered for each site in a grid, previewed as invoices, and
+// then committed together.
+type BillingRunPage struct {
+	nav.Route
+	App *avisha.App
+	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+	// Invalidate requests a frame once the previous runs have loaded.
+	Invalidate func()
+
+	// Service selects the metered service to bill.
+	Service  widget.Enum
+	Date     materials.TextField
+	UnitCost materials.TextField
+
+	Start   widget.Clickable
+	Preview widget.Clickable
+	Edit    widget.Clickable
+	Commit  widget.Clickable
+	Discard widget.Clickable
+	// Suppliers navigates to the supplier bills recovered by billing runs.
+	Suppliers widget.Clickable
+	// Income navigates to the income and GST report.
+	Income widget.Clickable
+
+	// Form validates the run details and the reading of each line.
+	Form widget.Form
+
+	run      avisha.BillingRun
+	lines    []billingLine
+	settings avisha.Settings
+	// runs committed so far, most recent first.
+	runs   []*avisha.BillingRun
+	loader Loader
+	// previewing is set once the invoices of the run have been previewed.
+	previewing bool
+	err        string
+	reverse    map[int]*widget.Clickable
+	scroll     layout.List
+}
+
+// billingLine contains the inputs for a line of the run, along with the
+// records needed to display it.
+type billingLine struct {
+	Reading   materials.TextField
+	OffPeak   materials.TextField
+	Estimated widget.Bool
+
+	Site   avisha.Site
+	Tenant avisha.Tenant
+	Meter  avisha.Meter
+}
+
+func (p *BillingRunPage) Title() string {
+	return "Billing Run"
+}
+
+func (p *BillingRunPage) Context() []layout.Widget {
+	return []layout.Widget{
+		func(gtx C) D {
+			return material.IconButton(
+				p.Th.Primary(),
+				&p.Suppliers,
+				icons.AccountBalance,
+			).Layout(gtx)
+		},
+		func(gtx C) D {
+			return material.IconButton(
+				p.Th.Primary(),
+				&p.Income,
+				icons.Assessment,
+			).Layout(gtx)
+		},
+	}
+}
+
+func (p *BillingRunPage) Receive(data interface{}) {
+	p.reset()
+}
+
+// reset discards the run in progress.
+func (p *BillingRunPage) reset() {
+	p.run = avisha.BillingRun{}
+	p.lines = nil
+	p.previewing = false
+	p.err = ""
+	p.Form.Load([]widget.Field{
+		{
+			Value: widget.DateValuer{Value: &p.run.Date, Default: today()},
+			Input: &p.Date,
+		},
+	})
+}
+
+// load prepares a run for the selected service and binds an input to the
+// reading of each line.
+func (p *BillingRunPage) load() error {
+	run, err := p.App.NewBillingRun(avisha.ServiceKey(p.Service.Value), p.run.Date)
+	if err != nil {
+		return err
+	}
+	p.run = run
+	p.lines = make([]billingLine, len(run.Lines))
+	fields := []widget.Field{
+		{
+			Value: widget.DateValuer{Value: &p.run.Date},
+			Input: &p.Date,
+		},
+		{
+			Value: widget.CurrencyValuer{Value: &p.run.UnitCost},
+			Input: &p.UnitCost,
+		},
+	}
+	for ii := range p.run.Lines {
+		var (
+			line   = &p.run.Lines[ii]
+			inputs = &p.lines[ii]
+		)
+		if err := p.App.One("ID", line.Site, &inputs.Site); err != nil {
+			p.Alerts.Error(fmt.Errorf("loading site: %w", err))
+		}
+		var lease avisha.Lease
+		if err := p.App.One("ID", line.Lease, &lease); err != nil {
+			p.Alerts.Error(fmt.Errorf("loading lease: %w", err))
+		}
+		if err := p.App.One("ID", lease.Tenant, &inputs.Tenant); err != nil {
+			p.Alerts.Error(fmt.Errorf("loading tenant: %w", err))
+		}
+		if line.Skip {
+			continue
+		}
+		if err := p.App.One("ID", line.Meter, &inputs.Meter); err != nil {
+			p.Alerts.Error(fmt.Errorf("loading meter: %w", err))
+		}
+		fields = append(fields, widget.Field{
+			Value: ReadingValuer{
+				Value:    &line.Reading,
+				Meter:    inputs.Meter,
+				Previous: line.Baseline.Value,
+			},
+			Input: &inputs.Reading,
+		})
+		if inputs.Meter.TimeOfUse {
+			fields = append(fields, widget.Field{
+				Value: ReadingValuer{
+					Value:    &line.OffPeak,
+					Meter:    inputs.Meter,
+					Previous: line.Baseline.OffPeak,
+				},
+				Input: &inputs.OffPeak,
+			})
+		}
+	}
+	p.Form.Load(fields)
+	return nil
+}
+
+// billingData is the data shown alongside the run being entered.
+type billingData struct {
+	Settings avisha.Settings
+	Runs     []*avisha.BillingRun
+}
+
+// refresh takes up the settings and previous runs loaded in the background.
+func (p *BillingRunPage) refresh() {
+	v, _ := p.loader.Load(p.App, p.Invalidate, "", func(app avisha.App) (interface{}, error) {
+		var (
+			data billingData
+			err  error
+		)
+		if data.Settings, err = app.LoadSettings(); err != nil {
+			p.Alerts.Error(fmt.Errorf("loading settings: %w", err))
+			return data, err
+		}
+		if data.Runs, err = app.BillingRuns(); err != nil {
+			p.Alerts.Error(fmt.Errorf("loading billing runs: %w", err))
+		}
+		return data, err
+	})
+	if data, ok := v.(billingData); ok {
+		p.settings, p.runs = data.Settings, data.Runs
+	}
+}
+
+func (p *BillingRunPage) Update(gtx C) {
+	p.refresh()
+	if p.Service.Value == "" {
+		for _, def := range p.settings.Services {
+			if def.Kind == avisha.Metered {
+				p.Service.Value = string(def.Key)
+				break
+			}
+		}
+	}
+	if p.Suppliers.Clicked() {
+		p.Route.To(RouteSuppliers, nil)
+	}
+	if p.Income.Clicked() {
+		p.Route.To(RouteIncome, nil)
+	}
+	if p.Service.Changed() {
+		p.reset()
+	}
+	p.Form.Validate(gtx)
+	if p.Start.Clicked() {
+		if p.Form.Submit() {
+			if err := p.load(); err != nil {
+				p.err = err.Error()
+			} else {
+				p.err = ""
+			}
+		}
+	}
+	if p.Preview.Clicked() {
+		if p.Form.Submit() {
+			for ii := range p.lines {
+				p.run.Lines[ii].Estimated = p.lines[ii].Estimated.Value
+			}
+			if err := p.App.PreviewBillingRun(&p.run); err != nil {
+				p.err = err.Error()
+			} else {
+				p.err = ""
+				p.previewing = true
+			}
+		}
+	}
+	if p.Edit.Clicked() {
+		p.previewing = false
+	}
+	if p.Discard.Clicked() {
+		p.reset()
+	}
+	if p.Commit.Clicked() {
+		if err := p.App.CommitBillingRun(&p.run); err != nil {
+			p.err = err.Error()
+			p.previewing = false
+		} else {
+			p.reset()
+		}
+	}
+	for id, btn := range p.reverse {
+		if btn.Clicked() {
+			if err := p.App.ReverseBillingRun(id); err != nil {
+				p.err = fmt.Sprintf("reversing billing run %d: %v", id, err)
+			} else {
+				p.err = ""
+			}
+		}
+	}
+}
+
+func (p *BillingRunPage) Layout(gtx C) D {
+	p.Update(gtx)
+	p.scroll.Axis = layout.Vertical
+	return p.scroll.Layout(gtx, 1, func(gtx C, _ int) D {
+		return layout.UniformInset(unit.Dp(20)).Layout(gtx, func(gtx C) D {
+			return layout.Flex{
+				Axis: layout.Vertical,
+			}.Layout(
+				gtx,
+				layout.Rigid(p.LayoutDetails),
+				layout.Rigid(func(gtx C) D {
+					if p.err == "" {
+						return D{}
+					}
+					return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+						return material.Body1(p.Th.Danger(), p.err).Layout(gtx)
+					})
+				}),
+				layout.Rigid(func(gtx C) D {
+					if len(p.run.Lines) == 0 {
+						return D{}
+					}
+					return layout.Inset{Top: unit.Dp(20)}.Layout(gtx, func(gtx C) D {
+						if p.previewing {
+							return p.LayoutPreview(gtx)
+						}
+						return p.LayoutReadings(gtx)
+					})
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Inset{Top: unit.Dp(20)}.Layout(gtx, p.LayoutHistory)
+				}),
+			)
+		})
+	})
+}
+
+// LayoutDetails renders the service and date of the run.
+func (p *BillingRunPage) LayoutDetails(gtx C) D {
+	var services []layout.FlexChild
+	for _, def := range p.settings.Services {
+		if def.Kind != avisha.Metered {
+			continue
+		}
+		def := def
+		services = append(services, layout.Rigid(func(gtx C) D {
+			return material.RadioButton(p.Th.Dark(), &p.Service, string(def.Key), def.Name).Layout(gtx)
+		}))
+	}
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(gtx, services...)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				layout.Flexed(1, func(gtx C) D {
+					if len(p.run.Lines) > 0 {
+						gtx.Queue = nil
+					}
+					return p.Date.Layout(gtx, p.Th.Dark(), "Reading Date")
+				}),
+				layout.Rigid(func(gtx C) D {
+					return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+				}),
+				layout.Flexed(1, func(gtx C) D {
+					if len(p.run.Lines) == 0 {
+						return D{}
+					}
+					def, _ := p.settings.Service(p.run.Service)
+					if tariff, ok := def.TariffAt(p.run.Date); ok {
+						return material.Body1(p.Th.Muted(), fmt.Sprintf(
+							"Priced by the tariff effective %s",
+							util.FormatTime(tariff.Effective),
+						)).Layout(gtx)
+					}
+					p.UnitCost.Prefix = func(gtx C) D {
+						return material.Body1(p.Th.Dark(), "$").Layout(gtx)
+					}
+					return p.UnitCost.Layout(gtx, p.Th.Dark(), "Unit Cost")
+				}),
+				layout.Rigid(func(gtx C) D {
+					return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+				}),
+				layout.Rigid(func(gtx C) D {
+					if len(p.run.Lines) > 0 {
+						return material.Button(p.Th.Secondary(), &p.Discard, "Discard").Layout(gtx)
+					}
+					return material.Button(p.Th.Primary(), &p.Start, "Start").Layout(gtx)
+				}),
+			)
+		}),
+	)
+}
+
+// LayoutReadings renders the grid of readings, one row per site.
+func (p *BillingRunPage) LayoutReadings(gtx C) D {
+	def, _ := p.settings.Service(p.run.Service)
+	rows := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return p.row(gtx, true, "Site", "Tenant", "Meter", "Previous", "Current", "")
+		}),
+	}
+	for ii := range p.run.Lines {
+		var (
+			line   = &p.run.Lines[ii]
+			inputs = &p.lines[ii]
+		)
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			if line.Skip {
+				return p.row(gtx, false, inputs.Site.Number, inputs.Tenant.Name, "No meter", "", "", "")
+			}
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				p.cell(inputs.Site.Number),
+				p.cell(inputs.Tenant.Name),
+				p.cell(inputs.Meter.String()),
+				p.cell(fmt.Sprintf("%d (%s)", line.Baseline.Value, util.FormatTime(line.Baseline.Date))),
+				layout.Flexed(1, func(gtx C) D {
+					if !inputs.Meter.TimeOfUse {
+						return inputs.Reading.Layout(gtx, p.Th.Dark(), def.Units())
+					}
+					return layout.Flex{
+						Axis: layout.Vertical,
+					}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							return inputs.Reading.Layout(gtx, p.Th.Dark(), fmt.Sprintf("Peak %s", def.Units()))
+						}),
+						layout.Rigid(func(gtx C) D {
+							return inputs.OffPeak.Layout(gtx, p.Th.Dark(), fmt.Sprintf("Off-peak %s (from %d)", def.Units(), line.Baseline.OffPeak))
+						}),
+					)
+				}),
+				layout.Flexed(1, func(gtx C) D {
+					return material.CheckBox(p.Th.Dark(), &inputs.Estimated, "Estimated").Layout(gtx)
+				}),
+			)
+		}))
+	}
+	rows = append(rows, layout.Rigid(func(gtx C) D {
+		return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+			return material.Button(p.Th.Primary(), &p.Preview, "Preview").Layout(gtx)
+		})
+	}))
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
+
+// LayoutPreview renders the invoices the run would issue, with totals.
+func (p *BillingRunPage) LayoutPreview(gtx C) D {
+	rows := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return p.row(gtx, true, "Site", "Tenant", "Units", "Activity", "Recurring", "GST", "Bill")
+		}),
+	}
+	var count int
+	for ii := range p.run.Lines {
+		var (
+			line   = &p.run.Lines[ii]
+			inputs = &p.lines[ii]
+		)
+		if line.Skip {
+			continue
+		}
+		count++
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			inv := line.Invoice
+			units := strconv.Itoa(inv.UnitsConsumed)
+			if line.Carried > 0 {
+				units += fmt.Sprintf(" (%d carried)", line.Carried)
+			}
+			if inputs.Meter.TimeOfUse {
+				units += fmt.Sprintf(" + %d off-peak", inv.OffPeak.UnitsConsumed)
+			}
+			if line.Estimated {
+				units += " (est)"
+			}
+			return p.row(
+				gtx,
+				false,
+				inputs.Site.Number,
+				inputs.Tenant.Name,
+				units,
+				inv.Charges.Activity.String(),
+				inv.Charges.Recurring.String(),
+				inv.Charges.GST.String(),
+				inv.Bill.String(),
+			)
+		}))
+	}
+	bill, gst := p.run.Total()
+	rows = append(
+		rows,
+		layout.Rigid(func(gtx C) D {
+			return p.row(gtx, true, "Total", fmt.Sprintf("%d invoices", count), "", "", "", gst.String(), bill.String())
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+				return layout.Flex{
+					Axis: layout.Horizontal,
+				}.Layout(
+					gtx,
+					layout.Rigid(func(gtx C) D {
+						return material.Button(p.Th.Secondary(), &p.Edit, "Edit").Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+					}),
+					layout.Rigid(func(gtx C) D {
+						return material.Button(p.Th.Success(), &p.Commit, "Issue Invoices").Layout(gtx)
+					}),
+				)
+			})
+		}),
+	)
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
+
+// LayoutHistory renders the runs committed so far, which can be reversed.
+func (p *BillingRunPage) LayoutHistory(gtx C) D {
+	rows := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return material.Label(p.Th.Dark(), unit.Dp(20), "Previous Runs").Layout(gtx)
+		}),
+	}
+	for _, run := range p.runs {
+		var (
+			run      = run
+			def, _   = p.settings.Service(run.Service)
+			bill, _  = run.Total()
+			reversal = p.reversal(run.ID)
+		)
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				layout.Flexed(1, func(gtx C) D {
+					summary := fmt.Sprintf(
+						"#%d %s read %s, %d invoices totalling %s",
+						run.ID,
+						def.Name,
+						util.FormatTime(run.Date),
+						len(run.Lines),
+						bill,
+					)
+					th := p.Th.Dark()
+					if run.IsReversed() {
+						summary += fmt.Sprintf(" (reversed %s)", util.FormatTime(run.Reversed))
+						th = p.Th.Muted()
+					}
+					return material.Body1(th, summary).Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					if run.IsReversed() {
+						return D{}
+					}
+					b := material.Button(p.Th.Danger(), reversal, "Reverse")
+					b.Inset = layout.UniformInset(unit.Dp(5))
+					return b.Layout(gtx)
+				}),
+			)
+		}))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
+
+// row renders a row of labelled cells.
+func (p *BillingRunPage) row(gtx C, header bool, cells ...string) D {
+	children := make([]layout.FlexChild, len(cells))
+	for ii, text := range cells {
+		text := text
+		children[ii] = layout.Flexed(1, func(gtx C) D {
+			lb := material.Body1(p.Th.Dark(), text)
+			if header {
+				lb = material.Body2(p.Th.Muted(), text)
+			}
+			return layout.UniformInset(unit.Dp(5)).Layout(gtx, lb.Layout)
+		})
+	}
+	return layout.Flex{
+		Axis:      layout.Horizontal,
+		Alignment: layout.Middle,
+	}.Layout(gtx, children...)
+}
+
+// cell renders a single labelled cell of a row.
+func (p *BillingRunPage) cell(text string) layout.FlexChild {
+	return layout.Flexed(1, func(gtx C) D {
+		return layout.UniformInset(unit.Dp(5)).Layout(gtx, material.Body1(p.Th.Dark(), text).Layout)
+	})
+}
+
+// reversal returns the reverse button state for the run.
+func (p *BillingRunPage) reversal(id int) *widget.Clickable {
+	if p.reverse == nil {
+		p.reverse = make(map[int]*widget.Clickable)
+	}
+	btn, ok := p.reverse[id]
+	if !ok {
+		btn = &widget.Clickable{}
+		p.reverse[id] = btn
+	}
+	return btn
+}
@@ -0,0 +1,149 @@
+package views
+
+import (
+	"image"
+	"strconv"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"git.sr.ht/~whereswaldon/materials"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+)
+
+// RecurringChargeForm collects a recurring charge for a lease service.
+type RecurringChargeForm struct {
+	Charge avisha.RecurringCharge
+	// Service the charge is attached to.
+	Service avisha.ServiceDefinition
+
+	Description materials.TextField
+	Amount      materials.TextField
+	Start       materials.TextField
+	End         materials.TextField
+	Frequency   widget.Enum
+
+	Form      widget.Form
+	SubmitBtn widget.Clickable
+	CancelBtn widget.Clickable
+}
+
+// Load the form with a new charge for the service, starting today.
+func (f *RecurringChargeForm) Load(def avisha.ServiceDefinition) {
+	f.Service = def
+	f.Charge = avisha.RecurringCharge{Frequency: avisha.Weekly}
+	f.Frequency.Value = strconv.Itoa(int(f.Charge.Frequency))
+	f.Form.Load([]widget.Field{
+		{
+			Value: widget.RequiredValuer{Valuer: widget.TextValuer{Value: &f.Charge.Description}},
+			Input: &f.Description,
+		},
+		{
+			Value: widget.CurrencyValuer{Value: &f.Charge.Amount},
+			Input: &f.Amount,
+		},
+		{
+			Value: widget.DateValuer{Value: &f.Charge.Start, Default: today()},
+			Input: &f.Start,
+		},
+		{
+			Value: widget.OptionalDateValuer{Value: &f.Charge.End},
+			Input: &f.End,
+		},
+	})
+}
+
+// Submit validates the input data and returns the charge.
+func (f *RecurringChargeForm) Submit() (charge avisha.RecurringCharge, ok bool) {
+	if !f.Form.Submit() {
+		return charge, false
+	}
+	if frequency, err := strconv.Atoi(f.Frequency.Value); err == nil {
+		f.Charge.Frequency = avisha.Frequency(frequency)
+	}
+	if err := f.Charge.Validate(); err != nil {
+		f.Amount.SetError(err.Error())
+		return charge, false
+	}
+	return f.Charge, true
+}
+
+func (f *RecurringChargeForm) Clear() {
+	f.Form.Clear()
+}
+
+func (f *RecurringChargeForm) Layout(gtx C, th *style.Theme) D {
+	f.Form.Validate(gtx)
+	var (
+		spacer = func(gtx C) D {
+			return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+		}
+		frequency = func(freq avisha.Frequency) layout.FlexChild {
+			return layout.Rigid(func(gtx C) D {
+				return material.RadioButton(th.Dark(), &f.Frequency, strconv.Itoa(int(freq)), freq.String()).Layout(gtx)
+			})
+		}
+	)
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
+		layout.Rigid(func(gtx C) D {
+			return f.Description.Layout(gtx, th.Dark(), "Description")
+		}),
+		layout.Rigid(func(gtx C) D {
+			f.Amount.Prefix = func(gtx C) D {
+				return material.Body1(th.Dark(), "$").Layout(gtx)
+			}
+			return f.Amount.Layout(gtx, th.Dark(), "Amount")
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				frequency(avisha.Weekly),
+				frequency(avisha.Fortnightly),
+				frequency(avisha.Monthly),
+				frequency(avisha.EachInvoice),
+			)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis: layout.Horizontal,
+			}.Layout(
+				gtx,
+				layout.Flexed(1, func(gtx C) D {
+					return f.Start.Layout(gtx, th.Dark(), "Start Date")
+				}),
+				layout.Rigid(spacer),
+				layout.Flexed(1, func(gtx C) D {
+					return f.End.Layout(gtx, th.Dark(), "End Date (optional)")
+				}),
+			)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{
+				Top: unit.Dp(10),
+			}.Layout(
+				gtx,
+				func(gtx C) D {
+					return layout.Flex{
+						Axis: layout.Horizontal,
+					}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							return material.Button(th.Secondary(), &f.CancelBtn, "Cancel").Layout(gtx)
+						}),
+						layout.Rigid(spacer),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(th.Primary(), &f.SubmitBtn, "Add").Layout(gtx)
+						}),
+					)
+				})
+		}),
+	)
+}
@@ -0,0 +1,115 @@
+package views
+
+import (
+	"image"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+)
+
+// Confirmation asks to confirm an action before taking it, such as archiving
+// or deleting a record.
+// If the action fails the dialog stays open with the reason.
+type Confirmation struct {
+	Ok     widget.Clickable
+	Cancel widget.Clickable
+
+	asking  bool
+	title   string
+	message string
+	action  string
+	danger  bool
+	do      func() error
+	err     error
+}
+
+// Ask for confirmation of the action, labelled by action, which do takes.
+// Dangerous actions are coloured as such.
+func (c *Confirmation) Ask(title, message, action string, danger bool, do func() error) {
+	*c = Confirmation{
+		asking:  true,
+		title:   title,
+		message: message,
+		action:  action,
+		danger:  danger,
+		do:      do,
+	}
+}
+
+// Asking reports whether the confirmation is showing.
+func (c *Confirmation) Asking() bool {
+	return c.asking
+}
+
+// Update takes the action once confirmed, reporting whether it was.
+func (c *Confirmation) Update() (done bool) {
+	if c.Cancel.Clicked() {
+		c.asking = false
+	}
+	if c.Ok.Clicked() {
+		if c.err = c.do(); c.err == nil {
+			c.asking = false
+			return true
+		}
+	}
+	return false
+}
+
+// Layout the confirmation dialog over the view, while asking.
+func (c *Confirmation) Layout(gtx C, th *style.Theme) D {
+	if !c.asking {
+		return D{}
+	}
+	return style.ModalDialog(gtx, th, unit.Dp(500), c.title, func(gtx C) D {
+		return layout.Flex{
+			Axis: layout.Vertical,
+		}.Layout(
+			gtx,
+			layout.Rigid(func(gtx C) D {
+				return material.Body1(th.Dark(), c.message).Layout(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if c.err == nil {
+					return D{}
+				}
+				return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+					lb := material.Body1(th.Dark(), c.err.Error())
+					lb.Color = th.Danger().Fg
+					return lb.Layout(gtx)
+				})
+			}),
+			layout.Rigid(func(gtx C) D {
+				return D{Size: image.Point{Y: gtx.Px(unit.Dp(10))}}
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{
+					Axis: layout.Horizontal,
+				}.Layout(
+					gtx,
+					layout.Flexed(1, func(gtx C) D {
+						return D{Size: gtx.Constraints.Min}
+					}),
+					layout.Rigid(func(gtx C) D {
+						btn := material.Button(th.Secondary(), &c.Cancel, "Cancel")
+						btn.Color = btn.Background
+						btn.Background = style.WithAlpha(btn.Background, 0)
+						return btn.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+					}),
+					layout.Rigid(func(gtx C) D {
+						mth := th.Primary()
+						if c.danger {
+							mth = th.Danger()
+						}
+						return material.Button(mth, &c.Ok, c.action).Layout(gtx)
+					}),
+				)
+			}),
+		)
+	})
+}
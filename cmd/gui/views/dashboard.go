@@ -0,0 +1,236 @@
+package views
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"unsafe"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+	"github.com/jackmordaunt/avisha.go/report"
+)
+
+// Dashboard summarises what needs attention across the leases, each figure
+// leading to the view behind it.
+type Dashboard struct {
+	nav.Route
+	App *avisha.App
+	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+	// Invalidate requests a frame once the summary has loaded in the
+	// background.
+	Invalidate func()
+
+	Arrears  tile
+	DueSoon  tile
+	Overdue  tile
+	Expiring tile
+	Vacant   tile
+
+	payments States
+	scroll   layout.List
+	loader   Loader
+}
+
+// tile is a clickable figure on the dashboard.
+type tile struct {
+	widget.Clickable
+	Hover widget.Hoverable
+}
+
+// summary is the data shown on the dashboard.
+type summary struct {
+	Arrears  report.ArrearsReport
+	DueSoon  int
+	Overdue  int
+	Expiring int
+	Vacant   int
+	Payments []report.Receipt
+	Err      error
+}
+
+// Dashboard figures look ahead and behind by these many days.
+const (
+	// dashboardExpiry is the days ahead to count leases expiring.
+	dashboardExpiry = 30
+	// dashboardPayments is the days back to list payments received.
+	dashboardPayments = 14
+)
+
+func (d *Dashboard) Title() string {
+	return "Dashboard"
+}
+
+// Receive reloads the summary whenever the dashboard is shown, since the
+// figures depend on the day as well as the records.
+func (d *Dashboard) Receive(data interface{}) {
+	d.loader.Reload()
+}
+
+// summarise the leases as of the given time.
+func summarise(app avisha.App, now time.Time) (s summary) {
+	arrears, err := report.Arrears(app, now)
+	if err != nil {
+		s.Err = fmt.Errorf("building arrears: %w", err)
+		return s
+	}
+	s.Arrears = arrears
+	for _, line := range arrears.Lines {
+		for _, inv := range line.Invoices {
+			if inv.IsOverdue() {
+				s.Overdue++
+			} else if inv.DueWithin(now, 7) {
+				s.DueSoon++
+			}
+		}
+	}
+	occupancy, err := report.OccupancyOf(app, now, avisha.Term{Start: now}, dashboardExpiry)
+	if err != nil {
+		s.Err = fmt.Errorf("building occupancy: %w", err)
+		return s
+	}
+	s.Expiring = len(occupancy.Expiring)
+	s.Vacant = occupancy.Total.Sites - occupancy.Total.Occupied
+	if s.Payments, err = report.Payments(app, now.AddDate(0, 0, -dashboardPayments)); err != nil {
+		s.Err = fmt.Errorf("listing payments: %w", err)
+	}
+	return s
+}
+
+func (d *Dashboard) Update(gtx C) {
+	if d.Arrears.Clicked() {
+		d.Route.To(RouteArrears, ArrearsAll)
+	}
+	if d.DueSoon.Clicked() {
+		d.Route.To(RouteArrears, ArrearsDueSoon)
+	}
+	if d.Overdue.Clicked() {
+		d.Route.To(RouteArrears, ArrearsOverdue)
+	}
+	if d.Expiring.Clicked() {
+		d.Route.To(RouteReports, ReportsExpiring)
+	}
+	if d.Vacant.Clicked() {
+		d.Route.To(RouteReports, ReportsVacant)
+	}
+	for _, state := range d.payments.List() {
+		if state.Item.Clicked() {
+			lease := (*report.Receipt)(state.Data).Lease
+			d.Route.To(RouteLeasePage, &lease)
+		}
+	}
+}
+
+func (d *Dashboard) Layout(gtx C) D {
+	d.Update(gtx)
+	data, _ := d.loader.Load(d.App, d.Invalidate, "", func(app avisha.App) (interface{}, error) {
+		s := summarise(app, time.Now())
+		if s.Err != nil {
+			d.Alerts.Error(fmt.Errorf("loading dashboard: %w", s.Err))
+		}
+		return s, nil
+	})
+	var (
+		s, _    = data.(summary)
+		loading = d.loader.Loading()
+	)
+	d.payments.Begin()
+	d.scroll.Axis = layout.Vertical
+	var (
+		figure = func(t *tile, caption, value string, th *material.Theme) layout.FlexChild {
+			return layout.Flexed(1, func(gtx C) D {
+				return layout.UniformInset(unit.Dp(5)).Layout(gtx, func(gtx C) D {
+					return style.ListItem(gtx, d.Th.Dark(), &t.Clickable, &t.Hover, false, func(gtx C) D {
+						return style.Card{
+							Content: []layout.Widget{
+								func(gtx C) D {
+									return material.Body2(d.Th.Muted(), caption).Layout(gtx)
+								},
+								func(gtx C) D {
+									return material.H5(th, value).Layout(gtx)
+								},
+							},
+						}.Layout(gtx, d.Th.Dark())
+					})
+				})
+			})
+		}
+		count = func(n int, th *material.Theme) *material.Theme {
+			if n == 0 {
+				return d.Th.Dark()
+			}
+			return th
+		}
+		rows = []layout.FlexChild{
+			layout.Rigid(func(gtx C) D {
+				if !loading && s.Err == nil {
+					return D{}
+				}
+				if s.Err != nil {
+					return material.Body1(d.Th.Danger(), s.Err.Error()).Layout(gtx)
+				}
+				return material.Body1(d.Th.Muted(), "Loading...").Layout(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Axis: layout.Horizontal}.Layout(
+					gtx,
+					figure(&d.Arrears, "Total Arrears", s.Arrears.Totals.Total().String(), count(int(s.Arrears.Totals.Total()), d.Th.Danger())),
+					figure(&d.DueSoon, "Due This Week", strconv.Itoa(s.DueSoon), count(s.DueSoon, d.Th.Warning())),
+					figure(&d.Overdue, "Overdue Invoices", strconv.Itoa(s.Overdue), count(s.Overdue, d.Th.Danger())),
+				)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Axis: layout.Horizontal}.Layout(
+					gtx,
+					figure(&d.Expiring, fmt.Sprintf("Leases Expiring in %d Days", dashboardExpiry), strconv.Itoa(s.Expiring), count(s.Expiring, d.Th.Warning())),
+					figure(&d.Vacant, "Vacant Sites", strconv.Itoa(s.Vacant), count(s.Vacant, d.Th.Warning())),
+				)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Inset{Top: unit.Dp(10), Left: unit.Dp(5)}.Layout(gtx, func(gtx C) D {
+					return material.H6(d.Th.Dark(), "Recent Payments").Layout(gtx)
+				})
+			}),
+		}
+	)
+	if len(s.Payments) == 0 && !loading {
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(unit.Dp(5)).Layout(gtx, func(gtx C) D {
+				return material.Body1(d.Th.Muted(), fmt.Sprintf("No payments in the last %d days", dashboardPayments)).Layout(gtx)
+			})
+		}))
+	}
+	for ii := range s.Payments {
+		var (
+			receipt = &s.Payments[ii]
+			state   = d.payments.Next(unsafe.Pointer(receipt))
+		)
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return style.ListItem(gtx, d.Th.Dark(), &state.Item, &state.Hover, false, func(gtx C) D {
+				return tableRow(
+					gtx,
+					d.Th,
+					false,
+					util.FormatTime(receipt.Payment.Time),
+					fmt.Sprintf("Site %s", receipt.Site.Number),
+					receipt.Tenant.Name,
+					receipt.Service.Name,
+					receipt.Payment.Amount.String(),
+				)
+			})
+		}))
+	}
+	return d.scroll.Layout(gtx, 1, func(gtx C, _ int) D {
+		return layout.UniformInset(unit.Dp(15)).Layout(gtx, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+		})
+	})
+}
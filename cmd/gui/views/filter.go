@@ -0,0 +1,65 @@
+package views
+
+import (
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+)
+
+// dwellingChips toggle filtering by dwelling type, indexed by dwelling.
+type dwellingChips [3]widget.Bool
+
+// Selected returns the dwelling types toggled on.
+func (c *dwellingChips) Selected() (dwellings []avisha.Dwelling) {
+	for _, d := range avisha.Dwellings {
+		if c[d].Value {
+			dwellings = append(dwellings, d)
+		}
+	}
+	return dwellings
+}
+
+// Chips returns a chip for each dwelling type.
+func (c *dwellingChips) Chips(th *style.Theme) (chips []layout.FlexChild) {
+	for _, d := range avisha.Dwellings {
+		d := d
+		chips = append(chips, layout.Rigid(func(gtx C) D {
+			return style.Chip(th.Primary(), &c[d], d.String()).Layout(gtx)
+		}))
+	}
+	return chips
+}
+
+// layoutChips renders a row of filter chips.
+func layoutChips(gtx C, chips ...layout.FlexChild) D {
+	return layout.Inset{
+		Top:   unit.Dp(5),
+		Left:  unit.Dp(10),
+		Right: unit.Dp(10),
+	}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{
+			Axis:      layout.Horizontal,
+			Alignment: layout.Middle,
+		}.Layout(gtx, chips...)
+	})
+}
+
+// layoutArchived renders a list item, marked if archived.
+func layoutArchived(gtx C, th *style.Theme, archived bool, w layout.Widget) D {
+	if !archived {
+		return w(gtx)
+	}
+	return layout.Flex{
+		Axis:      layout.Horizontal,
+		Alignment: layout.Baseline,
+	}.Layout(
+		gtx,
+		layout.Rigid(w),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{Left: unit.Dp(10)}.Layout(gtx, material.Body2(th.Muted(), "Archived").Layout)
+		}),
+	)
+}
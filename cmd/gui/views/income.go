@@ -0,0 +1,206 @@
+package views
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"git.sr.ht/~whereswaldon/materials"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+	"github.com/jackmordaunt/avisha.go/report"
+)
+
+// IncomePage reports the income and GST of a period, invoiced and received,
+// for filing GST returns.
+type IncomePage struct {
+	nav.Route
+	App *avisha.App
+	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+
+	From materials.TextField
+	To   materials.TextField
+
+	Form      widget.Form
+	RunBtn    widget.Clickable
+	ExportCSV widget.Clickable
+	Print     widget.Clickable
+
+	// from and to are the first and last days of the period.
+	from, to time.Time
+	report   report.IncomeReport
+	err      string
+	scroll   layout.List
+}
+
+func (p *IncomePage) Title() string {
+	return "Income & GST"
+}
+
+// Receive defaults the period to the last calendar month and runs the report,
+// keeping the period entered when returning to the page.
+func (p *IncomePage) Receive(data interface{}) {
+	if p.from.IsZero() {
+		var (
+			now  = today()
+			this = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		)
+		p.from, p.to = this.AddDate(0, -1, 0), this.AddDate(0, 0, -1)
+	}
+	p.Form.Load([]widget.Field{
+		{
+			Value: widget.DateValuer{Value: &p.from},
+			Input: &p.From,
+		},
+		{
+			Value: widget.DateValuer{Value: &p.to},
+			Input: &p.To,
+		},
+	})
+	p.run()
+}
+
+// run builds the report over the period entered.
+func (p *IncomePage) run() {
+	p.err = ""
+	if p.to.Before(p.from) {
+		p.To.SetError("must not be before the start of the period")
+		return
+	}
+	r, err := report.Income(*p.App, avisha.Term{
+		Start:    p.from,
+		Duration: p.to.AddDate(0, 0, 1).Sub(p.from),
+	})
+	if err != nil {
+		p.err = fmt.Sprintf("building report: %v", err)
+		return
+	}
+	p.report = r
+}
+
+func (p *IncomePage) Update(gtx C) {
+	p.Form.Validate(gtx)
+	if p.RunBtn.Clicked() {
+		if p.Form.Submit() {
+			p.run()
+		}
+	}
+	if p.ExportCSV.Clicked() {
+		if err := p.export("csv"); err != nil {
+			p.Alerts.Error(err)
+		}
+	}
+	if p.Print.Clicked() {
+		if err := p.export("html"); err != nil {
+			p.Alerts.Error(err)
+		}
+	}
+}
+
+// export writes the report in the given format and opens it.
+func (p *IncomePage) export(format string) error {
+	var (
+		buffer = new(bytes.Buffer)
+		err    error
+	)
+	switch format {
+	case "csv":
+		err = p.report.Table().WriteCSV(buffer)
+	case "html":
+		var settings avisha.Settings
+		if settings, err = p.App.LoadSettings(); err != nil {
+			return fmt.Errorf("loading settings: %w", err)
+		}
+		buffer, err = util.ReportDocument{
+			Title: "Income & GST",
+			Tables: []report.Table{
+				p.report.GSTTable(),
+				p.report.ServiceTable(),
+				p.report.Table(),
+			},
+			Settings: settings,
+		}.Render()
+	}
+	if err != nil {
+		return fmt.Errorf("exporting report: %w", err)
+	}
+	return openDocument(
+		"reports",
+		fmt.Sprintf(
+			"income-%s-%s.%s",
+			p.report.Period.Start.Format("20060102"),
+			p.report.Period.End().AddDate(0, 0, -1).Format("20060102"),
+			format,
+		),
+		buffer,
+	)
+}
+
+func (p *IncomePage) Layout(gtx C) D {
+	p.Update(gtx)
+	p.scroll.Axis = layout.Vertical
+	var (
+		spacer = func(gtx C) D {
+			return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+		}
+		table = func(t report.Table) layout.FlexChild {
+			return layout.Rigid(func(gtx C) D {
+				return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+					return layoutTable(gtx, p.Th, t)
+				})
+			})
+		}
+	)
+	return p.scroll.Layout(gtx, 1, func(gtx C, _ int) D {
+		return layout.UniformInset(unit.Dp(20)).Layout(gtx, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(
+				gtx,
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{
+						Axis:      layout.Horizontal,
+						Alignment: layout.Middle,
+					}.Layout(
+						gtx,
+						layout.Flexed(1, func(gtx C) D {
+							return p.From.Layout(gtx, p.Th.Dark(), "From")
+						}),
+						layout.Rigid(spacer),
+						layout.Flexed(1, func(gtx C) D {
+							return p.To.Layout(gtx, p.Th.Dark(), "To")
+						}),
+						layout.Rigid(spacer),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(p.Th.Primary(), &p.RunBtn, "Report").Layout(gtx)
+						}),
+						layout.Rigid(spacer),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(p.Th.Secondary(), &p.ExportCSV, "Export CSV").Layout(gtx)
+						}),
+						layout.Rigid(spacer),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(p.Th.Secondary(), &p.Print, "Print").Layout(gtx)
+						}),
+					)
+				}),
+				layout.Rigid(func(gtx C) D {
+					if p.err == "" {
+						return D{}
+					}
+					return material.Body1(p.Th.Danger(), p.err).Layout(gtx)
+				}),
+				table(p.report.GSTTable()),
+				table(p.report.ServiceTable()),
+				table(p.report.Table()),
+			)
+		})
+	})
+}
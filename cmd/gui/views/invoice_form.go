@@ -15,16 +15,29 @@ import (
 	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
-	"github.com/jackmordaunt/avisha.go/currency"
 )
 
 // UtilitiesInvoiceForm is form for collecting utility invoice data.
 type UtilitiesInvoiceForm struct {
 	Invoice avisha.UtilityInvoice
 
+	// Service is the metered service being invoiced.
+	Service avisha.ServiceDefinition
+	// Subscription is the lease's subscription to the service, whose
+	// recurring charges are added to the invoice.
+	Subscription avisha.Service
+	// Meter the current reading is read off.
+	Meter avisha.Meter
+	// Previous is the reading consumption is calculated from.
+	Previous avisha.Reading
+	// Carried is the units consumed on replaced meters since the previous
+	// invoice.
+	Carried int
+
 	UnitsConsumed   materials.TextField
 	PreviousReading materials.TextField
 	CurrentReading  materials.TextField
+	OffPeakReading  materials.TextField
 	UnitCost        materials.TextField
 
 	// Bill is the final amount due.
@@ -36,8 +49,18 @@ type UtilitiesInvoiceForm struct {
 	GST        materials.TextField
 	Activity   materials.TextField
 
-	IssueDate materials.TextField
-	DueDate   materials.TextField
+	IssueDate style.DatePicker
+	DueDate   style.DatePicker
+
+	// Estimated marks the current reading as an estimate.
+	Estimated widget.Bool
+
+	// tariff in effect on the issue date, zero if the units are charged at
+	// the unit cost.
+	tariff avisha.Tariff
+	// lines itemise the charges of the tariff followed by the recurring
+	// charges.
+	lines []avisha.LineItem
 
 	dueDateOverride      bool
 	dueDatePreviousValue string
@@ -52,37 +75,53 @@ type UtilitiesInvoiceForm struct {
 func (f *UtilitiesInvoiceForm) Load(
 	invoice avisha.UtilityInvoice,
 	settings avisha.Settings,
-	previousReading int,
+	subscription avisha.Service,
+	meter avisha.Meter,
+	previous avisha.Reading,
+	carried int,
 ) {
+	def, _ := settings.Service(invoice.Service)
 	f.Invoice = invoice
+	f.Service = def
+	f.Subscription = subscription
+	f.Meter = meter
+	f.Previous = previous
+	f.Carried = carried
+	f.Estimated.Value = false
 	f.invoiceNet = settings.Defaults.InvoiceNet
-	f.Invoice.GST = settings.Defaults.GST
-	f.PreviousReading.SetText(strconv.Itoa(previousReading))
-	f.Form.Load([]widget.Field{
+	// Offer the due date the usual net from today, should the issue date be
+	// today.
+	f.DueDate.Quick = []int{int(f.invoiceNet.Hours() / 24)}
+	f.Invoice.GST = def.Tax.Rate(settings.Defaults.GST)
+	f.PreviousReading.SetText(strconv.Itoa(previous.Value))
+	fields := []widget.Field{
 		{
-			Value: widget.IntValuer{
-				Value:   &f.Invoice.Reading,
-				Default: previousReading,
+			Value: ReadingValuer{
+				Value:    &f.Invoice.Reading,
+				Meter:    meter,
+				Previous: previous.Value,
 			},
 			Input: &f.CurrentReading,
 		},
 		{
 			Value: widget.CurrencyValuer{
 				Value:   &f.Invoice.UnitCost,
-				Default: currency.Dollar * 1,
+				Default: def.Price,
 			},
 			Input: &f.UnitCost,
 		},
 		{
-			Value: widget.DateValuer{
-				Value:   &f.Invoice.Issued,
-				Default: time.Now(),
+			Value: style.DateValuer{
+				Value:    &f.Invoice.Issued,
+				Default:  time.Now(),
+				Calendar: &f.IssueDate.Calendar,
 			},
 			Input: &f.IssueDate,
 		},
 		{
-			Value: widget.DateValuer{
-				Value: &f.Invoice.Due,
+			Value: style.DateValuer{
+				Value:    &f.Invoice.Due,
+				Calendar: &f.DueDate.Calendar,
 			},
 			Input: &f.DueDate,
 		},
@@ -122,28 +161,49 @@ func (f *UtilitiesInvoiceForm) Load(
 			},
 			Input: &f.Bill,
 		},
-	})
+	}
+	if meter.TimeOfUse {
+		fields = append(fields, widget.Field{
+			Value: ReadingValuer{
+				Value:    &f.Invoice.OffPeak.Reading,
+				Meter:    meter,
+				Previous: previous.OffPeak,
+			},
+			Input: &f.OffPeakReading,
+		})
+	}
+	f.Form.Load(fields)
 }
 
-func (f *UtilitiesInvoiceForm) Submit() (invoice avisha.UtilityInvoice, ok bool) {
-	f.Invoice.Balance.Debit(avisha.Payment{
-		Amount: f.Invoice.Bill,
-		Time:   time.Now(),
-	})
-	return f.Invoice, f.Form.Submit()
+// Submit validates the input data and returns the invoice along with the
+// current reading to record for it.
+func (f *UtilitiesInvoiceForm) Submit() (invoice avisha.UtilityInvoice, reading avisha.Reading, ok bool) {
+	ok = f.Form.Submit()
+	reading = avisha.Reading{
+		Meter:     f.Meter.ID,
+		Date:      f.Invoice.Issued,
+		Value:     f.Invoice.Reading,
+		OffPeak:   f.Invoice.OffPeak.Reading,
+		Estimated: f.Estimated.Value,
+	}
+	return f.Invoice, reading, ok
 }
 
 func (f *UtilitiesInvoiceForm) Clear() {
 	f.Form.Clear()
+	f.IssueDate.Close()
+	f.DueDate.Close()
 	f.dueDateOverride = false
 	f.invoiceNet = 0
 	f.dueDatePreviousValue = ""
+	f.tariff = avisha.Tariff{}
+	f.lines = nil
 }
 
 func (f *UtilitiesInvoiceForm) Update(gtx C) {
 	// Compute DueDate unless manually overridden.
 	{
-		if f.DueDate.Focused() && f.dueDatePreviousValue != f.DueDate.Text() {
+		if f.DueDate.Update() || (f.DueDate.Input.Focused() && f.dueDatePreviousValue != f.DueDate.Text()) {
 			f.dueDateOverride = true
 		}
 		if !f.dueDateOverride {
@@ -157,66 +217,56 @@ func (f *UtilitiesInvoiceForm) Update(gtx C) {
 			f.dueDatePreviousValue = f.DueDate.Text()
 		}
 	}
-	f.UnitsConsumed.SetText(func() string {
-		current, err := util.ParseInt(f.CurrentReading.Text())
-		if err != nil {
-			return "0"
-		}
-		previous, err := util.ParseInt(f.PreviousReading.Text())
-		if err != nil {
-			return "0"
-		}
-		return strconv.Itoa(current - previous)
-	}())
-	f.Activity.SetText(func() string {
-		consumed, err := util.ParseInt(f.UnitsConsumed.Text())
-		if err != nil {
-			return "0"
-		}
-		cost, err := util.ParseCurrency(f.UnitCost.Text())
-		if err != nil {
-			return "0"
-		}
-		total := cost * currency.Currency(consumed)
-		return strconv.FormatFloat(total.Dollars(), 'f', 2, 64)
-	}())
-	f.GST.SetText(func() string {
-		activity, err := util.ParseCurrency(f.Activity.Text())
-		if err != nil {
-			return "0"
-		}
-		latefee, err := util.ParseCurrency(f.LateFee.Text())
-		if err != nil {
-			return "0"
-		}
-		linecharge, err := util.ParseCurrency(f.LineCharge.Text())
-		if err != nil {
-			return "0"
-		}
-		total := activity + latefee + linecharge
-		return strings.TrimPrefix(currency.Currency(float64(total)*(f.Invoice.GST/100)).String(), "$")
-	}())
-	f.Bill.SetText(func() string {
-		activity, err := util.ParseCurrency(f.Activity.Text())
-		if err != nil {
-			return "0"
-		}
-		latefee, err := util.ParseCurrency(f.LateFee.Text())
-		if err != nil {
-			return "0"
-		}
-		linecharge, err := util.ParseCurrency(f.LineCharge.Text())
-		if err != nil {
-			return "0"
-		}
-		gst, err := util.ParseCurrency(f.GST.Text())
+	// The invoice falls due no sooner than it is issued.
+	if issued, err := util.ParseDate(f.IssueDate.Text()); err == nil {
+		f.DueDate.Min = issued
+	}
+	f.calculate()
+	f.Form.Validate(gtx)
+}
+
+// calculate derives the consumption and charges from the readings and
+// charges entered so far.
+// Consumption is priced by the tariff in effect on the issue date.
+func (f *UtilitiesInvoiceForm) calculate() {
+	var (
+		inv    = avisha.UtilityInvoice{GST: f.Invoice.GST}
+		issued time.Time
+		errs   = make([]error, 8)
+	)
+	inv.Reading, errs[0] = util.ParseInt(f.CurrentReading.Text())
+	inv.UnitsConsumed, errs[1] = f.Meter.Consumption(f.Previous.Value, inv.Reading)
+	inv.UnitCost, errs[2] = util.ParseCurrency(f.UnitCost.Text())
+	inv.Charges.LateFee, errs[3] = util.ParseCurrency(f.LateFee.Text())
+	inv.Charges.LineCharge, errs[4] = util.ParseCurrency(f.LineCharge.Text())
+	issued, errs[5] = util.ParseDate(f.IssueDate.Text())
+	if f.Meter.TimeOfUse {
+		inv.OffPeak.Reading, errs[6] = util.ParseInt(f.OffPeakReading.Text())
+		inv.OffPeak.UnitsConsumed, errs[7] = f.Meter.Consumption(f.Previous.OffPeak, inv.OffPeak.Reading)
+	}
+	f.tariff, _ = f.Service.TariffAt(issued)
+	for _, err := range errs {
 		if err != nil {
-			return "0"
+			for _, input := range []*materials.TextField{&f.UnitsConsumed, &f.Activity, &f.GST, &f.Bill} {
+				input.SetText("0")
+			}
+			f.lines = nil
+			return
 		}
-		total := activity + latefee + linecharge + gst
-		return strings.TrimPrefix(total.String(), "$")
-	}())
-	f.Form.Validate(gtx)
+	}
+	inv.UnitsConsumed += f.Carried
+	inv.Period = avisha.Term{
+		Start:    f.Previous.Date,
+		Duration: issued.Sub(f.Previous.Date),
+	}
+	inv.Tariff = f.tariff
+	inv.Recurring = f.Subscription.ChargeLines(inv.Period)
+	inv.Calculate()
+	f.lines = append(inv.Lines, inv.Recurring...)
+	f.UnitsConsumed.SetText(strconv.Itoa(inv.UnitsConsumed))
+	f.Activity.SetText(strings.TrimPrefix(inv.Charges.Activity.String(), "$"))
+	f.GST.SetText(strings.TrimPrefix(inv.Charges.GST.String(), "$"))
+	f.Bill.SetText(strings.TrimPrefix(inv.Bill.String(), "$"))
 }
 
 func (f *UtilitiesInvoiceForm) Layout(gtx C, th *style.Theme) D {
@@ -247,10 +297,16 @@ func (f *UtilitiesInvoiceForm) Layout(gtx C, th *style.Theme) D {
 					)
 				}),
 				layout.Rigid(func(gtx C) D {
+					if !f.tariff.IsZero() {
+						return material.Body1(th.Muted(), fmt.Sprintf(
+							"Priced by the tariff effective %s",
+							util.FormatTime(f.tariff.Effective),
+						)).Layout(gtx)
+					}
 					f.UnitCost.Prefix = func(gtx C) D {
 						return material.Body1(th.Dark(), "$").Layout(gtx)
 					}
-					return f.UnitCost.Layout(gtx, th.Dark(), "Unit Cost")
+					return f.UnitCost.Layout(gtx, th.Dark(), fmt.Sprintf("Unit Cost (per %s)", f.Service.Units()))
 				}),
 				layout.Rigid(func(gtx C) D {
 					return layout.Flex{
@@ -269,6 +325,15 @@ func (f *UtilitiesInvoiceForm) Layout(gtx C, th *style.Theme) D {
 						}),
 					)
 				}),
+				layout.Rigid(func(gtx C) D {
+					if !f.Meter.TimeOfUse {
+						return D{}
+					}
+					return f.OffPeakReading.Layout(gtx, th.Dark(), fmt.Sprintf("Current Off-Peak Reading (previous %d)", f.Previous.OffPeak))
+				}),
+				layout.Rigid(func(gtx C) D {
+					return material.CheckBox(th.Dark(), &f.Estimated, fmt.Sprintf("Estimated reading (%s)", f.Meter)).Layout(gtx)
+				}),
 				layout.Rigid(func(gtx C) D {
 					return layout.Flex{
 						Axis: layout.Horizontal,
@@ -276,7 +341,7 @@ func (f *UtilitiesInvoiceForm) Layout(gtx C, th *style.Theme) D {
 						gtx,
 						layout.Flexed(1, func(gtx C) D {
 							gtx.Queue = nil
-							return f.UnitsConsumed.Layout(gtx, th.Dark(), "Units Consumed")
+							return f.UnitsConsumed.Layout(gtx, th.Dark(), fmt.Sprintf("Units Consumed (%s)", f.Service.Units()))
 						}),
 						layout.Rigid(func(gtx C) D {
 							return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
@@ -290,6 +355,9 @@ func (f *UtilitiesInvoiceForm) Layout(gtx C, th *style.Theme) D {
 						}),
 					)
 				}),
+				layout.Rigid(func(gtx C) D {
+					return f.LayoutLines(gtx, th)
+				}),
 				layout.Rigid(func(gtx C) D {
 					f.LineCharge.Prefix = func(gtx C) D {
 						return material.Body1(th.Dark(), "$").Layout(gtx)
@@ -342,3 +410,62 @@ func (f *UtilitiesInvoiceForm) Layout(gtx C, th *style.Theme) D {
 		}),
 	)
 }
+
+// ReadingValuer maps a value read off a meter to text, validating that it
+// follows on from the previous reading.
+type ReadingValuer struct {
+	Value    *int
+	Meter    avisha.Meter
+	Previous int
+}
+
+func (v ReadingValuer) To() (string, error) {
+	var n = *v.Value
+	if n == 0 {
+		n = v.Previous
+	}
+	return strconv.Itoa(n), nil
+}
+
+func (v ReadingValuer) From(text string) (err error) {
+	if *v.Value, err = util.ParseInt(text); err != nil {
+		return err
+	}
+	_, err = v.Meter.Consumption(v.Previous, *v.Value)
+	return err
+}
+
+func (v ReadingValuer) Clear() {
+	*v.Value = 0
+}
+
+// LayoutLines renders the charges itemised by the tariff and the recurring
+// charges.
+func (f *UtilitiesInvoiceForm) LayoutLines(gtx C, th *style.Theme) D {
+	if len(f.lines) == 0 {
+		return D{}
+	}
+	rows := make([]layout.FlexChild, len(f.lines))
+	for ii, line := range f.lines {
+		line := line
+		rows[ii] = layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis: layout.Horizontal,
+			}.Layout(
+				gtx,
+				layout.Flexed(2, func(gtx C) D {
+					return material.Body2(th.Dark(), line.Description).Layout(gtx)
+				}),
+				layout.Flexed(1, func(gtx C) D {
+					return material.Body2(th.Dark(), fmt.Sprintf("%d @ %s", line.Quantity, line.Rate)).Layout(gtx)
+				}),
+				layout.Flexed(1, func(gtx C) D {
+					return material.Body2(th.Dark(), line.Amount.String()).Layout(gtx)
+				}),
+			)
+		})
+	}
+	return layout.Inset{Top: unit.Dp(5), Bottom: unit.Dp(5)}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+	})
+}
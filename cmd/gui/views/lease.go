@@ -1,12 +1,14 @@
 package views
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unsafe"
@@ -19,6 +21,7 @@ import (
 	"github.com/asdine/storm/v3"
 	"github.com/asdine/storm/v3/q"
 	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/icons"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
@@ -33,23 +36,97 @@ type LeasePage struct {
 	nav.Route
 	App *avisha.App
 	Th  *style.Theme
+	// Invalidate requests a frame once the lease has loaded.
+	Invalidate func()
+	// Undo records the changes made to the lease so they can be undone.
+	Undo *Undo
+	// Alerts tells the user of failures.
+	Alerts *Alerts
 
 	lease avisha.Lease
+	// tenant, site, invoices and position are loaded with the lease.
+	tenant   avisha.Tenant
+	site     avisha.Site
+	invoices []invoiceItem
+	position *avisha.RentPosition
+	loader   Loader
 
 	Form                 LeaseForm
 	Dialog               style.Dialog
 	UtilitiesInvoiceForm UtilitiesInvoiceForm
+	RentIncreaseForm     RentIncreaseForm
+	RecurringChargeForm  RecurringChargeForm
 
-	PayUtility  widget.Clickable
-	BillUtility widget.Clickable
-	PayRent     widget.Clickable
-	BillRent    widget.Clickable
+	// History shows the audit trail of the lease.
+	History widget.Clickable
+	// Archive archives the lease, or restores it if archived.
+	Archive widget.Clickable
+	// Delete deletes the lease, if nothing has been billed or paid.
+	Delete widget.Clickable
+	// ArchivedInvoices shows the archived invoices along with the rest.
+	ArchivedInvoices widget.Bool
+
+	// Lifecycle actions.
+	Activate   widget.Clickable
+	GiveNotice widget.Clickable
+	Terminate  widget.Clickable
+	Renew      widget.Clickable
 
 	modal         layout.Widget
+	action        dialogAction
+	settings      avisha.Settings
+	services      map[avisha.ServiceKey]*serviceCard
+	subscriptions map[avisha.ServiceKey]*widget.Bool
 	invoiceStates States
 	invoiceList   layout.List
-	scroll        layout.List
-	dummy         widget.Editor
+	// invoiceActions archive and delete each invoice.
+	invoiceActions map[invoiceKey]*invoiceActions
+	confirm        Confirmation
+	scroll         layout.List
+	dummy          widget.Editor
+}
+
+// serviceCard contains the actions for a subscribed service.
+type serviceCard struct {
+	Pay      widget.Clickable
+	Bill     widget.Clickable
+	Increase widget.Clickable
+	Charge   widget.Clickable
+	// RemoveCharge removes each of the recurring charges, in order.
+	RemoveCharge []widget.Clickable
+}
+
+// invoiceKey identifies an invoice by its service, since rent and utility
+// invoices are numbered apart.
+type invoiceKey struct {
+	Service avisha.ServiceKey
+	ID      int
+}
+
+// invoiceActions archive and delete an invoice.
+type invoiceActions struct {
+	Archive widget.Clickable
+	Delete  widget.Clickable
+	item    invoiceItem
+}
+
+// actionKind enumerates the actions the dialog can collect input for.
+type actionKind int
+
+const (
+	actionNone actionKind = iota
+	actionPay
+	actionBill
+	actionBillRent
+	actionNotice
+	actionTerminate
+	actionRenew
+)
+
+// dialogAction describes what the dialog is collecting input for.
+type dialogAction struct {
+	Kind    actionKind
+	Service avisha.ServiceDefinition
 }
 
 func (page *LeasePage) Title() string {
@@ -58,12 +135,16 @@ func (page *LeasePage) Title() string {
 
 func (p *LeasePage) Receive(data interface{}) {
 	p.Form.App = p.App
+	p.Form.Undo = p.Undo
+	p.Form.Invalidate = p.Invalidate
 	if lease, ok := data.(*avisha.Lease); ok && lease != nil {
 		p.lease = *lease
 	} else {
 		p.lease = avisha.Lease{}
 		defer p.Form.Clear()
 	}
+	// Known once the invoices have loaded.
+	p.Form.Invoiced = false
 	p.Form.Load(p.lease)
 	p.UtilitiesInvoiceForm.Clear()
 }
@@ -83,53 +164,150 @@ func (p *LeasePage) Context() (list []layout.Widget) {
 					return label.Layout(gtx)
 				})
 		})
+		list = append(list, func(gtx C) D {
+			return material.IconButton(p.Th.Primary(), &p.History, icons.History).Layout(gtx)
+		})
+		list = append(list, func(gtx C) D {
+			icon := icons.Archive
+			if p.lease.IsArchived() {
+				icon = icons.Unarchive
+			}
+			return material.IconButton(p.Th.Primary(), &p.Archive, icon).Layout(gtx)
+		})
+		list = append(list, func(gtx C) D {
+			return material.IconButton(p.Th.Primary(), &p.Delete, icons.Delete).Layout(gtx)
+		})
 	}
 	return list
 }
 
 func (p *LeasePage) Modal(gtx C) D {
+	if p.confirm.Asking() {
+		return p.confirm.Layout(gtx, p.Th)
+	}
 	if p.modal == nil {
 		return D{}
 	}
 	return p.modal(gtx)
 }
 
-func (p *LeasePage) Update(gtx C) {
-	var (
-		tenant avisha.Tenant
-		site   avisha.Site
-	)
-	if p.lease.ID > 0 {
-		if err := p.App.One("ID", p.lease.ID, &p.lease); err != nil {
-			log.Printf("error: loading lease: %d: %v", p.lease.ID, err)
-		}
-		if err := p.App.One("ID", p.lease.Tenant, &tenant); err != nil {
-			log.Printf("erorr: loading tenant: %v", err)
+// leaseData is the data shown by the lease page.
+type leaseData struct {
+	Lease    avisha.Lease
+	Tenant   avisha.Tenant
+	Site     avisha.Site
+	Settings avisha.Settings
+	Invoices []invoiceItem
+	// Position is the rent position, for leases that are not drafts.
+	Position *avisha.RentPosition
+}
+
+// loadLease loads the lease and the records shown with it.
+// Only the settings are loaded for a lease yet to be created.
+func loadLease(app avisha.App, id int) (data leaseData, err error) {
+	if data.Settings, err = app.LoadSettings(); err != nil {
+		return data, fmt.Errorf("loading settings: %w", err)
+	}
+	if id == 0 {
+		return data, nil
+	}
+	if err := app.One("ID", id, &data.Lease); err != nil {
+		return data, fmt.Errorf("loading lease: %d: %w", id, err)
+	}
+	if err := app.One("ID", data.Lease.Tenant, &data.Tenant); err != nil {
+		return data, fmt.Errorf("loading tenant: %w", err)
+	}
+	if err := app.One("ID", data.Lease.Site, &data.Site); err != nil {
+		return data, fmt.Errorf("loading site: %w", err)
+	}
+	if data.Invoices, err = loadInvoices(app, id); err != nil {
+		return data, fmt.Errorf("loading invoices: %w", err)
+	}
+	if data.Lease.Status != avisha.Draft {
+		position, err := app.RentPosition(id, time.Now())
+		if err != nil {
+			return data, fmt.Errorf("calculating rent position: %w", err)
 		}
-		if err := p.App.One("ID", p.lease.Site, &site); err != nil {
-			log.Printf("erorr: loading site: %v", err)
+		data.Position = &position
+	}
+	return data, nil
+}
+
+// refresh takes up the lease data loaded in the background.
+func (p *LeasePage) refresh() {
+	id := p.lease.ID
+	v, _ := p.loader.Load(p.App, p.Invalidate, strconv.Itoa(id), func(app avisha.App) (interface{}, error) {
+		data, err := loadLease(app, id)
+		p.Alerts.Error(err)
+		return data, err
+	})
+	data, ok := v.(leaseData)
+	if !ok {
+		return
+	}
+	p.settings = data.Settings
+	p.Form.Settings = data.Settings
+	if id > 0 && data.Lease.ID == id {
+		p.lease = data.Lease
+		p.tenant, p.site = data.Tenant, data.Site
+		p.Form.Loaded(data.Tenant, data.Site)
+		p.invoices, p.position = data.Invoices, data.Position
+		p.Form.Invoiced = false
+		for _, item := range p.invoices {
+			if item.Rent != nil {
+				p.Form.Invoiced = true
+				break
+			}
 		}
 	}
-	if p.Form.SubmitBtn.Clicked() {
+}
+
+func (p *LeasePage) Update(gtx C) {
+	p.updateSubscriptions()
+	p.refresh()
+	if p.History.Clicked() {
+		p.Route.To(RouteAudit, &AuditSubject{
+			Entity: "Lease",
+			ID:     p.lease.ID,
+			Title:  fmt.Sprintf("Lease %d (Site %s, %s)", p.lease.ID, p.site.Number, p.tenant.Name),
+		})
+	}
+	p.updateArchive()
+	if draft := p.Form.DraftBtn.Clicked(); p.Form.SubmitBtn.Clicked() || draft {
 		if lease, ok := p.Form.Submit(); ok {
+			if draft {
+				lease.Status = avisha.Draft
+			}
 			if err := func() error {
 				if create := p.lease.ID == 0; create {
 					if err := p.App.CreateLease(&lease); err != nil {
 						return fmt.Errorf("creating lease: %w", err)
 					}
+					p.Alerts.Success("Created lease %d", lease.ID)
 				} else {
-					if err := p.App.Update(&lease); err != nil {
+					if err := p.Undo.Do(fmt.Sprintf("Edited lease %d", lease.ID), func(app avisha.App) error {
+						return app.UpdateLease(&lease)
+					}); err != nil {
 						return fmt.Errorf("updating lease: %w", err)
 					}
 				}
 				return nil
 			}(); err != nil {
-				log.Printf("%v", err)
+				p.Alerts.Error(err)
 			} else {
 				p.Unfocus()
 			}
 		}
 	}
+	if p.Form.IncreaseRent.Clicked() {
+		p.showRentIncrease()
+	}
+	if p.Form.Tenant.Create.Clicked() {
+		if _, err := p.Form.CreateTenant(); err != nil {
+			p.Form.Tenant.SetError(err.Error())
+			p.Alerts.Error(err)
+		}
+	}
 	if p.Form.CancelBtn.Clicked() {
 		p.Unfocus()
 		p.Form.Load(p.lease)
@@ -138,66 +316,183 @@ func (p *LeasePage) Update(gtx C) {
 			p.Route.Back()
 		}
 	}
-	if p.PayUtility.Clicked() {
-		p.Dialog.Context = "pay-utilities"
-		p.modal = func(gtx C) D {
-			return style.ModalDialog(gtx, p.Th, unit.Dp(700), "Pay Utilities", func(gtx C) D {
-				p.Dialog.Input.Prefix = func(gtx C) D {
-					return material.Label(p.Th.Dark(), p.Th.TextSize, "$").Layout(gtx)
-				}
-				return p.Dialog.Layout(gtx, p.Th.Primary(), "Amount")
-			})
-		}
-	}
-	if p.BillUtility.Clicked() {
-		p.Dialog.Context = "bill-utilities"
+	for _, def := range p.settings.Subscribed(p.lease) {
 		var (
-			prevReading = 0
-			invoices    []*avisha.UtilityInvoice
+			def  = def
+			card = p.card(def.Key)
 		)
-		if err := p.App.Select(q.Eq("Lease", p.lease.ID)).OrderBy("ID", "Paid").Reverse().Find(&invoices); err != nil {
-			if err != storm.ErrNotFound {
-				log.Printf("loading invoices: %v", err)
+		if card.Pay.Clicked() {
+			p.showDialog(
+				dialogAction{Kind: actionPay, Service: def},
+				fmt.Sprintf("Pay %s", def.Name),
+				"Amount",
+				p.dollarPrefix,
+				nil,
+			)
+		}
+		if card.Bill.Clicked() {
+			switch def.Kind {
+			case avisha.Rental:
+				period, err := p.App.NextRentPeriod(p.lease.ID, p.settings.Defaults.RentCycle)
+				if err != nil {
+					p.Alerts.Error(fmt.Errorf("finding rent period: %w", err))
+				}
+				p.Dialog.Input.SetText(util.FormatTime(period.Start))
+				p.showDialog(
+					dialogAction{Kind: actionBillRent, Service: def},
+					fmt.Sprintf("Bill %s", def.Name),
+					"Period Start",
+					nil,
+					func(gtx C) D {
+						return material.Label(
+							p.Th.Muted(),
+							p.Th.TextSize,
+							fmt.Sprintf(" for %d days", int(p.settings.Defaults.RentCycle.Hours()/24)),
+						).Layout(gtx)
+					},
+				)
+			case avisha.Metered:
+				meter, previous, carried, err := p.App.MeterBaseline(p.lease.ID, def.Key)
+				if err != nil {
+					p.Alerts.Warning("Install a %s meter at site %s before billing: %v", def.Name, p.site.Number, err)
+					// A meter must be installed at the site before the service
+					// can be billed.
+					p.Route.To(RouteSiteForm, &p.site)
+					break
+				}
+				p.UtilitiesInvoiceForm.Load(
+					avisha.UtilityInvoice{Service: def.Key},
+					p.settings,
+					p.lease.Services[def.Key],
+					meter,
+					previous,
+					carried,
+				)
+				p.modal = func(gtx C) D {
+					return style.ModalDialog(gtx, p.Th, unit.Dp(700), fmt.Sprintf("Bill %s", def.Name), func(gtx C) D {
+						return p.UtilitiesInvoiceForm.Layout(gtx, p.Th)
+					})
+				}
+			default:
+				p.Dialog.Input.SetText(strings.TrimPrefix(def.Price.String(), "$"))
+				p.showDialog(
+					dialogAction{Kind: actionBill, Service: def},
+					fmt.Sprintf("Bill %s", def.Name),
+					"Amount",
+					p.dollarPrefix,
+					nil,
+				)
 			}
 		}
-		if len(invoices) > 0 {
-			prevReading = invoices[0].Reading
+		if card.Increase.Clicked() {
+			p.showRentIncrease()
 		}
-		settings, err := p.App.LoadSettings()
-		if err != nil {
-			log.Printf("loading settings: %v", err)
+		if card.Charge.Clicked() {
+			p.RecurringChargeForm.Load(def)
+			p.modal = func(gtx C) D {
+				return style.ModalDialog(gtx, p.Th, unit.Dp(700), fmt.Sprintf("Add %s Charge", def.Name), func(gtx C) D {
+					return p.RecurringChargeForm.Layout(gtx, p.Th)
+				})
+			}
 		}
-		p.UtilitiesInvoiceForm.Load(avisha.UtilityInvoice{}, settings, prevReading)
-		p.modal = func(gtx C) D {
-			return style.ModalDialog(gtx, p.Th, unit.Dp(700), "Bill Utilities", func(gtx C) D {
-				return p.UtilitiesInvoiceForm.Layout(gtx, p.Th)
-			})
+		for ii, c := range p.lease.Services[def.Key].Charges {
+			if ii < len(card.RemoveCharge) && card.RemoveCharge[ii].Clicked() {
+				if err := p.App.RemoveRecurringCharge(p.lease.ID, def.Key, c.ID); err != nil {
+					p.Alerts.Error(fmt.Errorf("removing charge: %w", err))
+				}
+			}
 		}
 	}
-	if p.PayRent.Clicked() {
-		p.Dialog.Context = "pay-rent"
-		p.modal = func(gtx C) D {
-			return style.ModalDialog(gtx, p.Th, unit.Dp(700), "Pay Rent", func(gtx C) D {
-				p.Dialog.Input.Prefix = func(gtx C) D {
-					return material.Label(p.Th.Dark(), p.Th.TextSize, "$").Layout(gtx)
-				}
-				return p.Dialog.Layout(gtx, p.Th.Primary(), "Amount")
-			})
+	if p.Activate.Clicked() {
+		if err := p.App.ActivateLease(p.lease.ID); err != nil {
+			p.Alerts.Error(fmt.Errorf("activating lease: %w", err))
+		}
+	}
+	if p.GiveNotice.Clicked() {
+		p.Dialog.Input.SetText(strconv.Itoa(int(p.settings.Defaults.NoticePeriod.Hours() / 24)))
+		p.showDialog(
+			dialogAction{Kind: actionNotice},
+			"Give Notice",
+			"Notice Period",
+			nil,
+			p.daysSuffix,
+		)
+	}
+	if p.Terminate.Clicked() {
+		date := time.Now()
+		if p.lease.Status == avisha.NoticeGiven {
+			date = p.lease.Notice.Effective()
 		}
+		p.Dialog.Input.SetText(util.FormatTime(date))
+		p.showDialog(
+			dialogAction{Kind: actionTerminate},
+			"Terminate Lease",
+			"Termination Date",
+			nil,
+			nil,
+		)
 	}
-	if p.BillRent.Clicked() {
-		p.Dialog.Context = "bill-rent"
-		p.modal = func(gtx C) D {
-			return style.ModalDialog(gtx, p.Th, unit.Dp(700), "Bill Rent", func(gtx C) D {
-				p.Dialog.Input.Prefix = func(gtx C) D {
-					return material.Label(p.Th.Dark(), p.Th.TextSize, "$").Layout(gtx)
+	if p.Renew.Clicked() {
+		p.Dialog.Input.SetText(strconv.Itoa(int(p.lease.Term.Duration.Hours() / 24)))
+		p.showDialog(
+			dialogAction{Kind: actionRenew},
+			"Renew Lease",
+			fmt.Sprintf("New Term from %s", util.FormatTime(p.lease.Term.End())),
+			nil,
+			p.daysSuffix,
+		)
+	}
+	if p.RentIncreaseForm.SubmitBtn.Clicked() {
+		if change, ok := p.RentIncreaseForm.Submit(); ok {
+			if err := func() error {
+				change, err := p.App.ScheduleRentIncrease(p.lease.ID, change.Amount, change.Effective, change.Notified)
+				if err != nil {
+					return fmt.Errorf("scheduling rent increase: %w", err)
 				}
-				return p.Dialog.Layout(gtx, p.Th.Primary(), "Amount")
-			})
+				buffer, err := util.RentIncreaseNoticeDocument{
+					Change:   change,
+					Current:  p.RentIncreaseForm.Current,
+					Lease:    p.lease,
+					Tenant:   p.tenant,
+					Site:     p.site,
+					Settings: p.settings,
+				}.Render()
+				if err != nil {
+					return fmt.Errorf("rendering notice document: %w", err)
+				}
+				return openDocument(
+					"notices",
+					fmt.Sprintf("rent-increase-%d-%s.html", p.lease.ID, change.Effective.Format("20060102")),
+					buffer,
+				)
+			}(); err != nil {
+				p.RentIncreaseForm.Effective.SetError(err.Error())
+			} else {
+				p.RentIncreaseForm.Clear()
+				p.modal = nil
+			}
+		}
+	}
+	if p.RentIncreaseForm.CancelBtn.Clicked() {
+		p.RentIncreaseForm.Clear()
+		p.modal = nil
+	}
+	if p.RecurringChargeForm.SubmitBtn.Clicked() {
+		if charge, ok := p.RecurringChargeForm.Submit(); ok {
+			if err := p.App.AddRecurringCharge(p.lease.ID, p.RecurringChargeForm.Service.Key, charge); err != nil {
+				p.RecurringChargeForm.Description.SetError(err.Error())
+			} else {
+				p.RecurringChargeForm.Clear()
+				p.modal = nil
+			}
 		}
 	}
+	if p.RecurringChargeForm.CancelBtn.Clicked() {
+		p.RecurringChargeForm.Clear()
+		p.modal = nil
+	}
 	for range p.Dialog.Input.Events() {
-		_, err := util.ParseCurrency(p.Dialog.Input.Text())
+		_, err := p.dialogValue()
 		if err != nil {
 			p.Dialog.Input.SetError(err.Error())
 		} else {
@@ -205,114 +500,48 @@ func (p *LeasePage) Update(gtx C) {
 		}
 	}
 	if p.Dialog.Ok.Clicked() {
-		if n, err := util.ParseCurrency(p.Dialog.Input.Text()); err != nil {
+		if v, err := p.dialogValue(); err != nil {
+			p.Dialog.Input.SetError(err.Error())
+		} else if err := p.submitDialog(v); err != nil {
+			// Keep the dialog open with the reason, so the value can be
+			// corrected.
 			p.Dialog.Input.SetError(err.Error())
+			p.Alerts.Error(err)
 		} else {
 			p.Dialog.Input.Clear()
-			// @Improvment Can we avoid stringly typed api?
-			parts := strings.Split(p.Dialog.Context, "-")
-			mode, service := parts[0], parts[1]
-			switch mode {
-			case "pay":
-				if err := p.App.PayService(p.lease.ID, service, n); err != nil {
-					log.Printf("paying service: %v", err)
-				}
-			case "bill":
-				if err := p.App.BillService(p.lease.ID, service, n); err != nil {
-					log.Printf("billing service: %v", err)
-				}
-			}
+			p.action = dialogAction{}
 			p.modal = nil
 		}
 	}
 	if p.Dialog.Cancel.Clicked() {
 		p.Dialog.Input.Clear()
+		p.action = dialogAction{}
 		p.modal = nil
 	}
 	if p.UtilitiesInvoiceForm.SubmitBtn.Clicked() {
-		if inv, ok := p.UtilitiesInvoiceForm.Submit(); ok {
-			inv.Lease = p.lease.ID
-			if err := p.App.Save(&inv); err != nil {
-				log.Printf("saving invoice: %v", err)
-			}
-			if err := p.App.BillService(
-				p.lease.ID,
-				"utilities",
-				inv.Bill,
-			); err != nil {
-				log.Printf("billing service: %v", err)
+		if inv, reading, ok := p.UtilitiesInvoiceForm.Submit(); ok {
+			def, _ := p.settings.Service(inv.Service)
+			name := fmt.Sprintf("Invoiced %s for %s", inv.Bill, def.Name)
+			if err := p.Undo.Do(name, func(app avisha.App) error {
+				return app.InvoiceReading(p.lease.ID, inv.Service, &reading, &inv)
+			}); err != nil {
+				p.UtilitiesInvoiceForm.Bill.SetError(err.Error())
+				p.Alerts.Error(err)
+			} else {
+				p.UtilitiesInvoiceForm.Clear()
+				p.modal = nil
 			}
 		}
-		p.UtilitiesInvoiceForm.Clear()
-		p.modal = nil
 	}
 	if p.UtilitiesInvoiceForm.CancelBtn.Clicked() {
 		p.UtilitiesInvoiceForm.Clear()
 		p.modal = nil
 	}
-	states := p.invoiceStates.List()
-	for ii, state := range states {
-		// @Todo Do io async to avoid blocking ui.
+	for _, state := range p.invoiceStates.List() {
 		if state.Item.Clicked() {
-			var (
-				history  []*avisha.UtilityInvoice
-				invoice  = (*avisha.UtilityInvoice)(state.Data)
-				previous avisha.UtilityInvoice
-				settings avisha.Settings
-			)
-			if ii < len(states)-1 {
-				previous = *(*avisha.UtilityInvoice)(states[ii+1].Data)
-			}
-			if s, err := p.App.LoadSettings(); err != nil {
-				log.Printf("loading settings: %v", err)
-			} else {
-				settings = s
-			}
-			if err := p.App.Select(q.Eq("Lease", p.lease.ID)).OrderBy("ID", "Paid").Reverse().Find(&history); err != nil {
-				if err != storm.ErrNotFound {
-					log.Printf("loading invoices: %v", err)
-				}
-			}
-			if len(history) > ii {
-				history = history[ii+1:]
-			}
-			if err := func() error {
-				doc := util.UtilityInvoiceDocument{
-					Invoice:  *invoice,
-					Previous: previous,
-					History:  history,
-					Lease:    p.lease,
-					Site:     site,
-					Tenant:   tenant,
-					Settings: settings,
-				}
-				buffer, err := doc.Render()
-				if err != nil {
-					return fmt.Errorf("rendering invoice document: %w", err)
-				}
-				dir, err := app.DataDir()
-				if err != nil {
-					return fmt.Errorf("locating data directory: %w", err)
-				}
-				dir = filepath.Join(dir, "invoices")
-				if err := os.MkdirAll(dir, 0777); err != nil && !os.IsNotExist(err) {
-					return fmt.Errorf("preparing directory: %w", err)
-				}
-				path := filepath.Join(dir, fmt.Sprintf("%d.html", invoice.ID))
-				if err := ioutil.WriteFile(
-					path,
-					buffer.Bytes(),
-					0777,
-				); err != nil {
-					return fmt.Errorf("writing invoice to disk: %w", err)
-				}
-				if err := open.Run(path); err != nil {
-					return fmt.Errorf("opening invoice: %w", err)
-				}
-				return nil
-			}(); err != nil {
-				log.Printf("%v", err)
-			}
+			go func(item invoiceItem, lease avisha.Lease, tenant avisha.Tenant, site avisha.Site) {
+				p.Alerts.Error(openInvoice(*p.App, item, lease, tenant, site))
+			}(*(*invoiceItem)(state.Data), p.lease, p.tenant, p.site)
 		}
 	}
 	if p.modal != nil {
@@ -324,6 +553,199 @@ func (p *LeasePage) Update(gtx C) {
 	}
 }
 
+// updateArchive asks to archive or delete the lease and its invoices.
+func (p *LeasePage) updateArchive() {
+	id := p.lease.ID
+	if p.Archive.Clicked() {
+		if p.lease.IsArchived() {
+			p.confirm.Ask(
+				"Restore Lease",
+				fmt.Sprintf("Restore lease %d to the list of leases?", id),
+				"Restore", false,
+				func() error { return p.App.RestoreLease(id) },
+			)
+		} else {
+			p.confirm.Ask(
+				"Archive Lease",
+				fmt.Sprintf("Archive lease %d? It will be hidden from the list of leases, and its history kept.", id),
+				"Archive", false,
+				func() error { return p.App.ArchiveLease(id) },
+			)
+		}
+	}
+	if p.Delete.Clicked() {
+		p.confirm.Ask(
+			"Delete Lease",
+			fmt.Sprintf("Delete lease %d for good? This can't be undone.", id),
+			"Delete", true,
+			func() error {
+				if err := p.App.DeleteLease(id); err != nil {
+					return err
+				}
+				p.Route.Back()
+				return nil
+			},
+		)
+	}
+	for key, actions := range p.invoiceActions {
+		var (
+			key  = key
+			name = fmt.Sprintf("invoice #%d", key.ID)
+		)
+		if actions.Archive.Clicked() {
+			if actions.item.IsArchived() {
+				p.confirm.Ask(
+					"Restore Invoice",
+					fmt.Sprintf("Restore %s to the list of invoices?", name),
+					"Restore", false,
+					func() error { return p.App.RestoreInvoice(key.Service, key.ID) },
+				)
+			} else {
+				p.confirm.Ask(
+					"Archive Invoice",
+					fmt.Sprintf("Archive %s? It will be hidden from the list of invoices, but is still owed until paid.", name),
+					"Archive", false,
+					func() error { return p.App.ArchiveInvoice(key.Service, key.ID) },
+				)
+			}
+		}
+		if actions.Delete.Clicked() {
+			p.confirm.Ask(
+				"Delete Invoice",
+				fmt.Sprintf("Delete %s for good and take its bill of %s off the ledger? This can't be undone.", name, actions.item.Bill),
+				"Delete", true,
+				func() error { return p.App.DeleteInvoice(key.Service, key.ID) },
+			)
+		}
+	}
+	p.confirm.Update()
+}
+
+// updateSubscriptions subscribes or unsubscribes the lease from services as
+// they are toggled.
+func (p *LeasePage) updateSubscriptions() {
+	for key, toggle := range p.subscriptions {
+		if !toggle.Changed() {
+			continue
+		}
+		if toggle.Value {
+			if err := p.App.Subscribe(p.lease.ID, key); err != nil {
+				p.Alerts.Error(fmt.Errorf("subscribing to %s: %w", key, err))
+			}
+		} else {
+			if err := p.App.Unsubscribe(p.lease.ID, key); err != nil {
+				p.Alerts.Error(fmt.Errorf("unsubscribing from %s: %w", key, err))
+			}
+		}
+	}
+}
+
+// showRentIncrease opens the form to schedule a rent increase.
+func (p *LeasePage) showRentIncrease() {
+	p.RentIncreaseForm.Load(p.lease, p.settings)
+	p.modal = func(gtx C) D {
+		return style.ModalDialog(gtx, p.Th, unit.Dp(700), "Increase Rent", func(gtx C) D {
+			return p.RentIncreaseForm.Layout(gtx, p.Th)
+		})
+	}
+}
+
+// showDialog opens the input dialog to collect a value for the action.
+func (p *LeasePage) showDialog(
+	action dialogAction,
+	title, hint string,
+	prefix, suffix layout.Widget,
+) {
+	p.action = action
+	p.Dialog.Dated = action.Kind == actionTerminate || action.Kind == actionBillRent
+	p.Dialog.Calendar.Close()
+	// Neither a lease can end, nor its rent be billed, before it starts.
+	p.Dialog.Calendar.Min = p.lease.Term.Start
+	p.Dialog.Calendar.Quick = nil
+	if days := int(p.settings.Defaults.NoticePeriod.Hours() / 24); action.Kind == actionTerminate && days > 0 {
+		p.Dialog.Calendar.Quick = []int{days}
+	}
+	// Payments are dated, today unless made earlier.
+	p.Dialog.Stamped = action.Kind == actionPay
+	p.Dialog.When.Close()
+	p.Dialog.When.Min = p.lease.Term.Start
+	p.Dialog.When.Max = time.Now()
+	p.Dialog.When.SetText(util.FormatTime(time.Now()))
+	p.Dialog.When.ClearError()
+	p.modal = func(gtx C) D {
+		return style.ModalDialog(gtx, p.Th, unit.Dp(700), title, func(gtx C) D {
+			p.Dialog.Input.Prefix = prefix
+			p.Dialog.Input.Suffix = suffix
+			th := p.Th.Primary()
+			if action.Kind == actionTerminate {
+				th = p.Th.Danger()
+			}
+			return p.Dialog.Layout(gtx, th, hint)
+		})
+	}
+}
+
+func (p *LeasePage) dollarPrefix(gtx C) D {
+	return material.Label(p.Th.Dark(), p.Th.TextSize, "$").Layout(gtx)
+}
+
+func (p *LeasePage) daysSuffix(gtx C) D {
+	return material.Label(p.Th.Muted(), p.Th.TextSize, " days").Layout(gtx)
+}
+
+// submitDialog performs the dialog action with the parsed value, such that it
+// can be undone.
+func (p *LeasePage) submitDialog(v interface{}) error {
+	var (
+		id      = p.lease.ID
+		service = p.action.Service
+	)
+	switch p.action.Kind {
+	case actionPay:
+		payment := v.(avisha.Payment)
+		if err := p.Undo.Do(fmt.Sprintf("Paid %s to %s", payment.Amount, service.Name), func(app avisha.App) error {
+			return app.PayService(id, service.Key, payment.Amount, payment.Time)
+		}); err != nil {
+			return fmt.Errorf("paying service: %w", err)
+		}
+	case actionBill:
+		amount := v.(currency.Currency)
+		if err := p.Undo.Do(fmt.Sprintf("Billed %s for %s", amount, service.Name), func(app avisha.App) error {
+			return app.BillService(id, service.Key, amount, time.Now())
+		}); err != nil {
+			return fmt.Errorf("billing service: %w", err)
+		}
+	case actionBillRent:
+		period := avisha.Term{Start: v.(time.Time), Duration: p.settings.Defaults.RentCycle}
+		if err := p.Undo.Do(fmt.Sprintf("Invoiced rent from %s", util.FormatTime(period.Start)), func(app avisha.App) error {
+			_, err := app.InvoiceRent(id, period, time.Now())
+			return err
+		}); err != nil {
+			return fmt.Errorf("invoicing rent: %w", err)
+		}
+	case actionNotice:
+		if err := p.Undo.Do("Gave notice", func(app avisha.App) error {
+			return app.GiveNotice(id, time.Now(), v.(time.Duration))
+		}); err != nil {
+			return fmt.Errorf("giving notice: %w", err)
+		}
+	case actionTerminate:
+		if err := p.Undo.Do("Terminated lease", func(app avisha.App) error {
+			return app.TerminateLease(id, v.(time.Time))
+		}); err != nil {
+			return fmt.Errorf("terminating lease: %w", err)
+		}
+	case actionRenew:
+		term := avisha.Term{Start: p.lease.Term.End(), Duration: v.(time.Duration)}
+		if err := p.Undo.Do("Renewed lease", func(app avisha.App) error {
+			return app.RenewLease(id, term)
+		}); err != nil {
+			return fmt.Errorf("renewing lease: %w", err)
+		}
+	}
+	return nil
+}
+
 func (p *LeasePage) Layout(gtx C) D {
 	p.scroll.Axis = layout.Vertical
 	p.scroll.ScrollToEnd = false
@@ -394,20 +816,151 @@ func (p *LeasePage) LayoutDetails(gtx C) D {
 		layout.Rigid(func(gtx C) D {
 			return D{Size: image.Point{Y: gtx.Px(unit.Dp(10))}}
 		}),
+		layout.Rigid(func(gtx C) D {
+			if p.lease.ID == 0 {
+				return D{}
+			}
+			return layout.Inset{Bottom: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+				return p.LayoutLifecycle(gtx)
+			})
+		}),
 		layout.Rigid(func(gtx C) D {
 			return p.Form.Layout(gtx, p.Th)
 		}),
 	)
 }
 
-// LayoutServices lays the service widgets in flex.
+// LayoutLifecycle renders the lease status and the actions available to
+// move the lease through its lifecycle.
+func (p *LeasePage) LayoutLifecycle(gtx C) D {
+	var (
+		status  = p.lease.StatusAt(time.Now())
+		actions []layout.FlexChild
+		action  = func(th *material.Theme, btn *widget.Clickable, label string) {
+			if len(actions) > 0 {
+				actions = append(actions, layout.Rigid(func(gtx C) D {
+					return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+				}))
+			}
+			actions = append(actions, layout.Rigid(func(gtx C) D {
+				b := material.Button(th, btn, label)
+				b.Inset = layout.UniformInset(unit.Dp(5))
+				return b.Layout(gtx)
+			}))
+		}
+	)
+	switch status {
+	case avisha.Draft:
+		action(p.Th.Success(), &p.Activate, "Activate")
+	case avisha.Active, avisha.Periodic:
+		action(p.Th.Primary(), &p.Renew, "Renew")
+		action(p.Th.Warning(), &p.GiveNotice, "Give Notice")
+		action(p.Th.Danger(), &p.Terminate, "Terminate")
+	case avisha.NoticeGiven:
+		action(p.Th.Primary(), &p.Renew, "Renew")
+		action(p.Th.Danger(), &p.Terminate, "Terminate")
+	case avisha.Ended:
+		// The notice has taken effect: terminating closes the services for
+		// their final invoices.
+		if p.lease.Status == avisha.NoticeGiven {
+			action(p.Th.Danger(), &p.Terminate, "Terminate")
+		}
+	}
+	return style.Card{
+		Content: []layout.Widget{
+			func(gtx C) D {
+				label := fmt.Sprintf("Status: %s", status)
+				switch status {
+				case avisha.NoticeGiven:
+					label = fmt.Sprintf("%s (ends %s)", label, util.FormatTime(p.lease.Notice.Effective()))
+				case avisha.Ended:
+					label = fmt.Sprintf("%s (%s)", label, util.FormatTime(p.lease.Ends()))
+				}
+				return material.H6(p.Th.Dark(), label).Layout(gtx)
+			},
+			func(gtx C) D {
+				if len(p.lease.History) == 0 {
+					return D{}
+				}
+				return material.Label(
+					p.Th.Muted(),
+					unit.Dp(14),
+					fmt.Sprintf("Renewed %d time(s), first term %s", len(p.lease.History), p.lease.History[0]),
+				).Layout(gtx)
+			},
+			func(gtx C) D {
+				return layout.Flex{
+					Axis:      layout.Horizontal,
+					Alignment: layout.Middle,
+				}.Layout(gtx, actions...)
+			},
+		},
+	}.Layout(gtx, p.Th.Dark())
+}
+
+// dialogValue parses the dialog input according to the dialog action.
+func (p *LeasePage) dialogValue() (interface{}, error) {
+	text := p.Dialog.Input.Text()
+	switch p.action.Kind {
+	case actionNotice, actionRenew:
+		return util.ParseDay(text)
+	case actionTerminate, actionBillRent:
+		return p.Dialog.Calendar.Parse(text)
+	case actionPay:
+		amount, err := util.ParseCurrency(text)
+		if err != nil {
+			return nil, err
+		}
+		paid, err := p.Dialog.When.Parse(p.Dialog.When.Text())
+		if err != nil {
+			p.Dialog.When.SetError(err.Error())
+			return nil, fmt.Errorf("date paid: %w", err)
+		}
+		p.Dialog.When.ClearError()
+		return avisha.Payment{Amount: amount, Time: paid}, nil
+	default:
+		return util.ParseCurrency(text)
+	}
+}
+
+// LayoutServices lays a card for each subscribed service in a grid.
 func (p *LeasePage) LayoutServices(gtx C) D {
 	var (
-		cs   = &gtx.Constraints
-		axis = layout.Horizontal
+		cs      = &gtx.Constraints
+		columns = 2
+		defs    = p.settings.Subscribed(p.lease)
+		rows    []layout.FlexChild
 	)
 	if breakpoint := gtx.Px(unit.Dp(350)); cs.Max.X < breakpoint {
-		axis = layout.Vertical
+		columns = 1
+	}
+	for ii := 0; ii < len(defs); ii += columns {
+		var cells []layout.FlexChild
+		for jj := ii; jj < ii+columns; jj++ {
+			if len(cells) > 0 {
+				cells = append(cells, layout.Rigid(func(gtx C) D {
+					return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+				}))
+			}
+			if jj >= len(defs) {
+				cells = append(cells, layout.Flexed(1, func(gtx C) D {
+					return D{Size: gtx.Constraints.Min}
+				}))
+				continue
+			}
+			def := defs[jj]
+			cells = append(cells, layout.Flexed(1, func(gtx C) D {
+				return p.LayoutService(gtx, def)
+			}))
+		}
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return layout.Inset{Bottom: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+				return layout.Flex{
+					Axis:      layout.Horizontal,
+					Alignment: layout.Start,
+				}.Layout(gtx, cells...)
+			})
+		}))
 	}
 	return layout.Flex{
 		Axis: layout.Vertical,
@@ -420,92 +973,346 @@ func (p *LeasePage) LayoutServices(gtx C) D {
 			return D{Size: image.Point{X: gtx.Px(unit.Dp(10)), Y: gtx.Px(unit.Dp(10))}}
 		}),
 		layout.Rigid(func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return p.LayoutSubscriptions(gtx)
+		}),
+	)
+}
+
+// LayoutService renders the card for a subscribed service.
+func (p *LeasePage) LayoutService(gtx C, def avisha.ServiceDefinition) D {
+	var (
+		card    = p.card(def.Key)
+		service = p.lease.Services[def.Key]
+		title   = def.Name
+		actions = []layout.FlexChild{
+			layout.Flexed(1, func(gtx C) D {
+				b := material.Button(p.Th.Success(), &card.Pay, "Pay")
+				b.Inset = layout.UniformInset(unit.Dp(5))
+				return b.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+			}),
+			layout.Flexed(1, func(gtx C) D {
+				b := material.Button(p.Th.Danger(), &card.Bill, "Bill")
+				b.Inset = layout.UniformInset(unit.Dp(5))
+				return b.Layout(gtx)
+			}),
+		}
+	)
+	if service.ClosedBy(time.Now()) {
+		title = fmt.Sprintf("%s (closed)", title)
+	} else if !service.Closed.IsZero() {
+		title = fmt.Sprintf("%s (closes %s)", title, util.FormatTime(service.Closed))
+	}
+	if def.Kind == avisha.Rental {
+		actions = append(
+			actions,
+			layout.Rigid(func(gtx C) D {
+				return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+			}),
+			layout.Flexed(1, func(gtx C) D {
+				b := material.Button(p.Th.Warning(), &card.Increase, "Increase")
+				b.Inset = layout.UniformInset(unit.Dp(5))
+				return b.Layout(gtx)
+			}),
+		)
+	}
+	return style.Card{
+		Content: []layout.Widget{
+			func(gtx C) D {
+				return material.H6(p.Th.Dark(), title).Layout(gtx)
+			},
+			func(gtx C) D {
+				return style.ServiceLabel(p.Th, "Balance", service.Balance()).Layout(gtx)
+			},
+			func(gtx C) D {
+				var summary string
+				switch def.Kind {
+				case avisha.Rental:
+					summary = p.rentSummary(time.Now())
+				case avisha.Metered:
+					summary = fmt.Sprintf("%s per unit (%s)", def.Price, def.Tax)
+				default:
+					summary = fmt.Sprintf("%s per cycle (%s)", def.Price, def.Tax)
+				}
+				return material.Body1(p.Th.Muted(), summary).Layout(gtx)
+			},
+			func(gtx C) D {
+				if def.Kind != avisha.Rental || p.lease.Status == avisha.Draft {
+					return D{}
+				}
+				position := p.position
+				if position == nil {
+					return D{}
+				}
+				th := p.Th.Success()
+				if position.InArrears() {
+					th = p.Th.Danger()
+				}
+				return material.Body1(th, position.String()).Layout(gtx)
+			},
+			func(gtx C) D {
+				return p.LayoutCharges(gtx, card, service)
+			},
+			func(gtx C) D {
+				return layout.Flex{
+					Axis:      layout.Horizontal,
+					Alignment: layout.Middle,
+				}.Layout(gtx, actions...)
+			},
+		},
+	}.Layout(gtx, p.Th.Dark())
+}
+
+// LayoutCharges lists the recurring charges of a service, with an action to
+// add another.
+func (p *LeasePage) LayoutCharges(gtx C, card *serviceCard, service avisha.Service) D {
+	if len(card.RemoveCharge) != len(service.Charges) {
+		card.RemoveCharge = make([]widget.Clickable, len(service.Charges))
+	}
+	rows := make([]layout.FlexChild, 0, len(service.Charges)+1)
+	for ii, c := range service.Charges {
+		var (
+			remove  = &card.RemoveCharge[ii]
+			summary = fmt.Sprintf(
+				"%s: %s %s from %s",
+				c.Description,
+				c.Amount,
+				strings.ToLower(c.Frequency.String()),
+				util.FormatTime(c.Start),
+			)
+		)
+		if !c.End.IsZero() {
+			summary += fmt.Sprintf(" until %s", util.FormatTime(c.End))
+		}
+		rows = append(rows, layout.Rigid(func(gtx C) D {
 			return layout.Flex{
-				Axis:      axis,
-				Spacing:   layout.SpaceBetween,
-				Alignment: layout.Start,
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
 			}.Layout(
 				gtx,
-				util.FlexStrategy(1, layout.Horizontal, axis, func(gtx C) D {
-					return style.Card{
-						Content: []layout.Widget{
-							func(gtx C) D {
-								return material.H6(p.Th.Dark(), "Utilities").Layout(gtx)
-							},
-							func(gtx C) D {
-								var balance currency.Currency
-								if service, ok := p.lease.Services["utilities"]; ok {
-									balance = service.Balance()
-								}
-								return style.ServiceLabel(p.Th, "Balance", balance).Layout(gtx)
-							},
-							func(gtx C) D {
-								return layout.Flex{
-									Axis:      layout.Horizontal,
-									Alignment: layout.Middle,
-								}.Layout(
-									gtx,
-									layout.Flexed(1, func(gtx C) D {
-										b := material.Button(p.Th.Success(), &p.PayUtility, "Pay")
-										b.Inset = layout.UniformInset(unit.Dp(5))
-										return b.Layout(gtx)
-									}),
-									layout.Rigid(func(gtx C) D {
-										return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
-									}),
-									layout.Flexed(1, func(gtx C) D {
-										b := material.Button(p.Th.Danger(), &p.BillUtility, "Bill")
-										b.Inset = layout.UniformInset(unit.Dp(5))
-										return b.Layout(gtx)
-									}),
-								)
-							},
-						},
-					}.Layout(gtx, p.Th.Dark())
+				layout.Flexed(1, func(gtx C) D {
+					return material.Body2(p.Th.Dark(), summary).Layout(gtx)
 				}),
 				layout.Rigid(func(gtx C) D {
-					return D{Size: image.Point{X: gtx.Px(unit.Dp(10)), Y: gtx.Px(unit.Dp(10))}}
-				}),
-				util.FlexStrategy(1, layout.Horizontal, axis, func(gtx C) D {
-					return style.Card{
-						Content: []layout.Widget{
-							func(gtx C) D {
-								return material.H6(p.Th.Dark(), "Rent").Layout(gtx)
-							},
-							func(gtx C) D {
-								var balance currency.Currency
-								if service, ok := p.lease.Services["rent"]; ok {
-									balance = service.Balance()
-								}
-								return style.ServiceLabel(p.Th, "Balance", balance).Layout(gtx)
-							},
-							func(gtx C) D {
-								return layout.Flex{
-									Axis:      layout.Horizontal,
-									Alignment: layout.Middle,
-								}.Layout(
-									gtx,
-									layout.Flexed(1, func(gtx C) D {
-										b := material.Button(p.Th.Success(), &p.PayRent, "Pay")
-										b.Inset = layout.UniformInset(unit.Dp(5))
-										return b.Layout(gtx)
-									}),
-									layout.Rigid(func(gtx C) D {
-										return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
-									}),
-									layout.Flexed(1, func(gtx C) D {
-										b := material.Button(p.Th.Danger(), &p.BillRent, "Bill")
-										b.Inset = layout.UniformInset(unit.Dp(5))
-										return b.Layout(gtx)
-									}),
-								)
-							},
-						},
-					}.Layout(gtx, p.Th.Dark())
+					b := material.Button(p.Th.Danger(), remove, "Remove")
+					b.Inset = layout.UniformInset(unit.Dp(5))
+					return b.Layout(gtx)
 				}),
 			)
+		}))
+	}
+	rows = append(rows, layout.Rigid(func(gtx C) D {
+		return layout.Inset{Top: unit.Dp(5), Bottom: unit.Dp(5)}.Layout(gtx, func(gtx C) D {
+			b := material.Button(p.Th.Secondary(), &card.Charge, "Add Charge")
+			b.Inset = layout.UniformInset(unit.Dp(5))
+			return b.Layout(gtx)
+		})
+	}))
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
+
+// LayoutSubscriptions renders a toggle for each service in the catalogue.
+func (p *LeasePage) LayoutSubscriptions(gtx C) D {
+	var toggles []layout.FlexChild
+	for _, def := range p.settings.Services {
+		var (
+			def     = def
+			toggle  = p.subscription(def.Key)
+			service = p.lease.Services[def.Key]
+			_, ok   = p.lease.Services[def.Key]
+		)
+		toggle.Value = ok && !service.ClosedBy(time.Now())
+		toggles = append(toggles, layout.Rigid(func(gtx C) D {
+			return material.CheckBox(p.Th.Dark(), toggle, def.Name).Layout(gtx)
+		}))
+	}
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
+		layout.Rigid(func(gtx C) D {
+			return material.Label(p.Th.Muted(), unit.Dp(14), "Subscribed Services").Layout(gtx)
 		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(gtx, toggles...)
+		}),
+	)
+}
+
+// card returns the action state for the service card.
+func (p *LeasePage) card(key avisha.ServiceKey) *serviceCard {
+	if p.services == nil {
+		p.services = make(map[avisha.ServiceKey]*serviceCard)
+	}
+	card, ok := p.services[key]
+	if !ok {
+		card = &serviceCard{}
+		p.services[key] = card
+	}
+	return card
+}
+
+// subscription returns the toggle state for the service subscription.
+func (p *LeasePage) subscription(key avisha.ServiceKey) *widget.Bool {
+	if p.subscriptions == nil {
+		p.subscriptions = make(map[avisha.ServiceKey]*widget.Bool)
+	}
+	toggle, ok := p.subscriptions[key]
+	if !ok {
+		toggle = &widget.Bool{}
+		p.subscriptions[key] = toggle
+	}
+	return toggle
+}
+
+// invoiceItem is an invoice for any of the lease services.
+type invoiceItem struct {
+	*avisha.Invoice
+	Utility *avisha.UtilityInvoice
+	Rent    *avisha.RentInvoice
+}
+
+// key identifies the invoice.
+func (item invoiceItem) key() invoiceKey {
+	if item.Utility != nil {
+		return invoiceKey{Service: item.Utility.Service, ID: item.ID}
+	}
+	return invoiceKey{Service: avisha.ServiceRent, ID: item.ID}
+}
+
+// loadInvoices loads the invoices of every service of the lease, most recent
+// first.
+func loadInvoices(app avisha.App, leaseID int) (list []invoiceItem, err error) {
+	var (
+		utilities []*avisha.UtilityInvoice
+		rent      []*avisha.RentInvoice
+	)
+	if err := app.Select(q.Eq("Lease", leaseID)).Find(&utilities); err != nil {
+		if err != storm.ErrNotFound {
+			return nil, fmt.Errorf("loading utility invoices: %w", err)
+		}
+	}
+	if err := app.Select(q.Eq("Lease", leaseID)).Find(&rent); err != nil {
+		if err != storm.ErrNotFound {
+			return nil, fmt.Errorf("loading rent invoices: %w", err)
+		}
+	}
+	for _, inv := range utilities {
+		list = append(list, invoiceItem{Invoice: &inv.Invoice, Utility: inv})
+	}
+	for _, inv := range rent {
+		list = append(list, invoiceItem{Invoice: &inv.Invoice, Rent: inv})
+	}
+	sort.SliceStable(list, func(ii, jj int) bool {
+		return list[ii].Issued.After(list[jj].Issued)
+	})
+	return list, nil
+}
+
+// openInvoice renders the invoice document and opens it.
+func openInvoice(app avisha.App, item invoiceItem, lease avisha.Lease, tenant avisha.Tenant, site avisha.Site) error {
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	var (
+		buffer *bytes.Buffer
+		name   string
 	)
+	switch {
+	case item.Rent != nil:
+		name = fmt.Sprintf("rent-%d.html", item.ID)
+		var position avisha.RentPosition
+		if position, err = app.RentPosition(lease.ID, time.Now()); err != nil {
+			return fmt.Errorf("calculating rent position: %w", err)
+		}
+		buffer, err = util.RentInvoiceDocument{
+			Invoice:  *item.Rent,
+			Position: &position,
+			Lease:    lease,
+			Site:     site,
+			Tenant:   tenant,
+			Settings: settings,
+		}.Render()
+	case item.Utility != nil:
+		var history []*avisha.UtilityInvoice
+		if err := app.Select(
+			q.Eq("Lease", lease.ID),
+			q.Eq("Service", item.Utility.Service),
+			q.Lt("ID", item.ID),
+		).OrderBy("ID", "Paid").Reverse().Find(&history); err != nil {
+			if err != storm.ErrNotFound {
+				return fmt.Errorf("loading invoices: %w", err)
+			}
+		}
+		name = fmt.Sprintf("%d.html", item.ID)
+		buffer, err = util.UtilityInvoiceDocument{
+			Invoice:  *item.Utility,
+			History:  history,
+			Lease:    lease,
+			Site:     site,
+			Tenant:   tenant,
+			Settings: settings,
+		}.Render()
+	}
+	if err != nil {
+		return fmt.Errorf("rendering invoice document: %w", err)
+	}
+	return openDocument("invoices", name, buffer)
+}
+
+// openDocument writes the document into the named folder of the data
+// directory and opens it with the default application.
+func openDocument(folder, name string, buffer *bytes.Buffer) error {
+	dir, err := app.DataDir()
+	if err != nil {
+		return fmt.Errorf("locating data directory: %w", err)
+	}
+	dir = filepath.Join(dir, folder)
+	if err := os.MkdirAll(dir, 0777); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("preparing directory: %w", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(
+		path,
+		buffer.Bytes(),
+		0777,
+	); err != nil {
+		return fmt.Errorf("writing document to disk: %w", err)
+	}
+	if err := open.Run(path); err != nil {
+		return fmt.Errorf("opening document: %w", err)
+	}
+	return nil
+}
+
+// invoiceButton renders a small icon button for an action on an invoice.
+func (p *LeasePage) invoiceButton(gtx C, state *widget.Clickable, icon *widget.Icon) D {
+	btn := material.IconButton(p.Th.Muted(), state, icon)
+	btn.Size = unit.Dp(16)
+	btn.Inset = layout.UniformInset(unit.Dp(4))
+	btn.Background = style.WithAlpha(btn.Background, 0)
+	btn.Color = p.Th.Muted().Fg
+	return layout.Inset{Left: unit.Dp(5)}.Layout(gtx, btn.Layout)
+}
+
+// rentSummary describes the rent in effect and any scheduled changes.
+func (p *LeasePage) rentSummary(now time.Time) string {
+	summary := fmt.Sprintf("%s per week", p.lease.RentAt(now))
+	for _, c := range p.lease.Rents {
+		if c.Effective.After(now) {
+			summary += fmt.Sprintf(", %s from %s", c.Amount, util.FormatTime(c.Effective))
+		}
+	}
+	return summary
 }
 
 // LayoutInvoiceList renders a list of invoices issued for the lease.
@@ -513,12 +1320,13 @@ func (p *LeasePage) LayoutInvoiceList(gtx C) D {
 	p.invoiceList.Axis = layout.Vertical
 	p.invoiceList.ScrollToEnd = false
 	p.invoiceStates.Begin()
-	var (
-		invoices []*avisha.UtilityInvoice
-	)
-	if err := p.App.Select(q.Eq("Lease", p.lease.ID)).OrderBy("ID", "Paid").Reverse().Find(&invoices); err != nil {
-		if err != storm.ErrNotFound {
-			log.Printf("loading invoices: %v", err)
+	if p.invoiceActions == nil {
+		p.invoiceActions = make(map[invoiceKey]*invoiceActions)
+	}
+	var invoices []invoiceItem
+	for _, item := range p.invoices {
+		if !item.IsArchived() || p.ArchivedInvoices.Value {
+			invoices = append(invoices, item)
 		}
 	}
 	return layout.Flex{
@@ -526,7 +1334,18 @@ func (p *LeasePage) LayoutInvoiceList(gtx C) D {
 	}.Layout(
 		gtx,
 		layout.Rigid(func(gtx C) D {
-			return material.Label(p.Th.Dark(), unit.Dp(20), "Invoices").Layout(gtx)
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				layout.Flexed(1, func(gtx C) D {
+					return material.Label(p.Th.Dark(), unit.Dp(20), "Invoices").Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return style.Chip(p.Th.Primary(), &p.ArchivedInvoices, "Archived").Layout(gtx)
+				}),
+			)
 		}),
 		layout.Rigid(func(gtx C) D {
 			return D{Size: image.Point{X: gtx.Px(unit.Dp(10)), Y: gtx.Px(unit.Dp(10))}}
@@ -534,53 +1353,90 @@ func (p *LeasePage) LayoutInvoiceList(gtx C) D {
 		layout.Rigid(func(gtx C) D {
 			return p.invoiceList.Layout(gtx, len(invoices), func(gtx C, ii int) D {
 				var (
-					invoice = invoices[ii]
+					invoice = &invoices[ii]
 					state   = p.invoiceStates.Next(unsafe.Pointer(invoice))
 					active  = false
+					service = "Rent"
+					actions = p.invoiceActions[invoice.key()]
+					status  string
 				)
-				return style.ListItem(
+				if invoice.Utility != nil {
+					def, _ := p.settings.Service(invoice.Utility.Service)
+					service = def.Name
+				}
+				if actions == nil {
+					actions = &invoiceActions{}
+					p.invoiceActions[invoice.key()] = actions
+				}
+				actions.item = *invoice
+				if invoice.IsArchived() {
+					status = " (archived)"
+				}
+				// The actions sit beside the item so its click doesn't swallow
+				// theirs.
+				return layout.Flex{
+					Axis:      layout.Horizontal,
+					Alignment: layout.Middle,
+				}.Layout(
 					gtx,
-					p.Th.Dark(),
-					&state.Item,
-					&state.Hover,
-					active,
-					func(gtx C) D {
-						return layout.Flex{
-							Axis: layout.Horizontal,
-						}.Layout(
+					layout.Flexed(1, func(gtx C) D {
+						return style.ListItem(
 							gtx,
-							layout.Flexed(3, func(gtx C) D {
-								return material.Label(
-									p.Th.Dark(),
-									unit.Dp(14),
-									fmt.Sprintf(
-										"#%d %s (%d %s %d)",
-										invoice.ID,
-										invoice.Bill,
-										invoice.Issued.Day(),
-										invoice.Issued.Month(),
-										invoice.Issued.Year()),
-								).Layout(gtx)
-							}),
-							layout.Rigid(func(gtx C) D {
-								var (
-									badge = "PAID"
-									c     = p.Th.Success().Fg
-								)
-								if invoice.Paid == (time.Time{}) {
-									badge = "NOT PAID"
-									c = p.Th.Danger().Fg
-								}
-								lb := material.Label(
-									p.Th.Dark(),
-									unit.Dp(14),
-									badge,
+							p.Th.Dark(),
+							&state.Item,
+							&state.Hover,
+							active,
+							func(gtx C) D {
+								return layout.Flex{
+									Axis: layout.Horizontal,
+								}.Layout(
+									gtx,
+									layout.Flexed(3, func(gtx C) D {
+										return material.Label(
+											p.Th.Dark(),
+											unit.Dp(14),
+											fmt.Sprintf(
+												"%s #%d %s (%d %s %d)%s",
+												service,
+												invoice.ID,
+												invoice.Bill,
+												invoice.Issued.Day(),
+												invoice.Issued.Month(),
+												invoice.Issued.Year(),
+												status),
+										).Layout(gtx)
+									}),
+									layout.Rigid(func(gtx C) D {
+										var (
+											badge = "PAID"
+											c     = p.Th.Success().Fg
+										)
+										if invoice.Paid == (time.Time{}) {
+											badge = "NOT PAID"
+											c = p.Th.Danger().Fg
+										}
+										lb := material.Label(
+											p.Th.Dark(),
+											unit.Dp(14),
+											badge,
+										)
+										lb.Color = c
+										return lb.Layout(gtx)
+									}),
 								)
-								lb.Color = c
-								return lb.Layout(gtx)
-							}),
+							},
 						)
-					},
+					}),
+					layout.Rigid(func(gtx C) D {
+						icon := icons.Archive
+						if invoice.IsArchived() {
+							icon = icons.Unarchive
+						}
+						return p.invoiceButton(gtx, &actions.Archive, icon)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return p.invoiceButton(gtx, &actions.Delete, icons.Delete)
+					}),
 				)
 			})
 		}),
@@ -590,61 +1446,3 @@ func (p *LeasePage) LayoutInvoiceList(gtx C) D {
 func (p *LeasePage) Unfocus() {
 	p.dummy.Focus()
 }
-
-// TenantValuer maps tenant name to ID.
-//
-// @Todo How to handle several tenants with the same name?
-type TenantValuer struct {
-	ID  *int
-	App *avisha.App
-}
-
-func (v TenantValuer) To() (string, error) {
-	var t avisha.Tenant
-	if err := v.App.One("ID", *v.ID, &t); err != nil {
-		return "", err
-	}
-	return t.Name, nil
-}
-
-func (v TenantValuer) From(text string) error {
-	var t avisha.Tenant
-	if err := v.App.One("Name", text, &t); err != nil {
-		return err
-	}
-	*v.ID = t.ID
-	return nil
-}
-
-func (v TenantValuer) Clear() {
-	*v.ID = -1
-}
-
-// SiteValuer maps site number to ID.
-//
-// @Todo How to handle several sites with the same number?
-type SiteValuer struct {
-	ID  *int
-	App *avisha.App
-}
-
-func (v SiteValuer) To() (string, error) {
-	var s avisha.Site
-	if err := v.App.One("ID", *v.ID, &s); err != nil {
-		return "", err
-	}
-	return s.Number, nil
-}
-
-func (v SiteValuer) From(text string) error {
-	var s avisha.Site
-	if err := v.App.One("Number", text, &s); err != nil {
-		return err
-	}
-	*v.ID = s.ID
-	return nil
-}
-
-func (v SiteValuer) Clear() {
-	*v.ID = -1
-}
@@ -1,7 +1,9 @@
 package views
 
 import (
+	"fmt"
 	"image"
+	"strings"
 
 	"gioui.org/layout"
 	"gioui.org/unit"
@@ -15,20 +17,41 @@ import (
 // LeaseForm performs data mutations on a Lease entity.
 type LeaseForm struct {
 	App *avisha.App
+	// Undo records the tenants created from the form so they can be undone.
+	Undo *Undo
+	// Invalidate requests a frame once the options of a picker have loaded.
+	Invalidate func()
 
 	Lease avisha.Lease
+	// Settings are those loaded by the page, for the defaults of the tenants
+	// created from the form.
+	Settings avisha.Settings
 
 	// Form fields.
-	Tenant materials.TextField
-	Site   materials.TextField
-	Date   materials.TextField
+	// Tenant and Site are picked by ID, from those matching the text typed.
+	Tenant widget.Picker
+	Site   widget.Picker
+	Date   style.DatePicker
 	Days   materials.TextField
 	Rent   materials.TextField
+	// Invoiced locks the starting rent once rent has been invoiced, after
+	// which IncreaseRent schedules a rent increase instead.
+	Invoiced     bool
+	IncreaseRent widget.Clickable
 
 	// Actions.
 	Form      widget.Form
 	SubmitBtn widget.Clickable
 	CancelBtn widget.Clickable
+	DraftBtn  widget.Clickable
+
+	// tenants and sites load the options of the pickers for the text typed.
+	tenants Loader
+	sites   Loader
+	// tenant and site are the options of the records of the lease, once the
+	// page has loaded them.
+	tenant widget.Option
+	site   widget.Option
 }
 
 // Submit validates the input data and returns a boolean indicating validity.
@@ -43,17 +66,19 @@ func (l *LeaseForm) Clear() {
 // Load form data from a lease entity.
 func (l *LeaseForm) Load(lease avisha.Lease) {
 	l.Lease = lease
+	l.Tenant.Search = l.searchTenants
+	l.Site.Search = l.searchSites
 	l.Form.Load([]widget.Field{
 		{
-			Value: TenantValuer{ID: &l.Lease.Tenant, App: l.App},
+			Value: widget.PickerValuer{ID: &l.Lease.Tenant, Picker: &l.Tenant, Lookup: l.lookupTenant},
 			Input: &l.Tenant,
 		},
 		{
-			Value: SiteValuer{ID: &l.Lease.Site, App: l.App},
+			Value: widget.PickerValuer{ID: &l.Lease.Site, Picker: &l.Site, Lookup: l.lookupSite},
 			Input: &l.Site,
 		},
 		{
-			Value: widget.DateValuer{Value: &l.Lease.Term.Start},
+			Value: style.DateValuer{Value: &l.Lease.Term.Start, Calendar: &l.Date.Calendar},
 			Input: &l.Date,
 		},
 		{
@@ -84,13 +109,15 @@ func (l *LeaseForm) Layout(gtx C, th *style.Theme) D {
 					}.Layout(
 						gtx,
 						layout.Flexed(1, func(gtx C) D {
-							return l.Tenant.Layout(gtx, th.Dark(), "Tenant")
+							picker := style.Picker(th, &l.Tenant, "Tenant")
+							picker.CreateLabel = "New tenant"
+							return picker.Layout(gtx)
 						}),
 						layout.Rigid(func(gtx C) D {
 							return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
 						}),
 						layout.Flexed(1, func(gtx C) D {
-							return l.Site.Layout(gtx, th.Dark(), "Site")
+							return style.Picker(th, &l.Site, "Site").Layout(gtx)
 						}),
 					)
 				}),
@@ -104,10 +131,13 @@ func (l *LeaseForm) Layout(gtx C, th *style.Theme) D {
 					return l.Days.Layout(gtx, th.Dark(), "Duration")
 				}),
 				layout.Rigid(func(gtx C) D {
+					if l.Invoiced {
+						return l.layoutLockedRent(gtx, th)
+					}
 					l.Rent.Prefix = func(gtx C) D {
 						return material.Body1(th.Dark(), "$").Layout(gtx)
 					}
-					return l.Rent.Layout(gtx, th.Dark(), "Rent (weekly)")
+					return l.Rent.Layout(gtx, th.Dark(), "Starting Rent (weekly)")
 				}),
 			)
 		}),
@@ -133,8 +163,189 @@ func (l *LeaseForm) Layout(gtx C, th *style.Theme) D {
 							// }
 							return material.Button(th.Primary(), &l.SubmitBtn, "Update").Layout(gtx)
 						}),
+						layout.Rigid(func(gtx C) D {
+							if l.Lease.ID != 0 {
+								return D{}
+							}
+							return layout.Inset{Left: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+								return material.Button(th.Muted(), &l.DraftBtn, "Save Draft").Layout(gtx)
+							})
+						}),
 					)
 				})
 		}),
 	)
 }
+
+// layoutLockedRent shows the starting rent, with the action to increase it.
+func (l *LeaseForm) layoutLockedRent(gtx C, th *style.Theme) D {
+	return layout.Inset{Top: unit.Dp(10), Bottom: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{
+			Axis:      layout.Horizontal,
+			Alignment: layout.Middle,
+		}.Layout(
+			gtx,
+			layout.Flexed(1, func(gtx C) D {
+				return layout.Flex{Axis: layout.Vertical}.Layout(
+					gtx,
+					layout.Rigid(func(gtx C) D {
+						return material.Body1(th.Dark(), fmt.Sprintf("Starting Rent (weekly): %s", l.Lease.Rent)).Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return material.Caption(th.Muted(), "Rent has been invoiced, so changes are scheduled as rent increases.").Layout(gtx)
+					}),
+				)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return material.Button(th.Secondary(), &l.IncreaseRent, "Increase Rent").Layout(gtx)
+			}),
+		)
+	})
+}
+
+// CreateTenant registers a tenant named by the text of the tenant picker and
+// picks them, for when the tenant is new.
+// Registering the tenant can be undone.
+func (l *LeaseForm) CreateTenant() (avisha.Tenant, error) {
+	t := avisha.Tenant{Name: strings.TrimSpace(l.Tenant.Text())}
+	t.Address = l.Settings.Defaults.Address
+	if err := l.Undo.Do(fmt.Sprintf("Registered tenant %s", t.Name), func(app avisha.App) error {
+		return app.RegisterTenant(&t)
+	}); err != nil {
+		return t, fmt.Errorf("registering tenant: %w", err)
+	}
+	l.Tenant.Select(tenantOption(t, nil))
+	l.Tenant.ClearError()
+	return t, nil
+}
+
+// searchTenants offers the tenants that aren't archived matching the text,
+// along with the site each one currently leases.
+func (l *LeaseForm) searchTenants(text string) ([]widget.Option, error) {
+	data, err := l.tenants.Load(l.App, l.Invalidate, text, func(app avisha.App) (interface{}, error) {
+		return tenantOptions(app, text)
+	})
+	options, _ := data.([]widget.Option)
+	return options, err
+}
+
+// searchSites offers the sites that aren't archived matching the text, along
+// with who currently leases each one.
+func (l *LeaseForm) searchSites(text string) ([]widget.Option, error) {
+	data, err := l.sites.Load(l.App, l.Invalidate, text, func(app avisha.App) (interface{}, error) {
+		return siteOptions(app, text)
+	})
+	options, _ := data.([]widget.Option)
+	return options, err
+}
+
+// tenantOptions queries the tenants matching the text, as options.
+func tenantOptions(app avisha.App, text string) ([]widget.Option, error) {
+	tenants, err := app.QueryTenants(avisha.TenantQuery{Search: text})
+	if err != nil {
+		return nil, err
+	}
+	current, err := currentLeases(app)
+	if err != nil {
+		return nil, err
+	}
+	options := make([]widget.Option, len(tenants))
+	for ii, t := range tenants {
+		var sites []string
+		for _, m := range current {
+			if m.Tenant.ID == t.ID {
+				sites = append(sites, m.Site.Number)
+			}
+		}
+		options[ii] = tenantOption(t, sites)
+	}
+	return options, nil
+}
+
+// siteOptions queries the sites matching the text, as options.
+func siteOptions(app avisha.App, text string) ([]widget.Option, error) {
+	sites, err := app.QuerySites(avisha.SiteQuery{Search: text})
+	if err != nil {
+		return nil, err
+	}
+	current, err := currentLeases(app)
+	if err != nil {
+		return nil, err
+	}
+	options := make([]widget.Option, len(sites))
+	for ii, s := range sites {
+		var tenants []string
+		for _, m := range current {
+			if m.Site.ID == s.ID {
+				tenants = append(tenants, m.Tenant.Name)
+			}
+		}
+		options[ii] = siteOption(s, tenants)
+	}
+	return options, nil
+}
+
+// Loaded takes up the tenant and site of the lease loaded by the page, showing
+// them in the pickers if they were waiting on them.
+func (l *LeaseForm) Loaded(t avisha.Tenant, s avisha.Site) {
+	l.tenant, l.site = tenantOption(t, nil), siteOption(s, nil)
+	for _, p := range []struct {
+		*widget.Picker
+		opt widget.Option
+	}{{&l.Tenant, l.tenant}, {&l.Site, l.site}} {
+		if picked, ok := p.Selected(); ok && picked.ID == p.opt.ID && picked.Label == "" {
+			p.Select(p.opt)
+		}
+	}
+}
+
+func (l *LeaseForm) lookupTenant(id int) (widget.Option, error) {
+	return lookup(&l.Tenant, l.tenant, id), nil
+}
+
+func (l *LeaseForm) lookupSite(id int) (widget.Option, error) {
+	return lookup(&l.Site, l.site, id), nil
+}
+
+// lookup returns the option for the record from those already loaded: the one
+// picked, else the one of the lease.
+// Until the record has loaded the option is unlabelled, to be labelled by
+// Loaded.
+func lookup(picker *widget.Picker, loaded widget.Option, id int) widget.Option {
+	if picked, ok := picker.Selected(); ok && picked.ID == id {
+		return picked
+	}
+	if loaded.ID == id {
+		return loaded
+	}
+	return widget.Option{ID: id}
+}
+
+// currentLeases returns the leases in force, to describe who occupies what.
+func currentLeases(app avisha.App) ([]avisha.LeaseMatch, error) {
+	return app.QueryLeases(avisha.LeaseQuery{Statuses: avisha.CurrentStatuses})
+}
+
+// tenantOption describes the tenant by how to contact them and the sites they
+// lease.
+func tenantOption(t avisha.Tenant, sites []string) widget.Option {
+	var detail []string
+	if t.Contact != "" {
+		detail = append(detail, t.Contact)
+	}
+	if len(sites) > 0 {
+		detail = append(detail, fmt.Sprintf("Leasing site %s", strings.Join(sites, ", ")))
+	}
+	return widget.Option{ID: t.ID, Label: t.Name, Detail: strings.Join(detail, " · ")}
+}
+
+// siteOption describes the site by its dwelling and who leases it.
+func siteOption(s avisha.Site, tenants []string) widget.Option {
+	detail := s.Dwelling.String()
+	if len(tenants) > 0 {
+		detail += fmt.Sprintf(" · Leased to %s", strings.Join(tenants, ", "))
+	} else {
+		detail += " · Vacant"
+	}
+	return widget.Option{ID: s.ID, Label: s.Number, Detail: detail}
+}
@@ -3,9 +3,8 @@ package views
 import (
 	"fmt"
 	"image"
-	"log"
-	"sort"
 	"sync"
+	"time"
 	"unsafe"
 
 	"gioui.org/layout"
@@ -19,16 +18,56 @@ import (
 )
 
 // @Todo: Cap width for list items for desktop view and pack into columns?
-// @Todo: Add search and filters
 type LeaseList struct {
 	nav.Route
-	App    *avisha.App
-	Th     *style.Theme
-	list   layout.List
-	states States
-	once   sync.Once
+	App *avisha.App
+	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+	// Invalidate requests a frame once leases have loaded.
+	Invalidate func()
+	list       layout.List
+	states     States
+	once       sync.Once
+	loader     Loader
+
+	// Search matches leases by site number and tenant as it is typed.
+	Search widget.Editor
+	// Current, Draft and Ended filter leases by lifecycle status, showing
+	// leases of any status when none are selected.
+	// Defaults to showing only current leases.
+	Current widget.Bool
+	Draft   widget.Bool
+	Ended   widget.Bool
+	// InArrears filters leases owing on an invoice past due.
+	InArrears widget.Bool
+	// Dwellings filters leases by the dwelling type of the site.
+	Dwellings dwellingChips
+	// Archived shows archived leases along with the rest.
+	Archived widget.Bool
 
 	CreateLease widget.Clickable
+	Arrears     widget.Clickable
+}
+
+// query builds the lease query from the search and the active filters.
+func (l *LeaseList) query() avisha.LeaseQuery {
+	q := avisha.LeaseQuery{
+		Search:    l.Search.Text(),
+		Dwellings: l.Dwellings.Selected(),
+		InArrears: l.InArrears.Value,
+		Archived:  l.Archived.Value,
+	}
+	if l.Current.Value {
+		q.Statuses = append(q.Statuses, avisha.CurrentStatuses...)
+	}
+	if l.Draft.Value {
+		q.Statuses = append(q.Statuses, avisha.Draft)
+	}
+	if l.Ended.Value {
+		q.Statuses = append(q.Statuses, avisha.Ended)
+	}
+	return q
 }
 
 func (l *LeaseList) Title() string {
@@ -37,6 +76,16 @@ func (l *LeaseList) Title() string {
 
 func (l *LeaseList) Context() []layout.Widget {
 	return []layout.Widget{
+		func(gtx C) D {
+			return style.SearchBar(l.Th.Dark(), &l.Search, "Search leases").Layout(gtx)
+		},
+		func(gtx C) D {
+			return material.IconButton(
+				l.Th.Primary(),
+				&l.Arrears,
+				icons.Warning,
+			).Layout(gtx)
+		},
 		func(gtx C) D {
 			return material.IconButton(
 				l.Th.Primary(),
@@ -56,45 +105,92 @@ func (l *LeaseList) Update(gtx C) {
 	if l.CreateLease.Clicked() {
 		l.Route.To(RouteLeasePage, nil)
 	}
+	if l.Arrears.Clicked() {
+		l.Route.To(RouteArrears, nil)
+	}
 }
 
 func (l *LeaseList) Layout(gtx C) D {
 	l.once.Do(func() {
 		l.list.Axis = layout.Vertical
 		l.list.ScrollToEnd = false
+		l.Current.Value = true
 	})
 	l.Update(gtx)
 	l.states.Begin()
-	var (
-		leases []*avisha.Lease
+	query := l.query()
+	data, _ := l.loader.Load(l.App, l.Invalidate, fmt.Sprintf("%+v", query), func(app avisha.App) (interface{}, error) {
+		var (
+			leases leases
+			err    error
+		)
+		if leases.Settings, err = app.LoadSettings(); err != nil {
+			l.Alerts.Error(fmt.Errorf("loading settings: %w", err))
+			return leases, err
+		}
+		if leases.Matches, err = app.QueryLeases(query); err != nil {
+			l.Alerts.Error(fmt.Errorf("loading leases: %w", err))
+			return leases, err
+		}
+		leases.Positions = make(map[int]avisha.RentPosition)
+		for _, m := range leases.Matches {
+			if _, ok := m.Lease.Services[avisha.ServiceRent]; !ok || m.Lease.Status == avisha.Draft {
+				continue
+			}
+			position, err := app.RentPosition(m.Lease.ID, time.Now())
+			if err != nil {
+				l.Alerts.Error(fmt.Errorf("calculating rent position of lease %d: %w", m.Lease.ID, err))
+				continue
+			}
+			leases.Positions[m.Lease.ID] = position
+		}
+		return leases, nil
+	})
+	list, _ := data.(leases)
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
+		layout.Rigid(func(gtx C) D {
+			return l.LayoutFilters(gtx)
+		}),
+		layout.Flexed(1, func(gtx C) D {
+			return l.LayoutList(gtx, list)
+		}),
 	)
-	if err := l.App.All(&leases); err != nil {
-		log.Printf("loading leases: %v", err)
+}
+
+// LayoutFilters renders the filter chips.
+func (l *LeaseList) LayoutFilters(gtx C) D {
+	chip := func(state *widget.Bool, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return style.Chip(l.Th.Primary(), state, label).Layout(gtx)
+		})
 	}
-	// @Improve
-	// Using this composite type so we can order by site number, since
-	// we can't do alphabetic ordering of integer IDs.
-	// Not sure about performance hit this takes, it's at least O(2n).
-	type Lease struct {
-		Lease  avisha.Lease
-		Site   avisha.Site
-		Tenant avisha.Tenant
+	chips := []layout.FlexChild{
+		chip(&l.Current, "Current"),
+		chip(&l.Draft, "Draft"),
+		chip(&l.Ended, "Ended"),
+		chip(&l.InArrears, "In Arrears"),
 	}
+	chips = append(chips, l.Dwellings.Chips(l.Th)...)
+	return layoutChips(gtx, append(chips, chip(&l.Archived, "Archived"))...)
+}
+
+// leases are the leases listed, with the settings needed to show them.
+type leases struct {
+	Settings avisha.Settings
+	Matches  []avisha.LeaseMatch
+	// Positions are the rent positions of the leases paying rent, by ID.
+	Positions map[int]avisha.RentPosition
+}
+
+// LayoutList renders the lease cards.
+func (l *LeaseList) LayoutList(gtx C, data leases) D {
 	var (
-		list = make([]Lease, len(leases))
+		settings = data.Settings
+		list     = data.Matches
 	)
-	for ii := range leases {
-		list[ii].Lease = *leases[ii]
-		if err := l.App.One("ID", leases[ii].Tenant, &list[ii].Tenant); err != nil {
-			log.Printf("lease list: %v", err)
-		}
-		if err := l.App.One("ID", leases[ii].Site, &list[ii].Site); err != nil {
-			log.Printf("lease list: %v", err)
-		}
-	}
-	sort.Slice(list, func(ii, jj int) bool {
-		return list[ii].Site.Number < list[jj].Site.Number
-	})
 	return l.list.Layout(gtx, len(list), func(gtx C, index int) D {
 		var (
 			lease  = &list[index].Lease
@@ -137,18 +233,31 @@ func (l *LeaseList) Layout(gtx C) D {
 							)
 						},
 						func(gtx C) D {
-							u := lease.Services["utilities"]
-							return style.ServiceLabel(l.Th, "Utilities", u.Balance()).Layout(gtx)
+							var balances []layout.FlexChild
+							for _, def := range settings.Subscribed(*lease) {
+								def := def
+								balances = append(balances, layout.Rigid(func(gtx C) D {
+									return style.ServiceLabel(l.Th, def.Name, lease.Services[def.Key].Balance()).Layout(gtx)
+								}))
+							}
+							return layout.Flex{Axis: layout.Vertical}.Layout(gtx, balances...)
 						},
 						func(gtx C) D {
-							u := lease.Services["rent"]
-							return style.ServiceLabel(l.Th, "Rent", u.Balance()).Layout(gtx)
+							position, ok := data.Positions[lease.ID]
+							if !ok {
+								return D{}
+							}
+							return l.LayoutRentPosition(gtx, position)
 						},
 						func(gtx C) D {
+							status := lease.StatusAt(time.Now()).String()
+							if lease.IsArchived() {
+								status += ", Archived"
+							}
 							lb := material.Label(
 								l.Th.Muted(),
 								unit.Dp(15),
-								lease.Term.String())
+								fmt.Sprintf("%s (%s)", lease.Term, status))
 							// lb.Color = l.Th.Muted().Fg
 							return lb.Layout(gtx)
 						},
@@ -157,3 +266,13 @@ func (l *LeaseList) Layout(gtx C) D {
 			})
 	})
 }
+
+// LayoutRentPosition renders the date the rent of a lease is paid to,
+// highlighting leases in arrears.
+func (l *LeaseList) LayoutRentPosition(gtx C, position avisha.RentPosition) D {
+	th := l.Th.Success()
+	if position.InArrears() {
+		th = l.Th.Danger()
+	}
+	return material.Body2(th, position.String()).Layout(gtx)
+}
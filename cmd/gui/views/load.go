@@ -0,0 +1,69 @@
+package views
+
+import (
+	"sync"
+
+	"github.com/jackmordaunt/avisha.go"
+)
+
+// Loader loads the data of a view off the UI goroutine, so that layout never
+// waits on the database.
+//
+// The data is cached until the records change or the view asks for different
+// data, then loaded again in the background. Meanwhile the stale data is
+// returned, so the view keeps showing what it had rather than flickering
+// empty.
+type Loader struct {
+	mu sync.Mutex
+	// key and revision identify the data cached, or being loaded.
+	key      string
+	revision uint64
+	started  bool
+	loading  bool
+	data     interface{}
+	err      error
+}
+
+// Load returns the data cached for the key, starting a load in the background
+// if there is none or the records have changed since.
+// The key identifies the data wanted, such as the query a list is showing.
+// invalidate is called once fresh data arrives, to request a frame.
+func (l *Loader) Load(
+	app *avisha.App,
+	invalidate func(),
+	key string,
+	load func(app avisha.App) (interface{}, error),
+) (interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	revision := app.Events.Revision()
+	if l.loading || (l.started && l.key == key && l.revision == revision) {
+		return l.data, l.err
+	}
+	l.key, l.revision, l.started, l.loading = key, revision, true, true
+	go func(app avisha.App) {
+		data, err := load(app)
+		l.mu.Lock()
+		l.data, l.err, l.loading = data, err, false
+		l.mu.Unlock()
+		if invalidate != nil {
+			invalidate()
+		}
+	}(*app)
+	return l.data, l.err
+}
+
+// Loading reports whether data is being loaded.
+func (l *Loader) Loading() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.loading
+}
+
+// Reload loads the data again on the next call to Load, even if nothing has
+// changed.
+func (l *Loader) Reload() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.started = false
+}
@@ -0,0 +1,299 @@
+package views
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"git.sr.ht/~whereswaldon/materials"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+)
+
+// MeterForm collects the details of a meter being installed, either for the
+// first time or in place of the meter in service.
+type MeterForm struct {
+	Meter avisha.Meter
+	// Replacing is the meter in service being replaced, zero when installing.
+	Replacing avisha.Meter
+	// Closing is the final reading of the replaced meter.
+	Closing avisha.Reading
+	// Opening is the values on the registers of the new meter.
+	Opening avisha.Reading
+
+	Serial         materials.TextField
+	Digits         materials.TextField
+	Date           materials.TextField
+	ClosingReading materials.TextField
+	ClosingOffPeak materials.TextField
+	OpeningReading materials.TextField
+	OpeningOffPeak materials.TextField
+	// TimeOfUse marks the new meter as having an off-peak register.
+	TimeOfUse widget.Bool
+
+	Form      widget.Form
+	SubmitBtn widget.Clickable
+	CancelBtn widget.Clickable
+}
+
+// Load the form to install a meter for the service, replacing the meter in
+// service if there is one.
+// Last is the last reading of the meter being replaced.
+func (f *MeterForm) Load(key avisha.ServiceKey, replacing avisha.Meter, last avisha.Reading) {
+	f.Meter = avisha.Meter{Service: key, Installed: today()}
+	f.Replacing = replacing
+	f.Closing = avisha.Reading{}
+	f.Opening = avisha.Reading{}
+	f.TimeOfUse.Value = replacing.TimeOfUse
+	fields := []widget.Field{
+		{
+			Value: widget.TextValuer{Value: &f.Meter.Serial},
+			Input: &f.Serial,
+		},
+		{
+			Value: widget.IntValuer{Value: &f.Meter.Digits},
+			Input: &f.Digits,
+		},
+		{
+			Value: widget.DateValuer{Value: &f.Meter.Installed},
+			Input: &f.Date,
+		},
+		{
+			Value: widget.IntValuer{Value: &f.Opening.Value},
+			Input: &f.OpeningReading,
+		},
+		{
+			Value: widget.IntValuer{Value: &f.Opening.OffPeak},
+			Input: &f.OpeningOffPeak,
+		},
+	}
+	if f.IsReplacement() {
+		fields = append(fields, widget.Field{
+			Value: ReadingValuer{
+				Value:    &f.Closing.Value,
+				Meter:    replacing,
+				Previous: last.Value,
+			},
+			Input: &f.ClosingReading,
+		})
+		if replacing.TimeOfUse {
+			fields = append(fields, widget.Field{
+				Value: ReadingValuer{
+					Value:    &f.Closing.OffPeak,
+					Meter:    replacing,
+					Previous: last.OffPeak,
+				},
+				Input: &f.ClosingOffPeak,
+			})
+		}
+	}
+	f.Form.Load(fields)
+}
+
+// IsReplacement reports whether the meter replaces the meter in service.
+func (f *MeterForm) IsReplacement() bool {
+	return f.Replacing.ID != 0
+}
+
+// Submit validates the input data and returns a boolean indicating validity.
+func (f *MeterForm) Submit() (ok bool) {
+	ok = f.Form.Submit()
+	f.Closing.Date = f.Meter.Installed
+	f.Meter.TimeOfUse = f.TimeOfUse.Value
+	return ok
+}
+
+func (f *MeterForm) Clear() {
+	f.Form.Clear()
+}
+
+func (f *MeterForm) Layout(gtx C, th *style.Theme) D {
+	f.Form.Validate(gtx)
+	var (
+		submit = "Install"
+		fields = []layout.FlexChild{
+			layout.Rigid(func(gtx C) D {
+				return f.Serial.Layout(gtx, th.Dark(), "Serial Number")
+			}),
+			layout.Rigid(func(gtx C) D {
+				return f.Digits.Layout(gtx, th.Dark(), "Register Digits (0 if unknown)")
+			}),
+			layout.Rigid(func(gtx C) D {
+				return f.Date.Layout(gtx, th.Dark(), "Installation Date")
+			}),
+		}
+	)
+	if f.IsReplacement() {
+		submit = "Replace"
+		fields = append(fields, layout.Rigid(func(gtx C) D {
+			return f.ClosingReading.Layout(gtx, th.Dark(), fmt.Sprintf("Closing Reading (%s)", f.Replacing))
+		}))
+		if f.Replacing.TimeOfUse {
+			fields = append(fields, layout.Rigid(func(gtx C) D {
+				return f.ClosingOffPeak.Layout(gtx, th.Dark(), fmt.Sprintf("Closing Off-Peak Reading (%s)", f.Replacing))
+			}))
+		}
+	}
+	fields = append(
+		fields,
+		layout.Rigid(func(gtx C) D {
+			return material.CheckBox(th.Dark(), &f.TimeOfUse, "Time of use (separate off-peak register)").Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return f.OpeningReading.Layout(gtx, th.Dark(), "Opening Reading")
+		}),
+		layout.Rigid(func(gtx C) D {
+			if !f.TimeOfUse.Value {
+				return D{}
+			}
+			return f.OpeningOffPeak.Layout(gtx, th.Dark(), "Opening Off-Peak Reading")
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{
+				Top: unit.Dp(10),
+			}.Layout(
+				gtx,
+				func(gtx C) D {
+					return layout.Flex{
+						Axis: layout.Horizontal,
+					}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							return material.Button(th.Secondary(), &f.CancelBtn, "Cancel").Layout(gtx)
+						}),
+						layout.Rigid(func(gtx C) D {
+							return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+						}),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(th.Primary(), &f.SubmitBtn, submit).Layout(gtx)
+						}),
+					)
+				})
+		}),
+	)
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(gtx, fields...)
+}
+
+// ReadingForm collects a reading of a meter.
+type ReadingForm struct {
+	Reading avisha.Reading
+	Meter   avisha.Meter
+
+	Value     materials.TextField
+	OffPeak   materials.TextField
+	Date      materials.TextField
+	Note      materials.TextField
+	Estimated widget.Bool
+
+	Form      widget.Form
+	SubmitBtn widget.Clickable
+	CancelBtn widget.Clickable
+}
+
+// Load the form to read the meter, following on from the last reading.
+func (f *ReadingForm) Load(meter avisha.Meter, last avisha.Reading) {
+	f.Meter = meter
+	f.Reading = avisha.Reading{Meter: meter.ID, Date: today()}
+	f.Estimated.Value = false
+	fields := []widget.Field{
+		{
+			Value: ReadingValuer{
+				Value:    &f.Reading.Value,
+				Meter:    meter,
+				Previous: last.Value,
+			},
+			Input: &f.Value,
+		},
+		{
+			Value: widget.DateValuer{Value: &f.Reading.Date},
+			Input: &f.Date,
+		},
+		{
+			Value: widget.TextValuer{Value: &f.Reading.Note},
+			Input: &f.Note,
+		},
+	}
+	if meter.TimeOfUse {
+		fields = append(fields, widget.Field{
+			Value: ReadingValuer{
+				Value:    &f.Reading.OffPeak,
+				Meter:    meter,
+				Previous: last.OffPeak,
+			},
+			Input: &f.OffPeak,
+		})
+	}
+	f.Form.Load(fields)
+}
+
+// Submit validates the input data and returns a boolean indicating validity.
+func (f *ReadingForm) Submit() (reading avisha.Reading, ok bool) {
+	ok = f.Form.Submit()
+	f.Reading.Estimated = f.Estimated.Value
+	return f.Reading, ok
+}
+
+func (f *ReadingForm) Clear() {
+	f.Form.Clear()
+}
+
+func (f *ReadingForm) Layout(gtx C, th *style.Theme) D {
+	f.Form.Validate(gtx)
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
+		layout.Rigid(func(gtx C) D {
+			return f.Value.Layout(gtx, th.Dark(), fmt.Sprintf("Reading (%s)", f.Meter))
+		}),
+		layout.Rigid(func(gtx C) D {
+			if !f.Meter.TimeOfUse {
+				return D{}
+			}
+			return f.OffPeak.Layout(gtx, th.Dark(), fmt.Sprintf("Off-Peak Reading (%s)", f.Meter))
+		}),
+		layout.Rigid(func(gtx C) D {
+			return f.Date.Layout(gtx, th.Dark(), "Date")
+		}),
+		layout.Rigid(func(gtx C) D {
+			return f.Note.Layout(gtx, th.Dark(), "Note")
+		}),
+		layout.Rigid(func(gtx C) D {
+			return material.CheckBox(th.Dark(), &f.Estimated, "Estimated").Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{
+				Top: unit.Dp(10),
+			}.Layout(
+				gtx,
+				func(gtx C) D {
+					return layout.Flex{
+						Axis: layout.Horizontal,
+					}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							return material.Button(th.Secondary(), &f.CancelBtn, "Cancel").Layout(gtx)
+						}),
+						layout.Rigid(func(gtx C) D {
+							return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+						}),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(th.Primary(), &f.SubmitBtn, "Record").Layout(gtx)
+						}),
+					)
+				})
+		}),
+	)
+}
+
+// today returns the start of the current day.
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+}
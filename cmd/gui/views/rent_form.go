@@ -0,0 +1,104 @@
+package views
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"git.sr.ht/~whereswaldon/materials"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+)
+
+// RentIncreaseForm collects the details of a scheduled rent increase.
+type RentIncreaseForm struct {
+	Change avisha.RentChange
+
+	// Current is the weekly rent being increased from.
+	Current avisha.RentChange
+
+	Amount    materials.TextField
+	Effective materials.TextField
+
+	Form      widget.Form
+	SubmitBtn widget.Clickable
+	CancelBtn widget.Clickable
+}
+
+// Load the form with the rent currently in effect, defaulting the effective
+// date to the earliest date allowed by the notice period.
+func (f *RentIncreaseForm) Load(lease avisha.Lease, settings avisha.Settings) {
+	now := time.Now()
+	f.Current = avisha.RentChange{Amount: lease.RentAt(now)}
+	f.Change = avisha.RentChange{
+		Amount:    f.Current.Amount,
+		Effective: now.Add(settings.Defaults.RentIncreaseNotice),
+		Notified:  now,
+	}
+	f.Form.Load([]widget.Field{
+		{
+			Value: widget.CurrencyValuer{Value: &f.Change.Amount},
+			Input: &f.Amount,
+		},
+		{
+			Value: widget.DateValuer{Value: &f.Change.Effective},
+			Input: &f.Effective,
+		},
+	})
+}
+
+// Submit validates the input data and returns a boolean indicating validity.
+func (f *RentIncreaseForm) Submit() (change avisha.RentChange, ok bool) {
+	return f.Change, f.Form.Submit()
+}
+
+func (f *RentIncreaseForm) Clear() {
+	f.Form.Clear()
+}
+
+func (f *RentIncreaseForm) Layout(gtx C, th *style.Theme) D {
+	f.Form.Validate(gtx)
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
+		layout.Rigid(func(gtx C) D {
+			return material.Body1(th.Muted(), fmt.Sprintf("Current rent %s per week", f.Current.Amount)).Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			f.Amount.Prefix = func(gtx C) D {
+				return material.Body1(th.Dark(), "$").Layout(gtx)
+			}
+			return f.Amount.Layout(gtx, th.Dark(), "New Rent (weekly)")
+		}),
+		layout.Rigid(func(gtx C) D {
+			return f.Effective.Layout(gtx, th.Dark(), "Effective Date")
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{
+				Top: unit.Dp(10),
+			}.Layout(
+				gtx,
+				func(gtx C) D {
+					return layout.Flex{
+						Axis: layout.Horizontal,
+					}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							return material.Button(th.Secondary(), &f.CancelBtn, "Cancel").Layout(gtx)
+						}),
+						layout.Rigid(func(gtx C) D {
+							return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+						}),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(th.Primary(), &f.SubmitBtn, "Schedule").Layout(gtx)
+						}),
+					)
+				})
+		}),
+	)
+}
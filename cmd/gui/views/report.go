@@ -0,0 +1,57 @@
+package views
+
+import (
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+	"github.com/jackmordaunt/avisha.go/report"
+)
+
+// tableRow renders a row of equally sized cells.
+func tableRow(gtx C, th *style.Theme, header bool, cells ...string) D {
+	children := make([]layout.FlexChild, len(cells))
+	for ii, text := range cells {
+		text := text
+		children[ii] = layout.Flexed(1, func(gtx C) D {
+			lb := material.Body1(th.Dark(), text)
+			if header {
+				lb = material.Body2(th.Muted(), text)
+			}
+			return layout.UniformInset(unit.Dp(5)).Layout(gtx, lb.Layout)
+		})
+	}
+	return layout.Flex{
+		Axis:      layout.Horizontal,
+		Alignment: layout.Middle,
+	}.Layout(gtx, children...)
+}
+
+// layoutTable renders a report table under its title, totals last.
+func layoutTable(gtx C, th *style.Theme, t report.Table) D {
+	rows := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			if t.Title == "" {
+				return D{}
+			}
+			return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+				return material.H6(th.Dark(), t.Title).Layout(gtx)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return tableRow(gtx, th, true, t.Header...)
+		}),
+	}
+	for _, cells := range t.Rows {
+		cells := cells
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return tableRow(gtx, th, false, cells...)
+		}))
+	}
+	if len(t.Footer) > 0 {
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return tableRow(gtx, th, true, t.Footer...)
+		}))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
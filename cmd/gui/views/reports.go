@@ -0,0 +1,246 @@
+package views
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"git.sr.ht/~whereswaldon/materials"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+	"github.com/jackmordaunt/avisha.go/report"
+)
+
+// ReportsPage reports how the sites are let: occupancy by dwelling, vacancies
+// with the rent they lost, and leases soon to expire.
+// It also leads to the other reports.
+type ReportsPage struct {
+	nav.Route
+	App *avisha.App
+	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+
+	From   materials.TextField
+	To     materials.TextField
+	Within materials.TextField
+
+	Form    widget.Form
+	RunBtn  widget.Clickable
+	Print   widget.Clickable
+	Arrears widget.Clickable
+	Income  widget.Clickable
+
+	// from and to are the first and last days vacancies are reported over.
+	from, to time.Time
+	// within is the days ahead to report expiring leases.
+	within int
+	// focus is the report selected when routing to the page, listed first.
+	focus  string
+	report report.OccupancyReport
+	err    string
+	scroll layout.List
+}
+
+// Reports that can be selected when routing to the page.
+const (
+	// ReportsExpiring lists the leases expiring within the days the dashboard
+	// counts.
+	ReportsExpiring = "expiring"
+	// ReportsVacant lists the sites vacant today.
+	ReportsVacant = "vacant"
+)
+
+func (p *ReportsPage) Title() string {
+	return "Reports"
+}
+
+// Receive defaults to vacancies over the last year and leases expiring in the
+// next 60 days, keeping what was entered when returning to the page.
+// A report selected by the data is reported as the dashboard counts it, and
+// listed first.
+func (p *ReportsPage) Receive(data interface{}) {
+	now := today()
+	if p.from.IsZero() {
+		p.from, p.to, p.within = now.AddDate(-1, 0, 0), now, 60
+	}
+	p.focus, _ = data.(string)
+	switch p.focus {
+	case ReportsExpiring:
+		p.within = dashboardExpiry
+	case ReportsVacant:
+		p.from, p.to = now, now
+	}
+	p.Form.Load([]widget.Field{
+		{
+			Value: widget.DateValuer{Value: &p.from},
+			Input: &p.From,
+		},
+		{
+			Value: widget.DateValuer{Value: &p.to},
+			Input: &p.To,
+		},
+		{
+			Value: widget.IntValuer{Value: &p.within},
+			Input: &p.Within,
+		},
+	})
+	p.run()
+}
+
+// run builds the report as of today.
+func (p *ReportsPage) run() {
+	p.err = ""
+	if p.to.Before(p.from) {
+		p.To.SetError("must not be before the start of the period")
+		return
+	}
+	r, err := report.OccupancyOf(*p.App, time.Now(), avisha.Term{
+		Start:    p.from,
+		Duration: p.to.AddDate(0, 0, 1).Sub(p.from),
+	}, p.within)
+	if err != nil {
+		p.err = fmt.Sprintf("building report: %v", err)
+		return
+	}
+	p.report = r
+}
+
+func (p *ReportsPage) Update(gtx C) {
+	p.Form.Validate(gtx)
+	if p.RunBtn.Clicked() {
+		if p.Form.Submit() {
+			p.run()
+		}
+	}
+	if p.Print.Clicked() {
+		if err := p.print(); err != nil {
+			p.Alerts.Error(err)
+		}
+	}
+	if p.Arrears.Clicked() {
+		p.Route.To(RouteArrears, nil)
+	}
+	if p.Income.Clicked() {
+		p.Route.To(RouteIncome, nil)
+	}
+}
+
+// tables of the report, the selected one first.
+func (p *ReportsPage) tables() []report.Table {
+	var (
+		occupancy = p.report.OccupancyTable()
+		vacancy   = p.report.VacancyTable()
+		expiry    = p.report.ExpiryTable()
+	)
+	switch p.focus {
+	case ReportsExpiring:
+		return []report.Table{expiry, occupancy, vacancy}
+	case ReportsVacant:
+		return []report.Table{vacancy, occupancy, expiry}
+	}
+	return []report.Table{occupancy, vacancy, expiry}
+}
+
+// print renders the report to a printable document and opens it.
+func (p *ReportsPage) print() error {
+	settings, err := p.App.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	buffer, err := util.ReportDocument{
+		Title:    "Occupancy",
+		Tables:   p.tables(),
+		Settings: settings,
+	}.Render()
+	if err != nil {
+		return fmt.Errorf("rendering report: %w", err)
+	}
+	return openDocument(
+		"reports",
+		fmt.Sprintf("occupancy-%s.html", p.report.At.Format("20060102")),
+		buffer,
+	)
+}
+
+func (p *ReportsPage) Layout(gtx C) D {
+	p.Update(gtx)
+	p.scroll.Axis = layout.Vertical
+	var (
+		tables = p.tables()
+		spacer = func(gtx C) D {
+			return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+		}
+		table = func(t report.Table) layout.FlexChild {
+			return layout.Rigid(func(gtx C) D {
+				return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+					return layoutTable(gtx, p.Th, t)
+				})
+			})
+		}
+	)
+	return p.scroll.Layout(gtx, 1, func(gtx C, _ int) D {
+		return layout.UniformInset(unit.Dp(20)).Layout(gtx, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(
+				gtx,
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{
+						Axis:      layout.Horizontal,
+						Alignment: layout.Middle,
+					}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							return material.Button(p.Th.Secondary(), &p.Arrears, "Arrears").Layout(gtx)
+						}),
+						layout.Rigid(spacer),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(p.Th.Secondary(), &p.Income, "Income & GST").Layout(gtx)
+						}),
+					)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{
+						Axis:      layout.Horizontal,
+						Alignment: layout.Middle,
+					}.Layout(
+						gtx,
+						layout.Flexed(1, func(gtx C) D {
+							return p.From.Layout(gtx, p.Th.Dark(), "Vacancies From")
+						}),
+						layout.Rigid(spacer),
+						layout.Flexed(1, func(gtx C) D {
+							return p.To.Layout(gtx, p.Th.Dark(), "To")
+						}),
+						layout.Rigid(spacer),
+						layout.Flexed(1, func(gtx C) D {
+							return p.Within.Layout(gtx, p.Th.Dark(), "Expiring Within (days)")
+						}),
+						layout.Rigid(spacer),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(p.Th.Primary(), &p.RunBtn, "Report").Layout(gtx)
+						}),
+						layout.Rigid(spacer),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(p.Th.Secondary(), &p.Print, "Print").Layout(gtx)
+						}),
+					)
+				}),
+				layout.Rigid(func(gtx C) D {
+					if p.err == "" {
+						return D{}
+					}
+					return material.Body1(p.Th.Danger(), p.err).Layout(gtx)
+				}),
+				table(tables[0]),
+				table(tables[1]),
+				table(tables[2]),
+			)
+		})
+	})
+}
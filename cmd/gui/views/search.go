@@ -0,0 +1,147 @@
+package views
+
+import (
+	"fmt"
+	"unsafe"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+)
+
+// SearchPage searches every record as the query is typed, listing the hits
+// grouped by kind.
+type SearchPage struct {
+	nav.Route
+	App *avisha.App
+	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+
+	Query widget.Editor
+
+	// query is the text the hits were found for.
+	query  string
+	hits   []avisha.Hit
+	err    error
+	states States
+	list   layout.List
+}
+
+func (p *SearchPage) Title() string {
+	return "Search"
+}
+
+// Receive focuses the query and searches it again, since the records may have
+// changed while away.
+func (p *SearchPage) Receive(data interface{}) {
+	p.Query.SingleLine = true
+	p.Query.Focus()
+	p.query = ""
+}
+
+func (p *SearchPage) Context() []layout.Widget {
+	return []layout.Widget{
+		func(gtx C) D {
+			return style.SearchBar(p.Th.Dark(), &p.Query, "Search everything").Layout(gtx)
+		},
+	}
+}
+
+func (p *SearchPage) Update(gtx C) {
+	if text := p.Query.Text(); text != p.query {
+		p.query = text
+		p.hits, p.err = p.App.Search(text)
+		if p.err != nil {
+			p.Alerts.Error(fmt.Errorf("searching: %w", p.err))
+		}
+	}
+	for _, state := range p.states.List() {
+		if state.Item.Clicked() {
+			if err := p.open(*(*avisha.Hit)(state.Data)); err != nil {
+				p.Alerts.Error(fmt.Errorf("opening search hit: %w", err))
+			}
+		}
+	}
+}
+
+// open the record behind the hit in its view.
+func (p *SearchPage) open(hit avisha.Hit) error {
+	switch hit.Kind {
+	case avisha.TenantRecord:
+		var tenant avisha.Tenant
+		if err := p.App.One("ID", hit.ID, &tenant); err != nil {
+			return fmt.Errorf("loading tenant: %w", err)
+		}
+		p.Route.To(RouteTenantForm, &tenant)
+	case avisha.SiteRecord, avisha.ReadingRecord:
+		var site avisha.Site
+		if err := p.App.One("ID", hit.ID, &site); err != nil {
+			return fmt.Errorf("loading site: %w", err)
+		}
+		p.Route.To(RouteSiteForm, &site)
+	default:
+		var lease avisha.Lease
+		if err := p.App.One("ID", hit.Lease, &lease); err != nil {
+			return fmt.Errorf("loading lease: %w", err)
+		}
+		p.Route.To(RouteLeasePage, &lease)
+	}
+	return nil
+}
+
+func (p *SearchPage) Layout(gtx C) D {
+	p.Update(gtx)
+	p.states.Begin()
+	p.list.Axis = layout.Vertical
+	var rows []layout.Widget
+	switch {
+	case p.err != nil:
+		rows = append(rows, func(gtx C) D {
+			return material.Body1(p.Th.Danger(), p.err.Error()).Layout(gtx)
+		})
+	case p.query == "":
+		rows = append(rows, func(gtx C) D {
+			return material.Body1(p.Th.Muted(), "Search tenants, sites, leases, invoices, payments and readings").Layout(gtx)
+		})
+	case len(p.hits) == 0:
+		rows = append(rows, func(gtx C) D {
+			return material.Body1(p.Th.Muted(), fmt.Sprintf("Nothing found for %q", p.query)).Layout(gtx)
+		})
+	}
+	for ii := range p.hits {
+		var (
+			hit   = &p.hits[ii]
+			state = p.states.Next(unsafe.Pointer(hit))
+		)
+		if ii == 0 || p.hits[ii-1].Kind != hit.Kind {
+			rows = append(rows, func(gtx C) D {
+				return layout.Inset{Top: unit.Dp(10), Bottom: unit.Dp(5)}.Layout(gtx, func(gtx C) D {
+					return material.H6(p.Th.Dark(), hit.Kind.String()).Layout(gtx)
+				})
+			})
+		}
+		rows = append(rows, func(gtx C) D {
+			return style.ListItem(gtx, p.Th.Dark(), &state.Item, &state.Hover, false, func(gtx C) D {
+				return layout.UniformInset(unit.Dp(5)).Layout(gtx, func(gtx C) D {
+					return layout.Flex{Axis: layout.Vertical}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							return material.Body1(p.Th.Dark(), hit.Title).Layout(gtx)
+						}),
+						layout.Rigid(func(gtx C) D {
+							return material.Body2(p.Th.Muted(), hit.Detail).Layout(gtx)
+						}),
+					)
+				})
+			})
+		})
+	}
+	return p.list.Layout(gtx, len(rows), func(gtx C, index int) D {
+		return layout.Inset{Left: unit.Dp(15), Right: unit.Dp(15)}.Layout(gtx, rows[index])
+	})
+}
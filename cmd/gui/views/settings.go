@@ -1,9 +1,10 @@
 package views
 
 import (
-	"log"
+	"fmt"
 
 	"gioui.org/layout"
+	"gioui.org/unit"
 	"github.com/jackmordaunt/avisha.go"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
@@ -15,7 +16,15 @@ type SettingsPage struct {
 	App  *avisha.App
 	Th   *style.Theme
 	Form SettingsForm
+	// Alerts tells the user of failures.
+	Alerts *Alerts
 
+	// TariffForm edits a tariff of the service identified by tariffService.
+	// Tariffs are saved along with the rest of the settings.
+	TariffForm    TariffForm
+	tariffService avisha.ServiceKey
+
+	modal  layout.Widget
 	scroll layout.List
 }
 
@@ -29,17 +38,69 @@ func (s *SettingsPage) Receive(_ interface{}) {
 
 func (s *SettingsPage) Load() {
 	if settings, err := s.App.LoadSettings(); err != nil {
-		log.Printf("loading settings: %v", err)
+		s.Alerts.Error(fmt.Errorf("loading settings: %w", err))
 	} else {
 		s.Form.Load(&settings)
 	}
 }
 
+func (s *SettingsPage) Modal(gtx C) D {
+	if s.modal == nil {
+		return D{}
+	}
+	return s.modal(gtx)
+}
+
+func (s *SettingsPage) Update(gtx C) {
+	if s.Form.Settings == nil {
+		return
+	}
+	for ii := range s.Form.Services {
+		var (
+			def    = s.Form.Settings.Services[ii]
+			inputs = &s.Form.Services[ii]
+		)
+		if inputs.AddTariff.Clicked() {
+			s.tariffService = def.Key
+			s.TariffForm.Load(def)
+			s.modal = func(gtx C) D {
+				return style.ModalDialog(gtx, s.Th, unit.Dp(500), fmt.Sprintf("%s Tariff", def.Name), func(gtx C) D {
+					return s.TariffForm.Layout(gtx, s.Th)
+				})
+			}
+		}
+		for jj := range inputs.RemoveTariff {
+			if inputs.RemoveTariff[jj].Clicked() && jj < len(def.Tariffs) {
+				if err := s.Form.Settings.RemoveTariff(def.Key, def.Tariffs[jj].Effective); err != nil {
+					s.Alerts.Error(fmt.Errorf("removing tariff: %w", err))
+				}
+			}
+		}
+	}
+	if s.TariffForm.SubmitBtn.Clicked() {
+		if tariff, ok := s.TariffForm.Submit(); ok {
+			if err := s.Form.Settings.SetTariff(s.tariffService, tariff); err != nil {
+				s.TariffForm.Effective.SetError(err.Error())
+			} else {
+				s.TariffForm.Clear()
+				s.modal = nil
+			}
+		}
+	}
+	if s.TariffForm.CancelBtn.Clicked() {
+		s.TariffForm.Clear()
+		s.modal = nil
+	}
+}
+
 func (s *SettingsPage) Layout(gtx C) D {
+	s.Update(gtx)
 	if s.Form.SubmitBtn.Clicked() {
 		if settings, ok := s.Form.Submit(); ok {
 			if err := s.App.SaveSettings(settings); err != nil {
-				log.Printf("updating settings: %v", err)
+				s.Alerts.Error(fmt.Errorf("updating settings: %w", err))
+			} else {
+				s.Alerts.Success("Saved settings")
 			}
 			s.Load()
 		}
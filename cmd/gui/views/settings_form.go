@@ -1,13 +1,16 @@
 package views
 
 import (
+	"fmt"
 	"image"
+	"strconv"
 
 	"gioui.org/layout"
 	"gioui.org/unit"
 	"gioui.org/widget/material"
 	"git.sr.ht/~whereswaldon/materials"
 	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
 )
@@ -30,10 +33,12 @@ type SettingsForm struct {
 	}
 
 	Defaults struct {
-		UnitCost   materials.TextField
-		RentCycle  materials.TextField
-		InvoiceNet materials.TextField
-		GST        materials.TextField
+		UnitCost     materials.TextField
+		RentCycle    materials.TextField
+		InvoiceNet   materials.TextField
+		NoticePeriod materials.TextField
+		GST          materials.TextField
+		Proration    widget.Enum
 	}
 
 	// // BillTo is the default billable address for Tenants.
@@ -46,11 +51,30 @@ type SettingsForm struct {
 
 	BillTo AddressForm
 
+	// Services edits the service catalogue.
+	Services []ServiceFields
+
 	Form      widget.Form
 	SubmitBtn widget.Clickable
 	CancelBtn widget.Clickable
 }
 
+// ServiceFields edits an entry of the service catalogue.
+type ServiceFields struct {
+	Name      materials.TextField
+	Price     materials.TextField
+	Unit      materials.TextField
+	Reference materials.TextField
+	Tax       widget.Enum
+	Default   widget.Bool
+	// PassLineCharge marks the line charge of a metered service as passing on
+	// the supplier's charges.
+	PassLineCharge widget.Bool
+	// AddTariff and RemoveTariff edit the tariffs of metered services.
+	AddTariff    widget.Clickable
+	RemoveTariff []widget.Clickable
+}
+
 func (s *SettingsForm) Clear() {
 	if s.Settings != nil {
 		s.Load(s.Settings)
@@ -64,7 +88,10 @@ func (s *SettingsForm) Load(settings *avisha.Settings) {
 	s.Settings = settings
 	s.Landlord.Load(&s.Settings.Landlord.Address)
 	s.BillTo.Load(&s.Settings.Defaults.Address)
-	s.Form.Load([]widget.Field{
+	if len(s.Services) != len(s.Settings.Services) {
+		s.Services = make([]ServiceFields, len(s.Settings.Services))
+	}
+	fields := []widget.Field{
 		{
 			Value: widget.TextValuer{Value: &s.Settings.Landlord.Name},
 			Input: &s.Landlord.Name,
@@ -97,11 +124,45 @@ func (s *SettingsForm) Load(settings *avisha.Settings) {
 			Value: widget.DaysValuer{Value: &s.Settings.Defaults.InvoiceNet},
 			Input: &s.Defaults.InvoiceNet,
 		},
+		{
+			Value: widget.DaysValuer{Value: &s.Settings.Defaults.NoticePeriod},
+			Input: &s.Defaults.NoticePeriod,
+		},
 		{
 			Value: widget.FloatValuer{Value: &s.Settings.Defaults.GST},
 			Input: &s.Defaults.GST,
 		},
-	})
+	}
+	s.Defaults.Proration.Value = strconv.Itoa(int(s.Settings.Defaults.Proration))
+	for ii := range s.Settings.Services {
+		var (
+			def    = &s.Settings.Services[ii]
+			inputs = &s.Services[ii]
+		)
+		inputs.Tax.Value = strconv.Itoa(int(def.Tax))
+		inputs.Default.Value = def.Default
+		inputs.PassLineCharge.Value = def.PassLineCharge
+		fields = append(
+			fields,
+			widget.Field{
+				Value: widget.RequiredValuer{Valuer: widget.TextValuer{Value: &def.Name}},
+				Input: &inputs.Name,
+			},
+			widget.Field{
+				Value: widget.CurrencyValuer{Value: &def.Price},
+				Input: &inputs.Price,
+			},
+			widget.Field{
+				Value: widget.TextValuer{Value: &def.Unit},
+				Input: &inputs.Unit,
+			},
+			widget.Field{
+				Value: widget.TextValuer{Value: &def.Reference},
+				Input: &inputs.Reference,
+			},
+		)
+	}
+	s.Form.Load(fields)
 }
 
 // Submit validates the data and returns a boolean indicating validity.
@@ -115,6 +176,20 @@ func (s *SettingsForm) Submit() (settings avisha.Settings, ok bool) {
 	if !s.Form.Submit() {
 		return settings, false
 	}
+	if proration, err := strconv.Atoi(s.Defaults.Proration.Value); err == nil {
+		s.Settings.Defaults.Proration = avisha.ProrationMethod(proration)
+	}
+	for ii := range s.Settings.Services {
+		var (
+			def    = &s.Settings.Services[ii]
+			inputs = &s.Services[ii]
+		)
+		if tax, err := strconv.Atoi(inputs.Tax.Value); err == nil {
+			def.Tax = avisha.TaxCode(tax)
+		}
+		def.Default = inputs.Default.Value
+		def.PassLineCharge = inputs.PassLineCharge.Value
+	}
 	return *s.Settings, true
 }
 
@@ -192,6 +267,7 @@ func (s *SettingsForm) Layout(gtx C, th *style.Theme) D {
 					})),
 				layout.Rigid(field(&s.Defaults.RentCycle, "Rent Cycle (days)")),
 				layout.Rigid(field(&s.Defaults.InvoiceNet, "Invoice Net (days)")),
+				layout.Rigid(field(&s.Defaults.NoticePeriod, "Notice Period (days)")),
 				layout.Rigid(field(
 					&s.Defaults.GST,
 					"GST",
@@ -200,6 +276,28 @@ func (s *SettingsForm) Layout(gtx C, th *style.Theme) D {
 							return material.Body1(th.Theme, "%").Layout(gtx)
 						}
 					})),
+				layout.Rigid(func(gtx C) D {
+					proration := func(method avisha.ProrationMethod) layout.FlexChild {
+						return layout.Rigid(func(gtx C) D {
+							return material.RadioButton(th.Dark(), &s.Defaults.Proration, strconv.Itoa(int(method)), method.String()).Layout(gtx)
+						})
+					}
+					return layout.Flex{
+						Axis:      layout.Horizontal,
+						Alignment: layout.Middle,
+					}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							return material.Body1(th.Dark(), "Prorate rent by").Layout(gtx)
+						}),
+						proration(avisha.DailyRate),
+						proration(avisha.CalendarMonth),
+					)
+				}),
+				layout.Rigid(title("Services")),
+				layout.Rigid(func(gtx C) D {
+					return s.LayoutServices(gtx, th)
+				}),
 				layout.Rigid(title("Default Billable Address")),
 				layout.Rigid(func(gtx C) D {
 					return s.BillTo.Layout(gtx, th)
@@ -227,3 +325,149 @@ func (s *SettingsForm) Layout(gtx C, th *style.Theme) D {
 		},
 	)
 }
+
+// LayoutServices renders the fields for each service in the catalogue.
+func (s *SettingsForm) LayoutServices(gtx C, th *style.Theme) D {
+	var (
+		items = make([]layout.FlexChild, len(s.Services))
+		tax   = func(inputs *ServiceFields, code avisha.TaxCode) layout.FlexChild {
+			return layout.Rigid(func(gtx C) D {
+				return material.RadioButton(th.Dark(), &inputs.Tax, strconv.Itoa(int(code)), code.String()).Layout(gtx)
+			})
+		}
+	)
+	for ii := range s.Services {
+		var (
+			def    = s.Settings.Services[ii]
+			inputs = &s.Services[ii]
+		)
+		items[ii] = layout.Rigid(func(gtx C) D {
+			return layout.Inset{Bottom: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+				return layout.Flex{
+					Axis: layout.Vertical,
+				}.Layout(
+					gtx,
+					layout.Rigid(func(gtx C) D {
+						return layout.Flex{
+							Axis: layout.Horizontal,
+						}.Layout(
+							gtx,
+							layout.Flexed(1, func(gtx C) D {
+								return inputs.Name.Layout(gtx, th.Dark(), "Name")
+							}),
+							layout.Rigid(func(gtx C) D {
+								return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+							}),
+							layout.Flexed(1, func(gtx C) D {
+								inputs.Price.Prefix = func(gtx C) D {
+									return material.Body1(th.Theme, "$").Layout(gtx)
+								}
+								return inputs.Price.Layout(gtx, th.Dark(), fmt.Sprintf("Default Price (%s)", def.Kind))
+							}),
+						)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return layout.Flex{
+							Axis: layout.Horizontal,
+						}.Layout(
+							gtx,
+							layout.Flexed(1, func(gtx C) D {
+								if def.Kind != avisha.Metered {
+									gtx.Queue = nil
+								}
+								return inputs.Unit.Layout(gtx, th.Dark(), "Unit")
+							}),
+							layout.Rigid(func(gtx C) D {
+								return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+							}),
+							layout.Flexed(1, func(gtx C) D {
+								return inputs.Reference.Layout(gtx, th.Dark(), "Payment Reference")
+							}),
+						)
+					}),
+					layout.Rigid(func(gtx C) D {
+						if def.Kind != avisha.Metered {
+							return D{}
+						}
+						return s.LayoutTariffs(gtx, th, def, inputs)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return layout.Flex{
+							Axis:      layout.Horizontal,
+							Alignment: layout.Middle,
+						}.Layout(
+							gtx,
+							tax(inputs, avisha.Standard),
+							tax(inputs, avisha.ZeroRated),
+							tax(inputs, avisha.Exempt),
+							layout.Rigid(func(gtx C) D {
+								return material.CheckBox(th.Dark(), &inputs.Default, "Subscribe new leases").Layout(gtx)
+							}),
+							layout.Rigid(func(gtx C) D {
+								if def.Kind != avisha.Metered {
+									return D{}
+								}
+								return material.CheckBox(th.Dark(), &inputs.PassLineCharge, "Line charge passes on supplier charges").Layout(gtx)
+							}),
+						)
+					}),
+				)
+			})
+		})
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, items...)
+}
+
+// LayoutTariffs renders the tariffs of a metered service, oldest first.
+func (s *SettingsForm) LayoutTariffs(gtx C, th *style.Theme, def avisha.ServiceDefinition, inputs *ServiceFields) D {
+	if len(inputs.RemoveTariff) != len(def.Tariffs) {
+		inputs.RemoveTariff = make([]widget.Clickable, len(def.Tariffs))
+	}
+	rows := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			if len(def.Tariffs) > 0 {
+				return D{}
+			}
+			return material.Body2(th.Muted(), fmt.Sprintf("No tariff, charged at the default price per %s", def.Units())).Layout(gtx)
+		}),
+	}
+	for ii, tariff := range def.Tariffs {
+		var (
+			tariff = tariff
+			remove = &inputs.RemoveTariff[ii]
+		)
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			summary := fmt.Sprintf(
+				"From %s: %s per day, %d tiers",
+				util.FormatTime(tariff.Effective),
+				tariff.DailyCharge,
+				len(tariff.Tiers),
+			)
+			if tariff.TimeOfUse {
+				summary += fmt.Sprintf(", off-peak %s", tariff.OffPeak)
+			}
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				layout.Flexed(1, func(gtx C) D {
+					return material.Body2(th.Dark(), summary).Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					b := material.Button(th.Danger(), remove, "Remove")
+					b.Inset = layout.UniformInset(unit.Dp(5))
+					return b.Layout(gtx)
+				}),
+			)
+		}))
+	}
+	rows = append(rows, layout.Rigid(func(gtx C) D {
+		return layout.Inset{Top: unit.Dp(5), Bottom: unit.Dp(5)}.Layout(gtx, func(gtx C) D {
+			b := material.Button(th.Secondary(), &inputs.AddTariff, "New Tariff")
+			b.Inset = layout.UniformInset(unit.Dp(5))
+			return b.Layout(gtx)
+		})
+	}))
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
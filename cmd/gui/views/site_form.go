@@ -1,17 +1,21 @@
 package views
 
 import (
+	"errors"
 	"fmt"
 	"image"
-	"log"
+	"strconv"
 
 	"gioui.org/layout"
 	"gioui.org/text"
 	"gioui.org/unit"
 	"gioui.org/widget/material"
 	"git.sr.ht/~whereswaldon/materials"
+	"github.com/asdine/storm/v3"
 	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/icons"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
 )
@@ -20,14 +24,65 @@ type SiteForm struct {
 	nav.Route
 	App *avisha.App
 	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+	// Invalidate requests a frame once the meters have loaded.
+	Invalidate func()
 
 	Site avisha.Site
+	// History shows the audit trail of the site.
+	History widget.Clickable
+	// Archive archives the site, or restores it if archived.
+	Archive widget.Clickable
+	// Delete deletes the site, if it has never been leased or metered.
+	Delete  widget.Clickable
+	confirm Confirmation
 
-	Number materials.TextField
+	Number    materials.TextField
+	FloorArea materials.TextField
 
 	Form      widget.Form
 	SubmitBtn widget.Clickable
 	CancelBtn widget.Clickable
+
+	MeterForm   MeterForm
+	ReadingForm ReadingForm
+
+	modal    layout.Widget
+	settings avisha.Settings
+	metered  map[avisha.ServiceKey]siteMeter
+	meters   map[avisha.ServiceKey]*meterCard
+	scroll   layout.List
+	loader   Loader
+}
+
+// siteData is the data shown with a site.
+type siteData struct {
+	Site     int
+	Settings avisha.Settings
+	// Meters in service at the site, by service.
+	Meters map[avisha.ServiceKey]siteMeter
+}
+
+// siteMeter is a meter in service along with its readings, oldest first.
+// Both are zero if no meter is in service.
+type siteMeter struct {
+	Meter    avisha.Meter
+	Readings []*avisha.Reading
+}
+
+// Last returns the last reading of the meter, zero if there is none.
+func (m siteMeter) Last() avisha.Reading {
+	if len(m.Readings) == 0 {
+		return avisha.Reading{}
+	}
+	return *m.Readings[len(m.Readings)-1]
+}
+
+// meterCard contains the actions for the meter of a metered service.
+type meterCard struct {
+	Read    widget.Clickable
+	Replace widget.Clickable
 }
 
 func (l *SiteForm) Title() string {
@@ -40,11 +95,16 @@ func (l *SiteForm) Receive(data interface{}) {
 	} else {
 		l.Site = avisha.Site{}
 	}
+	l.modal = nil
 	l.Form.Load([]widget.Field{
 		{
 			Value: widget.RequiredValuer{Valuer: widget.TextValuer{Value: &l.Site.Number}},
 			Input: &l.Number,
 		},
+		{
+			Value: widget.FloatValuer{Value: &l.Site.FloorArea},
+			Input: &l.FloorArea,
+		},
 	})
 }
 
@@ -61,9 +121,34 @@ func (l *SiteForm) Context() (list []layout.Widget) {
 				})
 		})
 	}
+	if l.Site.ID != 0 {
+		list = append(list, func(gtx C) D {
+			return material.IconButton(l.Th.Primary(), &l.History, icons.History).Layout(gtx)
+		})
+		list = append(list, func(gtx C) D {
+			icon := icons.Archive
+			if l.Site.IsArchived() {
+				icon = icons.Unarchive
+			}
+			return material.IconButton(l.Th.Primary(), &l.Archive, icon).Layout(gtx)
+		})
+		list = append(list, func(gtx C) D {
+			return material.IconButton(l.Th.Primary(), &l.Delete, icons.Delete).Layout(gtx)
+		})
+	}
 	return list
 }
 
+func (l *SiteForm) Modal(gtx C) D {
+	if l.confirm.Asking() {
+		return l.confirm.Layout(gtx, l.Th)
+	}
+	if l.modal == nil {
+		return D{}
+	}
+	return l.modal(gtx)
+}
+
 // Submit validates form data and returns a boolean to indicate validity.
 func (l *SiteForm) Submit() (s avisha.Site, ok bool) {
 	return l.Site, l.Form.Submit()
@@ -71,6 +156,44 @@ func (l *SiteForm) Submit() (s avisha.Site, ok bool) {
 
 func (l *SiteForm) Update(gtx C) {
 	l.Form.Validate(gtx)
+	if l.History.Clicked() {
+		l.Route.To(RouteAudit, &AuditSubject{
+			Entity: "Site",
+			ID:     l.Site.ID,
+			Title:  fmt.Sprintf("Site %s", l.Site.Number),
+		})
+	}
+	if l.Archive.Clicked() {
+		id := l.Site.ID
+		if l.Site.IsArchived() {
+			l.confirm.Ask(
+				"Restore Site",
+				fmt.Sprintf("Restore site %s to the list of sites?", l.Site.Number),
+				"Restore", false,
+				func() error { return l.App.RestoreSite(id) },
+			)
+		} else {
+			l.confirm.Ask(
+				"Archive Site",
+				fmt.Sprintf("Archive site %s? It will be hidden from the list of sites, and its history kept.", l.Site.Number),
+				"Archive", false,
+				func() error { return l.App.ArchiveSite(id) },
+			)
+		}
+	}
+	if l.Delete.Clicked() {
+		id := l.Site.ID
+		l.confirm.Ask(
+			"Delete Site",
+			fmt.Sprintf("Delete site %s for good? This can't be undone.", l.Site.Number),
+			"Delete", true,
+			func() error { return l.App.DeleteSite(id) },
+		)
+	}
+	if l.confirm.Update() {
+		l.Form.Clear()
+		l.Route.Back()
+	}
 	if l.SubmitBtn.Clicked() {
 		if s, ok := l.Submit(); ok {
 			if err := func() error {
@@ -79,13 +202,13 @@ func (l *SiteForm) Update(gtx C) {
 						return fmt.Errorf("listing site: %w", err)
 					}
 				} else {
-					if err := l.App.Update(&s); err != nil {
+					if err := l.App.UpdateSite(&s); err != nil {
 						return fmt.Errorf("updating site: %w", err)
 					}
 				}
 				return nil
 			}(); err != nil {
-				log.Printf("%v", err)
+				l.Alerts.Error(err)
 			} else {
 				l.Form.Clear()
 				l.Route.Back()
@@ -96,6 +219,128 @@ func (l *SiteForm) Update(gtx C) {
 		l.Form.Clear()
 		l.Route.Back()
 	}
+	if l.Site.ID == 0 {
+		return
+	}
+	l.refresh()
+	for _, def := range l.settings.Services {
+		if def.Kind != avisha.Metered {
+			continue
+		}
+		var (
+			def         = def
+			card        = l.card(def.Key)
+			meter, last = l.metered[def.Key].Meter, l.metered[def.Key].Last()
+		)
+		if card.Read.Clicked() {
+			l.ReadingForm.Load(meter, last)
+			l.modal = func(gtx C) D {
+				return style.ModalDialog(gtx, l.Th, unit.Dp(500), fmt.Sprintf("Read %s Meter", def.Name), func(gtx C) D {
+					return l.ReadingForm.Layout(gtx, l.Th)
+				})
+			}
+		}
+		if card.Replace.Clicked() {
+			title := fmt.Sprintf("Install %s Meter", def.Name)
+			if meter.ID != 0 {
+				title = fmt.Sprintf("Replace %s Meter", def.Name)
+			}
+			l.MeterForm.Load(def.Key, meter, last)
+			l.modal = func(gtx C) D {
+				return style.ModalDialog(gtx, l.Th, unit.Dp(500), title, func(gtx C) D {
+					return l.MeterForm.Layout(gtx, l.Th)
+				})
+			}
+		}
+	}
+	if l.ReadingForm.SubmitBtn.Clicked() {
+		if reading, ok := l.ReadingForm.Submit(); ok {
+			if err := l.App.RecordReading(&reading); err != nil {
+				l.ReadingForm.Value.SetError(err.Error())
+			} else {
+				l.ReadingForm.Clear()
+				l.modal = nil
+			}
+		}
+	}
+	if l.ReadingForm.CancelBtn.Clicked() {
+		l.ReadingForm.Clear()
+		l.modal = nil
+	}
+	if l.MeterForm.SubmitBtn.Clicked() {
+		if ok := l.MeterForm.Submit(); ok {
+			if err := func() error {
+				m := l.MeterForm.Meter
+				m.Site = l.Site.ID
+				if l.MeterForm.IsReplacement() {
+					return l.App.ReplaceMeter(l.MeterForm.Replacing.ID, l.MeterForm.Closing, &m, l.MeterForm.Opening)
+				}
+				return l.App.InstallMeter(&m, l.MeterForm.Opening)
+			}(); err != nil {
+				l.MeterForm.OpeningReading.SetError(err.Error())
+			} else {
+				l.MeterForm.Clear()
+				l.modal = nil
+			}
+		}
+	}
+	if l.MeterForm.CancelBtn.Clicked() {
+		l.MeterForm.Clear()
+		l.modal = nil
+	}
+}
+
+// loadSite loads the settings and the meters in service at the site, with
+// their readings.
+func loadSite(app avisha.App, id int) (data siteData, err error) {
+	data.Site = id
+	if data.Settings, err = app.LoadSettings(); err != nil {
+		return data, fmt.Errorf("loading settings: %w", err)
+	}
+	data.Meters = make(map[avisha.ServiceKey]siteMeter)
+	for _, def := range data.Settings.Services {
+		if def.Kind != avisha.Metered {
+			continue
+		}
+		var m siteMeter
+		m.Meter, err = app.ActiveMeter(id, def.Key)
+		if errors.Is(err, storm.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return data, fmt.Errorf("loading %s meter: %w", def.Key, err)
+		}
+		if m.Readings, err = app.Readings(m.Meter.ID); err != nil {
+			return data, fmt.Errorf("loading %s readings: %w", def.Key, err)
+		}
+		data.Meters[def.Key] = m
+	}
+	return data, nil
+}
+
+// refresh takes up the meters loaded in the background.
+func (l *SiteForm) refresh() {
+	id := l.Site.ID
+	v, _ := l.loader.Load(l.App, l.Invalidate, strconv.Itoa(id), func(app avisha.App) (interface{}, error) {
+		data, err := loadSite(app, id)
+		l.Alerts.Error(err)
+		return data, err
+	})
+	if data, ok := v.(siteData); ok && data.Site == id {
+		l.settings, l.metered = data.Settings, data.Meters
+	}
+}
+
+// card returns the action state for the meter card.
+func (l *SiteForm) card(key avisha.ServiceKey) *meterCard {
+	if l.meters == nil {
+		l.meters = make(map[avisha.ServiceKey]*meterCard)
+	}
+	card, ok := l.meters[key]
+	if !ok {
+		card = &meterCard{}
+		l.meters[key] = card
+	}
+	return card
 }
 
 func (l *SiteForm) Layout(gtx C) D {
@@ -103,44 +348,166 @@ func (l *SiteForm) Layout(gtx C) D {
 	if breakpoint := gtx.Px(unit.Dp(700)); gtx.Constraints.Max.X > breakpoint {
 		gtx.Constraints.Max.X = breakpoint
 	}
-	return layout.UniformInset(unit.Dp(20)).Layout(gtx, func(gtx C) D {
-		return layout.Flex{
-			Axis: layout.Vertical,
-		}.Layout(
-			gtx,
-			layout.Rigid(func(gtx C) D {
-				return layout.Flex{
-					Axis: layout.Vertical,
-				}.Layout(
-					gtx,
-					layout.Rigid(func(gtx C) D {
-						l.Number.SingleLine = true
-						return l.Number.Layout(gtx, l.Th.Dark(), "Number")
-					}),
-				)
-			}),
-			layout.Rigid(func(gtx C) D {
-				return layout.Inset{
-					Top: unit.Dp(10),
-				}.Layout(
-					gtx,
-					func(gtx C) D {
-						return layout.Flex{
-							Axis: layout.Horizontal,
-						}.Layout(
-							gtx,
-							layout.Rigid(func(gtx C) D {
-								return material.Button(l.Th.Secondary(), &l.CancelBtn, "Cancel").Layout(gtx)
-							}),
-							layout.Rigid(func(gtx C) D {
-								return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
-							}),
-							layout.Rigid(func(gtx C) D {
-								return material.Button(l.Th.Primary(), &l.SubmitBtn, "Submit").Layout(gtx)
-							}),
-						)
-					})
+	l.scroll.Axis = layout.Vertical
+	return l.scroll.Layout(gtx, 1, func(gtx C, _ int) D {
+		return layout.UniformInset(unit.Dp(20)).Layout(gtx, func(gtx C) D {
+			return layout.Flex{
+				Axis: layout.Vertical,
+			}.Layout(
+				gtx,
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{
+						Axis: layout.Vertical,
+					}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							l.Number.SingleLine = true
+							return l.Number.Layout(gtx, l.Th.Dark(), "Number")
+						}),
+						layout.Rigid(func(gtx C) D {
+							l.FloorArea.SingleLine = true
+							l.FloorArea.Suffix = func(gtx C) D {
+								return material.Body1(l.Th.Dark(), "m²").Layout(gtx)
+							}
+							return l.FloorArea.Layout(gtx, l.Th.Dark(), "Floor Area")
+						}),
+					)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Inset{
+						Top: unit.Dp(10),
+					}.Layout(
+						gtx,
+						func(gtx C) D {
+							return layout.Flex{
+								Axis: layout.Horizontal,
+							}.Layout(
+								gtx,
+								layout.Rigid(func(gtx C) D {
+									return material.Button(l.Th.Secondary(), &l.CancelBtn, "Cancel").Layout(gtx)
+								}),
+								layout.Rigid(func(gtx C) D {
+									return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+								}),
+								layout.Rigid(func(gtx C) D {
+									return material.Button(l.Th.Primary(), &l.SubmitBtn, "Submit").Layout(gtx)
+								}),
+							)
+						})
+				}),
+				layout.Rigid(func(gtx C) D {
+					if l.Site.ID == 0 {
+						return D{}
+					}
+					return layout.Inset{
+						Top: unit.Dp(20),
+					}.Layout(gtx, l.LayoutMeters)
+				}),
+			)
+		})
+	})
+}
+
+// LayoutMeters renders a card for the meter of each metered service.
+func (l *SiteForm) LayoutMeters(gtx C) D {
+	items := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return material.Label(l.Th.Dark(), unit.Dp(20), "Meters").Layout(gtx)
+		}),
+	}
+	for _, def := range l.settings.Services {
+		if def.Kind != avisha.Metered {
+			continue
+		}
+		def := def
+		items = append(items, layout.Rigid(func(gtx C) D {
+			return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+				return l.LayoutMeter(gtx, def)
+			})
+		}))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, items...)
+}
+
+// LayoutMeter renders the meter in service for a metered service, along with
+// its recent readings.
+func (l *SiteForm) LayoutMeter(gtx C, def avisha.ServiceDefinition) D {
+	var (
+		card     = l.card(def.Key)
+		content  []layout.Widget
+		meter    = l.metered[def.Key].Meter
+		readings = l.metered[def.Key].Readings
+	)
+	content = append(content, func(gtx C) D {
+		return material.H6(l.Th.Dark(), fmt.Sprintf("%s Meter", def.Name)).Layout(gtx)
+	})
+	if meter.ID == 0 {
+		content = append(content, func(gtx C) D {
+			return material.Body1(l.Th.Muted(), "No meter installed").Layout(gtx)
+		})
+	} else {
+		content = append(content, func(gtx C) D {
+			digits := "digits unknown"
+			if meter.Digits > 0 {
+				digits = fmt.Sprintf("%d digits", meter.Digits)
+			}
+			if meter.TimeOfUse {
+				digits += ", time of use"
+			}
+			return material.Body1(l.Th.Muted(), fmt.Sprintf(
+				"%s (%s), installed %s",
+				meter,
+				digits,
+				util.FormatTime(meter.Installed),
+			)).Layout(gtx)
+		})
+		// Show the most recent readings first.
+		for ii := len(readings) - 1; ii >= 0 && ii >= len(readings)-5; ii-- {
+			r := readings[ii]
+			content = append(content, func(gtx C) D {
+				label := fmt.Sprintf("%s  %d %s", util.FormatTime(r.Date), r.Value, def.Units())
+				if meter.TimeOfUse {
+					label += fmt.Sprintf(", %d off-peak", r.OffPeak)
+				}
+				if r.Estimated {
+					label += " (estimated)"
+				}
+				if r.Note != "" {
+					label += fmt.Sprintf(" - %s", r.Note)
+				}
+				return material.Body2(l.Th.Dark(), label).Layout(gtx)
+			})
+		}
+	}
+	content = append(content, func(gtx C) D {
+		replace := "Install"
+		if meter.ID != 0 {
+			replace = "Replace"
+		}
+		actions := []layout.FlexChild{
+			layout.Flexed(1, func(gtx C) D {
+				b := material.Button(l.Th.Warning(), &card.Replace, replace)
+				b.Inset = layout.UniformInset(unit.Dp(5))
+				return b.Layout(gtx)
 			}),
-		)
+		}
+		if meter.ID != 0 {
+			actions = append(
+				actions,
+				layout.Rigid(func(gtx C) D {
+					return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+				}),
+				layout.Flexed(1, func(gtx C) D {
+					b := material.Button(l.Th.Primary(), &card.Read, "Read")
+					b.Inset = layout.UniformInset(unit.Dp(5))
+					return b.Layout(gtx)
+				}),
+			)
+		}
+		return layout.Flex{
+			Axis:      layout.Horizontal,
+			Alignment: layout.Middle,
+		}.Layout(gtx, actions...)
 	})
+	return style.Card{Content: content}.Layout(gtx, l.Th.Dark())
 }
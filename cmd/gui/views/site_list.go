@@ -21,12 +21,23 @@ type Sites struct {
 
 	App *avisha.App
 	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+	// Invalidate requests a frame once sites have loaded.
+	Invalidate func()
 
 	RegisterSite widget.Clickable
+	// Search matches sites by number as it is typed.
+	Search widget.Editor
+	// Dwellings filters sites by dwelling type.
+	Dwellings dwellingChips
+	// Archived shows archived sites along with the rest.
+	Archived widget.Bool
 
 	list   layout.List
 	states States
 	once   sync.Once
+	loader Loader
 }
 
 func (s *Sites) Title() string {
@@ -35,6 +46,9 @@ func (s *Sites) Title() string {
 
 func (s *Sites) Context() []layout.Widget {
 	return []layout.Widget{
+		func(gtx C) D {
+			return style.SearchBar(s.Th.Dark(), &s.Search, "Search sites").Layout(gtx)
+		},
 		func(gtx C) D {
 			return material.IconButton(
 				s.Th.Primary(),
@@ -63,15 +77,39 @@ func (s *Sites) Layout(gtx C) D {
 	})
 	s.Update(gtx)
 	s.states.Begin()
-	var (
-		sites []*avisha.Site
-	)
-	if err := s.App.All(&sites); err != nil {
-		fmt.Printf("error: loading sites: %v\n", err)
+	query := avisha.SiteQuery{
+		Search:    s.Search.Text(),
+		Dwellings: s.Dwellings.Selected(),
+		Archived:  s.Archived.Value,
 	}
+	data, _ := s.loader.Load(s.App, s.Invalidate, fmt.Sprintf("%+v", query), func(app avisha.App) (interface{}, error) {
+		sites, err := app.QuerySites(query)
+		if err != nil {
+			s.Alerts.Error(fmt.Errorf("loading sites: %w", err))
+		}
+		return sites, err
+	})
+	sites, _ := data.([]avisha.Site)
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
+		layout.Rigid(func(gtx C) D {
+			return layoutChips(gtx, append(s.Dwellings.Chips(s.Th), layout.Rigid(func(gtx C) D {
+				return style.Chip(s.Th.Primary(), &s.Archived, "Archived").Layout(gtx)
+			}))...)
+		}),
+		layout.Flexed(1, func(gtx C) D {
+			return s.LayoutList(gtx, sites)
+		}),
+	)
+}
+
+// LayoutList renders the sites.
+func (s *Sites) LayoutList(gtx C, sites []avisha.Site) D {
 	return s.list.Layout(gtx, len(sites), func(gtx C, index int) D {
 		var (
-			site   = sites[index]
+			site   = &sites[index]
 			state  = s.states.Next(unsafe.Pointer(site))
 			active = false
 		)
@@ -82,11 +120,11 @@ func (s *Sites) Layout(gtx C) D {
 			&state.Hover,
 			active,
 			func(gtx C) D {
-				return material.Label(
+				return layoutArchived(gtx, s.Th, site.IsArchived(), material.Label(
 					s.Th.Dark(),
 					unit.Dp(20),
 					site.Number,
-				).Layout(gtx)
+				).Layout)
 			})
 	})
 }
@@ -0,0 +1,424 @@
+package views
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"time"
+	"unsafe"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"git.sr.ht/~whereswaldon/materials"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+)
+
+// SupplierBillsPage records the bills received from suppliers of metered
+// services and reconciles them with what was recovered from tenants.
+type SupplierBillsPage struct {
+	nav.Route
+	App *avisha.App
+	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+	// Invalidate requests a frame once the bills have loaded.
+	Invalidate func()
+
+	// Service selects the metered service.
+	Service widget.Enum
+
+	Bill avisha.SupplierBill
+	// end of the bill period.
+	end time.Time
+
+	Supplier   materials.TextField
+	Reference  materials.TextField
+	Start      materials.TextField
+	End        materials.TextField
+	Units      materials.TextField
+	Cost       materials.TextField
+	Allocation widget.Enum
+
+	Form      widget.Form
+	SubmitBtn widget.Clickable
+
+	// selected is the bill being reconciled.
+	selected int
+	settings avisha.Settings
+	data     supplierData
+	states   States
+	err      string
+	scroll   layout.List
+	loader   Loader
+}
+
+// supplierData is the data shown for the selected service.
+type supplierData struct {
+	Settings avisha.Settings
+	Bills    []*avisha.SupplierBill
+	// Reconciliation of the selected bill, if any.
+	Reconciliation *avisha.Reconciliation
+	// Sites allocated the selected bill, by ID.
+	Sites map[int]avisha.Site
+}
+
+// loadSupplier loads the bills of the service and reconciles the selected
+// bill, if any.
+func loadSupplier(app avisha.App, key avisha.ServiceKey, selected int) (data supplierData, err error) {
+	if data.Settings, err = app.LoadSettings(); err != nil {
+		return data, fmt.Errorf("loading settings: %w", err)
+	}
+	if data.Bills, err = app.SupplierBills(key); err != nil {
+		return data, fmt.Errorf("loading supplier bills: %w", err)
+	}
+	if selected == 0 {
+		return data, nil
+	}
+	r, err := app.Reconcile(selected)
+	if err != nil {
+		return data, fmt.Errorf("reconciling: %w", err)
+	}
+	data.Reconciliation = &r
+	data.Sites = make(map[int]avisha.Site)
+	for _, a := range r.Allocations {
+		var site avisha.Site
+		if err := app.One("ID", a.Site, &site); err != nil {
+			return data, fmt.Errorf("loading site: %w", err)
+		}
+		data.Sites[a.Site] = site
+	}
+	return data, nil
+}
+
+// refresh takes up the data loaded in the background.
+func (p *SupplierBillsPage) refresh() {
+	var (
+		key      = avisha.ServiceKey(p.Service.Value)
+		selected = p.selected
+	)
+	v, _ := p.loader.Load(p.App, p.Invalidate, fmt.Sprintf("%s/%d", key, selected), func(app avisha.App) (interface{}, error) {
+		data, err := loadSupplier(app, key, selected)
+		p.Alerts.Error(err)
+		return data, err
+	})
+	if data, ok := v.(supplierData); ok {
+		p.settings, p.data = data.Settings, data
+	}
+}
+
+func (p *SupplierBillsPage) Title() string {
+	return "Supplier Bills"
+}
+
+func (p *SupplierBillsPage) Receive(data interface{}) {
+	p.selected = 0
+	p.err = ""
+	p.load()
+}
+
+// load the form to record a new bill.
+func (p *SupplierBillsPage) load() {
+	p.Bill = avisha.SupplierBill{}
+	p.end = time.Time{}
+	p.Allocation.Value = strconv.Itoa(int(avisha.ByConsumption))
+	p.Form.Load([]widget.Field{
+		{
+			Value: widget.RequiredValuer{Valuer: widget.TextValuer{Value: &p.Bill.Supplier}},
+			Input: &p.Supplier,
+		},
+		{
+			Value: widget.TextValuer{Value: &p.Bill.Reference},
+			Input: &p.Reference,
+		},
+		{
+			Value: widget.DateValuer{Value: &p.Bill.Period.Start},
+			Input: &p.Start,
+		},
+		{
+			Value: widget.DateValuer{Value: &p.end, Default: today()},
+			Input: &p.End,
+		},
+		{
+			Value: widget.IntValuer{Value: &p.Bill.Units},
+			Input: &p.Units,
+		},
+		{
+			Value: widget.CurrencyValuer{Value: &p.Bill.Cost},
+			Input: &p.Cost,
+		},
+	})
+}
+
+func (p *SupplierBillsPage) Update(gtx C) {
+	p.refresh()
+	if p.Service.Value == "" {
+		for _, def := range p.settings.Services {
+			if def.Kind == avisha.Metered {
+				p.Service.Value = string(def.Key)
+				break
+			}
+		}
+	}
+	if p.Service.Changed() {
+		p.selected = 0
+	}
+	p.Form.Validate(gtx)
+	if p.SubmitBtn.Clicked() {
+		if p.Form.Submit() {
+			bill := p.Bill
+			bill.Service = avisha.ServiceKey(p.Service.Value)
+			bill.Period.Duration = p.end.Sub(bill.Period.Start)
+			if allocation, err := strconv.Atoi(p.Allocation.Value); err == nil {
+				bill.Allocation = avisha.AllocationMethod(allocation)
+			}
+			if err := p.App.RecordSupplierBill(&bill); err != nil {
+				p.err = fmt.Sprintf("recording supplier bill: %v", err)
+			} else {
+				p.err = ""
+				p.selected = bill.ID
+				p.Form.Clear()
+				p.load()
+			}
+		}
+	}
+	for _, state := range p.states.List() {
+		if state.Item.Clicked() {
+			p.selected = (*avisha.SupplierBill)(state.Data).ID
+		}
+	}
+}
+
+func (p *SupplierBillsPage) Layout(gtx C) D {
+	p.Update(gtx)
+	p.scroll.Axis = layout.Vertical
+	return p.scroll.Layout(gtx, 1, func(gtx C, _ int) D {
+		return layout.UniformInset(unit.Dp(20)).Layout(gtx, func(gtx C) D {
+			return layout.Flex{
+				Axis: layout.Vertical,
+			}.Layout(
+				gtx,
+				layout.Rigid(p.LayoutServices),
+				layout.Rigid(p.LayoutForm),
+				layout.Rigid(func(gtx C) D {
+					if p.err == "" {
+						return D{}
+					}
+					return material.Body1(p.Th.Danger(), p.err).Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Inset{Top: unit.Dp(20)}.Layout(gtx, p.LayoutBills)
+				}),
+				layout.Rigid(func(gtx C) D {
+					if p.selected == 0 {
+						return D{}
+					}
+					return layout.Inset{Top: unit.Dp(20)}.Layout(gtx, p.LayoutReconciliation)
+				}),
+			)
+		})
+	})
+}
+
+// LayoutServices renders the choice of metered service.
+func (p *SupplierBillsPage) LayoutServices(gtx C) D {
+	var services []layout.FlexChild
+	for _, def := range p.settings.Services {
+		if def.Kind != avisha.Metered {
+			continue
+		}
+		def := def
+		services = append(services, layout.Rigid(func(gtx C) D {
+			return material.RadioButton(p.Th.Dark(), &p.Service, string(def.Key), def.Name).Layout(gtx)
+		}))
+	}
+	return layout.Flex{
+		Axis:      layout.Horizontal,
+		Alignment: layout.Middle,
+	}.Layout(gtx, services...)
+}
+
+// LayoutForm renders the form to record a supplier bill.
+func (p *SupplierBillsPage) LayoutForm(gtx C) D {
+	var (
+		def, _ = p.settings.Service(avisha.ServiceKey(p.Service.Value))
+		spacer = func(gtx C) D {
+			return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+		}
+		pair = func(left, right layout.Widget) layout.FlexChild {
+			return layout.Rigid(func(gtx C) D {
+				return layout.Flex{
+					Axis: layout.Horizontal,
+				}.Layout(
+					gtx,
+					layout.Flexed(1, left),
+					layout.Rigid(spacer),
+					layout.Flexed(1, right),
+				)
+			})
+		}
+		allocation = func(method avisha.AllocationMethod) layout.FlexChild {
+			return layout.Rigid(func(gtx C) D {
+				return material.RadioButton(p.Th.Dark(), &p.Allocation, strconv.Itoa(int(method)), method.String()).Layout(gtx)
+			})
+		}
+	)
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
+		pair(
+			func(gtx C) D {
+				return p.Supplier.Layout(gtx, p.Th.Dark(), "Supplier")
+			},
+			func(gtx C) D {
+				return p.Reference.Layout(gtx, p.Th.Dark(), "Bill Number")
+			},
+		),
+		pair(
+			func(gtx C) D {
+				return p.Start.Layout(gtx, p.Th.Dark(), "Period Start")
+			},
+			func(gtx C) D {
+				return p.End.Layout(gtx, p.Th.Dark(), "Period End")
+			},
+		),
+		pair(
+			func(gtx C) D {
+				return p.Units.Layout(gtx, p.Th.Dark(), fmt.Sprintf("Units (%s)", def.Units()))
+			},
+			func(gtx C) D {
+				p.Cost.Prefix = func(gtx C) D {
+					return material.Body1(p.Th.Dark(), "$").Layout(gtx)
+				}
+				return p.Cost.Layout(gtx, p.Th.Dark(), "Cost (excluding GST)")
+			},
+		),
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				layout.Rigid(func(gtx C) D {
+					return material.Body1(p.Th.Dark(), "Allocate by").Layout(gtx)
+				}),
+				allocation(avisha.ByConsumption),
+				allocation(avisha.ByFloorArea),
+				allocation(avisha.Equally),
+				layout.Flexed(1, func(gtx C) D {
+					return D{Size: gtx.Constraints.Min}
+				}),
+				layout.Rigid(func(gtx C) D {
+					return material.Button(p.Th.Primary(), &p.SubmitBtn, "Record").Layout(gtx)
+				}),
+			)
+		}),
+	)
+}
+
+// LayoutBills renders the bills recorded for the service, most recent first.
+func (p *SupplierBillsPage) LayoutBills(gtx C) D {
+	bills := p.data.Bills
+	p.states.Begin()
+	items := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return material.Label(p.Th.Dark(), unit.Dp(20), "Bills").Layout(gtx)
+		}),
+	}
+	if len(bills) == 0 {
+		items = append(items, layout.Rigid(func(gtx C) D {
+			return material.Body1(p.Th.Muted(), "No bills recorded").Layout(gtx)
+		}))
+	}
+	for _, bill := range bills {
+		var (
+			bill  = bill
+			state = p.states.Next(unsafe.Pointer(bill))
+		)
+		items = append(items, layout.Rigid(func(gtx C) D {
+			return style.ListItem(
+				gtx,
+				p.Th.Dark(),
+				&state.Item,
+				&state.Hover,
+				bill.ID == p.selected,
+				func(gtx C) D {
+					return layout.UniformInset(unit.Dp(10)).Layout(gtx, func(gtx C) D {
+						return material.Body1(p.Th.Dark(), fmt.Sprintf(
+							"%s %s, %s: %d units, %s",
+							bill.Supplier,
+							bill.Reference,
+							bill.Period,
+							bill.Units,
+							bill.Cost,
+						)).Layout(gtx)
+					})
+				},
+			)
+		}))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, items...)
+}
+
+// LayoutReconciliation renders the allocation of the selected bill and what
+// was recovered against it.
+func (p *SupplierBillsPage) LayoutReconciliation(gtx C) D {
+	if p.data.Reconciliation == nil || p.data.Reconciliation.Bill.ID != p.selected {
+		return D{}
+	}
+	var (
+		r   = *p.data.Reconciliation
+		row = func(header bool, cells ...string) layout.FlexChild {
+			return layout.Rigid(func(gtx C) D {
+				children := make([]layout.FlexChild, len(cells))
+				for ii, text := range cells {
+					text := text
+					children[ii] = layout.Flexed(1, func(gtx C) D {
+						lb := material.Body1(p.Th.Dark(), text)
+						if header {
+							lb = material.Body2(p.Th.Muted(), text)
+						}
+						return layout.UniformInset(unit.Dp(5)).Layout(gtx, lb.Layout)
+					})
+				}
+				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, children...)
+			})
+		}
+		rows = []layout.FlexChild{
+			layout.Rigid(func(gtx C) D {
+				return material.Label(p.Th.Dark(), unit.Dp(20), fmt.Sprintf(
+					"Reconciliation (allocated by %s)",
+					r.Bill.Allocation,
+				)).Layout(gtx)
+			}),
+			row(true, "Site", "Units", "Share", "Allocated", "Recovered", "Variance"),
+		}
+	)
+	for _, d := range r.Discrepancies {
+		d := d
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return material.Body1(p.Th.Danger(), d).Layout(gtx)
+		}))
+	}
+	for _, a := range r.Allocations {
+		rows = append(rows, row(
+			false,
+			p.data.Sites[a.Site].Number,
+			strconv.Itoa(a.Units),
+			fmt.Sprintf("%.1f%%", a.Share*100),
+			a.Cost.String(),
+			a.Recovered.String(),
+			a.Variance().String(),
+		))
+	}
+	rows = append(
+		rows,
+		row(false, "Common", strconv.Itoa(r.CommonUnits), "", r.CommonCost.String(), "", ""),
+		row(true, "Total", strconv.Itoa(r.Bill.Units), "", r.Bill.Cost.String(), r.Recovered.String(), r.Variance().String()),
+	)
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
@@ -0,0 +1,234 @@
+package views
+
+import (
+	"fmt"
+	"image"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"git.sr.ht/~whereswaldon/materials"
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
+)
+
+// TariffForm collects a tariff for a metered service.
+type TariffForm struct {
+	Tariff avisha.Tariff
+	// Service the tariff prices.
+	Service avisha.ServiceDefinition
+
+	Effective   materials.TextField
+	DailyCharge materials.TextField
+	OffPeak     materials.TextField
+	TimeOfUse   widget.Bool
+	Tiers       []TierFields
+
+	AddTier    widget.Clickable
+	RemoveTier widget.Clickable
+
+	Form      widget.Form
+	SubmitBtn widget.Clickable
+	CancelBtn widget.Clickable
+}
+
+// TierFields edits a tier of the tariff.
+type TierFields struct {
+	Units materials.TextField
+	Price materials.TextField
+}
+
+// Load the form with a tariff for the service, starting from the tariff in
+// effect today so that a new version only needs the changes entered.
+func (f *TariffForm) Load(def avisha.ServiceDefinition) {
+	f.Service = def
+	f.Tariff = avisha.Tariff{Tiers: []avisha.Tier{{Price: def.Price}}}
+	if current, ok := def.TariffAt(today()); ok {
+		f.Tariff = current
+		f.Tariff.Tiers = append([]avisha.Tier(nil), current.Tiers...)
+	}
+	f.Tariff.Effective = today()
+	f.TimeOfUse.Value = f.Tariff.TimeOfUse
+	f.load()
+}
+
+// load binds the inputs to the tariff, with an input for each tier.
+func (f *TariffForm) load() {
+	if len(f.Tiers) != len(f.Tariff.Tiers) {
+		f.Tiers = make([]TierFields, len(f.Tariff.Tiers))
+	}
+	fields := []widget.Field{
+		{
+			Value: widget.DateValuer{Value: &f.Tariff.Effective},
+			Input: &f.Effective,
+		},
+		{
+			Value: widget.CurrencyValuer{Value: &f.Tariff.DailyCharge},
+			Input: &f.DailyCharge,
+		},
+		{
+			Value: widget.CurrencyValuer{Value: &f.Tariff.OffPeak},
+			Input: &f.OffPeak,
+		},
+	}
+	for ii := range f.Tariff.Tiers {
+		var (
+			tier   = &f.Tariff.Tiers[ii]
+			inputs = &f.Tiers[ii]
+		)
+		fields = append(
+			fields,
+			widget.Field{
+				Value: widget.IntValuer{Value: &tier.Units},
+				Input: &inputs.Units,
+			},
+			widget.Field{
+				Value: widget.CurrencyValuer{Value: &tier.Price},
+				Input: &inputs.Price,
+			},
+		)
+	}
+	f.Form.Load(fields)
+}
+
+// Submit validates the input data and returns the tariff.
+func (f *TariffForm) Submit() (tariff avisha.Tariff, ok bool) {
+	if !f.Form.Submit() {
+		return tariff, false
+	}
+	f.Tariff.TimeOfUse = f.TimeOfUse.Value
+	if !f.Tariff.TimeOfUse {
+		f.Tariff.OffPeak = 0
+	}
+	if err := f.Tariff.Validate(); err != nil {
+		f.Effective.SetError(err.Error())
+		return tariff, false
+	}
+	tariff = f.Tariff
+	tariff.Tiers = append([]avisha.Tier(nil), f.Tariff.Tiers...)
+	return tariff, true
+}
+
+func (f *TariffForm) Clear() {
+	f.Form.Clear()
+	f.Tiers = nil
+}
+
+func (f *TariffForm) Update(gtx C) {
+	// Tiers are added and removed at the end, keeping what has been entered
+	// for the others.
+	if f.AddTier.Clicked() {
+		f.Form.Submit()
+		f.Tariff.Tiers = append(f.Tariff.Tiers, avisha.Tier{})
+		f.load()
+	}
+	if f.RemoveTier.Clicked() && len(f.Tariff.Tiers) > 1 {
+		f.Form.Submit()
+		f.Tariff.Tiers = f.Tariff.Tiers[:len(f.Tariff.Tiers)-1]
+		f.load()
+	}
+	f.Form.Validate(gtx)
+}
+
+func (f *TariffForm) Layout(gtx C, th *style.Theme) D {
+	f.Update(gtx)
+	var (
+		dollar = func(gtx C) D {
+			return material.Body1(th.Dark(), "$").Layout(gtx)
+		}
+		spacer = func(gtx C) D {
+			return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
+		}
+		fields = []layout.FlexChild{
+			layout.Rigid(func(gtx C) D {
+				return f.Effective.Layout(gtx, th.Dark(), "Effective Date")
+			}),
+			layout.Rigid(func(gtx C) D {
+				f.DailyCharge.Prefix = dollar
+				return f.DailyCharge.Layout(gtx, th.Dark(), "Daily Charge")
+			}),
+		}
+	)
+	for ii := range f.Tiers {
+		var (
+			inputs = &f.Tiers[ii]
+			last   = ii == len(f.Tiers)-1
+			units  = fmt.Sprintf("Tier %d (%s)", ii+1, f.Service.Units())
+		)
+		if last {
+			units = fmt.Sprintf("Tier %d (unlimited)", ii+1)
+		}
+		fields = append(fields, layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis: layout.Horizontal,
+			}.Layout(
+				gtx,
+				layout.Flexed(1, func(gtx C) D {
+					if last {
+						gtx.Queue = nil
+					}
+					return inputs.Units.Layout(gtx, th.Dark(), units)
+				}),
+				layout.Rigid(spacer),
+				layout.Flexed(1, func(gtx C) D {
+					inputs.Price.Prefix = dollar
+					return inputs.Price.Layout(gtx, th.Dark(), fmt.Sprintf("Price (per %s)", f.Service.Units()))
+				}),
+			)
+		}))
+	}
+	fields = append(
+		fields,
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis: layout.Horizontal,
+			}.Layout(
+				gtx,
+				layout.Rigid(func(gtx C) D {
+					return material.Button(th.Secondary(), &f.AddTier, "Add Tier").Layout(gtx)
+				}),
+				layout.Rigid(spacer),
+				layout.Rigid(func(gtx C) D {
+					if len(f.Tiers) < 2 {
+						gtx.Queue = nil
+					}
+					return material.Button(th.Secondary(), &f.RemoveTier, "Remove Tier").Layout(gtx)
+				}),
+			)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return material.CheckBox(th.Dark(), &f.TimeOfUse, "Time of use (off-peak priced separately)").Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			if !f.TimeOfUse.Value {
+				return D{}
+			}
+			f.OffPeak.Prefix = dollar
+			return f.OffPeak.Layout(gtx, th.Dark(), fmt.Sprintf("Off-Peak Price (per %s)", f.Service.Units()))
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{
+				Top: unit.Dp(10),
+			}.Layout(
+				gtx,
+				func(gtx C) D {
+					return layout.Flex{
+						Axis: layout.Horizontal,
+					}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							return material.Button(th.Secondary(), &f.CancelBtn, "Cancel").Layout(gtx)
+						}),
+						layout.Rigid(spacer),
+						layout.Rigid(func(gtx C) D {
+							return material.Button(th.Primary(), &f.SubmitBtn, "Save").Layout(gtx)
+						}),
+					)
+				})
+		}),
+	)
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(gtx, fields...)
+}
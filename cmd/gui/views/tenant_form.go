@@ -3,7 +3,6 @@ package views
 import (
 	"fmt"
 	"image"
-	"log"
 
 	"gioui.org/layout"
 	"gioui.org/text"
@@ -11,6 +10,7 @@ import (
 	"gioui.org/widget/material"
 	"git.sr.ht/~whereswaldon/materials"
 	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/icons"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/nav"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
 	"github.com/jackmordaunt/avisha.go/cmd/gui/widget/style"
@@ -20,8 +20,17 @@ type TenantForm struct {
 	nav.Route
 	App *avisha.App
 	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
 
 	Tenant avisha.Tenant
+	// History shows the audit trail of the tenant.
+	History widget.Clickable
+	// Archive archives the tenant, or restores them if archived.
+	Archive widget.Clickable
+	// Delete deletes the tenant, if they have never leased a site.
+	Delete  widget.Clickable
+	confirm Confirmation
 
 	Name    materials.TextField
 	Contact materials.TextField
@@ -47,7 +56,7 @@ func (f *TenantForm) Receive(data interface{}) {
 	if f.Tenant.Address == (avisha.Address{}) {
 		settings, err := f.App.LoadSettings()
 		if err != nil {
-			log.Printf("loading settings: %v", err)
+			f.Alerts.Error(fmt.Errorf("loading settings: %w", err))
 		}
 		f.Tenant.Address = settings.Defaults.Address
 	}
@@ -77,9 +86,28 @@ func (f *TenantForm) Context() (list []layout.Widget) {
 				})
 		})
 	}
+	if f.Tenant.ID != 0 {
+		list = append(list, func(gtx C) D {
+			return material.IconButton(f.Th.Primary(), &f.History, icons.History).Layout(gtx)
+		})
+		list = append(list, func(gtx C) D {
+			icon := icons.Archive
+			if f.Tenant.IsArchived() {
+				icon = icons.Unarchive
+			}
+			return material.IconButton(f.Th.Primary(), &f.Archive, icon).Layout(gtx)
+		})
+		list = append(list, func(gtx C) D {
+			return material.IconButton(f.Th.Primary(), &f.Delete, icons.Delete).Layout(gtx)
+		})
+	}
 	return list
 }
 
+func (f *TenantForm) Modal(gtx C) D {
+	return f.confirm.Layout(gtx, f.Th)
+}
+
 // Submit validates the input adata and returns a boolean indicating validity.
 func (f *TenantForm) Submit() (tenant avisha.Tenant, ok bool) {
 	return f.Tenant, f.Form.Submit()
@@ -87,6 +115,40 @@ func (f *TenantForm) Submit() (tenant avisha.Tenant, ok bool) {
 
 func (f *TenantForm) Update(gtx C) {
 	f.Form.Validate(gtx)
+	if f.History.Clicked() {
+		f.Route.To(RouteAudit, &AuditSubject{Entity: "Tenant", ID: f.Tenant.ID, Title: f.Tenant.Name})
+	}
+	if f.Archive.Clicked() {
+		id := f.Tenant.ID
+		if f.Tenant.IsArchived() {
+			f.confirm.Ask(
+				"Restore Tenant",
+				fmt.Sprintf("Restore %s to the list of tenants?", f.Tenant.Name),
+				"Restore", false,
+				func() error { return f.App.RestoreTenant(id) },
+			)
+		} else {
+			f.confirm.Ask(
+				"Archive Tenant",
+				fmt.Sprintf("Archive %s? They will be hidden from the list of tenants, and their history kept.", f.Tenant.Name),
+				"Archive", false,
+				func() error { return f.App.ArchiveTenant(id) },
+			)
+		}
+	}
+	if f.Delete.Clicked() {
+		id := f.Tenant.ID
+		f.confirm.Ask(
+			"Delete Tenant",
+			fmt.Sprintf("Delete %s for good? This can't be undone.", f.Tenant.Name),
+			"Delete", true,
+			func() error { return f.App.DeleteTenant(id) },
+		)
+	}
+	if f.confirm.Update() {
+		f.Form.Clear()
+		f.Route.Back()
+	}
 	if f.SubmitBtn.Clicked() {
 		if t, ok := f.Submit(); ok {
 			if err := func() error {
@@ -95,21 +157,13 @@ func (f *TenantForm) Update(gtx C) {
 						return fmt.Errorf("registering tenant: %w", err)
 					}
 				} else {
-					if err := f.App.Update(&t); err != nil {
-						return fmt.Errorf("updating tenant: %w", err)
-					}
-					// Allow for zero value contact field.
-					if err := f.App.UpdateField(
-						&avisha.Tenant{ID: f.Tenant.ID},
-						"Contact",
-						t.Contact,
-					); err != nil {
+					if err := f.App.UpdateTenant(&t); err != nil {
 						return fmt.Errorf("updating tenant: %w", err)
 					}
 				}
 				return nil
 			}(); err != nil {
-				log.Printf("%v", err)
+				f.Alerts.Error(err)
 			} else {
 				f.Form.Clear()
 				f.Route.Back()
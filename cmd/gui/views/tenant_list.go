@@ -21,12 +21,21 @@ type Tenants struct {
 
 	App *avisha.App
 	Th  *style.Theme
+	// Alerts tells the user of failures.
+	Alerts *Alerts
+	// Invalidate requests a frame once tenants have loaded.
+	Invalidate func()
 
 	RegisterTenant widget.Clickable
+	// Search matches tenants by name, contact and address as it is typed.
+	Search widget.Editor
+	// Archived shows archived tenants along with the rest.
+	Archived widget.Bool
 
 	list   layout.List
 	states States
 	once   sync.Once
+	loader Loader
 }
 
 func (t *Tenants) Title() string {
@@ -35,6 +44,9 @@ func (t *Tenants) Title() string {
 
 func (t *Tenants) Context() []layout.Widget {
 	return []layout.Widget{
+		func(gtx C) D {
+			return style.SearchBar(t.Th.Dark(), &t.Search, "Search tenants").Layout(gtx)
+		},
 		func(gtx C) D {
 			return material.IconButton(
 				t.Th.Primary(),
@@ -63,15 +75,35 @@ func (t *Tenants) Layout(gtx C) D {
 	})
 	t.Update(gtx)
 	t.states.Begin()
-	var (
-		tenants []*avisha.Tenant
+	query := avisha.TenantQuery{Search: t.Search.Text(), Archived: t.Archived.Value}
+	data, _ := t.loader.Load(t.App, t.Invalidate, fmt.Sprintf("%+v", query), func(app avisha.App) (interface{}, error) {
+		tenants, err := app.QueryTenants(query)
+		if err != nil {
+			t.Alerts.Error(fmt.Errorf("loading tenants: %w", err))
+		}
+		return tenants, err
+	})
+	tenants, _ := data.([]avisha.Tenant)
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
+		layout.Rigid(func(gtx C) D {
+			return layoutChips(gtx, layout.Rigid(func(gtx C) D {
+				return style.Chip(t.Th.Primary(), &t.Archived, "Archived").Layout(gtx)
+			}))
+		}),
+		layout.Flexed(1, func(gtx C) D {
+			return t.LayoutList(gtx, tenants)
+		}),
 	)
-	if err := t.App.DB.All(&tenants); err != nil {
-		fmt.Printf("error: loading tenants: %s\n", err)
-	}
+}
+
+// LayoutList renders the tenants.
+func (t *Tenants) LayoutList(gtx C, tenants []avisha.Tenant) D {
 	return t.list.Layout(gtx, len(tenants), func(gtx C, index int) D {
 		var (
-			tenant = tenants[index]
+			tenant = &tenants[index]
 			state  = t.states.Next(unsafe.Pointer(tenant))
 			active = false
 		)
@@ -82,11 +114,11 @@ func (t *Tenants) Layout(gtx C) D {
 			&state.Hover,
 			active,
 			func(gtx C) D {
-				return material.Label(
+				return layoutArchived(gtx, t.Th, tenant.IsArchived(), material.Label(
 					t.Th.Dark(),
 					unit.Dp(20),
 					tenant.Name,
-				).Layout(gtx)
+				).Layout)
 			})
 	})
 }
@@ -0,0 +1,51 @@
+package views
+
+import (
+	"fmt"
+
+	"github.com/jackmordaunt/avisha.go"
+)
+
+// Undo keeps the actions taken this session so they can be undone, and posts
+// an alert after each one offering to undo it.
+type Undo struct {
+	App     *avisha.App
+	Alerts  *Alerts
+	History avisha.History
+}
+
+// Do performs the named action such that it can be undone.
+// The name describes what was done, such as "Paid $70.00 to Rent".
+func (u *Undo) Do(name string, fn func(app avisha.App) error) error {
+	a, err := u.App.Record(name, fn)
+	if err != nil {
+		return err
+	}
+	u.History.Push(a)
+	u.Alerts.Act(Success, a.Name, "Undo", u.Undo)
+	return nil
+}
+
+// Undo the most recent action.
+func (u *Undo) Undo() {
+	a, ok, err := u.History.Undo(*u.App)
+	if err != nil {
+		u.Alerts.Error(err)
+		return
+	}
+	if ok {
+		u.Alerts.Act(Info, fmt.Sprintf("Undone: %s", a.Name), "Redo", u.Redo)
+	}
+}
+
+// Redo the most recently undone action.
+func (u *Undo) Redo() {
+	a, ok, err := u.History.Redo(*u.App)
+	if err != nil {
+		u.Alerts.Error(err)
+		return
+	}
+	if ok {
+		u.Alerts.Act(Success, a.Name, "Undo", u.Undo)
+	}
+}
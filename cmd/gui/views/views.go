@@ -15,6 +15,7 @@ type (
 type Route = string
 
 const (
+	RouteDashboard  Route = "dashboard"
 	RouteLease      Route = "lease"
 	RouteTenants    Route = "tenants"
 	RouteSites      Route = "sites"
@@ -22,6 +23,13 @@ const (
 	RouteTenantForm Route = "tenant-form"
 	RouteSiteForm   Route = "site-form"
 	RouteSettings   Route = "settings"
+	RouteBilling    Route = "billing"
+	RouteSuppliers  Route = "suppliers"
+	RouteArrears    Route = "arrears"
+	RouteIncome     Route = "income"
+	RouteReports    Route = "reports"
+	RouteSearch     Route = "search"
+	RouteAudit      Route = "audit"
 )
 
 // States maintains list-item state, between frame updates.
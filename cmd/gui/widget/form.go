@@ -242,6 +242,32 @@ func (v DateValuer) Clear() {
 	*v.Value = time.Now()
 }
 
+// OptionalDateValuer maps a date that may be left empty to text, where empty
+// text is the zero time.
+type OptionalDateValuer struct {
+	Value *time.Time
+}
+
+func (v OptionalDateValuer) To() (string, error) {
+	if v.Value.IsZero() {
+		return "", nil
+	}
+	return DateValuer{Value: v.Value}.To()
+}
+
+func (v OptionalDateValuer) From(text string) (err error) {
+	if strings.TrimSpace(text) == "" {
+		*v.Value = time.Time{}
+		return nil
+	}
+	*v.Value, err = util.ParseDate(text)
+	return err
+}
+
+func (v OptionalDateValuer) Clear() {
+	*v.Value = time.Time{}
+}
+
 // RequiredValuer ensures the field is not empty.
 type RequiredValuer struct {
 	Valuer
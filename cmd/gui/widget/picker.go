@@ -0,0 +1,250 @@
+package widget
+
+import (
+	"fmt"
+
+	"git.sr.ht/~whereswaldon/materials"
+)
+
+// Option is a record that can be picked.
+type Option struct {
+	ID int
+	// Label names the record in the input, such as the name of a tenant.
+	Label string
+	// Detail describes the record further, to tell it apart from the others,
+	// such as how to contact a tenant and where they live.
+	Detail string
+}
+
+// Picker picks a record by ID from those matching the text typed into it.
+//
+// The options matching the text are searched for while the input is focused,
+// and offered beneath the input until one is picked. Enter picks the first.
+// Create can be offered for when the text matches no option.
+type Picker struct {
+	// Input is where the text is typed.
+	Input materials.TextField
+	// Search returns the options matching the text, the most relevant
+	// first.
+	// Search is asked every frame while the input is focused, so it must not
+	// block: views search through a Loader keyed by the text, returning the
+	// options last found until those for the text have loaded.
+	Search func(text string) ([]Option, error)
+	// Limit is how many options are offered at once, 5 if zero.
+	Limit int
+	// Create is clicked to create a record from the text.
+	Create Clickable
+
+	selected Option
+	picked   bool
+	// found is every option matching the query, options those offered.
+	found   []Option
+	options []Option
+	items   []pickerItem
+	err     error
+	// query is the text the options were searched for.
+	query   string
+	focused bool
+	open    bool
+}
+
+type pickerItem struct {
+	Clickable
+	Hoverable
+}
+
+// Text of the input.
+func (p *Picker) Text() string {
+	return p.Input.Text()
+}
+
+// SetText of the input, without offering the options it matches.
+func (p *Picker) SetText(text string) {
+	p.Input.SetText(text)
+	p.query = text
+}
+
+// SetError of the input.
+func (p *Picker) SetError(err string) {
+	p.Input.SetError(err)
+}
+
+// ClearError of the input.
+func (p *Picker) ClearError() {
+	p.Input.ClearError()
+}
+
+// Select the option, as if it were picked.
+func (p *Picker) Select(opt Option) {
+	p.selected, p.picked, p.open = opt, true, false
+	p.SetText(opt.Label)
+}
+
+// Deselect the picked option, if any.
+func (p *Picker) Deselect() {
+	p.selected, p.picked = Option{}, false
+}
+
+// Selected returns the option picked, if any.
+func (p *Picker) Selected() (Option, bool) {
+	return p.selected, p.picked
+}
+
+// Options returns the options offered, while open.
+func (p *Picker) Options() []Option {
+	if !p.open {
+		return nil
+	}
+	return p.options
+}
+
+// Item returns the state of the ith option offered.
+func (p *Picker) Item(ii int) (*Clickable, *Hoverable) {
+	return &p.items[ii].Clickable, &p.items[ii].Hoverable
+}
+
+// Err returns why the options could not be searched for, if they couldn't.
+func (p *Picker) Err() error {
+	return p.err
+}
+
+// Creatable reports whether a record could be created from the text, which is
+// so when it names none of the options.
+func (p *Picker) Creatable() bool {
+	if !p.open || p.query == "" {
+		return false
+	}
+	for _, opt := range p.options {
+		if opt.Label == p.query {
+			return false
+		}
+	}
+	return true
+}
+
+// Match returns the option labelled exactly by the text, from those found
+// for it.
+// The option picked is preferred, so that records sharing a label can be told
+// apart.
+func (p *Picker) Match(text string) (Option, error) {
+	if p.picked && p.selected.Label == text {
+		return p.selected, nil
+	}
+	if p.err != nil {
+		return Option{}, p.err
+	}
+	if text != p.query {
+		return Option{}, fmt.Errorf("not found: pick from the list")
+	}
+	var matches []Option
+	for _, opt := range p.found {
+		if opt.Label == text {
+			matches = append(matches, opt)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Option{}, fmt.Errorf("not found: pick from the list")
+	case 1:
+		return matches[0], nil
+	default:
+		return Option{}, fmt.Errorf("%d match: pick one from the list", len(matches))
+	}
+}
+
+// Update searches for the options as the text changes and handles them being
+// picked. Reports whether an option was picked.
+func (p *Picker) Update(gtx C) (picked bool) {
+	p.Input.SingleLine = true
+	p.Input.Submit = true
+	for ii := range p.Options() {
+		if p.items[ii].Clicked() {
+			p.Select(p.options[ii])
+			picked = true
+		}
+	}
+	for _, event := range p.Input.Events() {
+		if _, ok := event.(SubmitEvent); ok && len(p.Options()) > 0 {
+			p.Select(p.options[0])
+			picked = true
+		}
+	}
+	focused := p.Input.Focused()
+	if text := p.Input.Text(); text != p.query || (focused && !p.focused) {
+		p.query = text
+		p.open = focused
+	}
+	if focused {
+		p.search()
+	} else {
+		p.open = false
+	}
+	p.focused = focused
+	return picked
+}
+
+// search for the options matching the query.
+func (p *Picker) search() {
+	p.found, p.err = nil, nil
+	if p.Search != nil {
+		p.found, p.err = p.Search(p.query)
+	}
+	// Offer the exact match first, should it be beyond the limit.
+	p.options = append(p.options[:0], p.found...)
+	for ii, opt := range p.options {
+		if opt.Label == p.query {
+			copy(p.options[1:ii+1], p.options[:ii])
+			p.options[0] = opt
+			break
+		}
+	}
+	limit := p.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+	if len(p.options) > limit {
+		p.options = p.options[:limit]
+	}
+	if len(p.items) < len(p.options) {
+		p.items = append(p.items, make([]pickerItem, len(p.options)-len(p.items))...)
+	}
+}
+
+// PickerValuer binds the ID of the record picked.
+// Lookup returns the option for a record by its ID, such that a record can
+// be shown as picked after it is no longer offered, such as when archived.
+type PickerValuer struct {
+	ID     *int
+	Picker *Picker
+	Lookup func(id int) (Option, error)
+}
+
+func (v PickerValuer) To() (string, error) {
+	if *v.ID <= 0 {
+		v.Picker.Deselect()
+		return "", nil
+	}
+	opt, err := v.Lookup(*v.ID)
+	if err != nil {
+		return "", err
+	}
+	v.Picker.Select(opt)
+	return opt.Label, nil
+}
+
+func (v PickerValuer) From(text string) error {
+	if text == "" {
+		return fmt.Errorf("required")
+	}
+	opt, err := v.Picker.Match(text)
+	if err != nil {
+		return err
+	}
+	*v.ID = opt.ID
+	return nil
+}
+
+func (v PickerValuer) Clear() {
+	*v.ID = -1
+	v.Picker.Deselect()
+}
@@ -0,0 +1,44 @@
+package widget
+
+import (
+	"testing"
+)
+
+// TestPickerMatch checks that matching the text reuses the options found for
+// it rather than searching again.
+func TestPickerMatch(t *testing.T) {
+	var searches int
+	p := Picker{Search: func(text string) ([]Option, error) {
+		searches++
+		return []Option{{ID: 1, Label: "Stark"}, {ID: 2, Label: "Starkey"}, {ID: 3, Label: "Stark"}}, nil
+	}}
+	p.SetText("Stark")
+	p.search()
+	tests := []struct {
+		text string
+		// picked, if any, before matching.
+		picked Option
+		want   int
+		err    bool
+	}{
+		{"Starkey", Option{}, 0, true},
+		{"Stark", Option{}, 0, true},
+		{"Stark", Option{ID: 3, Label: "Stark"}, 3, false},
+	}
+	for _, tt := range tests {
+		p.Deselect()
+		if tt.picked.ID != 0 {
+			p.selected, p.picked = tt.picked, true
+		}
+		got, err := p.Match(tt.text)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: got error %v, want error %v", tt.text, err, tt.err)
+		}
+		if got.ID != tt.want {
+			t.Errorf("%q: got option %d, want %d", tt.text, got.ID, tt.want)
+		}
+	}
+	if searches != 1 {
+		t.Errorf("searched %d times, want once", searches)
+	}
+}
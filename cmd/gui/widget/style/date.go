@@ -3,80 +3,388 @@ package style
 import (
 	"fmt"
 	"image"
-	"strconv"
 	"time"
 
 	"gioui.org/layout"
+	"gioui.org/text"
 	"gioui.org/unit"
+	"gioui.org/widget/material"
 	"git.sr.ht/~whereswaldon/materials"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/icons"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
 )
 
-// DateInput allows inputting of date information textually.
-// Composed of three inputs: day, month, year.
-type DateInput struct {
-	Day   materials.TextField
-	Month materials.TextField
-	Year  materials.TextField
+// Calendar picks a date for a text input from a month of days, opened and
+// closed by a toggle beside the input.
+//
+// The date is still typed as dd/mm/yyyy if preferred, and the calendar follows
+// along to the month typed.
+type Calendar struct {
+	// Min and Max bound the dates that can be picked, either unbounded if
+	// zero.
+	Min, Max time.Time
+	// Quick offers dates the given number of days from today, such as 14 for
+	// a fortnight. Today is always offered; zero or fewer days are not.
+	Quick []int
+
+	Toggle widget.Clickable
+	Prev   widget.Clickable
+	Next   widget.Clickable
+	Today  widget.Clickable
+
+	open bool
+	// month is the first day of the month shown.
+	month time.Time
+	// text the month was last followed from.
+	text  string
+	days  [42]widget.Clickable
+	quick []widget.Clickable
 }
 
-func (input *DateInput) Set(date time.Time) {
-	var (
-		day, month, year = "", "", ""
-	)
-	if date != (time.Time{}) {
-		day = fmt.Sprintf("%d", date.Day())
-		month = fmt.Sprintf("%d", date.Month())
-		year = fmt.Sprintf("%d", date.Year())
+// Parse the date from dd/mm/yyyy text, refusing dates out of bounds.
+func (c *Calendar) Parse(text string) (time.Time, error) {
+	date, err := util.ParseDate(text)
+	if err != nil {
+		return date, err
 	}
-	input.Day.SetText(day)
-	input.Month.SetText(month)
-	input.Year.SetText(year)
+	if !c.Min.IsZero() && date.Before(day(c.Min)) {
+		return date, fmt.Errorf("must be on or after %s", util.FormatTime(c.Min))
+	}
+	if !c.Max.IsZero() && date.After(day(c.Max)) {
+		return date, fmt.Errorf("must be on or before %s", util.FormatTime(c.Max))
+	}
+	return date, nil
 }
 
-func (input *DateInput) Date() (time.Time, error) {
-	year, err := strconv.Atoi(input.Year.Text())
-	if err != nil {
-		return time.Time{}, fmt.Errorf("year: not a number")
+// IsOpen reports whether the calendar is showing.
+func (c *Calendar) IsOpen() bool {
+	return c.open
+}
+
+// Close the calendar.
+func (c *Calendar) Close() {
+	c.open = false
+}
+
+// Update handles the calendar being toggled, navigated and picked from,
+// setting the text of the input to the date picked.
+// Reports whether a date was picked.
+func (c *Calendar) Update(input widget.Input) (picked bool) {
+	if c.Toggle.Clicked() {
+		c.open = !c.open
+		c.text = ""
 	}
-	if year < 0 {
-		return time.Time{}, fmt.Errorf("year: out of bounds (must be positive number) got %d", year)
+	if !c.open {
+		return false
 	}
-	month, err := strconv.Atoi(input.Month.Text())
-	if err != nil {
-		return time.Time{}, fmt.Errorf("month: not a number")
+	if text := input.Text(); text != c.text || c.month.IsZero() {
+		c.text = text
+		date, err := util.ParseDate(text)
+		if err != nil {
+			date = time.Now()
+		}
+		if err == nil || c.month.IsZero() {
+			c.month = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.Local)
+		}
 	}
-	if month < int(time.January) || month > int(time.December) {
-		return time.Time{}, fmt.Errorf("month: out of bounds (1-12) got %d", month)
+	if c.Prev.Clicked() {
+		c.month = c.month.AddDate(0, -1, 0)
 	}
-	day, err := strconv.Atoi(input.Day.Text())
-	if err != nil {
-		return time.Time{}, fmt.Errorf("day: not a number")
+	if c.Next.Clicked() {
+		c.month = c.month.AddDate(0, 1, 0)
+	}
+	pick := func(date time.Time) {
+		if !c.allowed(date) {
+			return
+		}
+		input.SetText(util.FormatTime(date))
+		input.ClearError()
+		c.open = false
+		picked = true
+	}
+	if c.Today.Clicked() {
+		pick(day(time.Now()))
+	}
+	if len(c.quick) != len(c.Quick) {
+		c.quick = make([]widget.Clickable, len(c.Quick))
+	}
+	for ii := range c.quick {
+		if c.quick[ii].Clicked() && c.Quick[ii] > 0 {
+			pick(day(time.Now()).AddDate(0, 0, c.Quick[ii]))
+		}
+	}
+	for ii := range c.days {
+		if c.days[ii].Clicked() {
+			pick(c.first().AddDate(0, 0, ii))
+		}
 	}
-	if day < 1 || day > 31 {
-		return time.Time{}, fmt.Errorf("day: out of bounds (1-31) got %d", day)
+	return picked
+}
+
+// allowed reports whether the date is within bounds.
+func (c *Calendar) allowed(date time.Time) bool {
+	return (c.Min.IsZero() || !date.Before(day(c.Min))) &&
+		(c.Max.IsZero() || !date.After(day(c.Max)))
+}
+
+// first returns the Monday the calendar starts from, which begins the week of
+// the first day of the month.
+func (c *Calendar) first() time.Time {
+	offset := (int(c.month.Weekday()) + 6) % 7
+	return c.month.AddDate(0, 0, -offset)
+}
+
+// LayoutToggle renders the button that opens and closes the calendar.
+func (c *Calendar) LayoutToggle(gtx C, th *material.Theme) D {
+	btn := material.IconButton(th, &c.Toggle, icons.Calendar)
+	btn.Size = unit.Dp(20)
+	btn.Inset = layout.UniformInset(unit.Dp(8))
+	if !c.open {
+		btn.Background = WithAlpha(btn.Background, 0)
+		btn.Color = th.ContrastBg
 	}
-	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local), nil
+	return layout.Inset{Left: unit.Dp(5)}.Layout(gtx, btn.Layout)
 }
 
-func (input *DateInput) Layout(gtx C, th *Theme) D {
+// Layout the calendar for the input, while open.
+func (c *Calendar) Layout(gtx C, th *material.Theme, input widget.Input) D {
+	c.Update(input)
+	if !c.open {
+		return D{}
+	}
+	selected, err := util.ParseDate(input.Text())
+	if err != nil {
+		selected = time.Time{}
+	}
+	return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+		if width := gtx.Px(unit.Dp(320)); gtx.Constraints.Max.X > width {
+			gtx.Constraints.Max.X = width
+		}
+		gtx.Constraints.Min.X = gtx.Constraints.Max.X
+		return Card{
+			Content: []layout.Widget{
+				func(gtx C) D {
+					return c.layoutMonth(gtx, th)
+				},
+				func(gtx C) D {
+					return c.layoutDays(gtx, th, selected)
+				},
+				func(gtx C) D {
+					return c.layoutQuick(gtx, th)
+				},
+			},
+		}.Layout(gtx, th)
+	})
+}
+
+// layoutMonth renders the month shown between buttons to move a month either
+// way.
+func (c *Calendar) layoutMonth(gtx C, th *material.Theme) D {
+	nav := func(state *widget.Clickable, icon *widget.Icon) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			btn := material.IconButton(th, state, icon)
+			btn.Size = unit.Dp(20)
+			btn.Inset = layout.UniformInset(unit.Dp(4))
+			btn.Background = WithAlpha(btn.Background, 0)
+			btn.Color = th.Fg
+			return btn.Layout(gtx)
+		})
+	}
 	return layout.Flex{
-		Axis: layout.Horizontal,
+		Axis:      layout.Horizontal,
+		Alignment: layout.Middle,
 	}.Layout(
 		gtx,
+		nav(&c.Prev, icons.ChevronLeft),
 		layout.Flexed(1, func(gtx C) D {
-			return input.Day.Layout(gtx, th.Dark(), "Day")
+			lb := material.Body1(th, c.month.Format("January 2006"))
+			lb.Alignment = text.Middle
+			return lb.Layout(gtx)
 		}),
+		nav(&c.Next, icons.ChevronRight),
+	)
+}
+
+// layoutDays renders six weeks of days from the Monday before the month,
+// highlighting the date selected and today.
+func (c *Calendar) layoutDays(gtx C, th *material.Theme, selected time.Time) D {
+	var (
+		width  = gtx.Constraints.Max.X / 7
+		height = gtx.Px(unit.Dp(32))
+		today  = day(time.Now())
+		first  = c.first()
+		rows   = make([]layout.FlexChild, 0, 7)
+	)
+	cell := func(gtx C, w layout.Widget) D {
+		size := image.Point{X: width, Y: height}
+		gtx.Constraints = layout.Exact(size)
+		return layout.Center.Layout(gtx, w)
+	}
+	weekdays := make([]layout.FlexChild, 7)
+	for ii := range weekdays {
+		name := time.Weekday((ii + 1) % 7).String()[:2]
+		weekdays[ii] = layout.Rigid(func(gtx C) D {
+			lb := material.Caption(th, name)
+			lb.Color = WithAlpha(th.Fg, 150)
+			return cell(gtx, lb.Layout)
+		})
+	}
+	rows = append(rows, layout.Rigid(func(gtx C) D {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, weekdays...)
+	}))
+	for week := 0; week < 6; week++ {
+		days := make([]layout.FlexChild, 7)
+		for ii := range days {
+			var (
+				index = week*7 + ii
+				date  = first.AddDate(0, 0, index)
+			)
+			days[ii] = layout.Rigid(func(gtx C) D {
+				allowed := c.allowed(date)
+				return layout.Stack{}.Layout(
+					gtx,
+					layout.Stacked(func(gtx C) D {
+						return cell(gtx, func(gtx C) D {
+							size := image.Point{X: height - gtx.Px(unit.Dp(4)), Y: height - gtx.Px(unit.Dp(4))}
+							lb := material.Body2(th, fmt.Sprintf("%d", date.Day()))
+							if date.Month() != c.month.Month() {
+								lb.Color = WithAlpha(th.Fg, 150)
+							}
+							switch {
+							case date.Equal(selected):
+								util.DrawRect(gtx, th.ContrastBg, size, unit.Dp(16))
+								lb.Color = th.ContrastFg
+							case date.Equal(today):
+								util.DrawRect(gtx, WithAlpha(th.ContrastBg, 38), size, unit.Dp(16))
+							}
+							if !allowed {
+								lb.Color = WithAlpha(lb.Color, 80)
+							}
+							gtx.Constraints = layout.Exact(size)
+							return layout.Center.Layout(gtx, lb.Layout)
+						})
+					}),
+					layout.Expanded(func(gtx C) D {
+						if !allowed {
+							return D{Size: gtx.Constraints.Min}
+						}
+						return c.days[index].Layout(gtx)
+					}),
+				)
+			})
+		}
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, days...)
+		}))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
+
+// layoutQuick renders the dates offered relative to today.
+func (c *Calendar) layoutQuick(gtx C, th *material.Theme) D {
+	button := func(state *widget.Clickable, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			btn := material.Button(th, state, label)
+			btn.Color = th.ContrastBg
+			btn.Background = WithAlpha(btn.Background, 0)
+			btn.Inset = layout.UniformInset(unit.Dp(6))
+			return btn.Layout(gtx)
+		})
+	}
+	buttons := []layout.FlexChild{button(&c.Today, "Today")}
+	for ii, days := range c.Quick {
+		if ii < len(c.quick) && days > 0 {
+			buttons = append(buttons, button(&c.quick[ii], fmt.Sprintf("%+d days", days)))
+		}
+	}
+	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, buttons...)
+}
+
+// DatePicker inputs a date, typed as dd/mm/yyyy or picked from a calendar
+// that opens beneath the input.
+type DatePicker struct {
+	Input materials.TextField
+	Calendar
+}
+
+// Text of the input.
+func (p *DatePicker) Text() string {
+	return p.Input.Text()
+}
+
+// SetText of the input.
+func (p *DatePicker) SetText(text string) {
+	p.Input.SetText(text)
+}
+
+// SetError of the input.
+func (p *DatePicker) SetError(err string) {
+	p.Input.SetError(err)
+}
+
+// ClearError of the input.
+func (p *DatePicker) ClearError() {
+	p.Input.ClearError()
+}
+
+// Update handles the calendar, reporting whether a date was picked from it.
+func (p *DatePicker) Update() (picked bool) {
+	return p.Calendar.Update(p)
+}
+
+// Layout the input labelled by hint, with the calendar toggle beside it.
+func (p *DatePicker) Layout(gtx C, th *material.Theme, hint string) D {
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
 		layout.Rigid(func(gtx C) D {
-			return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
-		}),
-		layout.Flexed(1, func(gtx C) D {
-			return input.Month.Layout(gtx, th.Dark(), "Month")
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				layout.Flexed(1, func(gtx C) D {
+					return p.Input.Layout(gtx, th, hint)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return p.Calendar.LayoutToggle(gtx, th)
+				}),
+			)
 		}),
 		layout.Rigid(func(gtx C) D {
-			return D{Size: image.Point{X: gtx.Px(unit.Dp(10))}}
-		}),
-		layout.Flexed(1, func(gtx C) D {
-			return input.Year.Layout(gtx, th.Dark(), "Year")
+			return p.Calendar.Layout(gtx, th, p)
 		}),
 	)
 }
+
+// DateValuer binds a date input with a calendar, refusing dates out of the
+// bounds of the calendar.
+type DateValuer struct {
+	Value    *time.Time
+	Default  time.Time
+	Calendar *Calendar
+}
+
+func (v DateValuer) To() (string, error) {
+	return widget.DateValuer{Value: v.Value, Default: v.Default}.To()
+}
+
+func (v DateValuer) From(text string) (err error) {
+	if v.Calendar == nil {
+		*v.Value, err = util.ParseDate(text)
+		return err
+	}
+	*v.Value, err = v.Calendar.Parse(text)
+	return err
+}
+
+func (v DateValuer) Clear() {
+	widget.DateValuer{Value: v.Value}.Clear()
+}
+
+// day truncates the time to midnight of its day.
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,131 @@
+package style
+
+import (
+	"fmt"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+)
+
+// PickerStyle renders a picker as a text field, with the options matching the
+// text listed beneath it.
+type PickerStyle struct {
+	State *widget.Picker
+	Theme *Theme
+	Hint  string
+	// CreateLabel labels the action that creates a record from the text, such
+	// as "New tenant". Creating is not offered if empty.
+	CreateLabel string
+}
+
+// Picker renders the picker with the hint labelling the input.
+func Picker(th *Theme, state *widget.Picker, hint string) PickerStyle {
+	return PickerStyle{
+		State: state,
+		Theme: th,
+		Hint:  hint,
+	}
+}
+
+func (p PickerStyle) Layout(gtx C) D {
+	p.State.Update(gtx)
+	var (
+		options   = p.State.Options()
+		creatable = p.CreateLabel != "" && p.State.Creatable()
+		err       = p.State.Err()
+	)
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(
+		gtx,
+		layout.Rigid(func(gtx C) D {
+			return p.State.Input.Layout(gtx, p.Theme.Dark(), p.Hint)
+		}),
+		layout.Rigid(func(gtx C) D {
+			if len(options) == 0 && !creatable && err == nil {
+				return D{}
+			}
+			return p.layoutOptions(gtx, options, creatable, err)
+		}),
+	)
+}
+
+// layoutOptions renders the options in a box that stands out from the form.
+func (p PickerStyle) layoutOptions(gtx C, options []widget.Option, creatable bool, err error) D {
+	items := make([]layout.FlexChild, 0, len(options)+2)
+	if err != nil {
+		items = append(items, layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(unit.Dp(10)).Layout(gtx, func(gtx C) D {
+				return material.Body2(p.Theme.Danger(), err.Error()).Layout(gtx)
+			})
+		}))
+	}
+	selected, picked := p.State.Selected()
+	for ii, opt := range options {
+		var (
+			opt          = opt
+			click, hover = p.State.Item(ii)
+			active       = picked && selected.ID == opt.ID
+		)
+		items = append(items, layout.Rigid(func(gtx C) D {
+			return ListItem(gtx, p.Theme.Dark(), click, hover, active, func(gtx C) D {
+				return layout.Flex{
+					Axis: layout.Vertical,
+				}.Layout(
+					gtx,
+					layout.Rigid(func(gtx C) D {
+						return material.Body1(p.Theme.Dark(), opt.Label).Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						if opt.Detail == "" {
+							return D{}
+						}
+						return material.Caption(p.Theme.Muted(), opt.Detail).Layout(gtx)
+					}),
+				)
+			})
+		}))
+	}
+	if creatable {
+		items = append(items, layout.Rigid(func(gtx C) D {
+			return material.Clickable(gtx, &p.State.Create, func(gtx C) D {
+				return layout.UniformInset(unit.Dp(10)).Layout(gtx, func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Constraints.Max.X
+					return material.Body1(
+						p.Theme.Primary(),
+						fmt.Sprintf("+ %s %q", p.CreateLabel, p.State.Text()),
+					).Layout(gtx)
+				})
+			})
+		}))
+	}
+	return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+		return layout.Stack{}.Layout(
+			gtx,
+			layout.Expanded(func(gtx C) D {
+				return util.DrawRect(
+					gtx,
+					color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+					gtx.Constraints.Min,
+					unit.Dp(4),
+				)
+			}),
+			layout.Stacked(func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Constraints.Max.X
+				return widget.Border{
+					Color:        p.Theme.Dark().ContrastBg,
+					CornerRadius: unit.Dp(4),
+					Width:        unit.Dp(0.5),
+				}.Layout(gtx, func(gtx C) D {
+					return layout.Flex{
+						Axis: layout.Vertical,
+					}.Layout(gtx, items...)
+				})
+			}),
+		)
+	})
+}
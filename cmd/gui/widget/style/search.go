@@ -0,0 +1,102 @@
+package style
+
+import (
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+)
+
+// SearchBarStyle renders a single line search input, sized to sit in the top
+// bar.
+type SearchBarStyle struct {
+	Editor *widget.Editor
+	Theme  *material.Theme
+	Hint   string
+	Width  unit.Value
+}
+
+// SearchBar renders a search input over the editor.
+func SearchBar(th *material.Theme, editor *widget.Editor, hint string) SearchBarStyle {
+	editor.SingleLine = true
+	return SearchBarStyle{
+		Editor: editor,
+		Theme:  th,
+		Hint:   hint,
+		Width:  unit.Dp(250),
+	}
+}
+
+func (s SearchBarStyle) Layout(gtx C) D {
+	return layout.Inset{
+		Top:    unit.Dp(8),
+		Bottom: unit.Dp(8),
+		Right:  unit.Dp(10),
+	}.Layout(gtx, func(gtx C) D {
+		width := gtx.Px(s.Width)
+		gtx.Constraints.Min.X, gtx.Constraints.Max.X = width, width
+		return layout.Stack{}.Layout(
+			gtx,
+			layout.Expanded(func(gtx C) D {
+				return util.DrawRect(gtx, s.Theme.Bg, gtx.Constraints.Min, unit.Dp(4))
+			}),
+			layout.Stacked(func(gtx C) D {
+				return layout.Inset{
+					Top:    unit.Dp(6),
+					Bottom: unit.Dp(6),
+					Left:   unit.Dp(10),
+					Right:  unit.Dp(10),
+				}.Layout(gtx, material.Editor(s.Theme, s.Editor, s.Hint).Layout)
+			}),
+		)
+	})
+}
+
+// ChipStyle renders a toggle as a rounded chip, filled when selected.
+type ChipStyle struct {
+	State *widget.Bool
+	Theme *material.Theme
+	Label string
+}
+
+// Chip renders a filter that can be toggled on and off.
+func Chip(th *material.Theme, state *widget.Bool, label string) ChipStyle {
+	return ChipStyle{
+		State: state,
+		Theme: th,
+		Label: label,
+	}
+}
+
+func (c ChipStyle) Layout(gtx C) D {
+	return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+		return layout.Stack{}.Layout(
+			gtx,
+			layout.Expanded(func(gtx C) D {
+				bg := WithAlpha(c.Theme.ContrastBg, 38)
+				if c.State.Value {
+					bg = c.Theme.ContrastBg
+				}
+				return util.DrawRect(gtx, bg, gtx.Constraints.Min, unit.Dp(16))
+			}),
+			layout.Stacked(func(gtx C) D {
+				return layout.Inset{
+					Top:    unit.Dp(6),
+					Bottom: unit.Dp(6),
+					Left:   unit.Dp(12),
+					Right:  unit.Dp(12),
+				}.Layout(gtx, func(gtx C) D {
+					lb := material.Body2(c.Theme, c.Label)
+					if c.State.Value {
+						lb.Color = c.Theme.ContrastFg
+					}
+					return lb.Layout(gtx)
+				})
+			}),
+			layout.Expanded(func(gtx C) D {
+				return c.State.Layout(gtx)
+			}),
+		)
+	})
+}
@@ -0,0 +1,80 @@
+package style
+
+import (
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/util"
+	"github.com/jackmordaunt/avisha.go/cmd/gui/widget"
+)
+
+// SnackbarStyle renders a brief message along the bottom of the window, with
+// an optional action.
+type SnackbarStyle struct {
+	Theme   *material.Theme
+	Message string
+	// Action is clicked to act on the message, such as to undo it.
+	// No action is shown if nil.
+	Action *widget.Clickable
+	Label  string
+	// ActionColor colors the action label so it stands out from the message.
+	ActionColor color.NRGBA
+	// Inset spaces the snackbar from the edges of the window.
+	Inset layout.Inset
+}
+
+// Snackbar renders the message with an action labelled by label.
+func Snackbar(th *material.Theme, message string, action *widget.Clickable, label string) SnackbarStyle {
+	return SnackbarStyle{
+		Theme:   th,
+		Message: message,
+		Action:  action,
+		Label:   label,
+		// Text color by default.
+		ActionColor: th.Bg,
+		Inset:       layout.UniformInset(unit.Dp(20)),
+	}
+}
+
+func (s SnackbarStyle) Layout(gtx C) D {
+	return s.Inset.Layout(gtx, func(gtx C) D {
+		return layout.Stack{}.Layout(
+			gtx,
+			layout.Expanded(func(gtx C) D {
+				return util.DrawRect(gtx, s.Theme.Fg, gtx.Constraints.Min, unit.Dp(4))
+			}),
+			layout.Stacked(func(gtx C) D {
+				return layout.Inset{
+					Left:  unit.Dp(16),
+					Right: unit.Dp(8),
+				}.Layout(gtx, func(gtx C) D {
+					return layout.Flex{Alignment: layout.Middle}.Layout(
+						gtx,
+						layout.Rigid(func(gtx C) D {
+							return layout.Inset{
+								Top:    unit.Dp(14),
+								Bottom: unit.Dp(14),
+								Right:  unit.Dp(16),
+							}.Layout(gtx, func(gtx C) D {
+								lb := material.Body2(s.Theme, s.Message)
+								lb.Color = s.Theme.Bg
+								return lb.Layout(gtx)
+							})
+						}),
+						layout.Rigid(func(gtx C) D {
+							if s.Action == nil {
+								return D{}
+							}
+							btn := material.Button(s.Theme, s.Action, s.Label)
+							btn.Background = s.Theme.Fg
+							btn.Color = s.ActionColor
+							return btn.Layout(gtx)
+						}),
+					)
+				})
+			}),
+		)
+	})
+}
@@ -73,11 +73,16 @@ func WithAlpha(c color.NRGBA, a uint8) color.NRGBA {
 
 // Dialog renders an input with ok / cancel actions.
 type Dialog struct {
-	Context string
-
-	Input  materials.TextField
-	Ok     widget.Clickable
-	Cancel widget.Clickable
+	Input materials.TextField
+	// Calendar picks the input as a date, while Dated.
+	Calendar Calendar
+	Dated    bool
+	// When picks the date the input applies to, such as when a payment was
+	// made, while Stamped.
+	When    DatePicker
+	Stamped bool
+	Ok      widget.Clickable
+	Cancel  widget.Clickable
 }
 
 func (d *Dialog) Layout(gtx C, th *material.Theme, title string) D {
@@ -86,7 +91,35 @@ func (d *Dialog) Layout(gtx C, th *material.Theme, title string) D {
 	}.Layout(
 		gtx,
 		layout.Rigid(func(gtx C) D {
-			return d.Input.Layout(gtx, th, title)
+			if !d.Dated {
+				return d.Input.Layout(gtx, th, title)
+			}
+			return layout.Flex{
+				Axis:      layout.Horizontal,
+				Alignment: layout.Middle,
+			}.Layout(
+				gtx,
+				layout.Flexed(1, func(gtx C) D {
+					return d.Input.Layout(gtx, th, title)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return d.Calendar.LayoutToggle(gtx, th)
+				}),
+			)
+		}),
+		layout.Rigid(func(gtx C) D {
+			if !d.Dated {
+				return D{}
+			}
+			return d.Calendar.Layout(gtx, th, &d.Input)
+		}),
+		layout.Rigid(func(gtx C) D {
+			if !d.Stamped {
+				return D{}
+			}
+			return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx C) D {
+				return d.When.Layout(gtx, th, "Date")
+			})
 		}),
 		layout.Rigid(func(gtx C) D {
 			return D{Size: image.Point{Y: gtx.Px(unit.Dp(10))}}
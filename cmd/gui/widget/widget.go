@@ -21,6 +21,7 @@ type (
 type (
 	Editor      = widget.Editor
 	EditorEvent = widget.EditorEvent
+	SubmitEvent = widget.SubmitEvent
 	Enum        = widget.Enum
 	Clickable   = widget.Clickable
 	Bool        = widget.Bool
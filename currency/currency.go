@@ -24,3 +24,29 @@ func (c Currency) Dollars() float64 {
 func (c Currency) String() string {
 	return fmt.Sprintf("$%.02f", c.Dollars())
 }
+
+// Scale multiplies the amount by the fraction num/den, rounding half away from
+// zero to the nearest mill.
+// Use Scale rather than floating point arithmetic when prorating amounts so
+// that the result is exact to the mill.
+func (c Currency) Scale(num, den int64) Currency {
+	var (
+		n    = int64(c) * num
+		q, r = n / den, n % den
+	)
+	if abs(2*r) >= abs(den) {
+		if (n < 0) != (den < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+	return Currency(q)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
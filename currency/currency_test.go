@@ -0,0 +1,33 @@
+package currency
+
+import "testing"
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   Currency
+		num, den int64
+		want     Currency
+	}{
+		{"exact", 700, 3, 7, 300},
+		{"whole", 700, 7, 7, 700},
+		{"nothing", 700, 0, 7, 0},
+		{"below half rounds down", 1000, 1, 3, 333},
+		{"above half rounds up", 2000, 1, 3, 667},
+		{"half rounds up", 5, 1, 2, 3},
+		{"just below half", 149, 1, 100, 1},
+		{"just above half", 151, 1, 100, 2},
+		{"negative half rounds away from zero", -5, 1, 2, -3},
+		{"negative below half", -1000, 1, 3, -333},
+		{"negative denominator", 5, 1, -2, -3},
+		{"both negative", -5, -1, 2, 3},
+		{"GST of a cent", Cent, 1000, 10000, 10},
+		{"GST rounding", 1005, 1500, 10000, 151},
+		{"large amount", 1000000 * Dollar, 365, 366, 9972677596},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Scale(tt.num, tt.den); got != tt.want {
+			t.Errorf("%s: %d scaled by %d/%d: got %d, want %d", tt.name, tt.amount, tt.num, tt.den, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package avisha
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// Event is something that happened to the records, published by the app once
+// it has been committed.
+//
+// Events are one of the types below; subscribers switch on the type for the
+// events they care about.
+type Event interface {
+	event()
+}
+
+// TenantRegistered is published when a tenant is registered.
+type TenantRegistered struct {
+	Tenant Tenant
+}
+
+// TenantUpdated is published when the details of a tenant change.
+type TenantUpdated struct {
+	Tenant Tenant
+}
+
+// SiteListed is published when a site is listed.
+type SiteListed struct {
+	Site Site
+}
+
+// SiteUpdated is published when the details of a site change.
+type SiteUpdated struct {
+	Site Site
+}
+
+// LeaseCreated is published when a lease is created, whether as a draft or
+// in force.
+type LeaseCreated struct {
+	Lease Lease
+}
+
+// LeaseUpdated is published when the details of a lease are edited.
+type LeaseUpdated struct {
+	Lease Lease
+}
+
+// LeaseActivated is published when a draft lease is put into force.
+type LeaseActivated struct {
+	Lease ID
+}
+
+// LeaseNoticeGiven is published when notice is given to end a lease.
+type LeaseNoticeGiven struct {
+	Lease  ID
+	Notice Notice
+}
+
+// LeaseTerminated is published when a lease ends.
+type LeaseTerminated struct {
+	Lease ID
+	Date  time.Time
+}
+
+// LeaseRenewed is published when a lease is renewed into a new term.
+type LeaseRenewed struct {
+	Lease ID
+	Term  Term
+}
+
+// ServiceSubscribed is published when a lease subscribes to a service.
+type ServiceSubscribed struct {
+	Lease   ID
+	Service ServiceKey
+}
+
+// ServiceUnsubscribed is published when a lease unsubscribes from a service.
+type ServiceUnsubscribed struct {
+	Lease   ID
+	Service ServiceKey
+}
+
+// ServiceBilled is published when a service of a lease is billed.
+type ServiceBilled struct {
+	Lease   ID
+	Service ServiceKey
+	Debit   Payment
+}
+
+// BillReversed is published when an amount billed to a service of a lease
+// in error is withdrawn.
+type BillReversed struct {
+	Lease    ID
+	Service  ServiceKey
+	Reversal Reversal
+}
+
+// PaymentRecorded is published when a payment is made to a service of a
+// lease.
+type PaymentRecorded struct {
+	Lease   ID
+	Service ServiceKey
+	Payment Payment
+}
+
+// InvoiceIssued is published when a rent or utility invoice is issued.
+type InvoiceIssued struct {
+	Lease   ID
+	Service ServiceKey
+	Invoice ID
+	Bill    currency.Currency
+	Due     time.Time
+}
+
+// InvoicePaid is published when the payments to a service cover an invoice.
+type InvoicePaid struct {
+	Lease   ID
+	Service ServiceKey
+	Invoice ID
+}
+
+// RentIncreaseScheduled is published when a rent increase is scheduled.
+type RentIncreaseScheduled struct {
+	Lease  ID
+	Change RentChange
+}
+
+// RecurringChargeAdded is published when a recurring charge is attached to a
+// service.
+type RecurringChargeAdded struct {
+	Lease   ID
+	Service ServiceKey
+	Charge  RecurringCharge
+}
+
+// RecurringChargeRemoved is published when a recurring charge is detached
+// from a service.
+type RecurringChargeRemoved struct {
+	Lease   ID
+	Service ServiceKey
+	Charge  ID
+}
+
+// MeterInstalled is published when a meter is installed at a site.
+type MeterInstalled struct {
+	Meter Meter
+}
+
+// MeterRemoved is published when a meter is taken out of service.
+type MeterRemoved struct {
+	Meter Meter
+}
+
+// ReadingRecorded is published when a meter is read.
+type ReadingRecorded struct {
+	Reading Reading
+}
+
+// BillingRunCommitted is published when a billing run issues its invoices.
+type BillingRunCommitted struct {
+	Run ID
+}
+
+// BillingRunReversed is published when a billing run is reversed.
+type BillingRunReversed struct {
+	Run ID
+}
+
+// SupplierBillRecorded is published when a supplier bill is recorded.
+type SupplierBillRecorded struct {
+	Bill SupplierBill
+}
+
+// SettingsSaved is published when the settings are saved.
+type SettingsSaved struct {
+	Settings Settings
+}
+
+// RecordArchived is published when a tenant, site, lease or invoice is
+// archived.
+// Entity names the kind of record, such as "Tenant" or "RentInvoice".
+type RecordArchived struct {
+	Entity string
+	ID     ID
+}
+
+// RecordRestored is published when an archived record is restored.
+type RecordRestored struct {
+	Entity string
+	ID     ID
+}
+
+// RecordDeleted is published when a record is deleted for good.
+type RecordDeleted struct {
+	Entity string
+	ID     ID
+}
+
+// ActionUndone is published when an action is undone, restoring the records
+// it wrote to how they were before it.
+type ActionUndone struct {
+	Action string
+}
+
+// ActionRedone is published when an undone action is redone.
+type ActionRedone struct {
+	Action string
+}
+
+func (TenantRegistered) event()       {}
+func (TenantUpdated) event()          {}
+func (SiteListed) event()             {}
+func (SiteUpdated) event()            {}
+func (LeaseCreated) event()           {}
+func (LeaseUpdated) event()           {}
+func (LeaseActivated) event()         {}
+func (LeaseNoticeGiven) event()       {}
+func (LeaseTerminated) event()        {}
+func (LeaseRenewed) event()           {}
+func (ServiceSubscribed) event()      {}
+func (ServiceUnsubscribed) event()    {}
+func (ServiceBilled) event()          {}
+func (BillReversed) event()           {}
+func (PaymentRecorded) event()        {}
+func (InvoiceIssued) event()          {}
+func (InvoicePaid) event()            {}
+func (RentIncreaseScheduled) event()  {}
+func (RecurringChargeAdded) event()   {}
+func (RecurringChargeRemoved) event() {}
+func (MeterInstalled) event()         {}
+func (MeterRemoved) event()           {}
+func (ReadingRecorded) event()        {}
+func (BillingRunCommitted) event()    {}
+func (BillingRunReversed) event()     {}
+func (SupplierBillRecorded) event()   {}
+func (SettingsSaved) event()          {}
+func (RecordArchived) event()         {}
+func (RecordRestored) event()         {}
+func (RecordDeleted) event()          {}
+func (ActionUndone) event()           {}
+func (ActionRedone) event()           {}
+
+// Bus publishes the events of an app to its subscribers.
+//
+// Every change made through the app publishes an event, so the revision of
+// the bus also tells anything derived from the records, such as the data
+// shown by a view, when it is out of date.
+type Bus struct {
+	mu          sync.Mutex
+	next        int
+	subscribers map[int]func(Event)
+	// revision counts the events published so far.
+	revision uint64
+}
+
+// Revision counts the events published so far.
+// A nil bus is always at revision zero.
+func (b *Bus) Revision() uint64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.revision
+}
+
+// Subscribe calls fn with every event published, in order, until
+// unsubscribed.
+// fn is called on the goroutine that made the change and must not block.
+func (b *Bus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[int]func(Event))
+	}
+	id := b.next
+	b.next++
+	b.subscribers[id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// publish the events to every subscriber.
+// Publishing to a nil bus does nothing.
+func (b *Bus) publish(events ...Event) {
+	if b == nil || len(events) == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.revision += uint64(len(events))
+	subscribers := make([]func(Event), 0, len(b.subscribers))
+	for id := 0; id < b.next; id++ {
+		if fn, ok := b.subscribers[id]; ok {
+			subscribers = append(subscribers, fn)
+		}
+	}
+	b.mu.Unlock()
+	for _, e := range events {
+		for _, fn := range subscribers {
+			fn(e)
+		}
+	}
+}
+
+// emit publishes the event, or holds it until the transaction the app is
+// bound to commits.
+func (app App) emit(e Event) {
+	if app.pending != nil {
+		*app.pending = append(*app.pending, e)
+		return
+	}
+	app.Events.publish(e)
+}
@@ -6,6 +6,7 @@ require (
 	gioui.org v0.0.0-20201218091821-40c082e94961
 	git.sr.ht/~whereswaldon/materials v0.0.0-20201212021906-748774a2ad9b
 	github.com/asdine/storm/v3 v3.2.1
+	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/skratchdot/open-golang v0.0.0-20200116055534-eef842397966
 	github.com/spf13/pflag v1.0.5
 	go.etcd.io/bbolt v1.3.5 // indirect
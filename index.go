@@ -0,0 +1,708 @@
+package avisha
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/asdine/storm/v3"
+)
+
+// RecordKind identifies the kind of record a search hit refers to.
+type RecordKind int
+
+const (
+	TenantRecord RecordKind = iota
+	SiteRecord
+	LeaseRecord
+	RentInvoiceRecord
+	UtilityInvoiceRecord
+	PaymentRecord
+	ReadingRecord
+)
+
+func (k RecordKind) String() string {
+	switch k {
+	case TenantRecord:
+		return "Tenants"
+	case SiteRecord:
+		return "Sites"
+	case LeaseRecord:
+		return "Leases"
+	case RentInvoiceRecord:
+		return "Rent Invoices"
+	case UtilityInvoiceRecord:
+		return "Utility Invoices"
+	case PaymentRecord:
+		return "Payments"
+	case ReadingRecord:
+		return "Readings"
+	default:
+		return "Unknown"
+	}
+}
+
+// Hit is a record matched by a search.
+type Hit struct {
+	Kind RecordKind
+	// ID of the record, which for payments is the ID of the lease paid to and
+	// for readings the ID of the site the meter is at.
+	ID int
+	// Lease the record belongs to, if any.
+	Lease int
+	// Title and Detail summarise the record for display.
+	Title  string
+	Detail string
+	// Score ranks hits of the same kind, higher first.
+	Score int
+}
+
+// Index is a full-text index over the records of the app: tenants, sites,
+// leases, invoices, the payments made against them and meter readings.
+//
+// The index is built from the records on the first search, then kept up to
+// date from the events the app publishes: the entries of each record changed
+// are indexed again on the next search.
+// Records are denormalised into the index (an invoice is found by the name of
+// its tenant), so a lease owns the entries of its payments and invoices, and
+// changing a tenant or site indexes their leases again.
+// Changes that can't be traced to the records they affect, such as renaming
+// a service, rebuild the index, as does a new day since lease statuses move
+// on with the date.
+type Index struct {
+	mu    sync.Mutex
+	built bool
+	// day the index was built on.
+	day     time.Time
+	entries []entry
+	// terms maps each term to the entries it appears in.
+	terms map[string][]int
+	// owned maps each record to the entries indexed for it.
+	owned map[owner][]int
+	// removed counts the entries of records that have since been indexed
+	// again, which are skipped until the index is rebuilt.
+	removed int
+
+	// changes guards the records changed since the last search, which are
+	// noted as events are published.
+	changes sync.Mutex
+	dirty   map[owner]bool
+	stale   bool
+}
+
+// owner identifies the record entries are indexed for.
+type owner struct {
+	entity string
+	id     int
+}
+
+// entry is an indexed record.
+type entry struct {
+	Hit
+	// when the record happened, to rank recent records first.
+	when time.Time
+	// owner of the entry.
+	owner owner
+	// removed entries have been indexed again under a new entry.
+	removed bool
+}
+
+// Indexed returns the app with a full-text index that is kept up to date with
+// every change made through it.
+func (app App) Indexed() App {
+	if app.Events == nil {
+		app.Events = &Bus{}
+	}
+	app.Index = &Index{}
+	app.Events.Subscribe(app.Index.changed)
+	return app
+}
+
+// changed notes the records affected by an event, to be indexed again on the
+// next search.
+func (idx *Index) changed(e Event) {
+	var o owner
+	switch e := e.(type) {
+	case TenantRegistered:
+		o = owner{"Tenant", e.Tenant.ID}
+	case TenantUpdated:
+		o = owner{"Tenant", e.Tenant.ID}
+	case SiteListed:
+		o = owner{"Site", e.Site.ID}
+	case SiteUpdated:
+		o = owner{"Site", e.Site.ID}
+	case LeaseCreated:
+		o = owner{"Lease", e.Lease.ID}
+	case LeaseUpdated:
+		o = owner{"Lease", e.Lease.ID}
+	case LeaseActivated:
+		o = owner{"Lease", e.Lease}
+	case LeaseNoticeGiven:
+		o = owner{"Lease", e.Lease}
+	case LeaseTerminated:
+		o = owner{"Lease", e.Lease}
+	case LeaseRenewed:
+		o = owner{"Lease", e.Lease}
+	case ServiceSubscribed:
+		o = owner{"Lease", e.Lease}
+	case ServiceUnsubscribed:
+		o = owner{"Lease", e.Lease}
+	case ServiceBilled:
+		o = owner{"Lease", e.Lease}
+	case BillReversed:
+		o = owner{"Lease", e.Lease}
+	case PaymentRecorded:
+		o = owner{"Lease", e.Lease}
+	case InvoiceIssued:
+		o = owner{"Lease", e.Lease}
+	case InvoicePaid:
+		o = owner{"Lease", e.Lease}
+	case RentIncreaseScheduled, RecurringChargeAdded, RecurringChargeRemoved, SupplierBillRecorded:
+		// Nothing indexed changes.
+		return
+	case MeterInstalled:
+		o = owner{"Meter", e.Meter.ID}
+	case MeterRemoved:
+		o = owner{"Meter", e.Meter.ID}
+	case ReadingRecorded:
+		o = owner{"Meter", e.Reading.Meter}
+	case RecordArchived:
+		o = owner{e.Entity, e.ID}
+	case RecordRestored:
+		o = owner{e.Entity, e.ID}
+	case RecordDeleted:
+		o = owner{e.Entity, e.ID}
+	}
+	idx.changes.Lock()
+	defer idx.changes.Unlock()
+	switch o.entity {
+	case "Tenant", "Site", "Lease", "Meter":
+		if idx.dirty == nil {
+			idx.dirty = make(map[owner]bool)
+		}
+		idx.dirty[o] = true
+	default:
+		// Billing runs, settings, undo and invoices archived on their own
+		// touch records that can't be told from the event.
+		idx.stale = true
+	}
+}
+
+// Search the records for the text, returning the hits grouped by kind and
+// best first within each kind.
+// Each word of the text must prefix a term of the record, ignoring case and
+// common words, so that "invoice for the stark flat in march" finds the
+// invoices of the flat leased to Stark issued or covering March.
+func (app App) Search(text string) ([]Hit, error) {
+	if app.Index == nil {
+		return nil, fmt.Errorf("searching: app is not indexed")
+	}
+	idx := app.Index
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if err := idx.update(app, time.Now()); err != nil {
+		return nil, fmt.Errorf("updating index: %w", err)
+	}
+	words := queryWords(text)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	// scores of the entries matching every word so far.
+	var scores map[int]int
+	for ii, word := range words {
+		matched := make(map[int]int)
+		for term, entries := range idx.terms {
+			if !strings.HasPrefix(term, word) {
+				continue
+			}
+			// Whole terms score higher than prefixes.
+			score := 1
+			if term == word {
+				score = 2
+			}
+			for _, e := range entries {
+				if idx.entries[e].removed {
+					continue
+				}
+				if matched[e] < score {
+					matched[e] = score
+				}
+			}
+		}
+		if ii == 0 {
+			scores = matched
+			continue
+		}
+		for e := range scores {
+			if score, ok := matched[e]; ok {
+				scores[e] += score
+			} else {
+				delete(scores, e)
+			}
+		}
+	}
+	found := make([]int, 0, len(scores))
+	for e := range scores {
+		found = append(found, e)
+	}
+	sort.Slice(found, func(ii, jj int) bool {
+		a, b := idx.entries[found[ii]], idx.entries[found[jj]]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if scores[found[ii]] != scores[found[jj]] {
+			return scores[found[ii]] > scores[found[jj]]
+		}
+		return a.when.After(b.when)
+	})
+	hits := make([]Hit, len(found))
+	for ii, e := range found {
+		hits[ii] = idx.entries[e].Hit
+		hits[ii].Score = scores[e]
+	}
+	return hits, nil
+}
+
+// update indexes the records changed since the last search again, or builds
+// the whole index where that can't be done.
+func (idx *Index) update(app App, now time.Time) error {
+	idx.changes.Lock()
+	dirty, stale := idx.dirty, idx.stale
+	idx.dirty, idx.stale = nil, false
+	idx.changes.Unlock()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if !idx.built || stale || !idx.day.Equal(day) || idx.removed > len(idx.entries)/2 {
+		return idx.build(app, day)
+	}
+	if len(dirty) == 0 {
+		return nil
+	}
+	src, err := newSource(app, day)
+	if err != nil {
+		return err
+	}
+	// Tenants and sites are denormalised into the entries of their leases,
+	// and sites into the readings of their meters.
+	for o := range dirty {
+		var (
+			leases []*Lease
+			meters []*Meter
+		)
+		switch o.entity {
+		case "Tenant", "Site":
+			if err := app.Find(o.entity, o.id, &leases); err != nil && err != storm.ErrNotFound {
+				return fmt.Errorf("loading leases: %w", err)
+			}
+		}
+		if o.entity == "Site" {
+			if err := app.Find("Site", o.id, &meters); err != nil && err != storm.ErrNotFound {
+				return fmt.Errorf("loading meters: %w", err)
+			}
+		}
+		for _, l := range leases {
+			dirty[owner{"Lease", l.ID}] = true
+		}
+		for _, m := range meters {
+			dirty[owner{"Meter", m.ID}] = true
+		}
+	}
+	for o := range dirty {
+		idx.remove(o)
+		if err := src.index(idx, o); err != nil {
+			return fmt.Errorf("indexing %s %d: %w", o.entity, o.id, err)
+		}
+	}
+	return nil
+}
+
+// build the index from all the records of the app.
+func (idx *Index) build(app App, day time.Time) error {
+	idx.entries, idx.terms, idx.owned, idx.removed = nil, make(map[string][]int), make(map[owner][]int), 0
+	src, err := newSource(app, day)
+	if err != nil {
+		return err
+	}
+	var (
+		tenants   []*Tenant
+		sites     []*Site
+		leases    []*Lease
+		rent      []*RentInvoice
+		utilities []*UtilityInvoice
+		meters    []*Meter
+		readings  []*Reading
+	)
+	for _, records := range []interface{}{&tenants, &sites, &leases, &rent, &utilities, &meters, &readings} {
+		if err := app.All(records); err != nil && err != storm.ErrNotFound {
+			return fmt.Errorf("loading records: %w", err)
+		}
+	}
+	var (
+		rentOf    = make(map[int][]*RentInvoice)
+		utilityOf = make(map[int][]*UtilityInvoice)
+		readingOf = make(map[int][]*Reading)
+	)
+	for _, inv := range rent {
+		rentOf[inv.Lease] = append(rentOf[inv.Lease], inv)
+	}
+	for _, inv := range utilities {
+		utilityOf[inv.Lease] = append(utilityOf[inv.Lease], inv)
+	}
+	for _, r := range readings {
+		readingOf[r.Meter] = append(readingOf[r.Meter], r)
+	}
+	for _, t := range tenants {
+		src.tenants[t.ID] = *t
+		src.addTenant(idx, *t)
+	}
+	for _, s := range sites {
+		src.sites[s.ID] = *s
+		src.addSite(idx, *s)
+	}
+	for _, l := range leases {
+		if err := src.addLease(idx, *l, rentOf[l.ID], utilityOf[l.ID]); err != nil {
+			return err
+		}
+	}
+	for _, m := range meters {
+		if err := src.addMeter(idx, *m, readingOf[m.ID]); err != nil {
+			return err
+		}
+	}
+	idx.built, idx.day = true, day
+	return nil
+}
+
+// remove the entries of a record, leaving them in place to be skipped until
+// the index is rebuilt.
+func (idx *Index) remove(o owner) {
+	for _, e := range idx.owned[o] {
+		idx.entries[e].removed = true
+		idx.removed++
+	}
+	delete(idx.owned, o)
+}
+
+// source loads the records entries are indexed from, caching the tenants and
+// sites shared by many entries.
+type source struct {
+	app      App
+	day      time.Time
+	settings Settings
+	tenants  map[int]Tenant
+	sites    map[int]Site
+}
+
+func newSource(app App, day time.Time) (*source, error) {
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return nil, fmt.Errorf("loading settings: %w", err)
+	}
+	return &source{
+		app:      app,
+		day:      day,
+		settings: settings,
+		tenants:  make(map[int]Tenant),
+		sites:    make(map[int]Site),
+	}, nil
+}
+
+// index the record again from what is saved, indexing nothing if it has been
+// deleted.
+func (src *source) index(idx *Index, o owner) error {
+	switch o.entity {
+	case "Tenant":
+		t, err := src.tenant(o.id)
+		if err != nil || t.ID == 0 {
+			return err
+		}
+		src.addTenant(idx, t)
+	case "Site":
+		s, err := src.site(o.id)
+		if err != nil || s.ID == 0 {
+			return err
+		}
+		src.addSite(idx, s)
+	case "Lease":
+		var (
+			l         Lease
+			rent      []*RentInvoice
+			utilities []*UtilityInvoice
+		)
+		if err := src.app.One("ID", o.id, &l); err == storm.ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := src.app.Find("Lease", l.ID, &rent); err != nil && err != storm.ErrNotFound {
+			return fmt.Errorf("loading rent invoices: %w", err)
+		}
+		if err := src.app.Find("Lease", l.ID, &utilities); err != nil && err != storm.ErrNotFound {
+			return fmt.Errorf("loading utility invoices: %w", err)
+		}
+		return src.addLease(idx, l, rent, utilities)
+	case "Meter":
+		var m Meter
+		if err := src.app.One("ID", o.id, &m); err == storm.ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		readings, err := src.app.Readings(m.ID)
+		if err != nil {
+			return fmt.Errorf("loading readings: %w", err)
+		}
+		return src.addMeter(idx, m, readings)
+	}
+	return nil
+}
+
+// tenant loads the tenant, zero if it doesn't exist.
+func (src *source) tenant(id int) (Tenant, error) {
+	t, ok := src.tenants[id]
+	if ok {
+		return t, nil
+	}
+	if err := src.app.One("ID", id, &t); err != nil && err != storm.ErrNotFound {
+		return t, fmt.Errorf("loading tenant: %w", err)
+	}
+	src.tenants[id] = t
+	return t, nil
+}
+
+// site loads the site, zero if it doesn't exist.
+func (src *source) site(id int) (Site, error) {
+	s, ok := src.sites[id]
+	if ok {
+		return s, nil
+	}
+	if err := src.app.One("ID", id, &s); err != nil && err != storm.ErrNotFound {
+		return s, fmt.Errorf("loading site: %w", err)
+	}
+	src.sites[id] = s
+	return s, nil
+}
+
+// service defines the service, falling back to its key for services no
+// longer in the catalogue.
+func (src *source) service(key ServiceKey) ServiceDefinition {
+	def, ok := src.settings.Service(key)
+	if !ok {
+		def = ServiceDefinition{Key: key, Name: string(key), Reference: string(key)}
+	}
+	return def
+}
+
+func (src *source) addTenant(idx *Index, t Tenant) {
+	idx.add(entry{
+		Hit: Hit{
+			Kind:   TenantRecord,
+			ID:     t.ID,
+			Title:  t.Name,
+			Detail: t.Contact,
+		},
+		owner: owner{"Tenant", t.ID},
+	}, append([]string{"tenant"}, tenantFields(t)...)...)
+}
+
+func (src *source) addSite(idx *Index, s Site) {
+	idx.add(entry{
+		Hit: Hit{
+			Kind:   SiteRecord,
+			ID:     s.ID,
+			Title:  fmt.Sprintf("Site %s", s.Number),
+			Detail: s.Dwelling.String(),
+		},
+		owner: owner{"Site", s.ID},
+	}, "site", s.Number, s.Dwelling.String())
+}
+
+// addLease indexes the lease along with the payments made to it and the
+// invoices issued for it.
+func (src *source) addLease(idx *Index, l Lease, rent []*RentInvoice, utilities []*UtilityInvoice) error {
+	t, err := src.tenant(l.Tenant)
+	if err != nil {
+		return err
+	}
+	s, err := src.site(l.Site)
+	if err != nil {
+		return err
+	}
+	var (
+		o      = owner{"Lease", l.ID}
+		status = l.StatusAt(src.day)
+		// fields describe who the lease is with.
+		fields = []string{"site", s.Number, s.Dwelling.String(), t.Name}
+	)
+	idx.add(entry{
+		Hit: Hit{
+			Kind:   LeaseRecord,
+			ID:     l.ID,
+			Lease:  l.ID,
+			Title:  fmt.Sprintf("Site %s, %s", s.Number, t.Name),
+			Detail: fmt.Sprintf("%s (%s)", l.Term, status),
+		},
+		when:  l.Term.Start,
+		owner: o,
+	}, append(fields, "lease", status.String(), dateTerms(l.Term.Start), dateTerms(l.Term.End()))...)
+	for key, service := range l.Services {
+		def := src.service(key)
+		for _, p := range service.Ledger.Credits {
+			idx.add(entry{
+				Hit: Hit{
+					Kind:   PaymentRecord,
+					ID:     l.ID,
+					Lease:  l.ID,
+					Title:  fmt.Sprintf("%s paid to %s", p.Amount, def.Name),
+					Detail: fmt.Sprintf("Site %s, %s, %s", s.Number, t.Name, p.Time.Format("2 January 2006")),
+				},
+				when:  p.Time,
+				owner: o,
+			}, append(
+				fields,
+				"payment", def.Name, p.Amount.String(), dateTerms(p.Time),
+				PaymentReference(t, s, def.Reference),
+			)...)
+		}
+	}
+	for _, inv := range rent {
+		idx.add(entry{
+			Hit: Hit{
+				Kind:   RentInvoiceRecord,
+				ID:     inv.ID,
+				Lease:  inv.Lease,
+				Title:  fmt.Sprintf("Rent invoice #%d, %s", inv.ID, inv.Bill),
+				Detail: fmt.Sprintf("Site %s, %s, %s", s.Number, t.Name, inv.Period),
+			},
+			when:  inv.Issued,
+			owner: o,
+		}, append(fields, invoiceTerms(inv.Invoice, "rent", PaymentReference(t, s, "RENT"))...)...)
+	}
+	for _, inv := range utilities {
+		def := src.service(inv.Service)
+		idx.add(entry{
+			Hit: Hit{
+				Kind:   UtilityInvoiceRecord,
+				ID:     inv.ID,
+				Lease:  inv.Lease,
+				Title:  fmt.Sprintf("%s invoice #%d, %s", def.Name, inv.ID, inv.Bill),
+				Detail: fmt.Sprintf("Site %s, %s, %s", s.Number, t.Name, inv.Period),
+			},
+			when:  inv.Issued,
+			owner: o,
+		}, append(fields, invoiceTerms(inv.Invoice, def.Name, PaymentReference(t, s, def.Reference))...)...)
+	}
+	return nil
+}
+
+// addMeter indexes the readings of the meter, found by their notes.
+func (src *source) addMeter(idx *Index, m Meter, readings []*Reading) error {
+	s, err := src.site(m.Site)
+	if err != nil {
+		return err
+	}
+	def := src.service(m.Service)
+	for _, r := range readings {
+		detail := fmt.Sprintf("Site %s, %s", s.Number, r.Date.Format("2 January 2006"))
+		if r.Note != "" {
+			detail = fmt.Sprintf("%s, %s", detail, r.Note)
+		}
+		fields := []string{
+			"reading", def.Name, "site", s.Number, m.Serial,
+			strconv.Itoa(r.Value), dateTerms(r.Date), r.Note,
+		}
+		if r.Estimated {
+			fields = append(fields, "estimated")
+		}
+		idx.add(entry{
+			Hit: Hit{
+				Kind:   ReadingRecord,
+				ID:     s.ID,
+				Title:  fmt.Sprintf("%s reading of %d", def.Name, r.Value),
+				Detail: detail,
+			},
+			when:  r.Date,
+			owner: owner{"Meter", m.ID},
+		}, fields...)
+	}
+	return nil
+}
+
+// add an entry to the index under the terms of the text fields.
+func (idx *Index) add(e entry, fields ...string) {
+	var (
+		n    = len(idx.entries)
+		seen = make(map[string]bool)
+	)
+	idx.entries = append(idx.entries, e)
+	idx.owned[e.owner] = append(idx.owned[e.owner], n)
+	for _, field := range fields {
+		for _, term := range terms(field) {
+			if seen[term] {
+				continue
+			}
+			seen[term] = true
+			idx.terms[term] = append(idx.terms[term], n)
+		}
+	}
+}
+
+// invoiceTerms are the fields of an invoice searched on.
+func invoiceTerms(inv Invoice, service, reference string) []string {
+	fields := []string{
+		"invoice", service, reference,
+		fmt.Sprintf("#%d", inv.ID), inv.Bill.String(),
+		dateTerms(inv.Issued), dateTerms(inv.Due),
+		dateTerms(inv.Period.Start), dateTerms(inv.Period.End().AddDate(0, 0, -1)),
+	}
+	if inv.IsPaid() {
+		fields = append(fields, "paid")
+	} else {
+		fields = append(fields, "unpaid")
+	}
+	return fields
+}
+
+// dateTerms writes a date the ways it might be searched for.
+func dateTerms(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2 January 2006 02/01/2006")
+}
+
+// stopWords are ignored in searches.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "at": true, "by": true, "for": true,
+	"from": true, "in": true, "of": true, "on": true, "that": true,
+	"the": true, "this": true, "to": true, "with": true,
+}
+
+// queryWords splits search text into the words to match, dropping common
+// words unless nothing else is left.
+func queryWords(text string) []string {
+	var (
+		all   = terms(text)
+		words []string
+	)
+	for _, word := range all {
+		if !stopWords[word] {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return all
+	}
+	return words
+}
+
+// terms splits text into lower case terms of letters and digits, keeping the
+// decimal point of amounts.
+func terms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	})
+}
@@ -0,0 +1,101 @@
+package avisha
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestIndexUpdates checks that the index follows changes to the records
+// without being rebuilt, including those denormalised into other entries.
+func TestIndexUpdates(t *testing.T) {
+	app := open(t).Indexed()
+	var (
+		tenant = Tenant{Name: "Stark"}
+		site   = Site{Number: "7"}
+	)
+	if err := app.RegisterTenant(&tenant); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.ListSite(&site); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().AddDate(0, -1, 0)
+	l := Lease{
+		Tenant: tenant.ID,
+		Site:   site.ID,
+		Term:   Term{Start: start, Duration: 365 * 24 * time.Hour},
+	}
+	if err := app.CreateLease(&l); err != nil {
+		t.Fatal(err)
+	}
+	meter := Meter{Site: site.ID, Service: ServiceElectricity, Serial: "E1", Installed: start}
+	if err := app.InstallMeter(&meter, Reading{Value: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Search(""); err != nil {
+		t.Fatalf("building: %v", err)
+	}
+	tests := []struct {
+		name   string
+		change func(app App) error
+		query  string
+		// want is the title and detail of each hit.
+		want []string
+	}{
+		{
+			"reading notes",
+			func(app App) error {
+				return app.RecordReading(&Reading{Meter: meter.ID, Date: start.AddDate(0, 0, 7), Value: 150, Note: "gate locked"})
+			},
+			"gate",
+			[]string{fmt.Sprintf("Electricity reading of 150: Site 7, %s, gate locked", start.AddDate(0, 0, 7).Format("2 January 2006"))},
+		},
+		{
+			"renamed tenant",
+			func(app App) error {
+				tenant.Name = "Lannister"
+				return app.UpdateTenant(&tenant)
+			},
+			"lannister lease",
+			[]string{fmt.Sprintf("Site 7, Lannister: %s (Active)", l.Term)},
+		},
+		{
+			"notice taken effect",
+			func(app App) error {
+				return app.GiveNotice(l.ID, start.AddDate(0, 0, 1), 7*24*time.Hour)
+			},
+			"lannister lease",
+			[]string{fmt.Sprintf("Site 7, Lannister: %s (Ended)", l.Term)},
+		},
+		{
+			"old name",
+			nil,
+			"stark",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.change != nil {
+				if err := tt.change(app); err != nil {
+					t.Fatalf("changing: %v", err)
+				}
+			}
+			hits, err := app.Search(tt.query)
+			if err != nil {
+				t.Fatalf("searching: %v", err)
+			}
+			var got []string
+			for _, hit := range hits {
+				got = append(got, fmt.Sprintf("%s: %s", hit.Title, hit.Detail))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if app.Index.removed == 0 {
+				t.Errorf("index was rebuilt rather than updated")
+			}
+		})
+	}
+}
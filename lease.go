@@ -0,0 +1,205 @@
+package avisha
+
+import (
+	"fmt"
+	"time"
+)
+
+// LeaseStatus describes where a Lease is in its lifecycle.
+type LeaseStatus int
+
+const (
+	// Active leases are in force within their term.
+	// Active is the zero value so that leases entered before statuses existed
+	// remain in force.
+	Active LeaseStatus = iota
+	// Draft leases are being prepared and are not yet in force.
+	Draft
+	// Periodic leases have run past the end of their term without being ended
+	// and are holding over.
+	Periodic
+	// NoticeGiven leases end once the notice period has elapsed.
+	NoticeGiven
+	// Ended leases are no longer in force and are kept for history.
+	Ended
+)
+
+func (s LeaseStatus) String() string {
+	switch s {
+	case Active:
+		return "Active"
+	case Draft:
+		return "Draft"
+	case Periodic:
+		return "Periodic"
+	case NoticeGiven:
+		return "Notice Given"
+	case Ended:
+		return "Ended"
+	default:
+		return "Unknown"
+	}
+}
+
+// Notice is given to end a Lease once the notice period has elapsed.
+type Notice struct {
+	// Given is when the notice was served.
+	Given time.Time
+	// Period is how long the notice runs for.
+	Period time.Duration
+}
+
+// Effective is when the notice expires and the lease ends.
+func (n Notice) Effective() time.Time {
+	return n.Given.Add(n.Period)
+}
+
+// Ends returns when the lease ends: when it was terminated, or otherwise when
+// the notice given takes effect. It is zero while the lease has no end.
+func (l Lease) Ends() time.Time {
+	if !l.Terminated.IsZero() {
+		return l.Terminated
+	}
+	if l.Status == NoticeGiven {
+		return l.Notice.Effective()
+	}
+	return time.Time{}
+}
+
+// StatusAt reports the status of the lease at the given time.
+// An active lease that has run past the end of its term is holding over and
+// is reported as Periodic. A lease given notice is reported as Ended once the
+// notice takes effect.
+func (l Lease) StatusAt(t time.Time) LeaseStatus {
+	if l.Status == Active && t.After(l.Term.End()) {
+		return Periodic
+	}
+	if l.Status == NoticeGiven && !t.Before(l.Notice.Effective()) {
+		return Ended
+	}
+	return l.Status
+}
+
+// IsCurrent reports whether the lease is in force at the given time.
+func (l Lease) IsCurrent(t time.Time) bool {
+	switch l.StatusAt(t) {
+	case Active, Periodic, NoticeGiven:
+		return true
+	}
+	return false
+}
+
+// ActivateLease puts a draft lease into force.
+func (app App) ActivateLease(leaseID int) error {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return fmt.Errorf("finding lease: %w", err)
+	}
+	if l.Status != Draft {
+		return fmt.Errorf("lease %d is %s, only drafts can be activated", l.ID, l.Status)
+	}
+	l.Status = Active
+	if err := app.Save(&l); err != nil {
+		return err
+	}
+	app.emit(LeaseActivated{Lease: l.ID})
+	return nil
+}
+
+// GiveNotice records notice to end a lease after the given notice period.
+// Every service on the lease closes when the notice takes effect and is
+// flagged as owing a final invoice.
+func (app App) GiveNotice(leaseID int, given time.Time, period time.Duration) error {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return fmt.Errorf("finding lease: %w", err)
+	}
+	if !l.IsCurrent(given) {
+		return fmt.Errorf("lease %d is %s, notice can only be given on a current lease", l.ID, l.StatusAt(given))
+	}
+	if period <= 0 {
+		return fmt.Errorf("notice period must be a positive duration, got %s", period)
+	}
+	l.Status = NoticeGiven
+	l.Notice = Notice{
+		Given:  given,
+		Period: period,
+	}
+	l.close(l.Notice.Effective())
+	if err := app.Save(&l); err != nil {
+		return err
+	}
+	app.emit(LeaseNoticeGiven{Lease: l.ID, Notice: l.Notice})
+	return nil
+}
+
+// TerminateLease ends a lease on the given date.
+// Every service on the lease is closed as of that date and flagged as owing a
+// final invoice.
+func (app App) TerminateLease(leaseID int, date time.Time) error {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return fmt.Errorf("finding lease: %w", err)
+	}
+	if l.Status == Ended {
+		return fmt.Errorf("lease %d has already ended", l.ID)
+	}
+	if date.Before(l.Term.Start) {
+		return fmt.Errorf("termination date must not be before the lease starts")
+	}
+	l.Status = Ended
+	l.Terminated = date
+	l.close(date)
+	if err := app.Save(&l); err != nil {
+		return err
+	}
+	app.emit(LeaseTerminated{Lease: l.ID, Date: date})
+	return nil
+}
+
+// close every service on the lease as of the date, flagging each as owing a
+// final invoice.
+func (l *Lease) close(date time.Time) {
+	for name, s := range l.Services {
+		s.Closed = date
+		s.Final = true
+		l.Services[name] = s
+	}
+}
+
+// RenewLease renews a lease into a new term.
+// The current term is kept in the lease history and any notice is withdrawn,
+// reopening the services it would have closed.
+func (app App) RenewLease(leaseID int, term Term) error {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return fmt.Errorf("finding lease: %w", err)
+	}
+	if l.Status == Ended || l.Status == Draft {
+		return fmt.Errorf("lease %d is %s, only current leases can be renewed", l.ID, l.Status)
+	}
+	if term.Start.Before(l.Term.Start) {
+		return fmt.Errorf("renewed term must not start before the current term")
+	}
+	if term.Duration <= 0 {
+		return fmt.Errorf("renewed term must have a positive duration")
+	}
+	if l.Status == NoticeGiven {
+		for name, s := range l.Services {
+			if s.Closed.Equal(l.Notice.Effective()) {
+				s.Closed = time.Time{}
+				s.Final = false
+				l.Services[name] = s
+			}
+		}
+	}
+	l.History = append(l.History, l.Term)
+	l.Term = term
+	l.Status = Active
+	l.Notice = Notice{}
+	if err := app.Save(&l); err != nil {
+		return err
+	}
+	app.emit(LeaseRenewed{Lease: l.ID, Term: term})
+	return nil
+}
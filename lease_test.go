@@ -0,0 +1,99 @@
+package avisha
+
+import (
+	"testing"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+func TestLeaseStatusAt(t *testing.T) {
+	var (
+		term   = Term{Start: date(2023, time.January, 1), Duration: 365 * day}
+		notice = Notice{Given: date(2023, time.June, 1), Period: 21 * day}
+	)
+	tests := []struct {
+		name    string
+		lease   Lease
+		at      time.Time
+		want    LeaseStatus
+		current bool
+	}{
+		{"draft", Lease{Term: term, Status: Draft}, date(2023, time.March, 1), Draft, false},
+		{"active within term", Lease{Term: term}, date(2023, time.March, 1), Active, true},
+		{"active past term", Lease{Term: term}, date(2024, time.March, 1), Periodic, true},
+		{"notice running", Lease{Term: term, Status: NoticeGiven, Notice: notice}, date(2023, time.June, 21), NoticeGiven, true},
+		{"notice effective", Lease{Term: term, Status: NoticeGiven, Notice: notice}, date(2023, time.June, 22), Ended, false},
+		{"notice past effective", Lease{Term: term, Status: NoticeGiven, Notice: notice}, date(2023, time.August, 1), Ended, false},
+		{"terminated", Lease{Term: term, Status: Ended, Terminated: date(2023, time.June, 1)}, date(2023, time.July, 1), Ended, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lease.StatusAt(tt.at); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if got := tt.lease.IsCurrent(tt.at); got != tt.current {
+				t.Errorf("got current %v, want %v", got, tt.current)
+			}
+		})
+	}
+}
+
+// TestLeaseTransitions walks a lease through its lifecycle, checking that
+// each transition is allowed only from the right status.
+func TestLeaseTransitions(t *testing.T) {
+	app := open(t)
+	l := Lease{
+		Term:   Term{Start: date(2023, time.January, 1), Duration: 365 * day},
+		Status: Draft,
+	}
+	if err := app.Save(&l); err != nil {
+		t.Fatal(err)
+	}
+	id := int(l.ID)
+	var (
+		given     = date(2023, time.June, 1)
+		effective = given.Add(21 * day)
+	)
+	steps := []struct {
+		name string
+		do   func() error
+		// fails reports whether the step should be refused.
+		fails bool
+		want  LeaseStatus
+		// at is when the status is checked.
+		at time.Time
+	}{
+		{"notice on a draft", func() error { return app.GiveNotice(id, given, 21*day) }, true, Draft, given},
+		{"activate", func() error { return app.ActivateLease(id) }, false, Active, given},
+		{"activate again", func() error { return app.ActivateLease(id) }, true, Active, given},
+		{"zero notice", func() error { return app.GiveNotice(id, given, 0) }, true, Active, given},
+		{"negative notice", func() error { return app.GiveNotice(id, given, -day) }, true, Active, given},
+		{"give notice", func() error { return app.GiveNotice(id, given, 21*day) }, false, NoticeGiven, given},
+		{"notice takes effect", func() error { return nil }, false, Ended, effective},
+		{"notice once ended", func() error { return app.GiveNotice(id, effective, 21*day) }, true, Ended, effective},
+		{"renew", func() error {
+			return app.RenewLease(id, Term{Start: date(2024, time.January, 1), Duration: 365 * day})
+		}, false, Active, effective},
+		{"terminate", func() error { return app.TerminateLease(id, date(2024, time.March, 1)) }, false, Ended, effective},
+		{"terminate again", func() error { return app.TerminateLease(id, date(2024, time.April, 1)) }, true, Ended, effective},
+		{"renew once ended", func() error {
+			return app.RenewLease(id, Term{Start: date(2025, time.January, 1), Duration: 365 * day})
+		}, true, Ended, effective},
+	}
+	for _, step := range steps {
+		err := step.do()
+		if step.fails && err == nil {
+			t.Errorf("%s: want refused, got nil", step.name)
+		}
+		if !step.fails && err != nil {
+			t.Errorf("%s: %v", step.name, err)
+		}
+		if err := app.One("ID", l.ID, &l); err != nil {
+			t.Fatal(err)
+		}
+		if got := l.StatusAt(step.at); got != step.want {
+			t.Errorf("%s: got %s, want %s", step.name, got, step.want)
+		}
+	}
+}
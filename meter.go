@@ -0,0 +1,442 @@
+package avisha
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+)
+
+// Meter measures the consumption of a metered service at a Site.
+type Meter struct {
+	ID      ID  `storm:"id,increment"`
+	Site    int `storm:"index"`
+	Service ServiceKey
+	// Serial number printed on the meter.
+	Serial string
+	// Digits on the register.
+	// The register rolls over to zero once it passes the largest value it can
+	// show. Zero digits means the register is assumed never to roll over.
+	Digits int
+	// TimeOfUse meters record off-peak consumption on a second register.
+	TimeOfUse bool
+	// Installed is when the meter started measuring.
+	Installed time.Time
+	// Removed is when the meter was replaced, zero while in service.
+	Removed time.Time
+}
+
+// Reading is a value read off the register of a meter.
+type Reading struct {
+	ID    ID  `storm:"id,increment"`
+	Meter int `storm:"index"`
+	Date  time.Time
+	Value int
+	// OffPeak is the value of the off-peak register of a time-of-use meter.
+	OffPeak int
+	// Estimated readings were not read off the register.
+	Estimated bool
+	// Note records anything noteworthy about the reading.
+	Note string
+	// Photo is the path to a photo of the register.
+	Photo string
+}
+
+// InService reports whether the meter is installed and has not been removed.
+func (m Meter) InService() bool {
+	return m.Removed.IsZero()
+}
+
+// Capacity returns the number of values the register can show before rolling
+// over, or zero if it does not roll over.
+func (m Meter) Capacity() int {
+	if m.Digits <= 0 {
+		return 0
+	}
+	capacity := 1
+	for ii := 0; ii < m.Digits; ii++ {
+		capacity *= 10
+	}
+	return capacity
+}
+
+// Validate the value can be shown on the register.
+func (m Meter) Validate(value int) error {
+	if value < 0 {
+		return fmt.Errorf("reading must not be negative")
+	}
+	if capacity := m.Capacity(); capacity > 0 && value >= capacity {
+		return fmt.Errorf("reading must have at most %d digits", m.Digits)
+	}
+	return nil
+}
+
+// Consumption returns the units consumed between two values read off the
+// register.
+// A current value less than the previous value is treated as the register
+// rolling over, which is only possible when the number of digits is known.
+func (m Meter) Consumption(previous, current int) (int, error) {
+	if err := m.Validate(current); err != nil {
+		return 0, err
+	}
+	if current >= previous {
+		return current - previous, nil
+	}
+	if m.Capacity() == 0 {
+		return 0, fmt.Errorf("reading %d is less than the previous reading %d", current, previous)
+	}
+	return m.Capacity() - previous + current, nil
+}
+
+func (m Meter) String() string {
+	if m.Serial == "" {
+		return fmt.Sprintf("Meter %d", m.ID)
+	}
+	return m.Serial
+}
+
+// InstallMeter installs a meter at a site for a metered service, with the
+// opening values read off its registers.
+// At most one meter can be in service for each service at a site.
+func (app App) InstallMeter(m *Meter, opening Reading) error {
+	if m.Site == 0 {
+		return fmt.Errorf("meter must be installed at a site")
+	}
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	if def, _ := settings.Service(m.Service); def.Kind != Metered {
+		return fmt.Errorf("service %q is not metered", m.Service)
+	}
+	if err := m.Validate(opening.Value); err != nil {
+		return fmt.Errorf("opening reading: %w", err)
+	}
+	if err := m.Validate(opening.OffPeak); err != nil {
+		return fmt.Errorf("opening off-peak reading: %w", err)
+	}
+	if existing, err := app.ActiveMeter(m.Site, m.Service); err == nil {
+		return fmt.Errorf("%s is already in service, replace it instead", existing)
+	} else if !isNotFound(err) {
+		return err
+	}
+	if m.Installed.IsZero() {
+		m.Installed = time.Now()
+	}
+	m.Removed = time.Time{}
+	if err := app.Save(m); err != nil {
+		return fmt.Errorf("saving meter: %w", err)
+	}
+	opening.Meter = m.ID
+	opening.Date = m.Installed
+	if !m.TimeOfUse {
+		opening.OffPeak = 0
+	}
+	if opening.Note == "" {
+		opening.Note = "Opening reading"
+	}
+	if err := app.Save(&opening); err != nil {
+		return fmt.Errorf("saving opening reading: %w", err)
+	}
+	app.emit(MeterInstalled{Meter: *m})
+	app.emit(ReadingRecorded{Reading: opening})
+	return nil
+}
+
+// ReplaceMeter removes a meter from service with a closing reading and
+// installs the replacement in its place.
+func (app App) ReplaceMeter(meterID int, closing Reading, replacement *Meter, opening Reading) error {
+	var m Meter
+	if err := app.One("ID", meterID, &m); err != nil {
+		return fmt.Errorf("finding meter: %w", err)
+	}
+	closing.Meter = m.ID
+	if closing.Note == "" {
+		closing.Note = "Closing reading"
+	}
+	if err := app.RecordReading(&closing); err != nil {
+		return fmt.Errorf("recording closing reading: %w", err)
+	}
+	m.Removed = closing.Date
+	if err := app.Save(&m); err != nil {
+		return fmt.Errorf("removing meter: %w", err)
+	}
+	app.emit(MeterRemoved{Meter: m})
+	replacement.Site = m.Site
+	replacement.Service = m.Service
+	replacement.Installed = closing.Date
+	if err := app.InstallMeter(replacement, opening); err != nil {
+		return fmt.Errorf("installing replacement: %w", err)
+	}
+	return nil
+}
+
+// Meters loads the meters installed at a site, oldest first.
+func (app App) Meters(siteID int) ([]*Meter, error) {
+	var meters []*Meter
+	if err := app.Find("Site", siteID, &meters); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	sort.SliceStable(meters, func(ii, jj int) bool {
+		return meters[ii].Installed.Before(meters[jj].Installed)
+	})
+	return meters, nil
+}
+
+// ActiveMeter returns the meter in service at a site for the service.
+// The error wraps storm.ErrNotFound when no meter is in service.
+func (app App) ActiveMeter(siteID int, key ServiceKey) (m Meter, err error) {
+	meters, err := app.Meters(siteID)
+	if err != nil {
+		return m, fmt.Errorf("loading meters: %w", err)
+	}
+	for _, meter := range meters {
+		if meter.Service == key && meter.InService() {
+			return *meter, nil
+		}
+	}
+	return m, fmt.Errorf("no %s meter in service: %w", key, storm.ErrNotFound)
+}
+
+// Readings loads the readings of a meter, oldest first.
+func (app App) Readings(meterID int) ([]*Reading, error) {
+	var readings []*Reading
+	if err := app.Find("Meter", meterID, &readings); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	sortReadings(readings)
+	return readings, nil
+}
+
+// RecordReading records a value read off the register of a meter.
+// The reading must follow the last reading, and must not imply negative
+// consumption unless the register has rolled over.
+func (app App) RecordReading(r *Reading) error {
+	var m Meter
+	if err := app.One("ID", r.Meter, &m); err != nil {
+		return fmt.Errorf("finding meter: %w", err)
+	}
+	if r.Date.IsZero() {
+		r.Date = time.Now()
+	}
+	if r.Date.Before(m.Installed) {
+		return fmt.Errorf("reading must not be before the meter was installed")
+	}
+	if !m.InService() && r.Date.After(m.Removed) {
+		return fmt.Errorf("reading must not be after the meter was removed")
+	}
+	if !m.TimeOfUse {
+		r.OffPeak = 0
+	}
+	readings, err := app.Readings(m.ID)
+	if err != nil {
+		return fmt.Errorf("loading readings: %w", err)
+	}
+	if len(readings) > 0 {
+		last := readings[len(readings)-1]
+		if r.Date.Before(last.Date) {
+			return fmt.Errorf("reading must not be before the last reading on %s", last.Date.Format("02/01/2006"))
+		}
+		if _, err := m.Consumption(last.Value, r.Value); err != nil {
+			return err
+		}
+		if _, err := m.Consumption(last.OffPeak, r.OffPeak); err != nil {
+			return fmt.Errorf("off-peak: %w", err)
+		}
+	} else if err := m.Validate(r.Value); err != nil {
+		return err
+	} else if err := m.Validate(r.OffPeak); err != nil {
+		return fmt.Errorf("off-peak: %w", err)
+	}
+	if err := app.Save(r); err != nil {
+		return err
+	}
+	app.emit(ReadingRecorded{Reading: *r})
+	return nil
+}
+
+// Consumption returns the units consumed between two readings of a service
+// at a site.
+// The readings may be of different meters when a meter was replaced in
+// between, in which case the consumption of each meter is summed.
+func (app App) Consumption(previous, current Reading) (int, error) {
+	return app.consumption(previous, current, func(r *Reading) int {
+		return r.Value
+	})
+}
+
+// OffPeakConsumption returns the units consumed off-peak between two
+// readings of a service at a site.
+// Only time-of-use meters contribute off-peak consumption.
+func (app App) OffPeakConsumption(previous, current Reading) (int, error) {
+	return app.consumption(previous, current, func(r *Reading) int {
+		return r.OffPeak
+	})
+}
+
+// consumption sums the units consumed between two readings on the register
+// selected by value.
+func (app App) consumption(previous, current Reading, value func(r *Reading) int) (int, error) {
+	var from, to Meter
+	if err := app.One("ID", previous.Meter, &from); err != nil {
+		return 0, fmt.Errorf("finding meter: %w", err)
+	}
+	if err := app.One("ID", current.Meter, &to); err != nil {
+		return 0, fmt.Errorf("finding meter: %w", err)
+	}
+	if from.Site != to.Site || from.Service != to.Service {
+		return 0, fmt.Errorf("readings must be of the same service at the same site")
+	}
+	if current.Date.Before(previous.Date) {
+		return 0, fmt.Errorf("current reading must not be before the previous reading")
+	}
+	if previous.ID == current.ID {
+		return 0, nil
+	}
+	var (
+		meters = make(map[int]Meter)
+		ids    []int
+	)
+	all, err := app.Meters(from.Site)
+	if err != nil {
+		return 0, fmt.Errorf("loading meters: %w", err)
+	}
+	for _, m := range all {
+		if m.Service == from.Service {
+			meters[m.ID] = *m
+			ids = append(ids, m.ID)
+		}
+	}
+	var readings []*Reading
+	if err := app.Select(
+		q.In("Meter", ids),
+		q.Gte("Date", previous.Date),
+		q.Lte("Date", current.Date),
+	).Find(&readings); err != nil && err != storm.ErrNotFound {
+		return 0, fmt.Errorf("loading readings: %w", err)
+	}
+	sortReadings(readings)
+	var (
+		total int
+		last  *Reading
+	)
+	for _, r := range readings {
+		if last == nil {
+			if r.ID != previous.ID {
+				continue
+			}
+			last = r
+			continue
+		}
+		if r.Meter == last.Meter {
+			units, err := meters[r.Meter].Consumption(value(last), value(r))
+			if err != nil {
+				return 0, fmt.Errorf("reading %d: %w", r.ID, err)
+			}
+			total += units
+		}
+		last = r
+		if r.ID == current.ID {
+			return total, nil
+		}
+	}
+	return 0, fmt.Errorf("readings are not in sequence")
+}
+
+// sortReadings sorts readings by date, keeping readings taken on the same
+// date in the order they were recorded.
+func sortReadings(readings []*Reading) {
+	sort.SliceStable(readings, func(ii, jj int) bool {
+		if readings[ii].Date.Equal(readings[jj].Date) {
+			return readings[ii].ID < readings[jj].ID
+		}
+		return readings[ii].Date.Before(readings[jj].Date)
+	})
+}
+
+// isNotFound reports whether the error is, or wraps, storm.ErrNotFound.
+func isNotFound(err error) bool {
+	return errors.Is(err, storm.ErrNotFound)
+}
+
+// migrateReadings records meter readings for utility invoices issued before
+// meters existed, installing a meter for each service at a site as needed.
+func (app App) migrateReadings() error {
+	var invoices []*UtilityInvoice
+	if err := app.AllByIndex("ID", &invoices); err != nil && err != storm.ErrNotFound {
+		return fmt.Errorf("loading invoices: %w", err)
+	}
+	type key struct {
+		Site    int
+		Service ServiceKey
+	}
+	var (
+		leases = make(map[int]Lease)
+		meters = make(map[key]Meter)
+		last   = make(map[key]Reading)
+	)
+	for _, inv := range invoices {
+		if inv.Readings.Current != 0 {
+			continue
+		}
+		l, ok := leases[inv.Lease]
+		if !ok {
+			if err := app.One("ID", inv.Lease, &l); err != nil {
+				return fmt.Errorf("finding lease %d: %w", inv.Lease, err)
+			}
+			leases[inv.Lease] = l
+		}
+		k := key{Site: l.Site, Service: inv.Service}
+		m, ok := meters[k]
+		if !ok {
+			var err error
+			if m, err = app.ActiveMeter(l.Site, inv.Service); isNotFound(err) {
+				m = Meter{Site: l.Site, Service: inv.Service, Installed: l.Term.Start}
+				if err := app.Save(&m); err != nil {
+					return fmt.Errorf("saving meter for invoice %d: %w", inv.ID, err)
+				}
+				if err := app.Save(&Reading{
+					Meter: m.ID,
+					Date:  m.Installed,
+					Value: inv.Reading - inv.UnitsConsumed,
+					Note:  "Opening reading",
+				}); err != nil {
+					return fmt.Errorf("saving opening reading: %w", err)
+				}
+			} else if err != nil {
+				return err
+			}
+			readings, err := app.Readings(m.ID)
+			if err != nil {
+				return fmt.Errorf("loading readings: %w", err)
+			}
+			if len(readings) > 0 {
+				last[k] = *readings[len(readings)-1]
+			}
+			meters[k] = m
+		}
+		// Historic meters and readings are saved directly since they may not
+		// pass validation.
+		r := Reading{
+			Meter: m.ID,
+			Date:  inv.Issued,
+			Value: inv.Reading,
+			Note:  fmt.Sprintf("Invoice %d", inv.ID),
+		}
+		if err := app.Save(&r); err != nil {
+			return fmt.Errorf("saving reading for invoice %d: %w", inv.ID, err)
+		}
+		previous := last[k]
+		inv.Readings.Previous = previous.ID
+		inv.Readings.Current = r.ID
+		inv.PreviousReading = previous.Value
+		if err := app.Save(inv); err != nil {
+			return fmt.Errorf("migrating invoice %d: %w", inv.ID, err)
+		}
+		last[k] = r
+	}
+	return nil
+}
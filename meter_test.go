@@ -0,0 +1,86 @@
+package avisha
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMeterConsumption checks the units consumed between values read off a
+// register, rolling over where the register has a known number of digits.
+func TestMeterConsumption(t *testing.T) {
+	tests := []struct {
+		name     string
+		digits   int
+		previous int
+		current  int
+		want     int
+		err      bool
+	}{
+		{"increase", 0, 100, 150, 50, false},
+		{"unchanged", 4, 100, 100, 0, false},
+		{"decrease without digits", 0, 150, 100, 0, true},
+		{"rolled over", 4, 9990, 10, 20, false},
+		{"beyond the register", 4, 9990, 10000, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Meter{Digits: tt.digits}.Consumption(tt.previous, tt.current)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMeterReplacement checks that the units consumed on a replaced meter,
+// rolling over as it went, are carried into the next invoice.
+func TestMeterReplacement(t *testing.T) {
+	app := open(t)
+	site := Site{Number: "1"}
+	if err := app.Save(&site); err != nil {
+		t.Fatal(err)
+	}
+	l := Lease{
+		Site:     site.ID,
+		Term:     Term{Start: date(2023, time.January, 1), Duration: 365 * day},
+		Services: map[ServiceKey]Service{ServiceElectricity: {}},
+	}
+	if err := app.Save(&l); err != nil {
+		t.Fatal(err)
+	}
+	old := Meter{Site: site.ID, Service: ServiceElectricity, Digits: 4, Installed: date(2023, time.January, 1)}
+	if err := app.InstallMeter(&old, Reading{Value: 9900}); err != nil {
+		t.Fatalf("installing: %v", err)
+	}
+	opening, err := app.PreviousReading(l.ID, ServiceElectricity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.RecordReading(&Reading{Meter: old.ID, Date: date(2023, time.February, 1), Value: 50}); err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	replacement := Meter{Digits: 5}
+	if err := app.ReplaceMeter(old.ID, Reading{Date: date(2023, time.March, 1), Value: 120}, &replacement, Reading{}); err != nil {
+		t.Fatalf("replacing: %v", err)
+	}
+	current := Reading{Meter: replacement.ID, Date: date(2023, time.April, 1), Value: 30}
+	if err := app.RecordReading(&current); err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	meter, baseline, carried, err := app.MeterBaseline(l.ID, ServiceElectricity)
+	if err != nil {
+		t.Fatalf("finding baseline: %v", err)
+	}
+	if meter.ID != replacement.ID || baseline.Meter != replacement.ID || baseline.Value != 0 {
+		t.Errorf("got baseline %d on meter %d, want the opening reading of meter %d", baseline.Value, meter.ID, replacement.ID)
+	}
+	if carried != 220 {
+		t.Errorf("got %d units carried, want 220", carried)
+	}
+	if units, err := app.Consumption(opening, current); err != nil || units != 250 {
+		t.Errorf("got %d units consumed (%v), want 250", units, err)
+	}
+}
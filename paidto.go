@@ -0,0 +1,150 @@
+package avisha
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// RentPosition describes how far the rent of a lease is paid, the way
+// property managers talk about it: paid to a date rather than a balance.
+type RentPosition struct {
+	// PaidTo is the first day the rent payments don't fully cover.
+	PaidTo time.Time
+	// Credit is paid toward the rent from PaidTo, short of a whole day.
+	Credit currency.Currency
+	// Days the rent is paid in advance of the date the position was taken,
+	// negative when in arrears.
+	Days int
+}
+
+// InArrears reports whether the rent is paid to a date before the position
+// was taken.
+func (p RentPosition) InArrears() bool {
+	return p.Days < 0
+}
+
+func (p RentPosition) String() string {
+	var (
+		paid = fmt.Sprintf("Paid to %s", p.PaidTo.Format("02/01/2006"))
+		days = func(n int) string {
+			if n == 1 {
+				return "1 day"
+			}
+			return fmt.Sprintf("%d days", n)
+		}
+	)
+	switch {
+	case p.Days < 0:
+		return fmt.Sprintf("%s, %s in arrears", paid, days(-p.Days))
+	case p.Days > 0:
+		return fmt.Sprintf("%s, %s in advance", paid, days(p.Days))
+	default:
+		return fmt.Sprintf("%s, up to date", paid)
+	}
+}
+
+// PaidTo applies an amount paid toward rent to each day of the lease in turn,
+// from when the lease first started, and returns the first day the amount
+// doesn't fully cover along with what is left over toward it.
+// The rent of each day includes GST at the percentage gst returns for it.
+func (l Lease) PaidTo(paid currency.Currency, method ProrationMethod, gst func(day time.Time) float64) (time.Time, currency.Currency) {
+	var (
+		cursor = l.Commenced()
+		// Guard against leases without rent, which are paid forever.
+		limit = cursor.AddDate(100, 0, 0)
+	)
+	for cursor.Before(limit) {
+		lines := l.RentLines(Term{Start: cursor, Duration: cursor.AddDate(0, 1, 0).Sub(cursor)}, method)
+		if len(lines) == 0 {
+			// The lease has ended and is paid in full.
+			return cursor, paid
+		}
+		for _, line := range lines {
+			rate := gst(line.Period.Start)
+			if cost := withGST(line.Amount, rate); cost <= paid {
+				paid -= cost
+				cursor = line.Period.End()
+				continue
+			}
+			// Cover as many whole days of the line as the amount allows.
+			var covered currency.Currency
+			for n := 1; n < line.Days; n++ {
+				end := line.Period.Start.AddDate(0, 0, n)
+				cost := withGST(rentLine(line.Rate, line.Period.Start, end, method).Amount, rate)
+				if cost > paid {
+					break
+				}
+				covered, cursor = cost, end
+			}
+			return cursor, paid - covered
+		}
+	}
+	return cursor, paid
+}
+
+// withGST returns the amount with GST added at the rate, a percentage.
+func withGST(amount currency.Currency, gst float64) currency.Currency {
+	return amount + gstOn(amount, gst)
+}
+
+// gstOn returns the GST on the amount at the rate, a percentage, rounded to
+// the mill.
+func gstOn(amount currency.Currency, gst float64) currency.Currency {
+	return amount.Scale(int64(math.Round(gst*100)), 100*100)
+}
+
+// RentPosition works out how far the rent of a lease is paid as of the given
+// date.
+// Payments to the rent service first settle the other charges of the rent
+// invoices paid, such as recurring charges, and the remainder pays the rent of
+// each day in turn. The rent of days invoiced includes GST as invoiced, and
+// of days yet to be invoiced as currently set.
+// For leases that have ended the position is taken as of the termination
+// date.
+func (app App) RentPosition(leaseID int, at time.Time) (p RentPosition, err error) {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return p, fmt.Errorf("finding lease: %w", err)
+	}
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return p, fmt.Errorf("loading settings: %w", err)
+	}
+	var invoices []*RentInvoice
+	if err := app.Select(q.Eq("Lease", leaseID)).Find(&invoices); err != nil && err != storm.ErrNotFound {
+		return p, fmt.Errorf("loading invoices: %w", err)
+	}
+	var paid currency.Currency
+	for _, credit := range l.Services[ServiceRent].Ledger.Credits {
+		paid += credit.Amount
+	}
+	for _, inv := range invoices {
+		if inv.IsPaid() {
+			paid -= inv.Bill - withGST(inv.Charges.Rent, inv.GST)
+		}
+	}
+	if paid < 0 {
+		paid = 0
+	}
+	def, _ := settings.Service(ServiceRent)
+	gst := func(day time.Time) float64 {
+		for _, inv := range invoices {
+			if !day.Before(inv.Period.Start) && day.Before(inv.Period.End()) {
+				return inv.GST
+			}
+		}
+		return def.Tax.Rate(settings.Defaults.GST)
+	}
+	p.PaidTo, p.Credit = l.PaidTo(paid, settings.Defaults.Proration, gst)
+	if ends := l.Ends(); !ends.IsZero() && ends.Before(at) {
+		at = ends
+	}
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	p.Days = int(math.Round(p.PaidTo.Sub(day).Hours() / 24))
+	return p, nil
+}
@@ -0,0 +1,87 @@
+package avisha
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+func TestRentPosition(t *testing.T) {
+	var (
+		start = date(2023, time.January, 1)
+		// fortnight of rent invoiced, at 10000 a day.
+		fortnight = Term{Start: start, Duration: 14 * 24 * time.Hour}
+	)
+	invoice := func(gst float64, recurring currency.Currency, paid bool) RentInvoice {
+		inv := RentInvoice{GST: gst}
+		inv.Period = fortnight
+		inv.Charges.Rent = 140000
+		inv.Charges.Recurring = recurring
+		inv.Bill = withGST(inv.Charges.Rent, gst) + recurring
+		if paid {
+			inv.Paid = start
+		}
+		return inv
+	}
+	tests := []struct {
+		name     string
+		invoices []RentInvoice
+		paid     currency.Currency
+		want     time.Time
+		credit   currency.Currency
+	}{
+		{"nothing paid", nil, 0, start, 0},
+		{"a week paid", nil, 70000, date(2023, time.January, 8), 0},
+		{"part of a day paid", nil, 75000, date(2023, time.January, 8), 5000},
+		{
+			"a week paid with GST as invoiced",
+			[]RentInvoice{invoice(10, 0, false)},
+			77000,
+			date(2023, time.January, 8),
+			0,
+		},
+		{
+			"other charges not yet paid",
+			[]RentInvoice{invoice(0, 5000, false)},
+			70000,
+			date(2023, time.January, 8),
+			0,
+		},
+		{
+			"other charges paid",
+			[]RentInvoice{invoice(0, 5000, true)},
+			145000,
+			date(2023, time.January, 15),
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := open(t)
+			l := Lease{
+				Term: Term{Start: start, Duration: 365 * 24 * time.Hour},
+				Rent: 70000,
+				Services: map[ServiceKey]Service{
+					ServiceRent: {Ledger: Ledger{Credits: []Payment{{Amount: tt.paid, Time: start}}}},
+				},
+			}
+			if err := app.Save(&l); err != nil {
+				t.Fatal(err)
+			}
+			for _, inv := range tt.invoices {
+				inv.Lease = int(l.ID)
+				if err := app.Save(&inv); err != nil {
+					t.Fatal(err)
+				}
+			}
+			p, err := app.RentPosition(int(l.ID), date(2023, time.January, 8))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.PaidTo.Equal(tt.want) || p.Credit != tt.credit {
+				t.Errorf("got paid to %s with %s credit, want %s with %s", p.PaidTo.Format("02/01/2006"), p.Credit, tt.want.Format("02/01/2006"), tt.credit)
+			}
+		})
+	}
+}
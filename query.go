@@ -0,0 +1,234 @@
+package avisha
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TenantQuery selects tenants.
+type TenantQuery struct {
+	// Search matches the name, contact and address of the tenant.
+	Search string
+	// Archived includes archived tenants, which are hidden otherwise.
+	Archived bool
+}
+
+// Match reports whether the tenant is selected by the query.
+func (q TenantQuery) Match(t Tenant) bool {
+	return (q.Archived || !t.IsArchived()) && search(q.Search, tenantFields(t)...)
+}
+
+// tenantFields are the fields of a tenant searched on.
+func tenantFields(t Tenant) []string {
+	fields := []string{t.Name, t.Contact}
+	if t.Address != (Address{}) {
+		fields = append(fields, t.Address.String())
+	}
+	return fields
+}
+
+// SiteQuery selects sites.
+type SiteQuery struct {
+	// Search matches the site number.
+	Search string
+	// Dwellings selects sites of these dwelling types, any if empty.
+	Dwellings []Dwelling
+	// Archived includes archived sites, which are hidden otherwise.
+	Archived bool
+}
+
+// Match reports whether the site is selected by the query.
+func (q SiteQuery) Match(s Site) bool {
+	return (q.Archived || !s.IsArchived()) && search(q.Search, s.Number) && q.dwelling(s.Dwelling)
+}
+
+func (q SiteQuery) dwelling(d Dwelling) bool {
+	if len(q.Dwellings) == 0 {
+		return true
+	}
+	for _, want := range q.Dwellings {
+		if want == d {
+			return true
+		}
+	}
+	return false
+}
+
+// LeaseQuery selects leases by their site and tenant as well as their
+// standing.
+type LeaseQuery struct {
+	// Search matches the number of the site, and the name, contact and
+	// address of the tenant.
+	Search string
+	// Statuses selects leases in these statuses at the time of the query, any
+	// if empty.
+	Statuses []LeaseStatus
+	// Dwellings selects leases of sites of these dwelling types, any if
+	// empty.
+	Dwellings []Dwelling
+	// InArrears selects only leases owing on an invoice past due.
+	InArrears bool
+	// Archived includes archived leases, which are hidden otherwise.
+	Archived bool
+	// At is the time the query is made as of, now if zero.
+	At time.Time
+}
+
+// CurrentStatuses are the statuses of leases in force.
+var CurrentStatuses = []LeaseStatus{Active, Periodic, NoticeGiven}
+
+// LeaseMatch is a lease selected by a query, joined with its site and tenant.
+type LeaseMatch struct {
+	Lease  Lease
+	Site   Site
+	Tenant Tenant
+}
+
+// QueryTenants returns the tenants selected by the query, ordered by name.
+func (app App) QueryTenants(q TenantQuery) (tenants []Tenant, err error) {
+	var all []*Tenant
+	if err := app.All(&all); err != nil {
+		return nil, fmt.Errorf("loading tenants: %w", err)
+	}
+	for _, t := range all {
+		if q.Match(*t) {
+			tenants = append(tenants, *t)
+		}
+	}
+	sort.SliceStable(tenants, func(ii, jj int) bool {
+		return strings.ToLower(tenants[ii].Name) < strings.ToLower(tenants[jj].Name)
+	})
+	return tenants, nil
+}
+
+// QuerySites returns the sites selected by the query, ordered by number.
+func (app App) QuerySites(q SiteQuery) (sites []Site, err error) {
+	var all []*Site
+	if err := app.All(&all); err != nil {
+		return nil, fmt.Errorf("loading sites: %w", err)
+	}
+	for _, s := range all {
+		if q.Match(*s) {
+			sites = append(sites, *s)
+		}
+	}
+	sort.SliceStable(sites, func(ii, jj int) bool {
+		return sites[ii].Number < sites[jj].Number
+	})
+	return sites, nil
+}
+
+// QueryLeases returns the leases selected by the query, ordered by site
+// number.
+func (app App) QueryLeases(q LeaseQuery) (matches []LeaseMatch, err error) {
+	if q.At.IsZero() {
+		q.At = time.Now()
+	}
+	var (
+		leases  []*Lease
+		sites   []*Site
+		tenants []*Tenant
+	)
+	if err := app.All(&leases); err != nil {
+		return nil, fmt.Errorf("loading leases: %w", err)
+	}
+	if err := app.All(&sites); err != nil {
+		return nil, fmt.Errorf("loading sites: %w", err)
+	}
+	if err := app.All(&tenants); err != nil {
+		return nil, fmt.Errorf("loading tenants: %w", err)
+	}
+	var (
+		bySite   = make(map[int]Site, len(sites))
+		byTenant = make(map[int]Tenant, len(tenants))
+		arrears  map[int]bool
+	)
+	for _, s := range sites {
+		bySite[s.ID] = *s
+	}
+	for _, t := range tenants {
+		byTenant[t.ID] = *t
+	}
+	if q.InArrears {
+		if arrears, err = app.overdue(q.At); err != nil {
+			return nil, fmt.Errorf("finding arrears: %w", err)
+		}
+	}
+	for _, l := range leases {
+		m := LeaseMatch{Lease: *l, Site: bySite[l.Site], Tenant: byTenant[l.Tenant]}
+		if !q.status(l.StatusAt(q.At)) || (l.IsArchived() && !q.Archived) {
+			continue
+		}
+		// The site of a lease is matched even if archived along with it.
+		if !(SiteQuery{Dwellings: q.Dwellings, Archived: true}).Match(m.Site) {
+			continue
+		}
+		if q.InArrears && !arrears[l.ID] {
+			continue
+		}
+		if !search(q.Search, append([]string{m.Site.Number}, tenantFields(m.Tenant)...)...) {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	sort.SliceStable(matches, func(ii, jj int) bool {
+		return matches[ii].Site.Number < matches[jj].Site.Number
+	})
+	return matches, nil
+}
+
+func (q LeaseQuery) status(s LeaseStatus) bool {
+	if len(q.Statuses) == 0 {
+		return true
+	}
+	for _, want := range q.Statuses {
+		if want == s {
+			return true
+		}
+	}
+	return false
+}
+
+// overdue returns the leases with an unpaid invoice past due at the given
+// time.
+func (app App) overdue(at time.Time) (map[int]bool, error) {
+	var (
+		leases    = make(map[int]bool)
+		rent      []*RentInvoice
+		utilities []*UtilityInvoice
+	)
+	if err := app.All(&rent); err != nil {
+		return nil, fmt.Errorf("loading rent invoices: %w", err)
+	}
+	if err := app.All(&utilities); err != nil {
+		return nil, fmt.Errorf("loading utility invoices: %w", err)
+	}
+	for _, inv := range rent {
+		if !inv.IsPaid() && inv.Due.Before(at) {
+			leases[inv.Lease] = true
+		}
+	}
+	for _, inv := range utilities {
+		if !inv.IsPaid() && inv.Due.Before(at) {
+			leases[inv.Lease] = true
+		}
+	}
+	return leases, nil
+}
+
+// search reports whether every word of the text appears in one of the fields,
+// ignoring case. Empty text matches anything.
+func search(text string, fields ...string) bool {
+	var (
+		words    = strings.Fields(strings.ToLower(text))
+		haystack = strings.ToLower(strings.Join(fields, " "))
+	)
+	for _, word := range words {
+		if !strings.Contains(haystack, word) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,393 @@
+package avisha
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// RentChange sets the weekly rent of a lease from the effective date onward.
+type RentChange struct {
+	// Amount is the weekly rent.
+	Amount currency.Currency
+	// Effective is when the amount comes into effect.
+	Effective time.Time
+	// Notified is when the tenant was given notice of the change.
+	Notified time.Time
+}
+
+// ProrationMethod is how rent is charged for part of a week or month.
+type ProrationMethod int
+
+const (
+	// DailyRate charges each day at a seventh of the weekly rent.
+	DailyRate ProrationMethod = iota
+	// CalendarMonth charges each day at the monthly equivalent of the weekly
+	// rent, being 52 weeks over 12 months, divided by the days in its calendar
+	// month.
+	// A whole month costs the same whatever its length.
+	CalendarMonth
+)
+
+func (m ProrationMethod) String() string {
+	switch m {
+	case DailyRate:
+		return "Daily Rate"
+	case CalendarMonth:
+		return "Calendar Month"
+	default:
+		return "Unknown"
+	}
+}
+
+// RentLine is the rent charged at one rate over part of an invoice period.
+type RentLine struct {
+	Period Term
+	// Rate is the weekly rent in effect.
+	Rate currency.Currency
+	// Days charged for.
+	Days int
+	// DailyRate is the rent charged per day, according to the proration
+	// method.
+	DailyRate currency.Currency
+	// Prorated reports whether the line covers part of a week, or part of a
+	// month when prorated by calendar month.
+	Prorated bool
+	// Amount is the rent charged for the period.
+	Amount currency.Currency
+}
+
+// RentInvoice is a document requesting payment for rent over a period.
+type RentInvoice struct {
+	Invoice `storm:"inline"`
+	// Lines break down the rent charged for each rate in effect over the
+	// period.
+	Lines []RentLine
+	// Proration records the proration method used at the time the invoice
+	// was generated.
+	Proration ProrationMethod
+	// Recurring itemises the recurring charges of the rent service that fell
+	// due over the period.
+	Recurring []LineItem
+	// GST records the GST used for rent at the time the invoice was
+	// generated.
+	GST float64
+	// Charges contains all the constituent parts of the total bill.
+	Charges struct {
+		// Rent is the total of the rent lines.
+		Rent currency.Currency
+		// Recurring is the total of the recurring charges.
+		Recurring currency.Currency
+		// GST calculated based on the percentage of each service.
+		GST currency.Currency
+	}
+}
+
+// RentAt returns the weekly rent in effect at the given time.
+func (l Lease) RentAt(t time.Time) currency.Currency {
+	rent := l.Rent
+	for _, c := range l.Rents {
+		if c.Effective.After(t) {
+			break
+		}
+		rent = c.Amount
+	}
+	return rent
+}
+
+// Commenced returns when the lease first started, before any renewals.
+func (l Lease) Commenced() time.Time {
+	if len(l.History) > 0 {
+		return l.History[0].Start
+	}
+	return l.Term.Start
+}
+
+// Billable returns the part of the period the lease is in force: from when
+// the lease first started, before any renewals, until it was terminated or its
+// notice took effect.
+// The duration is zero if the lease is not in force at any point over the
+// period.
+func (l Lease) Billable(period Term) Term {
+	var (
+		start = period.Start
+		end   = period.End()
+	)
+	if first := l.Commenced(); start.Before(first) {
+		start = first
+	}
+	if ends := l.Ends(); !ends.IsZero() && ends.Before(end) {
+		end = ends
+	}
+	if !end.After(start) {
+		return Term{Start: start}
+	}
+	return Term{Start: start, Duration: end.Sub(start)}
+}
+
+// RentLines breaks the billable part of the period into lines, one for each
+// rent amount that was in effect over the period.
+// When prorating by calendar month the lines are also broken at the start of
+// each month, since the daily rate depends on the length of the month.
+func (l Lease) RentLines(period Term, method ProrationMethod) []RentLine {
+	var (
+		lines    []RentLine
+		billable = l.Billable(period)
+		start    = billable.Start
+		end      = billable.End()
+	)
+	for start.Before(end) {
+		next := end
+		for _, c := range l.Rents {
+			if c.Effective.After(start) && c.Effective.Before(next) {
+				next = c.Effective
+				break
+			}
+		}
+		if method == CalendarMonth {
+			if month := startOfMonth(start).AddDate(0, 1, 0); month.Before(next) {
+				next = month
+			}
+		}
+		lines = append(lines, rentLine(l.RentAt(start), start, next, method))
+		start = next
+	}
+	return lines
+}
+
+// RentFor returns the total rent owed over the period.
+func (l Lease) RentFor(period Term, method ProrationMethod) (total currency.Currency) {
+	for _, line := range l.RentLines(period, method) {
+		total += line.Amount
+	}
+	return total
+}
+
+func rentLine(rate currency.Currency, start, end time.Time, method ProrationMethod) RentLine {
+	var (
+		period = Term{Start: start, Duration: end.Sub(start)}
+		line   = RentLine{
+			Period: period,
+			Rate:   rate,
+			Days:   period.Days(),
+		}
+	)
+	switch method {
+	case CalendarMonth:
+		var (
+			monthly = rate.Scale(52, 12)
+			days    = int64(startOfMonth(start).AddDate(0, 1, -1).Day())
+		)
+		line.DailyRate = monthly.Scale(1, days)
+		line.Amount = monthly.Scale(int64(line.Days), days)
+		line.Prorated = int64(line.Days) < days
+	default:
+		line.DailyRate = rate.Scale(1, 7)
+		line.Amount = rate.Scale(int64(line.Days), 7)
+		line.Prorated = line.Days%7 != 0
+	}
+	return line
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// Days returns the number of whole days in the term.
+func (t Term) Days() int {
+	return int((t.Duration + time.Hour*12) / (time.Hour * 24))
+}
+
+// ScheduleRentIncrease schedules the weekly rent of a lease to increase to
+// amount on the effective date.
+// The increase must be notified at least the minimum notice period in advance.
+func (app App) ScheduleRentIncrease(
+	leaseID int,
+	amount currency.Currency,
+	effective time.Time,
+	notified time.Time,
+) (change RentChange, err error) {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return change, fmt.Errorf("finding lease: %w", err)
+	}
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return change, fmt.Errorf("loading settings: %w", err)
+	}
+	if current := l.RentAt(effective); amount <= current {
+		return change, fmt.Errorf("rent increase must be more than the current rent of %s", current)
+	}
+	if earliest := notified.Add(settings.Defaults.RentIncreaseNotice); effective.Before(earliest) {
+		return change, fmt.Errorf(
+			"rent increase requires %d days notice, must not take effect before %s",
+			int(settings.Defaults.RentIncreaseNotice.Hours()/24),
+			earliest.Format("02/01/2006"),
+		)
+	}
+	change = RentChange{
+		Amount:    amount,
+		Effective: effective,
+		Notified:  notified,
+	}
+	l.Rents = append(l.Rents, change)
+	sort.SliceStable(l.Rents, func(ii, jj int) bool {
+		return l.Rents[ii].Effective.Before(l.Rents[jj].Effective)
+	})
+	if err := app.Save(&l); err != nil {
+		return change, err
+	}
+	app.emit(RentIncreaseScheduled{Lease: l.ID, Change: change})
+	return change, nil
+}
+
+// InvoiceRent issues a rent invoice for the period and bills the rent service
+// for it.
+// The invoice charges whichever rent was in effect on each day of the period
+// the lease was in force, along with the recurring charges of the rent
+// service.
+// Services that are neither rented nor metered are invoiced for their
+// recurring charges at the same time, each on its own invoice and ledger.
+func (app App) InvoiceRent(leaseID int, period Term, issued time.Time) (inv RentInvoice, err error) {
+	err = app.Transaction(func(tx App) error {
+		var l Lease
+		if err := tx.One("ID", leaseID, &l); err != nil {
+			return fmt.Errorf("finding lease: %w", err)
+		}
+		settings, err := tx.LoadSettings()
+		if err != nil {
+			return fmt.Errorf("loading settings: %w", err)
+		}
+		if period.Duration <= 0 {
+			return fmt.Errorf("rent period must have a positive duration")
+		}
+		// Rent is only charged while the lease is in force, prorating the
+		// first and last periods.
+		if period = l.Billable(period); period.Duration <= 0 {
+			return fmt.Errorf("lease is not in force over the rent period")
+		}
+		inv = RentInvoice{
+			Invoice: Invoice{
+				Lease:  leaseID,
+				Issued: issued,
+				Due:    issued.Add(settings.Defaults.InvoiceNet),
+				Period: period,
+			},
+			Lines:     l.RentLines(period, settings.Defaults.Proration),
+			Proration: settings.Defaults.Proration,
+			Recurring: l.Services[ServiceRent].ChargeLines(period),
+		}
+		for _, line := range inv.Lines {
+			inv.Charges.Rent += line.Amount
+		}
+		for _, line := range inv.Recurring {
+			inv.Charges.Recurring += line.Amount
+		}
+		def, _ := settings.Service(ServiceRent)
+		inv.GST = def.Tax.Rate(settings.Defaults.GST)
+		inv.Charges.GST = gstOn(inv.Charges.Rent+inv.Charges.Recurring, inv.GST)
+		inv.Bill = inv.Charges.Rent + inv.Charges.Recurring + inv.Charges.GST
+		inv.Balance.Debit(Payment{
+			Amount: inv.Bill,
+			Time:   issued,
+		})
+		inv.Final = l.Services[ServiceRent].FinalOver(period)
+		var charges []UtilityInvoice
+		for _, other := range settings.Subscribed(l) {
+			if other.Kind != Fixed {
+				continue
+			}
+			if c := chargeInvoice(l, other, inv.Invoice, settings.Defaults.GST); len(c.Recurring) > 0 {
+				charges = append(charges, c)
+			}
+		}
+		// Final invoices clear the flag of their service, which is saved
+		// before billing updates the ledgers of the lease.
+		finals := map[ServiceKey]bool{ServiceRent: inv.Final}
+		for _, c := range charges {
+			finals[c.Service] = c.Final
+		}
+		var final bool
+		for key, ok := range finals {
+			if ok {
+				s := l.Services[key]
+				s.Final = false
+				l.Services[key] = s
+				final = true
+			}
+		}
+		if final {
+			if err := tx.Save(&l); err != nil {
+				return fmt.Errorf("updating lease: %w", err)
+			}
+		}
+		if err := tx.Save(&inv); err != nil {
+			return fmt.Errorf("saving invoice: %w", err)
+		}
+		tx.emit(InvoiceIssued{Lease: leaseID, Service: ServiceRent, Invoice: inv.ID, Bill: inv.Bill, Due: inv.Due})
+		if err := tx.BillService(leaseID, ServiceRent, inv.Bill, inv.Issued); err != nil {
+			return err
+		}
+		for ii := range charges {
+			c := &charges[ii]
+			if err := tx.Save(c); err != nil {
+				return fmt.Errorf("saving %s invoice: %w", c.Service, err)
+			}
+			tx.emit(InvoiceIssued{Lease: leaseID, Service: c.Service, Invoice: c.ID, Bill: c.Bill, Due: c.Due})
+			if err := tx.BillService(leaseID, c.Service, c.Bill, c.Issued); err != nil {
+				return fmt.Errorf("billing %s: %w", c.Service, err)
+			}
+		}
+		return nil
+	})
+	return inv, err
+}
+
+// chargeInvoice calculates the invoice of a fixed service for the recurring
+// charges that fell due over the period of the rent invoice.
+// The invoice has no recurring lines when none fell due.
+func chargeInvoice(l Lease, def ServiceDefinition, rent Invoice, gst float64) UtilityInvoice {
+	s := l.Services[def.Key]
+	inv := UtilityInvoice{
+		Invoice: Invoice{
+			Lease:  l.ID,
+			Issued: rent.Issued,
+			Due:    rent.Due,
+			Period: rent.Period,
+			Final:  s.FinalOver(rent.Period),
+		},
+		Service:   def.Key,
+		Recurring: s.ChargeLines(rent.Period),
+		GST:       def.Tax.Rate(gst),
+	}
+	inv.Calculate()
+	inv.Balance.Debit(Payment{
+		Amount: inv.Bill,
+		Time:   inv.Issued,
+	})
+	return inv
+}
+
+// NextRentPeriod returns the next unbilled rent period for a lease, which
+// starts when the last rent invoice ended, or at the start of the lease.
+func (app App) NextRentPeriod(leaseID int, cycle time.Duration) (Term, error) {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return Term{}, fmt.Errorf("finding lease: %w", err)
+	}
+	var invoices []*RentInvoice
+	if err := app.Select(q.Eq("Lease", leaseID)).Find(&invoices); err != nil && err != storm.ErrNotFound {
+		return Term{}, fmt.Errorf("loading invoices: %w", err)
+	}
+	start := l.Term.Start
+	for _, inv := range invoices {
+		if end := inv.Period.End(); end.After(start) {
+			start = end
+		}
+	}
+	return Term{Start: start, Duration: cycle}, nil
+}
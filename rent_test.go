@@ -0,0 +1,269 @@
+package avisha
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// TestInvoiceRentCharges checks that the rent invoice only bills the rent
+// service, while the charges of fixed services are invoiced and billed to
+// their own ledgers.
+func TestInvoiceRentCharges(t *testing.T) {
+	app := open(t)
+	var defaults Defaults
+	defaults.Default()
+	defaults.GST = 10
+	if err := app.SaveSettings(Settings{Defaults: defaults}); err != nil {
+		t.Fatal(err)
+	}
+	start := date(2023, time.January, 1)
+	charge := func(amount int) []RecurringCharge {
+		return []RecurringCharge{{ID: 1, Amount: currency.Currency(amount), Frequency: EachInvoice, Start: start}}
+	}
+	l := Lease{
+		Term: Term{Start: start, Duration: 365 * 24 * time.Hour},
+		Rent: 700,
+		Services: map[ServiceKey]Service{
+			ServiceRent:        {Charges: charge(10)},
+			ServiceElectricity: {Charges: charge(20)},
+			ServiceInternet:    {Charges: charge(50)},
+			ServiceStorage:     {},
+		},
+	}
+	if err := app.Save(&l); err != nil {
+		t.Fatal(err)
+	}
+	rent, err := app.InvoiceRent(int(l.ID), Term{Start: start, Duration: 14 * 24 * time.Hour}, start)
+	if err != nil {
+		t.Fatalf("invoicing rent: %v", err)
+	}
+	if rent.ID == 0 {
+		t.Fatalf("got no rent invoice")
+	}
+	if want := rent.Charges.Rent + 10; rent.Bill != want {
+		t.Errorf("got rent bill %v, want %v", rent.Bill, want)
+	}
+	if err := app.One("ID", l.ID, &l); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key ServiceKey
+		// bill is what the service should be billed, zero if nothing.
+		bill currency.Currency
+	}{
+		{ServiceRent, rent.Bill},
+		{ServiceElectricity, 0},
+		{ServiceInternet, 55},
+		{ServiceStorage, 0},
+	}
+	for _, tt := range tests {
+		if got := -l.Services[tt.key].Balance(); got != tt.bill {
+			t.Errorf("%s: got ledger billed %v, want %v", tt.key, got, tt.bill)
+		}
+		if tt.key == ServiceRent {
+			continue
+		}
+		invoices, err := app.UtilityInvoices(int(l.ID), tt.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got currency.Currency
+		for _, inv := range invoices {
+			got += inv.Bill
+		}
+		if got != tt.bill {
+			t.Errorf("%s: got invoiced %v, want %v", tt.key, got, tt.bill)
+		}
+	}
+}
+
+// TestRentLines checks the rent charged over a period, prorated by each
+// method and broken at every rent change, within the term of the lease.
+func TestRentLines(t *testing.T) {
+	var (
+		start = date(2023, time.January, 1)
+		term  = Term{Start: start, Duration: 365 * day}
+	)
+	tests := []struct {
+		name   string
+		lease  Lease
+		period Term
+		method ProrationMethod
+		// want is the days, amount in units and whether prorated of each
+		// line.
+		want []string
+	}{
+		{
+			"whole weeks",
+			Lease{Term: term, Rent: 700},
+			Term{Start: start, Duration: 14 * day},
+			DailyRate,
+			[]string{"14 days 1400"},
+		},
+		{
+			"part week",
+			Lease{Term: term, Rent: 700},
+			Term{Start: start, Duration: 10 * day},
+			DailyRate,
+			[]string{"10 days 1000 prorated"},
+		},
+		{
+			"commenced within the period",
+			Lease{Term: Term{Start: date(2023, time.January, 5), Duration: 365 * day}, Rent: 700},
+			Term{Start: start, Duration: 14 * day},
+			DailyRate,
+			[]string{"10 days 1000 prorated"},
+		},
+		{
+			"terminated within the period",
+			Lease{Term: term, Rent: 700, Terminated: date(2023, time.January, 8)},
+			Term{Start: start, Duration: 14 * day},
+			DailyRate,
+			[]string{"7 days 700"},
+		},
+		{
+			"notice effective within the period",
+			Lease{Term: term, Rent: 700, Status: NoticeGiven, Notice: Notice{Given: start, Period: 10 * day}},
+			Term{Start: start, Duration: 14 * day},
+			DailyRate,
+			[]string{"10 days 1000 prorated"},
+		},
+		{
+			"rent increased within the period",
+			Lease{Term: term, Rent: 700, Rents: []RentChange{{Amount: 1400, Effective: date(2023, time.January, 8)}}},
+			Term{Start: start, Duration: 14 * day},
+			DailyRate,
+			[]string{"7 days 700", "7 days 1400"},
+		},
+		{
+			"whole calendar month",
+			Lease{Term: term, Rent: 700},
+			month(2023, time.February),
+			CalendarMonth,
+			[]string{"28 days 3033"},
+		},
+		{
+			"across calendar months",
+			Lease{Term: term, Rent: 700},
+			Term{Start: date(2023, time.January, 15), Duration: 31 * day},
+			CalendarMonth,
+			[]string{"17 days 1663 prorated", "14 days 1517 prorated"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, line := range tt.lease.RentLines(tt.period, tt.method) {
+				s := fmt.Sprintf("%d days %d", line.Days, line.Amount)
+				if line.Prorated {
+					s += " prorated"
+				}
+				got = append(got, s)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestInvoiceRentNotice checks that rent is invoiced up until notice takes
+// effect, with the invoice reaching it being the final one, and not after.
+func TestInvoiceRentNotice(t *testing.T) {
+	app := open(t)
+	start := date(2023, time.January, 1)
+	l := Lease{
+		Term:     Term{Start: start, Duration: 365 * day},
+		Rent:     700,
+		Services: map[ServiceKey]Service{ServiceRent: {}},
+	}
+	if err := app.Save(&l); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.GiveNotice(l.ID, start, 21*day); err != nil {
+		t.Fatalf("giving notice: %v", err)
+	}
+	tests := []struct {
+		start time.Time
+		// days invoiced, none if the invoice is refused.
+		days  int
+		final bool
+	}{
+		{date(2023, time.January, 1), 14, false},
+		{date(2023, time.January, 15), 7, true},
+		{date(2023, time.January, 29), 0, false},
+	}
+	for _, tt := range tests {
+		inv, err := app.InvoiceRent(l.ID, Term{Start: tt.start, Duration: 14 * day}, tt.start)
+		if tt.days == 0 {
+			if err == nil {
+				t.Errorf("%s: got invoice for %d days after the notice took effect", tt.start.Format("2 Jan"), inv.Period.Days())
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: invoicing: %v", tt.start.Format("2 Jan"), err)
+		}
+		if inv.Period.Days() != tt.days || inv.Final != tt.final {
+			t.Errorf("%s: got %d days final %v, want %d days final %v", tt.start.Format("2 Jan"), inv.Period.Days(), inv.Final, tt.days, tt.final)
+		}
+	}
+	if err := app.One("ID", l.ID, &l); err != nil {
+		t.Fatal(err)
+	}
+	s := l.Services[ServiceRent]
+	if !s.Closed.Equal(l.Notice.Effective()) || s.Final {
+		t.Errorf("got service closed %v final %v, want closed %v with no final invoice owed", s.Closed, s.Final, l.Notice.Effective())
+	}
+	if want := currency.Currency(2100); -s.Balance() != want {
+		t.Errorf("got %d billed, want %d", -s.Balance(), want)
+	}
+}
+
+// TestScheduleRentIncrease checks that rent increases must raise the rent
+// with enough notice, and apply from their effective date.
+func TestScheduleRentIncrease(t *testing.T) {
+	app := open(t)
+	start := date(2023, time.January, 1)
+	l := Lease{Term: Term{Start: start, Duration: 365 * day}, Rent: 700}
+	if err := app.Save(&l); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		amount    int
+		effective time.Time
+		notified  time.Time
+		err       bool
+	}{
+		{"not an increase", 700, date(2023, time.June, 1), start, true},
+		{"short notice", 800, date(2023, time.February, 1), start, true},
+		{"later increase", 900, date(2023, time.September, 1), start, false},
+		{"earlier increase", 800, date(2023, time.June, 1), start, false},
+	}
+	for _, tt := range tests {
+		_, err := app.ScheduleRentIncrease(l.ID, currency.Currency(tt.amount), tt.effective, tt.notified)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.err)
+		}
+	}
+	if err := app.One("ID", l.ID, &l); err != nil {
+		t.Fatal(err)
+	}
+	schedule := []struct {
+		at   time.Time
+		want int
+	}{
+		{date(2023, time.May, 31), 700},
+		{date(2023, time.June, 1), 800},
+		{date(2023, time.September, 1), 900},
+	}
+	for _, tt := range schedule {
+		if got := l.RentAt(tt.at); got != currency.Currency(tt.want) {
+			t.Errorf("rent at %s: got %v, want %v", tt.at.Format("2006-01-02"), got, currency.Currency(tt.want))
+		}
+	}
+}
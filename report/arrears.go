@@ -0,0 +1,298 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// Bucket is an age range of amounts owed, by days past due.
+type Bucket int
+
+const (
+	// Current amounts are not yet past due.
+	Current Bucket = iota
+	Days1To30
+	Days31To60
+	Days61To90
+	Over90
+)
+
+// Buckets lists every bucket, youngest first.
+var Buckets = []Bucket{Current, Days1To30, Days31To60, Days61To90, Over90}
+
+func (b Bucket) String() string {
+	switch b {
+	case Current:
+		return "Current"
+	case Days1To30:
+		return "1-30"
+	case Days31To60:
+		return "31-60"
+	case Days61To90:
+		return "61-90"
+	case Over90:
+		return "90+"
+	default:
+		return "Unknown"
+	}
+}
+
+// BucketOf returns the bucket for an amount past due by the given days.
+func BucketOf(overdue int) Bucket {
+	switch {
+	case overdue <= 0:
+		return Current
+	case overdue <= 30:
+		return Days1To30
+	case overdue <= 60:
+		return Days31To60
+	case overdue <= 90:
+		return Days61To90
+	default:
+		return Over90
+	}
+}
+
+// Aged is an amount owed split across the age buckets, indexed by bucket.
+type Aged [5]currency.Currency
+
+// Total returns the amount owed across all buckets.
+func (a Aged) Total() (total currency.Currency) {
+	for _, amount := range a {
+		total += amount
+	}
+	return total
+}
+
+// Strings formats the amount of each bucket followed by the total.
+func (a Aged) Strings() []string {
+	cells := make([]string, 0, len(a)+1)
+	for _, amount := range a {
+		cells = append(cells, amount.String())
+	}
+	return append(cells, a.Total().String())
+}
+
+// Add the amounts of another aged amount to this one.
+func (a *Aged) Add(other Aged) {
+	for ii := range a {
+		a[ii] += other[ii]
+	}
+}
+
+// Receivable is the amount still owed on an invoice.
+type Receivable struct {
+	Invoice avisha.Invoice
+	// Outstanding is the part of the bill not yet paid.
+	Outstanding currency.Currency
+	// Overdue is the days past the due date, zero if not yet due.
+	Overdue int
+	Bucket  Bucket
+}
+
+// ArrearsLine is what is owed on a service of a lease.
+type ArrearsLine struct {
+	Lease   avisha.Lease
+	Site    avisha.Site
+	Tenant  avisha.Tenant
+	Service avisha.ServiceDefinition
+	Aged    Aged
+	// Invoices owed on, oldest first.
+	Invoices []Receivable
+}
+
+// ArrearsReport ages the unpaid invoices of every lease as of a date.
+type ArrearsReport struct {
+	At     time.Time
+	Lines  []ArrearsLine
+	Totals Aged
+}
+
+// Arrears builds an aged receivables report as of the given date, with a line
+// for each service of a lease that is owed on.
+// Payments to a service pay its invoices oldest first, so the outstanding
+// amount of an invoice is whatever the payments don't cover once the older
+// invoices are paid.
+func Arrears(app avisha.App, at time.Time) (r ArrearsReport, err error) {
+	r.At = at
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return r, fmt.Errorf("loading settings: %w", err)
+	}
+	var (
+		leases    []*avisha.Lease
+		rent      []*avisha.RentInvoice
+		utilities []*avisha.UtilityInvoice
+	)
+	if err := app.All(&leases); err != nil {
+		return r, fmt.Errorf("loading leases: %w", err)
+	}
+	if err := app.All(&rent); err != nil {
+		return r, fmt.Errorf("loading rent invoices: %w", err)
+	}
+	if err := app.All(&utilities); err != nil {
+		return r, fmt.Errorf("loading utility invoices: %w", err)
+	}
+	type account struct {
+		lease int
+		key   avisha.ServiceKey
+	}
+	invoices := make(map[account][]avisha.Invoice)
+	for _, inv := range rent {
+		key := account{lease: inv.Lease, key: avisha.ServiceRent}
+		invoices[key] = append(invoices[key], inv.Invoice)
+	}
+	for _, inv := range utilities {
+		key := account{lease: inv.Lease, key: inv.Service}
+		invoices[key] = append(invoices[key], inv.Invoice)
+	}
+	for _, l := range leases {
+		for _, def := range settings.Subscribed(*l) {
+			line := ArrearsLine{
+				Lease:    *l,
+				Service:  def,
+				Invoices: receivables(invoices[account{lease: l.ID, key: def.Key}], l.Services[def.Key], at),
+			}
+			if len(line.Invoices) == 0 {
+				continue
+			}
+			for _, inv := range line.Invoices {
+				line.Aged[inv.Bucket] += inv.Outstanding
+			}
+			if err := app.One("ID", l.Site, &line.Site); err != nil {
+				return r, fmt.Errorf("lease %d: finding site: %w", l.ID, err)
+			}
+			if err := app.One("ID", l.Tenant, &line.Tenant); err != nil {
+				return r, fmt.Errorf("lease %d: finding tenant: %w", l.ID, err)
+			}
+			r.Totals.Add(line.Aged)
+			r.Lines = append(r.Lines, line)
+		}
+	}
+	sort.SliceStable(r.Lines, func(ii, jj int) bool {
+		return r.Lines[ii].Site.Number < r.Lines[jj].Site.Number
+	})
+	return r, nil
+}
+
+// Filter returns the report with only the invoices kept, dropping lines left
+// with none.
+func (r ArrearsReport) Filter(keep func(Receivable) bool) ArrearsReport {
+	filtered := ArrearsReport{At: r.At}
+	for _, line := range r.Lines {
+		var invoices []Receivable
+		for _, inv := range line.Invoices {
+			if keep(inv) {
+				invoices = append(invoices, inv)
+			}
+		}
+		if len(invoices) == 0 {
+			continue
+		}
+		line.Invoices, line.Aged = invoices, Aged{}
+		for _, inv := range invoices {
+			line.Aged[inv.Bucket] += inv.Outstanding
+		}
+		filtered.Totals.Add(line.Aged)
+		filtered.Lines = append(filtered.Lines, line)
+	}
+	return filtered
+}
+
+// IsOverdue reports whether the invoice is past due.
+func (r Receivable) IsOverdue() bool {
+	return r.Overdue > 0
+}
+
+// DueWithin reports whether the invoice falls due within the given days of
+// the date, without being overdue.
+func (r Receivable) DueWithin(at time.Time, days int) bool {
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	return r.Overdue == 0 && r.Invoice.Due.Before(day.AddDate(0, 0, days))
+}
+
+// receivables applies the payments made to the service by the given time to
+// its invoices, oldest first, and returns those left owing.
+func receivables(invoices []avisha.Invoice, s avisha.Service, at time.Time) (owed []Receivable) {
+	sort.Slice(invoices, func(ii, jj int) bool {
+		return invoices[ii].ID < invoices[jj].ID
+	})
+	var paid currency.Currency
+	for _, credit := range s.Ledger.Credits {
+		if credit.Time.After(at) {
+			continue
+		}
+		paid += credit.Amount
+	}
+	for _, inv := range invoices {
+		if inv.Issued.After(at) {
+			continue
+		}
+		if paid >= inv.Bill {
+			paid -= inv.Bill
+			continue
+		}
+		rec := Receivable{
+			Invoice:     inv,
+			Outstanding: inv.Bill - paid,
+		}
+		paid = 0
+		if overdue := int(math.Floor(at.Sub(inv.Due).Hours() / 24)); overdue > 0 {
+			rec.Overdue = overdue
+		}
+		rec.Bucket = BucketOf(rec.Overdue)
+		owed = append(owed, rec)
+	}
+	return owed
+}
+
+// Table lays out the report with a row for each line.
+func (r ArrearsReport) Table() Table {
+	t := Table{
+		Title:  fmt.Sprintf("Arrears as of %s", r.At.Format("02/01/2006")),
+		Header: []string{"Site", "Tenant", "Service"},
+	}
+	for _, b := range Buckets {
+		t.Header = append(t.Header, b.String())
+	}
+	t.Header = append(t.Header, "Total")
+	for _, line := range r.Lines {
+		t.Rows = append(t.Rows, append(
+			[]string{line.Site.Number, line.Tenant.Name, line.Service.Name},
+			line.Aged.Strings()...,
+		))
+	}
+	t.Footer = append([]string{"Total", "", ""}, r.Totals.Strings()...)
+	return t
+}
+
+// InvoiceTable lays out the invoices owed on, with a row for each invoice.
+func (r ArrearsReport) InvoiceTable() Table {
+	t := Table{
+		Title:  "Invoices",
+		Header: []string{"Site", "Tenant", "Service", "Invoice", "Issued", "Due", "Days Overdue", "Bill", "Outstanding"},
+	}
+	for _, line := range r.Lines {
+		for _, inv := range line.Invoices {
+			t.Rows = append(t.Rows, []string{
+				line.Site.Number,
+				line.Tenant.Name,
+				line.Service.Name,
+				strconv.Itoa(inv.Invoice.ID),
+				inv.Invoice.Issued.Format("02/01/2006"),
+				inv.Invoice.Due.Format("02/01/2006"),
+				strconv.Itoa(inv.Overdue),
+				inv.Invoice.Bill.String(),
+				inv.Outstanding.String(),
+			})
+		}
+	}
+	t.Footer = []string{"Total", "", "", "", "", "", "", "", r.Totals.Total().String()}
+	return t
+}
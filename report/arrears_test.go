@@ -0,0 +1,100 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go"
+)
+
+func TestBucketOf(t *testing.T) {
+	tests := []struct {
+		overdue int
+		want    Bucket
+	}{
+		{0, Current},
+		{1, Days1To30},
+		{30, Days1To30},
+		{31, Days31To60},
+		{60, Days31To60},
+		{61, Days61To90},
+		{90, Days61To90},
+		{91, Over90},
+	}
+	for _, tt := range tests {
+		if got := BucketOf(tt.overdue); got != tt.want {
+			t.Errorf("%d days overdue: got %s, want %s", tt.overdue, got, tt.want)
+		}
+	}
+}
+
+func TestReceivables(t *testing.T) {
+	invoices := []avisha.Invoice{
+		{ID: 1, Bill: 100, Issued: date(2023, time.January, 1), Due: date(2023, time.January, 15)},
+		{ID: 2, Bill: 100, Issued: date(2023, time.February, 1), Due: date(2023, time.February, 15)},
+		{ID: 3, Bill: 100, Issued: date(2023, time.April, 1), Due: date(2023, time.April, 15)},
+	}
+	tests := []struct {
+		name    string
+		credits []avisha.Payment
+		at      time.Time
+		// outstanding of each invoice owed, oldest first.
+		outstanding []int
+		buckets     []Bucket
+	}{
+		{
+			name:        "unpaid",
+			at:          date(2023, time.March, 1),
+			outstanding: []int{100, 100},
+			buckets:     []Bucket{Days31To60, Days1To30},
+		},
+		{
+			name:        "part paid pays the oldest",
+			credits:     []avisha.Payment{{Amount: 150, Time: date(2023, time.February, 20)}},
+			at:          date(2023, time.March, 1),
+			outstanding: []int{50},
+			buckets:     []Bucket{Days1To30},
+		},
+		{
+			name:        "paid after the date",
+			credits:     []avisha.Payment{{Amount: 200, Time: date(2023, time.March, 10)}},
+			at:          date(2023, time.March, 1),
+			outstanding: []int{100, 100},
+			buckets:     []Bucket{Days31To60, Days1To30},
+		},
+		{
+			name: "paid before and after the date",
+			credits: []avisha.Payment{
+				{Amount: 100, Time: date(2023, time.January, 10)},
+				{Amount: 100, Time: date(2023, time.March, 10)},
+			},
+			at:          date(2023, time.March, 1),
+			outstanding: []int{100},
+			buckets:     []Bucket{Days1To30},
+		},
+		{
+			name:        "not yet due",
+			credits:     []avisha.Payment{{Amount: 200, Time: date(2023, time.March, 10)}},
+			at:          date(2023, time.April, 10),
+			outstanding: []int{100},
+			buckets:     []Bucket{Current},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := avisha.Service{Ledger: avisha.Ledger{Credits: tt.credits}}
+			owed := receivables(append([]avisha.Invoice(nil), invoices...), s, tt.at)
+			if len(owed) != len(tt.outstanding) {
+				t.Fatalf("got %d owed, want %d: %+v", len(owed), len(tt.outstanding), owed)
+			}
+			for ii, rec := range owed {
+				if int(rec.Outstanding) != tt.outstanding[ii] {
+					t.Errorf("invoice %d: got %s outstanding, want %d", rec.Invoice.ID, rec.Outstanding, tt.outstanding[ii])
+				}
+				if rec.Bucket != tt.buckets[ii] {
+					t.Errorf("invoice %d: got bucket %s, want %s", rec.Invoice.ID, rec.Bucket, tt.buckets[ii])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package report
+
+import (
+	"fmt"
+
+	"github.com/jackmordaunt/avisha.go"
+)
+
+// AuditReport is the history of changes made to a record, as evidence of who
+// changed what and when.
+type AuditReport struct {
+	// Subject names the record, such as "Lease 4 (Site 12, Tony Stark)".
+	Subject string
+	Entries []avisha.AuditEntry
+}
+
+// Audit builds the audit report of a record.
+func Audit(app avisha.App, subject, entity string, id int) (r AuditReport, err error) {
+	r.Subject = subject
+	if r.Entries, err = app.AuditTrail(entity, id); err != nil {
+		return r, fmt.Errorf("loading audit trail: %w", err)
+	}
+	return r, nil
+}
+
+// Table lists every field changed, one row per field, oldest change first.
+func (r AuditReport) Table() Table {
+	t := Table{
+		Title:  fmt.Sprintf("Changes to %s", r.Subject),
+		Header: []string{"Time", "Actor", "Record", "Change", "Field", "Before", "After"},
+	}
+	for _, e := range r.Entries {
+		var (
+			time = e.Time.Format("02/01/2006 15:04:05")
+			op   = e.Operation.String()
+		)
+		if len(e.Changes) == 0 {
+			t.Rows = append(t.Rows, []string{time, e.Actor, e.Subject(), op, "", "", ""})
+		}
+		for _, c := range e.Changes {
+			t.Rows = append(t.Rows, []string{time, e.Actor, e.Subject(), op, c.Field, c.Before, c.After})
+		}
+	}
+	return t
+}
@@ -0,0 +1,314 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// Amount is income split into the part kept and the GST collected on it.
+type Amount struct {
+	// Net of GST.
+	Net currency.Currency
+	GST currency.Currency
+}
+
+// Total returns the amount including GST.
+func (a Amount) Total() currency.Currency {
+	return a.Net + a.GST
+}
+
+// Add another amount to this one.
+func (a *Amount) Add(other Amount) {
+	a.Net += other.Net
+	a.GST += other.GST
+}
+
+// IncomeLine is the income of a service at a site.
+type IncomeLine struct {
+	Site    avisha.Site
+	Service avisha.ServiceDefinition
+	// Accrual is the income invoiced over the period.
+	Accrual Amount
+	// Cash is the income received over the period.
+	Cash Amount
+}
+
+// IncomeReport totals the income of every site over a period, on both the
+// accrual and cash bases.
+type IncomeReport struct {
+	Period avisha.Term
+	Lines  []IncomeLine
+	Totals struct {
+		Accrual Amount
+		Cash    Amount
+	}
+}
+
+// Income builds an income report over the period.
+// On the accrual basis income is counted when it is invoiced, and on the cash
+// basis when it is paid.
+// Payments pay the invoices of a service oldest first, and the GST received is
+// the share of GST in the bills they pay. Payments in advance of any invoice
+// carry no GST until invoiced.
+// Recurring charges count as income of the service they are charged on: those
+// of the rent service as rent, and those of fixed services, invoiced on their
+// own, as income of that service.
+func Income(app avisha.App, period avisha.Term) (r IncomeReport, err error) {
+	r.Period = period
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return r, fmt.Errorf("loading settings: %w", err)
+	}
+	var (
+		leases    []*avisha.Lease
+		sites     []*avisha.Site
+		rent      []*avisha.RentInvoice
+		utilities []*avisha.UtilityInvoice
+	)
+	if err := app.All(&leases); err != nil {
+		return r, fmt.Errorf("loading leases: %w", err)
+	}
+	if err := app.All(&sites); err != nil {
+		return r, fmt.Errorf("loading sites: %w", err)
+	}
+	if err := app.All(&rent); err != nil {
+		return r, fmt.Errorf("loading rent invoices: %w", err)
+	}
+	if err := app.All(&utilities); err != nil {
+		return r, fmt.Errorf("loading utility invoices: %w", err)
+	}
+	type account struct {
+		lease int
+		key   avisha.ServiceKey
+	}
+	type line struct {
+		site int
+		key  avisha.ServiceKey
+	}
+	var (
+		invoices = make(map[account][]billed)
+		lines    = make(map[line]*IncomeLine)
+		siteOf   = make(map[int]int)
+		bySite   = make(map[int]avisha.Site)
+		in       = func(t time.Time) bool {
+			return !t.Before(period.Start) && t.Before(period.End())
+		}
+		lineOf = func(lease int, key avisha.ServiceKey) *IncomeLine {
+			k := line{site: siteOf[lease], key: key}
+			if l, ok := lines[k]; ok {
+				return l
+			}
+			def, ok := settings.Service(key)
+			if !ok {
+				def = avisha.ServiceDefinition{Key: key, Name: string(key)}
+			}
+			lines[k] = &IncomeLine{Site: bySite[k.site], Service: def}
+			return lines[k]
+		}
+	)
+	for _, s := range sites {
+		bySite[s.ID] = *s
+	}
+	for _, l := range leases {
+		siteOf[l.ID] = l.Site
+	}
+	for _, inv := range rent {
+		key := account{lease: inv.Lease, key: avisha.ServiceRent}
+		invoices[key] = append(invoices[key], billed{Invoice: inv.Invoice, GST: inv.Charges.GST})
+	}
+	for _, inv := range utilities {
+		key := account{lease: inv.Lease, key: inv.Service}
+		invoices[key] = append(invoices[key], billed{Invoice: inv.Invoice, GST: inv.Charges.GST})
+	}
+	for key, billed := range invoices {
+		for _, inv := range billed {
+			if in(inv.Issued) {
+				lineOf(key.lease, key.key).Accrual.Add(Amount{Net: inv.Bill - inv.GST, GST: inv.GST})
+			}
+		}
+	}
+	for _, l := range leases {
+		for key, s := range l.Services {
+			for _, p := range received(invoices[account{lease: l.ID, key: key}], s.Ledger.Credits) {
+				if in(p.Time) {
+					lineOf(l.ID, key).Cash.Add(p.Amount)
+				}
+			}
+		}
+	}
+	for _, l := range lines {
+		r.Lines = append(r.Lines, *l)
+		r.Totals.Accrual.Add(l.Accrual)
+		r.Totals.Cash.Add(l.Cash)
+	}
+	sort.Slice(r.Lines, func(ii, jj int) bool {
+		if r.Lines[ii].Site.Number != r.Lines[jj].Site.Number {
+			return r.Lines[ii].Site.Number < r.Lines[jj].Site.Number
+		}
+		return r.Lines[ii].Service.Name < r.Lines[jj].Service.Name
+	})
+	return r, nil
+}
+
+// billed is an invoice with the GST included in its bill.
+type billed struct {
+	avisha.Invoice
+	GST currency.Currency
+}
+
+// receipt is a payment split into the income and the GST it pays.
+type receipt struct {
+	Time   time.Time
+	Amount Amount
+}
+
+// received applies the payments made to a service to its invoices, oldest
+// first, splitting each payment by the share of GST in the bills it pays.
+func received(invoices []billed, credits []avisha.Payment) (receipts []receipt) {
+	sort.Slice(invoices, func(ii, jj int) bool {
+		return invoices[ii].ID < invoices[jj].ID
+	})
+	credits = append([]avisha.Payment(nil), credits...)
+	sort.SliceStable(credits, func(ii, jj int) bool {
+		return credits[ii].Time.Before(credits[jj].Time)
+	})
+	var (
+		next int
+		// owing on the invoice being paid.
+		owing currency.Currency
+	)
+	if len(invoices) > 0 {
+		owing = invoices[0].Bill
+	}
+	for _, credit := range credits {
+		rec := receipt{Time: credit.Time}
+		for left := credit.Amount; left > 0; {
+			if next >= len(invoices) {
+				rec.Amount.Net += left
+				break
+			}
+			inv := invoices[next]
+			paid := left
+			if paid > owing {
+				paid = owing
+			}
+			var gst currency.Currency
+			if inv.Bill > 0 {
+				gst = inv.GST.Scale(paid.Mills(), inv.Bill.Mills())
+			}
+			rec.Amount.Add(Amount{Net: paid - gst, GST: gst})
+			left -= paid
+			if owing -= paid; owing <= 0 {
+				if next++; next < len(invoices) {
+					owing = invoices[next].Bill
+				}
+			}
+		}
+		receipts = append(receipts, rec)
+	}
+	return receipts
+}
+
+// Table lays out the report with a row for the income of each service at each
+// site.
+func (r IncomeReport) Table() Table {
+	t := Table{
+		Title: fmt.Sprintf("Income by Site, %s", dates(r.Period)),
+		Header: []string{
+			"Site", "Service",
+			"Invoiced", "GST Invoiced", "Invoiced incl. GST",
+			"Received", "GST Received", "Received incl. GST",
+		},
+	}
+	for _, line := range r.Lines {
+		t.Rows = append(t.Rows, append(
+			[]string{line.Site.Number, line.Service.Name},
+			line.cells()...,
+		))
+	}
+	t.Footer = append([]string{"Total", ""}, IncomeLine{
+		Accrual: r.Totals.Accrual,
+		Cash:    r.Totals.Cash,
+	}.cells()...)
+	return t
+}
+
+// ByService totals the income of each service across the sites.
+func (r IncomeReport) ByService() []IncomeLine {
+	var (
+		services []IncomeLine
+		index    = make(map[avisha.ServiceKey]int)
+	)
+	for _, line := range r.Lines {
+		ii, ok := index[line.Service.Key]
+		if !ok {
+			ii = len(services)
+			index[line.Service.Key] = ii
+			services = append(services, IncomeLine{Service: line.Service})
+		}
+		services[ii].Accrual.Add(line.Accrual)
+		services[ii].Cash.Add(line.Cash)
+	}
+	sort.Slice(services, func(ii, jj int) bool {
+		return services[ii].Service.Name < services[jj].Service.Name
+	})
+	return services
+}
+
+// ServiceTable lays out the report with a row for the income of each service.
+func (r IncomeReport) ServiceTable() Table {
+	t := Table{
+		Title: fmt.Sprintf("Income by Service, %s", dates(r.Period)),
+		Header: []string{
+			"Service",
+			"Invoiced", "GST Invoiced", "Invoiced incl. GST",
+			"Received", "GST Received", "Received incl. GST",
+		},
+	}
+	for _, line := range r.ByService() {
+		t.Rows = append(t.Rows, append([]string{line.Service.Name}, line.cells()...))
+	}
+	t.Footer = append([]string{"Total"}, IncomeLine{
+		Accrual: r.Totals.Accrual,
+		Cash:    r.Totals.Cash,
+	}.cells()...)
+	return t
+}
+
+// GSTTable summarises the GST collected over the period on each basis, as
+// needed to file a GST return.
+func (r IncomeReport) GSTTable() Table {
+	return Table{
+		Title:  fmt.Sprintf("GST, %s", dates(r.Period)),
+		Header: []string{"Basis", "Income incl. GST", "GST Collected", "Income excl. GST"},
+		Rows: [][]string{
+			{
+				"Accrual (invoiced)",
+				r.Totals.Accrual.Total().String(),
+				r.Totals.Accrual.GST.String(),
+				r.Totals.Accrual.Net.String(),
+			},
+			{
+				"Cash (received)",
+				r.Totals.Cash.Total().String(),
+				r.Totals.Cash.GST.String(),
+				r.Totals.Cash.Net.String(),
+			},
+		},
+	}
+}
+
+func (line IncomeLine) cells() []string {
+	return []string{
+		line.Accrual.Net.String(),
+		line.Accrual.GST.String(),
+		line.Accrual.Total().String(),
+		line.Cash.Net.String(),
+		line.Cash.GST.String(),
+		line.Cash.Total().String(),
+	}
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// TestReceived checks that payments are counted as cash income when made,
+// split by the share of GST in the bills they pay, oldest bill first.
+func TestReceived(t *testing.T) {
+	var (
+		first  = billed{Invoice: avisha.Invoice{ID: 1, Bill: 110, Issued: date(2023, time.January, 1)}, GST: 10}
+		second = billed{Invoice: avisha.Invoice{ID: 2, Bill: 220, Issued: date(2023, time.February, 1)}, GST: 20}
+		paid   = func(day, amount int) avisha.Payment {
+			return avisha.Payment{Amount: currency.Currency(amount), Time: date(2023, time.March, day)}
+		}
+	)
+	tests := []struct {
+		name     string
+		invoices []billed
+		credits  []avisha.Payment
+		// want is the net and GST received by each payment, in units.
+		want []string
+	}{
+		{
+			"paid in full",
+			[]billed{first},
+			[]avisha.Payment{paid(1, 110)},
+			[]string{"100+10"},
+		},
+		{
+			"paid in parts",
+			[]billed{first},
+			[]avisha.Payment{paid(1, 55), paid(2, 55)},
+			[]string{"50+5", "50+5"},
+		},
+		{
+			"paid across invoices",
+			[]billed{first, second},
+			[]avisha.Payment{paid(1, 165)},
+			[]string{"150+15"},
+		},
+		{
+			"oldest invoice first",
+			[]billed{second, first},
+			[]avisha.Payment{paid(1, 110)},
+			[]string{"100+10"},
+		},
+		{
+			"earliest payment first",
+			[]billed{first, second},
+			[]avisha.Payment{paid(2, 220), paid(1, 110)},
+			[]string{"100+10", "200+20"},
+		},
+		{
+			"paid in advance",
+			[]billed{first},
+			[]avisha.Payment{paid(1, 150)},
+			[]string{"140+10"},
+		},
+		{
+			"nothing invoiced",
+			nil,
+			[]avisha.Payment{paid(1, 50)},
+			[]string{"50+0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range received(tt.invoices, tt.credits) {
+				got = append(got, fmt.Sprintf("%d+%d", r.Amount.Net, r.Amount.GST))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// Occupancy counts the sites of a dwelling type that are let.
+type Occupancy struct {
+	Dwelling avisha.Dwelling
+	Sites    int
+	Occupied int
+}
+
+// Rate returns the percentage of sites occupied.
+func (o Occupancy) Rate() float64 {
+	if o.Sites == 0 {
+		return 0
+	}
+	return float64(o.Occupied) / float64(o.Sites) * 100
+}
+
+// Vacancy is a period a site was not let.
+type Vacancy struct {
+	Site   avisha.Site
+	Period avisha.Term
+	// Rent is the weekly rent the vacancy is estimated to have lost, being
+	// the rent of the lease before it, or after it if there was none before.
+	Rent currency.Currency
+	// Lost is the rent estimated lost over the period.
+	Lost currency.Currency
+}
+
+// Days returns the length of the vacancy in days.
+func (v Vacancy) Days() int {
+	return v.Period.Days()
+}
+
+// Expiry is a lease whose term is coming to an end.
+type Expiry struct {
+	Lease  avisha.Lease
+	Site   avisha.Site
+	Tenant avisha.Tenant
+	// Days left until the term ends.
+	Days int
+}
+
+// OccupancyReport describes how the sites are let: occupancy by dwelling at
+// a date, the vacancies over a period and the leases soon to expire.
+type OccupancyReport struct {
+	At        time.Time
+	Period    avisha.Term
+	Occupancy []Occupancy
+	Total     Occupancy
+	Vacancies []Vacancy
+	// Within is the number of days from At the expiring leases end within.
+	Within   int
+	Expiring []Expiry
+}
+
+// Lost returns the rent estimated lost across the vacancies.
+func (r OccupancyReport) Lost() (lost currency.Currency) {
+	for _, v := range r.Vacancies {
+		lost += v.Lost
+	}
+	return lost
+}
+
+// OccupancyOf builds an occupancy report: occupancy as of a date, vacancies
+// over the period and leases whose term ends within the given days of the
+// date.
+// A site is occupied from when a lease on it first started until the lease
+// was terminated or its notice took effect. Draft leases don't occupy a site.
+// Archived sites are counted only before they were archived.
+func OccupancyOf(app avisha.App, at time.Time, period avisha.Term, within int) (r OccupancyReport, err error) {
+	r.At, r.Period, r.Within = at, period, within
+	var (
+		sites   []*avisha.Site
+		leases  []*avisha.Lease
+		tenants []*avisha.Tenant
+	)
+	if err := app.All(&sites); err != nil {
+		return r, fmt.Errorf("loading sites: %w", err)
+	}
+	if err := app.All(&leases); err != nil {
+		return r, fmt.Errorf("loading leases: %w", err)
+	}
+	if err := app.All(&tenants); err != nil {
+		return r, fmt.Errorf("loading tenants: %w", err)
+	}
+	sort.Slice(sites, func(ii, jj int) bool {
+		return sites[ii].Number < sites[jj].Number
+	})
+	var (
+		bySite   = make(map[int][]avisha.Lease)
+		byTenant = make(map[int]avisha.Tenant)
+		dwelling = make(map[avisha.Dwelling]*Occupancy)
+		day      = time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	)
+	for _, l := range leases {
+		if l.Status == avisha.Draft {
+			continue
+		}
+		bySite[l.Site] = append(bySite[l.Site], *l)
+	}
+	for _, t := range tenants {
+		byTenant[t.ID] = *t
+	}
+	for _, s := range sites {
+		r.Vacancies = append(r.Vacancies, vacancies(*s, bySite[s.ID], period)...)
+		if s.ArchivedBy(at) {
+			continue
+		}
+		o, ok := dwelling[s.Dwelling]
+		if !ok {
+			o = &Occupancy{Dwelling: s.Dwelling}
+			dwelling[s.Dwelling] = o
+		}
+		o.Sites++
+		r.Total.Sites++
+		for _, l := range bySite[s.ID] {
+			if occupies(l, at) {
+				o.Occupied++
+				r.Total.Occupied++
+				break
+			}
+		}
+		for _, l := range bySite[s.ID] {
+			if l.StatusAt(at) == avisha.Ended {
+				continue
+			}
+			end := l.Term.End()
+			if end.Before(day) || end.After(day.AddDate(0, 0, within)) {
+				continue
+			}
+			r.Expiring = append(r.Expiring, Expiry{
+				Lease:  l,
+				Site:   *s,
+				Tenant: byTenant[l.Tenant],
+				Days:   int(math.Round(end.Sub(day).Hours() / 24)),
+			})
+		}
+	}
+	for _, o := range dwelling {
+		r.Occupancy = append(r.Occupancy, *o)
+	}
+	sort.Slice(r.Occupancy, func(ii, jj int) bool {
+		return r.Occupancy[ii].Dwelling < r.Occupancy[jj].Dwelling
+	})
+	sort.SliceStable(r.Expiring, func(ii, jj int) bool {
+		return r.Expiring[ii].Days < r.Expiring[jj].Days
+	})
+	return r, nil
+}
+
+// occupies reports whether the lease has a site let at the given time.
+func occupies(l avisha.Lease, at time.Time) bool {
+	ends := l.Ends()
+	return !at.Before(l.Commenced()) && (ends.IsZero() || at.Before(ends))
+}
+
+// vacancies finds the gaps between the leases of a site over the period, up
+// until the site was archived.
+func vacancies(s avisha.Site, leases []avisha.Lease, period avisha.Term) (gaps []Vacancy) {
+	if s.ArchivedBy(period.Start) {
+		return nil
+	}
+	if s.ArchivedBy(period.End()) {
+		period.Duration = s.Archived.Sub(period.Start)
+	}
+	var lets []avisha.Term
+	for _, l := range leases {
+		if t := l.Billable(period); t.Duration > 0 {
+			lets = append(lets, t)
+		}
+	}
+	sort.Slice(lets, func(ii, jj int) bool {
+		return lets[ii].Start.Before(lets[jj].Start)
+	})
+	// rent estimates the weekly rent of a site vacant from the given time.
+	rent := func(from time.Time) currency.Currency {
+		var (
+			before avisha.Lease
+			after  avisha.Lease
+		)
+		for _, l := range leases {
+			if l.Commenced().Before(from) {
+				if before.ID == 0 || l.Commenced().After(before.Commenced()) {
+					before = l
+				}
+			} else if after.ID == 0 || l.Commenced().Before(after.Commenced()) {
+				after = l
+			}
+		}
+		if before.ID != 0 {
+			return before.RentAt(from)
+		}
+		return after.RentAt(from)
+	}
+	gap := func(start, end time.Time) {
+		if !end.After(start) {
+			return
+		}
+		v := Vacancy{
+			Site:   s,
+			Period: avisha.Term{Start: start, Duration: end.Sub(start)},
+			Rent:   rent(start),
+		}
+		v.Lost = v.Rent.Scale(int64(v.Days()), 7)
+		gaps = append(gaps, v)
+	}
+	cursor := period.Start
+	for _, l := range lets {
+		gap(cursor, l.Start)
+		if l.End().After(cursor) {
+			cursor = l.End()
+		}
+	}
+	gap(cursor, period.End())
+	return gaps
+}
+
+// OccupancyTable lays out the occupancy of each dwelling type.
+func (r OccupancyReport) OccupancyTable() Table {
+	t := Table{
+		Title:  fmt.Sprintf("Occupancy as of %s", r.At.Format("02/01/2006")),
+		Header: []string{"Dwelling", "Sites", "Occupied", "Vacant", "Occupancy"},
+	}
+	row := func(label string, o Occupancy) []string {
+		return []string{
+			label,
+			strconv.Itoa(o.Sites),
+			strconv.Itoa(o.Occupied),
+			strconv.Itoa(o.Sites - o.Occupied),
+			fmt.Sprintf("%.1f%%", o.Rate()),
+		}
+	}
+	for _, o := range r.Occupancy {
+		t.Rows = append(t.Rows, row(o.Dwelling.String(), o))
+	}
+	t.Footer = row("Total", r.Total)
+	return t
+}
+
+// VacancyTable lays out the vacancies of each site.
+func (r OccupancyReport) VacancyTable() Table {
+	t := Table{
+		Title:  fmt.Sprintf("Vacancies, %s", dates(r.Period)),
+		Header: []string{"Site", "Dwelling", "Vacant", "Days", "Weekly Rent", "Rent Lost"},
+	}
+	for _, v := range r.Vacancies {
+		t.Rows = append(t.Rows, []string{
+			v.Site.Number,
+			v.Site.Dwelling.String(),
+			dates(v.Period),
+			strconv.Itoa(v.Days()),
+			v.Rent.String(),
+			v.Lost.String(),
+		})
+	}
+	t.Footer = []string{"Total", "", "", "", "", r.Lost().String()}
+	return t
+}
+
+// ExpiryTable lays out the leases soon to expire, soonest first.
+func (r OccupancyReport) ExpiryTable() Table {
+	t := Table{
+		Title:  fmt.Sprintf("Leases Expiring Within %d Days", r.Within),
+		Header: []string{"Site", "Tenant", "Status", "Term Ends", "Days Left"},
+	}
+	for _, e := range r.Expiring {
+		t.Rows = append(t.Rows, []string{
+			e.Site.Number,
+			e.Tenant.Name,
+			e.Lease.StatusAt(r.At).String(),
+			e.Lease.Term.End().Format("02/01/2006"),
+			strconv.Itoa(e.Days),
+		})
+	}
+	return t
+}
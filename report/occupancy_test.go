@@ -0,0 +1,74 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go"
+)
+
+func date(year int, m time.Month, day int) time.Time {
+	return time.Date(year, m, day, 0, 0, 0, 0, time.Local)
+}
+
+func TestVacancies(t *testing.T) {
+	var (
+		march = avisha.Term{Start: date(2023, time.March, 1), Duration: 31 * 24 * time.Hour}
+		ended = avisha.Lease{
+			ID:         1,
+			Term:       avisha.Term{Start: date(2022, time.March, 1), Duration: 365 * 24 * time.Hour},
+			Rent:       700,
+			Terminated: date(2023, time.March, 11),
+		}
+	)
+	tests := []struct {
+		name     string
+		archived time.Time
+		// days vacant over March, none if empty.
+		days []int
+	}{
+		{"not archived", time.Time{}, []int{21}},
+		{"archived during the period", date(2023, time.March, 21), []int{10}},
+		{"archived when the lease ended", date(2023, time.March, 11), nil},
+		{"archived before the period", date(2023, time.February, 1), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := avisha.Site{ID: 1, Archived: tt.archived}
+			gaps := vacancies(site, []avisha.Lease{ended}, march)
+			if len(gaps) != len(tt.days) {
+				t.Fatalf("got %d vacancies, want %d", len(gaps), len(tt.days))
+			}
+			for ii, v := range gaps {
+				if v.Days() != tt.days[ii] {
+					t.Errorf("vacancy %d: got %d days, want %d", ii, v.Days(), tt.days[ii])
+				}
+				if want := ended.Rent.Scale(int64(tt.days[ii]), 7); v.Lost != want {
+					t.Errorf("vacancy %d: got %s lost, want %s", ii, v.Lost, want)
+				}
+			}
+		})
+	}
+}
+
+// TestVacanciesNotice checks that a site is vacant from when the notice given
+// on its lease takes effect, as it is reported unoccupied.
+func TestVacanciesNotice(t *testing.T) {
+	var (
+		march = avisha.Term{Start: date(2023, time.March, 1), Duration: 31 * 24 * time.Hour}
+		l     = avisha.Lease{
+			ID:     1,
+			Term:   avisha.Term{Start: date(2022, time.March, 1), Duration: 365 * 24 * time.Hour},
+			Rent:   700,
+			Status: avisha.NoticeGiven,
+			Notice: avisha.Notice{Given: date(2023, time.February, 18), Period: 21 * 24 * time.Hour},
+		}
+	)
+	gaps := vacancies(avisha.Site{ID: 1}, []avisha.Lease{l}, march)
+	if len(gaps) != 1 || gaps[0].Days() != 21 {
+		t.Fatalf("got vacancies %+v, want 21 days from 11 March", gaps)
+	}
+	if occupies(l, date(2023, time.March, 11)) {
+		t.Errorf("got site occupied once the notice took effect")
+	}
+}
@@ -0,0 +1,69 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go"
+)
+
+// Receipt is a payment made to a service of a lease.
+type Receipt struct {
+	Lease   avisha.Lease
+	Site    avisha.Site
+	Tenant  avisha.Tenant
+	Service avisha.ServiceDefinition
+	Payment avisha.Payment
+}
+
+// Payments lists the payments made to every lease since the given time, most
+// recent first.
+func Payments(app avisha.App, since time.Time) (receipts []Receipt, err error) {
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return nil, fmt.Errorf("loading settings: %w", err)
+	}
+	var leases []*avisha.Lease
+	if err := app.All(&leases); err != nil {
+		return nil, fmt.Errorf("loading leases: %w", err)
+	}
+	for _, l := range leases {
+		var (
+			site   avisha.Site
+			tenant avisha.Tenant
+			found  bool
+		)
+		for key, s := range l.Services {
+			for _, p := range s.Ledger.Credits {
+				if p.Time.Before(since) {
+					continue
+				}
+				if !found {
+					if err := app.One("ID", l.Site, &site); err != nil {
+						return nil, fmt.Errorf("lease %d: finding site: %w", l.ID, err)
+					}
+					if err := app.One("ID", l.Tenant, &tenant); err != nil {
+						return nil, fmt.Errorf("lease %d: finding tenant: %w", l.ID, err)
+					}
+					found = true
+				}
+				def, ok := settings.Service(key)
+				if !ok {
+					def = avisha.ServiceDefinition{Key: key, Name: string(key)}
+				}
+				receipts = append(receipts, Receipt{
+					Lease:   *l,
+					Site:    site,
+					Tenant:  tenant,
+					Service: def,
+					Payment: p,
+				})
+			}
+		}
+	}
+	sort.SliceStable(receipts, func(ii, jj int) bool {
+		return receipts[ii].Payment.Time.After(receipts[jj].Payment.Time)
+	})
+	return receipts, nil
+}
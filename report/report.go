@@ -0,0 +1,99 @@
+// Package report builds reports across leases from the records kept by the
+// app, and exports them as CSV or PDF.
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Table is a report laid out as rows of text, ready for export.
+type Table struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+	// Footer holds the totals, if any.
+	Footer []string
+}
+
+// WriteCSV writes the table as comma separated values, header first.
+func (t Table) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.Rows); err != nil {
+		return err
+	}
+	if len(t.Footer) > 0 {
+		if err := cw.Write(t.Footer); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WritePDF writes the tables to a landscape A4 document under the title, one
+// after another.
+func WritePDF(w io.Writer, title string, tables ...Table) error {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.SetTitle(title, true)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, title, "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(0, 6, fmt.Sprintf("Generated %s", time.Now().Format("02/01/2006 15:04")), "", 1, "L", false, 0, "")
+	// The core fonts are latin-1, so text is translated to render symbols
+	// such as the square metre.
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	for _, t := range tables {
+		if len(t.Header) == 0 {
+			continue
+		}
+		var (
+			width, _          = pdf.GetPageSize()
+			left, _, right, _ = pdf.GetMargins()
+			cell              = (width - left - right) / float64(len(t.Header))
+			row               = func(cells []string, style string, border string) {
+				pdf.SetFont("Helvetica", style, 9)
+				for _, text := range cells {
+					pdf.CellFormat(cell, 6, tr(text), border, 0, "L", false, 0, "")
+				}
+				pdf.Ln(-1)
+			}
+		)
+		pdf.Ln(4)
+		if t.Title != "" {
+			pdf.SetFont("Helvetica", "B", 12)
+			pdf.CellFormat(0, 8, tr(t.Title), "", 1, "L", false, 0, "")
+		}
+		row(t.Header, "B", "B")
+		for _, cells := range t.Rows {
+			row(cells, "", "")
+		}
+		if len(t.Footer) > 0 {
+			row(t.Footer, "B", "T")
+		}
+	}
+	return pdf.Output(w)
+}
+
+// dates formats the days of a period, inclusive of the last.
+func dates(period avisha.Term) string {
+	return fmt.Sprintf(
+		"%s to %s",
+		period.Start.Format("02/01/2006"),
+		period.End().AddDate(0, 0, -1).Format("02/01/2006"),
+	)
+}
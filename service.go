@@ -0,0 +1,320 @@
+package avisha
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// ServiceKey identifies a service in the catalogue.
+type ServiceKey string
+
+// Services known to the catalogue.
+const (
+	ServiceRent        ServiceKey = "rent"
+	ServiceElectricity ServiceKey = "electricity"
+	ServiceWater       ServiceKey = "water"
+	ServiceGas         ServiceKey = "gas"
+	ServiceInternet    ServiceKey = "internet"
+	ServiceStorage     ServiceKey = "storage"
+	ServiceParking     ServiceKey = "parking"
+)
+
+// BillingKind describes how a service is billed.
+type BillingKind int
+
+const (
+	// Fixed services are billed a set price each cycle.
+	Fixed BillingKind = iota
+	// Metered services are billed by the units consumed.
+	Metered
+	// Rental services are billed by the rent in effect over the period.
+	Rental
+)
+
+func (k BillingKind) String() string {
+	switch k {
+	case Fixed:
+		return "Fixed"
+	case Metered:
+		return "Metered"
+	case Rental:
+		return "Rental"
+	default:
+		return "Unknown"
+	}
+}
+
+// TaxCode describes how GST applies to a service.
+type TaxCode int
+
+const (
+	// Standard services are charged GST at the standard rate.
+	Standard TaxCode = iota
+	// ZeroRated services are taxable at 0%.
+	ZeroRated
+	// Exempt services are not subject to GST, such as residential rent.
+	Exempt
+)
+
+func (c TaxCode) String() string {
+	switch c {
+	case Standard:
+		return "GST"
+	case ZeroRated:
+		return "Zero Rated"
+	case Exempt:
+		return "Exempt"
+	default:
+		return "Unknown"
+	}
+}
+
+// Rate returns the percentage of GST to charge given the standard rate.
+func (c TaxCode) Rate(standard float64) float64 {
+	if c == Standard {
+		return standard
+	}
+	return 0
+}
+
+// ServiceDefinition describes a service in the catalogue that leases can
+// subscribe to.
+type ServiceDefinition struct {
+	Key  ServiceKey
+	Name string
+	Kind BillingKind
+	Tax  TaxCode
+	// Price is the default price of the service.
+	// Metered services are priced per unit, rental per week and fixed per
+	// billing cycle.
+	Price currency.Currency
+	// Unit that metered services are measured in, such as kWh or litres.
+	Unit string
+	// Reference is appended to payment references to identify the service.
+	Reference string
+	// Tariffs price metered services in place of the per unit price, ordered
+	// by effective date.
+	Tariffs []Tariff
+	// Default services are subscribed to by new leases.
+	Default bool
+	// PassLineCharge reports whether the line charge of metered invoices
+	// passes on the supplier's fixed charges, and so recovers their bill.
+	PassLineCharge bool
+}
+
+// DefaultServices returns the initial service catalogue.
+func DefaultServices(d Defaults) []ServiceDefinition {
+	return []ServiceDefinition{
+		{Key: ServiceRent, Name: "Rent", Kind: Rental, Tax: Exempt, Reference: "RENT", Default: true},
+		{Key: ServiceElectricity, Name: "Electricity", Kind: Metered, Price: d.UnitCost, Unit: "kWh", Reference: "POWR", Default: true},
+		{Key: ServiceWater, Name: "Water", Kind: Metered, Unit: "m³", Reference: "WATR"},
+		{Key: ServiceGas, Name: "Gas", Kind: Metered, Unit: "m³", Reference: "GAS"},
+		{Key: ServiceInternet, Name: "Internet", Kind: Fixed, Reference: "INET"},
+		{Key: ServiceStorage, Name: "Storage", Kind: Fixed, Reference: "STOR"},
+		{Key: ServiceParking, Name: "Parking", Kind: Fixed, Reference: "PARK"},
+	}
+}
+
+// Units returns the label for the units the service is measured in.
+func (def ServiceDefinition) Units() string {
+	if def.Unit == "" {
+		return "units"
+	}
+	return def.Unit
+}
+
+// fill populates details missing from catalogue entries saved before those
+// details existed, using the defaults for known services.
+func (s *Settings) fill() {
+	for _, d := range DefaultServices(s.Defaults) {
+		for ii := range s.Services {
+			def := &s.Services[ii]
+			if def.Key != d.Key {
+				continue
+			}
+			if def.Unit == "" {
+				def.Unit = d.Unit
+			}
+			if def.Reference == "" {
+				def.Reference = d.Reference
+			}
+		}
+	}
+}
+
+// Service returns the catalogue definition for the service key.
+func (s Settings) Service(key ServiceKey) (ServiceDefinition, bool) {
+	for _, def := range s.Services {
+		if def.Key == key {
+			return def, true
+		}
+	}
+	return ServiceDefinition{Key: key, Name: string(key)}, false
+}
+
+// Subscribed returns the definitions of the services the lease subscribes to,
+// in catalogue order.
+// Services missing from the catalogue are listed last.
+func (s Settings) Subscribed(l Lease) []ServiceDefinition {
+	var (
+		defs  = make([]ServiceDefinition, 0, len(l.Services))
+		order = make(map[ServiceKey]int, len(s.Services))
+	)
+	for ii, def := range s.Services {
+		order[def.Key] = ii
+	}
+	for key := range l.Services {
+		def, _ := s.Service(key)
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(ii, jj int) bool {
+		oi, ok := order[defs[ii].Key]
+		if !ok {
+			oi = len(order)
+		}
+		oj, ok := order[defs[jj].Key]
+		if !ok {
+			oj = len(order)
+		}
+		if oi == oj {
+			return defs[ii].Key < defs[jj].Key
+		}
+		return oi < oj
+	})
+	return defs
+}
+
+// Subscribe the lease to a service from the catalogue.
+func (app App) Subscribe(leaseID int, key ServiceKey) error {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return fmt.Errorf("finding lease: %w", err)
+	}
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	if _, ok := settings.Service(key); !ok {
+		return fmt.Errorf("service %q is not in the catalogue", key)
+	}
+	if l.Services == nil {
+		l.Services = make(map[ServiceKey]Service)
+	}
+	s := l.Services[key]
+	s.Closed = time.Time{}
+	l.Services[key] = s
+	if err := app.Save(&l); err != nil {
+		return err
+	}
+	app.emit(ServiceSubscribed{Lease: l.ID, Service: key})
+	return nil
+}
+
+// Unsubscribe the lease from a service.
+// A service with ledger history is closed rather than removed so that its
+// history is kept.
+func (app App) Unsubscribe(leaseID int, key ServiceKey) error {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return fmt.Errorf("finding lease: %w", err)
+	}
+	s, ok := l.Services[key]
+	if !ok {
+		return nil
+	}
+	if s.Balance() != 0 {
+		return fmt.Errorf("service %q has an outstanding balance of %s", key, s.Balance())
+	}
+	if len(s.Ledger.Credits) == 0 && len(s.Ledger.Debits) == 0 {
+		delete(l.Services, key)
+	} else {
+		s.Closed = time.Now()
+		l.Services[key] = s
+	}
+	if err := app.Save(&l); err != nil {
+		return err
+	}
+	app.emit(ServiceUnsubscribed{Lease: l.ID, Service: key})
+	return nil
+}
+
+// Migrate upgrades records written by earlier versions.
+func (app App) Migrate() error {
+	var leases []*Lease
+	if err := app.All(&leases); err != nil {
+		return fmt.Errorf("loading leases: %w", err)
+	}
+	for _, l := range leases {
+		// Utilities were billed as a single "utilities" service which was
+		// always electricity.
+		s, ok := l.Services["utilities"]
+		if !ok {
+			continue
+		}
+		delete(l.Services, "utilities")
+		l.Services[ServiceElectricity] = s
+		if err := app.Save(l); err != nil {
+			return fmt.Errorf("migrating lease %d: %w", l.ID, err)
+		}
+	}
+	var invoices []*UtilityInvoice
+	if err := app.Select(q.Eq("Service", ServiceKey(""))).OrderBy("ID").Find(&invoices); err != nil && err != storm.ErrNotFound {
+		return fmt.Errorf("loading invoices: %w", err)
+	}
+	byLease := make(map[int][]*UtilityInvoice)
+	for _, inv := range invoices {
+		// Utility invoices were always for electricity before other metered
+		// services existed.
+		inv.Service = ServiceElectricity
+		// Nor were they debited their bill, so they never balanced.
+		if len(inv.Balance.Debits) == 0 {
+			inv.Balance.Debit(Payment{Amount: inv.Bill, Time: inv.Issued})
+		}
+		byLease[inv.Lease] = append(byLease[inv.Lease], inv)
+	}
+	for _, l := range leases {
+		settle(l.Services[ServiceElectricity].Ledger, byLease[l.ID])
+	}
+	for _, inv := range invoices {
+		if err := app.Save(inv); err != nil {
+			return fmt.Errorf("migrating invoice %d: %w", inv.ID, err)
+		}
+	}
+	if err := app.migrateReadings(); err != nil {
+		return fmt.Errorf("migrating readings: %w", err)
+	}
+	return nil
+}
+
+// settle pays the invoices covered by the credits to the ledger, oldest first,
+// as of the credit that covered each.
+func settle(ledger Ledger, invoices []*UtilityInvoice) {
+	var (
+		credits = ledger.Credits
+		total   currency.Currency
+		at      time.Time
+	)
+	for _, inv := range invoices {
+		for total < inv.Bill && len(credits) > 0 {
+			total += credits[0].Amount
+			at = credits[0].Time
+			credits = credits[1:]
+		}
+		if total < inv.Bill {
+			return
+		}
+		total -= inv.Bill
+		if inv.IsPaid() {
+			continue
+		}
+		if owing := -inv.Balance.Balance(); owing > 0 {
+			inv.Balance.Credit(Payment{Amount: owing, Time: at})
+		}
+		inv.Paid = at
+	}
+}
@@ -0,0 +1,102 @@
+package avisha
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// open an app over an empty database that lasts as long as the test.
+func open(t *testing.T) App {
+	t.Helper()
+	db, err := storm.Open(filepath.Join(t.TempDir(), "avisha.db"))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return App{Node: db, Events: &Bus{}}
+}
+
+// TestMigrateUtilityInvoices checks that the utility invoices of a database
+// from before services are debited their bill, and paid where the credits to
+// the old "utilities" ledger covered them.
+func TestMigrateUtilityInvoices(t *testing.T) {
+	app := open(t)
+	l := Lease{
+		Site: 1,
+		Term: Term{Start: date(2020, time.January, 1), Duration: 365 * 24 * time.Hour},
+		Services: map[ServiceKey]Service{
+			"utilities": {Ledger: Ledger{Credits: []Payment{
+				{Amount: 100, Time: date(2020, time.February, 10)},
+				{Amount: 50, Time: date(2020, time.March, 10)},
+			}}},
+		},
+	}
+	if err := app.Save(&l); err != nil {
+		t.Fatal(err)
+	}
+	// Shaped as they were saved, with neither a service nor a debit.
+	bills := []struct {
+		bill   int
+		issued time.Time
+		// paid is when the invoice should be paid after migrating, if at all.
+		paid time.Time
+	}{
+		{100, date(2020, time.February, 1), date(2020, time.February, 10)},
+		{120, date(2020, time.March, 1), time.Time{}},
+	}
+	for ii, b := range bills {
+		if err := app.Save(&UtilityInvoice{
+			Invoice: Invoice{
+				Lease:  int(l.ID),
+				Bill:   currency.Currency(b.bill),
+				Issued: b.issued,
+			},
+			Reading:       100 * (ii + 1),
+			UnitsConsumed: 100,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.Migrate(); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	var invoices []*UtilityInvoice
+	if err := app.AllByIndex("ID", &invoices); err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != len(bills) {
+		t.Fatalf("got %d invoices, want %d", len(invoices), len(bills))
+	}
+	for ii, inv := range invoices {
+		want := bills[ii]
+		if inv.Service != ServiceElectricity {
+			t.Errorf("invoice %d: got service %q, want %q", inv.ID, inv.Service, ServiceElectricity)
+		}
+		if len(inv.Balance.Debits) != 1 || int(inv.Balance.Debits[0].Amount) != want.bill {
+			t.Errorf("invoice %d: got debits %v, want the bill %d", inv.ID, inv.Balance.Debits, want.bill)
+		}
+		if !inv.Paid.Equal(want.paid) {
+			t.Errorf("invoice %d: got paid %v, want %v", inv.ID, inv.Paid, want.paid)
+		}
+		if inv.IsPaid() && inv.Balance.Balance() != 0 {
+			t.Errorf("invoice %d: paid with balance %s", inv.ID, inv.Balance.Balance())
+		}
+	}
+	// Migrating again changes nothing.
+	if err := app.Migrate(); err != nil {
+		t.Fatalf("migrating again: %v", err)
+	}
+	var again []*UtilityInvoice
+	if err := app.AllByIndex("ID", &again); err != nil {
+		t.Fatal(err)
+	}
+	for ii, inv := range again {
+		if len(inv.Balance.Debits) != 1 || !inv.Paid.Equal(invoices[ii].Paid) {
+			t.Errorf("invoice %d changed when migrated again", inv.ID)
+		}
+	}
+}
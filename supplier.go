@@ -0,0 +1,332 @@
+package avisha
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// SupplierBill is a bill received from a supplier for a metered service
+// across the whole property, recovered from tenants through the sub-meter of
+// each site.
+type SupplierBill struct {
+	ID      ID         `storm:"id,increment"`
+	Service ServiceKey `storm:"index"`
+	// Supplier that issued the bill.
+	Supplier string
+	// Reference is the number of the bill given by the supplier.
+	Reference string
+	// Period the bill covers.
+	Period Term
+	// Units is the consumption of the property as measured by the supplier.
+	Units int
+	// Cost is the amount billed, excluding GST.
+	Cost currency.Currency
+	// Allocation is how the cost is shared between sites.
+	Allocation AllocationMethod
+}
+
+// AllocationMethod describes how a supplier bill is shared between sites.
+type AllocationMethod int
+
+const (
+	// ByConsumption allocates the cost of the units each site consumed, as
+	// measured by its sub-meter, at the supplier's unit rate.
+	// Units not accounted for by sub-meters are common-area losses.
+	ByConsumption AllocationMethod = iota
+	// ByFloorArea allocates the cost in proportion to the floor area of each
+	// site.
+	ByFloorArea
+	// Equally allocates the same share of the cost to each site.
+	Equally
+)
+
+func (m AllocationMethod) String() string {
+	switch m {
+	case ByConsumption:
+		return "Consumption"
+	case ByFloorArea:
+		return "Floor Area"
+	case Equally:
+		return "Equal Split"
+	default:
+		return "Unknown"
+	}
+}
+
+// UnitRate returns the supplier's cost per unit.
+func (b SupplierBill) UnitRate() currency.Currency {
+	if b.Units == 0 {
+		return 0
+	}
+	return b.Cost / currency.Currency(b.Units)
+}
+
+// Allocation is the share of a supplier bill allocated to a site, along with
+// what was recovered from the site's tenants over the period.
+type Allocation struct {
+	Site int
+	// Units consumed at the site over the period, as measured by its
+	// sub-meter.
+	Units int
+	// Share of the bill allocated to the site, as a fraction.
+	Share float64
+	// Cost allocated to the site.
+	Cost currency.Currency
+	// Recovered is what tenants at the site were invoiced for the service
+	// over the period, excluding GST and late fees.
+	Recovered currency.Currency
+}
+
+// Variance returns the amount recovered over the cost allocated; negative
+// when the site was under-recovered.
+func (a Allocation) Variance() currency.Currency {
+	return a.Recovered - a.Cost
+}
+
+// Reconciliation compares what was paid for a supplier bill with what was
+// recovered from tenants.
+type Reconciliation struct {
+	Bill        SupplierBill
+	Allocations []Allocation
+	// MeteredUnits is the total consumption measured by sub-meters.
+	MeteredUnits int
+	// CommonUnits is the consumption not accounted for by sub-meters, such as
+	// common areas and line losses.
+	CommonUnits int
+	// CommonCost is the cost of the common units: the part of the bill not
+	// allocated to sites.
+	CommonCost currency.Currency
+	// Recovered is the total recovered from tenants.
+	Recovered currency.Currency
+	// Discrepancies describe where the sub-meters disagree with the bill.
+	Discrepancies []string
+}
+
+// Variance returns the amount recovered over the amount paid; negative when
+// the bill was under-recovered.
+func (r Reconciliation) Variance() currency.Currency {
+	return r.Recovered - r.Bill.Cost
+}
+
+// RecordSupplierBill saves a supplier bill for a metered service.
+func (app App) RecordSupplierBill(b *SupplierBill) error {
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	if def, _ := settings.Service(b.Service); def.Kind != Metered {
+		return fmt.Errorf("service %q is not metered", b.Service)
+	}
+	if b.Period.Duration <= 0 {
+		return fmt.Errorf("bill must cover a period")
+	}
+	if b.Units < 0 || b.Cost < 0 {
+		return fmt.Errorf("units and cost must not be negative")
+	}
+	if err := app.Save(b); err != nil {
+		return err
+	}
+	app.emit(SupplierBillRecorded{Bill: *b})
+	return nil
+}
+
+// SupplierBills loads the supplier bills for a metered service, most recent
+// period first.
+func (app App) SupplierBills(key ServiceKey) ([]*SupplierBill, error) {
+	var bills []*SupplierBill
+	if err := app.Find("Service", key, &bills); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	sort.SliceStable(bills, func(ii, jj int) bool {
+		return bills[ii].Period.Start.After(bills[jj].Period.Start)
+	})
+	return bills, nil
+}
+
+// Reconcile allocates a supplier bill across the sites metered for the
+// service over the period, and compares each allocation with what was
+// recovered from the site's tenants.
+// Sub-meters are rarely read on the supplier's dates, so consumption is
+// measured between the readings nearest the start and end of the period, and
+// invoices are counted by the date their period ends.
+// Sub-meters measuring more than the supplier billed are reported as a
+// discrepancy, and the bill is then shared by the units they measured.
+func (app App) Reconcile(billID int) (r Reconciliation, err error) {
+	if err := app.One("ID", billID, &r.Bill); err != nil {
+		return r, fmt.Errorf("finding supplier bill: %w", err)
+	}
+	var (
+		bill  = r.Bill
+		sites []*Site
+	)
+	if err := app.All(&sites); err != nil {
+		return r, fmt.Errorf("loading sites: %w", err)
+	}
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return r, fmt.Errorf("loading settings: %w", err)
+	}
+	def, _ := settings.Service(bill.Service)
+	// weights share the bill between the sites, out of total.
+	var weights []int64
+	for _, s := range sites {
+		metered, err := app.metered(s.ID, bill.Service, bill.Period)
+		if err != nil {
+			return r, fmt.Errorf("site %s: %w", s.Number, err)
+		}
+		if !metered {
+			continue
+		}
+		a := Allocation{Site: s.ID}
+		if a.Units, err = app.SiteConsumption(s.ID, bill.Service, bill.Period); err != nil {
+			return r, fmt.Errorf("site %s: calculating consumption: %w", s.Number, err)
+		}
+		if a.Recovered, err = app.recovered(s.ID, def, bill.Period); err != nil {
+			return r, fmt.Errorf("site %s: %w", s.Number, err)
+		}
+		switch bill.Allocation {
+		case ByConsumption:
+			weights = append(weights, int64(a.Units))
+		case ByFloorArea:
+			weights = append(weights, int64(math.Round(s.FloorArea*100)))
+		case Equally:
+			weights = append(weights, 1)
+		}
+		r.MeteredUnits += a.Units
+		r.Recovered += a.Recovered
+		r.Allocations = append(r.Allocations, a)
+	}
+	r.CommonUnits = bill.Units - r.MeteredUnits
+	var total int64
+	for _, w := range weights {
+		total += w
+	}
+	if bill.Allocation == ByConsumption {
+		if r.CommonUnits < 0 {
+			r.Discrepancies = append(r.Discrepancies, fmt.Sprintf(
+				"sub-meters measured %d units more than the supplier billed",
+				-r.CommonUnits,
+			))
+		} else {
+			total = int64(bill.Units)
+		}
+	}
+	// Costs are scaled from the running total of the weights, so that the
+	// allocations and the common cost add up to the bill exactly.
+	var (
+		cumulative int64
+		allocated  currency.Currency
+	)
+	for ii := range r.Allocations {
+		a := &r.Allocations[ii]
+		if total <= 0 {
+			continue
+		}
+		cumulative += weights[ii]
+		a.Share = float64(weights[ii]) / float64(total)
+		a.Cost = bill.Cost.Scale(cumulative, total) - allocated
+		allocated += a.Cost
+	}
+	r.CommonCost = bill.Cost - allocated
+	return r, nil
+}
+
+// SiteConsumption returns the units of a metered service consumed at a site
+// over a period, measured from the last reading at or before the start of the
+// period, or the first reading after it, to the last reading at or before the
+// end of the period.
+// Off-peak consumption is included, and sites without readings over the
+// period consumed nothing.
+func (app App) SiteConsumption(siteID int, key ServiceKey, period Term) (int, error) {
+	meters, err := app.Meters(siteID)
+	if err != nil {
+		return 0, fmt.Errorf("loading meters: %w", err)
+	}
+	var readings []*Reading
+	for _, m := range meters {
+		if m.Service != key {
+			continue
+		}
+		rs, err := app.Readings(m.ID)
+		if err != nil {
+			return 0, fmt.Errorf("loading readings: %w", err)
+		}
+		readings = append(readings, rs...)
+	}
+	sortReadings(readings)
+	var start, end *Reading
+	for _, r := range readings {
+		if r.Date.After(period.End()) {
+			break
+		}
+		if start == nil || !r.Date.After(period.Start) {
+			start = r
+		}
+		end = r
+	}
+	if start == nil || end == nil || start.ID == end.ID {
+		return 0, nil
+	}
+	units, err := app.Consumption(*start, *end)
+	if err != nil {
+		return 0, err
+	}
+	offPeak, err := app.OffPeakConsumption(*start, *end)
+	if err != nil {
+		return 0, err
+	}
+	return units + offPeak, nil
+}
+
+// metered reports whether a site had a meter for the service in service at
+// any point over the period.
+func (app App) metered(siteID int, key ServiceKey, period Term) (bool, error) {
+	meters, err := app.Meters(siteID)
+	if err != nil {
+		return false, fmt.Errorf("loading meters: %w", err)
+	}
+	for _, m := range meters {
+		if m.Service != key || m.Installed.After(period.End()) {
+			continue
+		}
+		if m.InService() || m.Removed.After(period.Start) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recovered returns what tenants at a site were invoiced for the service for
+// periods ending within the given period towards the supplier's bill: the
+// units consumed, and the line charge if it passes on the supplier's charges.
+// GST, late fees and the landlord's own recurring charges recover nothing.
+func (app App) recovered(siteID int, def ServiceDefinition, period Term) (total currency.Currency, err error) {
+	var leases []*Lease
+	if err := app.Select(q.Eq("Site", siteID)).Find(&leases); err != nil && err != storm.ErrNotFound {
+		return 0, fmt.Errorf("loading leases: %w", err)
+	}
+	for _, l := range leases {
+		invoices, err := app.UtilityInvoices(l.ID, def.Key)
+		if err != nil {
+			return 0, fmt.Errorf("loading invoices: %w", err)
+		}
+		for _, inv := range invoices {
+			end := inv.Issued
+			if inv.Period.Duration > 0 {
+				end = inv.Period.End()
+			}
+			if end.After(period.Start) && !end.After(period.End()) {
+				total += inv.Charges.Activity
+				if def.PassLineCharge {
+					total += inv.Charges.LineCharge
+				}
+			}
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,130 @@
+package avisha
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// TestRecovered checks that only the charges passing on the supplier's bill
+// count as recovering it.
+func TestRecovered(t *testing.T) {
+	app := open(t)
+	l := Lease{Site: 1, Term: Term{Start: date(2023, time.January, 1), Duration: 365 * 24 * time.Hour}}
+	if err := app.Save(&l); err != nil {
+		t.Fatal(err)
+	}
+	period := month(2023, time.March)
+	for _, p := range []Term{month(2023, time.February), period, month(2023, time.April)} {
+		inv := UtilityInvoice{
+			Invoice: Invoice{Lease: int(l.ID), Issued: p.End(), Period: p},
+			Service: ServiceElectricity,
+		}
+		inv.Charges.Activity = 1000
+		inv.Charges.LineCharge = 200
+		inv.Charges.Recurring = 300
+		inv.Charges.GST = 150
+		inv.Charges.LateFee = 50
+		if err := app.Save(&inv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name string
+		pass bool
+		want currency.Currency
+	}{
+		{"line charge kept", false, 1000},
+		{"line charge passed on", true, 1200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := ServiceDefinition{Key: ServiceElectricity, Kind: Metered, PassLineCharge: tt.pass}
+			got, err := app.recovered(1, def, period)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReconcile checks that each method allocates the whole bill between the
+// sites and the common areas, to the mill.
+func TestReconcile(t *testing.T) {
+	app := open(t)
+	sites := []struct {
+		area  float64
+		units int
+	}{
+		{10, 100},
+		{20, 200},
+		{30.3, 333},
+	}
+	for _, s := range sites {
+		site := Site{FloorArea: s.area}
+		if err := app.Save(&site); err != nil {
+			t.Fatal(err)
+		}
+		m := Meter{Site: site.ID, Service: ServiceElectricity, Installed: date(2023, time.January, 1)}
+		if err := app.InstallMeter(&m, Reading{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.RecordReading(&Reading{Meter: m.ID, Date: date(2023, time.February, 1), Value: s.units}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name       string
+		units      int
+		allocation AllocationMethod
+		// want is the cost allocated to each site, then the common cost.
+		want        []int
+		discrepancy bool
+	}{
+		{"by consumption", 1000, ByConsumption, []int{1000, 2000, 3329, 3670}, false},
+		{"by floor area", 1000, ByFloorArea, []int{1658, 3317, 5024, 0}, false},
+		{"equally", 1000, Equally, []int{3333, 3333, 3333, 0}, false},
+		{"metered more than billed", 500, ByConsumption, []int{1580, 3159, 5260, 0}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bill := SupplierBill{
+				Service:    ServiceElectricity,
+				Period:     month(2023, time.January),
+				Units:      tt.units,
+				Cost:       9999,
+				Allocation: tt.allocation,
+			}
+			if err := app.RecordSupplierBill(&bill); err != nil {
+				t.Fatal(err)
+			}
+			r, err := app.Reconcile(bill.ID)
+			if err != nil {
+				t.Fatalf("reconciling: %v", err)
+			}
+			var (
+				got   []int
+				total = r.CommonCost
+			)
+			for _, a := range r.Allocations {
+				got = append(got, int(a.Cost))
+				total += a.Cost
+			}
+			got = append(got, int(r.CommonCost))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got costs %v, want %v", got, tt.want)
+			}
+			if total != bill.Cost {
+				t.Errorf("got %d allocated in total, want the bill of %d", total, bill.Cost)
+			}
+			if got := len(r.Discrepancies) > 0; got != tt.discrepancy {
+				t.Errorf("got discrepancies %q, want some %v", r.Discrepancies, tt.discrepancy)
+			}
+		})
+	}
+}
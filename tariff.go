@@ -0,0 +1,173 @@
+package avisha
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// Tariff prices a metered service from its effective date until superseded
+// by a later tariff.
+type Tariff struct {
+	// Effective is when the tariff comes into effect.
+	Effective time.Time
+	// DailyCharge is a fixed charge for each day of the billing period.
+	DailyCharge currency.Currency
+	// Tiers price consumption in blocks, charged in order.
+	Tiers []Tier
+	// TimeOfUse tariffs charge the units read off the off-peak register at
+	// the off-peak price, leaving the tiers to price peak consumption.
+	// Otherwise off-peak units are priced by the tiers along with the rest.
+	TimeOfUse bool
+	// OffPeak is the price per unit consumed off-peak.
+	OffPeak currency.Currency
+}
+
+// Tier is a block of consumption charged at one price.
+type Tier struct {
+	// Units in the block. The final tier is unbounded and ignores its units.
+	Units int
+	// Price per unit consumed within the block.
+	Price currency.Currency
+}
+
+// Validate the tariff can price consumption.
+func (t Tariff) Validate() error {
+	if t.Effective.IsZero() {
+		return fmt.Errorf("tariff must have an effective date")
+	}
+	if len(t.Tiers) == 0 {
+		return fmt.Errorf("tariff must have at least one tier")
+	}
+	for ii, tier := range t.Tiers {
+		if ii < len(t.Tiers)-1 && tier.Units <= 0 {
+			return fmt.Errorf("tier %d must have a positive number of units", ii+1)
+		}
+		if tier.Price < 0 {
+			return fmt.Errorf("tier %d must not have a negative price", ii+1)
+		}
+	}
+	if t.DailyCharge < 0 || t.OffPeak < 0 {
+		return fmt.Errorf("charges must not be negative")
+	}
+	return nil
+}
+
+// IsZero reports whether the tariff is unset.
+func (t Tariff) IsZero() bool {
+	return len(t.Tiers) == 0
+}
+
+// Lines itemises the charges for the units consumed over a number of days.
+func (t Tariff) Lines(units, offPeak, days int) (lines []LineItem) {
+	if t.DailyCharge > 0 && days > 0 {
+		lines = append(lines, lineItem("Daily charge", days, t.DailyCharge))
+	}
+	var prefix string
+	if t.TimeOfUse {
+		prefix = "Peak: "
+	} else {
+		units += offPeak
+	}
+	var (
+		remaining = units
+		floor     int
+	)
+	for ii, tier := range t.Tiers {
+		var (
+			last        = ii == len(t.Tiers)-1
+			quantity    = remaining
+			description string
+		)
+		switch {
+		case len(t.Tiers) == 1:
+			description = "Consumption"
+		case last:
+			description = fmt.Sprintf("Over %d", floor)
+		case ii == 0:
+			description = fmt.Sprintf("First %d", tier.Units)
+		default:
+			description = fmt.Sprintf("Next %d", tier.Units)
+		}
+		if !last && quantity > tier.Units {
+			quantity = tier.Units
+		}
+		// Tiers that were not reached are left off, though the first is
+		// always shown so that consumption is accounted for.
+		if quantity > 0 || ii == 0 {
+			lines = append(lines, lineItem(prefix+description, quantity, tier.Price))
+		}
+		remaining -= quantity
+		floor += tier.Units
+	}
+	if t.TimeOfUse {
+		lines = append(lines, lineItem("Off-peak", offPeak, t.OffPeak))
+	}
+	return lines
+}
+
+// TariffAt returns the tariff in effect at the given time, if any.
+// Services without a tariff in effect are priced per unit.
+func (def ServiceDefinition) TariffAt(t time.Time) (Tariff, bool) {
+	var (
+		tariff Tariff
+		found  bool
+	)
+	for _, candidate := range def.Tariffs {
+		if candidate.Effective.After(t) {
+			break
+		}
+		tariff, found = candidate, true
+	}
+	return tariff, found
+}
+
+// SetTariff adds a tariff to a metered service, replacing any tariff that
+// comes into effect on the same date.
+func (s *Settings) SetTariff(key ServiceKey, t Tariff) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	for ii := range s.Services {
+		def := &s.Services[ii]
+		if def.Key != key {
+			continue
+		}
+		if def.Kind != Metered {
+			return fmt.Errorf("service %q is not metered", key)
+		}
+		tariffs := []Tariff{t}
+		for _, existing := range def.Tariffs {
+			if !existing.Effective.Equal(t.Effective) {
+				tariffs = append(tariffs, existing)
+			}
+		}
+		sort.SliceStable(tariffs, func(ii, jj int) bool {
+			return tariffs[ii].Effective.Before(tariffs[jj].Effective)
+		})
+		def.Tariffs = tariffs
+		return nil
+	}
+	return fmt.Errorf("service %q is not in the catalogue", key)
+}
+
+// RemoveTariff removes the tariff of a service that comes into effect on the
+// given date.
+func (s *Settings) RemoveTariff(key ServiceKey, effective time.Time) error {
+	for ii := range s.Services {
+		def := &s.Services[ii]
+		if def.Key != key {
+			continue
+		}
+		for jj, existing := range def.Tariffs {
+			if existing.Effective.Equal(effective) {
+				def.Tariffs = append(def.Tariffs[:jj], def.Tariffs[jj+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("no tariff effective %s", effective.Format("02/01/2006"))
+	}
+	return fmt.Errorf("service %q is not in the catalogue", key)
+}
@@ -0,0 +1,105 @@
+package avisha
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestTariffLines checks the lines tiered and time-of-use tariffs itemise
+// consumption into.
+func TestTariffLines(t *testing.T) {
+	tiered := []Tier{{Units: 100, Price: 10}, {Units: 100, Price: 20}, {Price: 30}}
+	tests := []struct {
+		name    string
+		tariff  Tariff
+		units   int
+		offPeak int
+		days    int
+		// want is the description, quantity and amount of each line.
+		want []string
+	}{
+		{
+			"flat",
+			Tariff{Tiers: []Tier{{Price: 10}}},
+			150, 0, 30,
+			[]string{"Consumption 150 1500"},
+		},
+		{
+			"within the first tier",
+			Tariff{Tiers: tiered},
+			50, 0, 30,
+			[]string{"First 100 50 500"},
+		},
+		{
+			"into the last tier",
+			Tariff{Tiers: tiered},
+			250, 0, 30,
+			[]string{"First 100 100 1000", "Next 100 100 2000", "Over 200 50 1500"},
+		},
+		{
+			"nothing consumed",
+			Tariff{Tiers: tiered},
+			0, 0, 30,
+			[]string{"First 100 0 0"},
+		},
+		{
+			"daily charge",
+			Tariff{DailyCharge: 5, Tiers: []Tier{{Price: 10}}},
+			10, 0, 30,
+			[]string{"Daily charge 30 150", "Consumption 10 100"},
+		},
+		{
+			"off-peak priced by the tiers",
+			Tariff{Tiers: tiered},
+			80, 40, 30,
+			[]string{"First 100 100 1000", "Next 100 20 400"},
+		},
+		{
+			"time of use",
+			Tariff{Tiers: tiered, TimeOfUse: true, OffPeak: 4},
+			120, 40, 30,
+			[]string{"Peak: First 100 100 1000", "Peak: Next 100 20 400", "Off-peak 40 160"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, line := range tt.tariff.Lines(tt.units, tt.offPeak, tt.days) {
+				got = append(got, fmt.Sprintf("%s %d %d", line.Description, line.Quantity, line.Amount))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTariffAt checks that the tariff in effect is the latest to have come
+// into effect.
+func TestTariffAt(t *testing.T) {
+	def := ServiceDefinition{Tariffs: []Tariff{
+		{Effective: date(2023, time.January, 1), Tiers: []Tier{{Price: 10}}},
+		{Effective: date(2023, time.July, 1), Tiers: []Tier{{Price: 20}}},
+	}}
+	tests := []struct {
+		at    time.Time
+		price int
+		found bool
+	}{
+		{date(2022, time.December, 31), 0, false},
+		{date(2023, time.January, 1), 10, true},
+		{date(2023, time.June, 30), 10, true},
+		{date(2023, time.July, 1), 20, true},
+	}
+	for _, tt := range tests {
+		tariff, found := def.TariffAt(tt.at)
+		var price int
+		if found {
+			price = int(tariff.Tiers[0].Price)
+		}
+		if found != tt.found || price != tt.price {
+			t.Errorf("at %s: got %d (found %v), want %d (found %v)", tt.at.Format("2006-01-02"), price, found, tt.price, tt.found)
+		}
+	}
+}
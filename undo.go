@@ -0,0 +1,260 @@
+package avisha
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/asdine/storm/v3"
+)
+
+// Action is a change made through the app that can be undone, and redone
+// again, by restoring the records it wrote.
+//
+// Every record written by the action is kept as it was before and after, so
+// undoing a payment takes the credit back off the ledger and unmarks the
+// invoices it paid, and undoing an edit restores the prior version of the
+// record. Settings are not records and aren't kept.
+type Action struct {
+	// Name describes the action, such as "Pay Rent".
+	Name    string
+	records []version
+}
+
+// version is a record before and after an action, nil where the record
+// didn't exist.
+type version struct {
+	entity        reflect.Type
+	id            int
+	before, after interface{}
+}
+
+// Record runs fn, recording the records it writes as an action that can be
+// undone.
+// The action is only returned if fn succeeds.
+func (app App) Record(name string, fn func(app App) error) (Action, error) {
+	var (
+		action = Action{Name: name}
+		rec    = app
+	)
+	r := &recorder{seen: make(map[string]bool)}
+	rec.Node = recordingNode{Node: app.Node, recorder: r}
+	if err := fn(rec); err != nil {
+		return action, err
+	}
+	for _, v := range r.records {
+		after, err := load(app.Node, v.entity, v.id)
+		if err != nil {
+			return action, fmt.Errorf("recording %s: %w", name, err)
+		}
+		v.after = after
+		action.records = append(action.records, v)
+	}
+	return action, nil
+}
+
+// Undo restores the records written by the action to how they were before it.
+// The action cannot be undone if any of its records have changed since.
+func (app App) Undo(a Action) error {
+	return app.restore(a, true)
+}
+
+// Redo writes the records of an undone action back to how the action left
+// them.
+func (app App) Redo(a Action) error {
+	return app.restore(a, false)
+}
+
+// restore the records of the action to their versions before or after it,
+// all or none.
+func (app App) restore(a Action, undo bool) error {
+	return app.Transaction(func(tx App) error {
+		for ii := range a.records {
+			v := a.records[ii]
+			if undo {
+				v = a.records[len(a.records)-1-ii]
+			}
+			from, to := v.after, v.before
+			if !undo {
+				from, to = v.before, v.after
+			}
+			current, err := load(tx.Node, v.entity, v.id)
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(current, from) {
+				return fmt.Errorf("%s %d has changed since %q", v.entity.Name(), v.id, a.Name)
+			}
+			switch {
+			case to == nil && from != nil:
+				if err := tx.DeleteStruct(from); err != nil {
+					return fmt.Errorf("deleting %s %d: %w", v.entity.Name(), v.id, err)
+				}
+			case to != nil:
+				// Save the copy so the action keeps its own version intact.
+				restored := reflect.New(v.entity)
+				restored.Elem().Set(reflect.ValueOf(to).Elem())
+				if err := tx.Save(restored.Interface()); err != nil {
+					return fmt.Errorf("restoring %s %d: %w", v.entity.Name(), v.id, err)
+				}
+			}
+		}
+		if undo {
+			tx.emit(ActionUndone{Action: a.Name})
+		} else {
+			tx.emit(ActionRedone{Action: a.Name})
+		}
+		return nil
+	})
+}
+
+// recorder collects the records written during an action, keeping the first
+// version seen of each.
+type recorder struct {
+	mu      sync.Mutex
+	seen    map[string]bool
+	records []version
+}
+
+// before records the version of the record about to be written, if it hasn't
+// been recorded already.
+func (r *recorder) before(node storm.Node, data interface{}) error {
+	var (
+		entity = reflect.Indirect(reflect.ValueOf(data)).Type()
+		id     = recordID(data)
+		key    = fmt.Sprintf("%s/%d", entity.Name(), id)
+	)
+	if id == 0 {
+		// Created by the write; recorded once it has an ID.
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen[key] {
+		return nil
+	}
+	v, err := load(node, entity, id)
+	if err != nil {
+		return err
+	}
+	r.seen[key] = true
+	r.records = append(r.records, version{entity: entity, id: id, before: v})
+	return nil
+}
+
+// created records a record that didn't exist before the action.
+func (r *recorder) created(data interface{}) {
+	var (
+		entity = reflect.Indirect(reflect.ValueOf(data)).Type()
+		id     = recordID(data)
+		key    = fmt.Sprintf("%s/%d", entity.Name(), id)
+	)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == 0 || r.seen[key] {
+		return
+	}
+	r.seen[key] = true
+	r.records = append(r.records, version{entity: entity, id: id})
+}
+
+// recordingNode records the records written through it for an action.
+type recordingNode struct {
+	storm.Node
+	recorder *recorder
+}
+
+func (n recordingNode) Begin(writable bool) (storm.Node, error) {
+	node, err := n.Node.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return recordingNode{Node: node, recorder: n.recorder}, nil
+}
+
+func (n recordingNode) Save(data interface{}) error {
+	if err := n.recorder.before(n.Node, data); err != nil {
+		return err
+	}
+	if err := n.Node.Save(data); err != nil {
+		return err
+	}
+	n.recorder.created(data)
+	return nil
+}
+
+func (n recordingNode) Update(data interface{}) error {
+	if err := n.recorder.before(n.Node, data); err != nil {
+		return err
+	}
+	return n.Node.Update(data)
+}
+
+func (n recordingNode) UpdateField(data interface{}, fieldName string, value interface{}) error {
+	if err := n.recorder.before(n.Node, data); err != nil {
+		return err
+	}
+	return n.Node.UpdateField(data, fieldName, value)
+}
+
+func (n recordingNode) DeleteStruct(data interface{}) error {
+	if err := n.recorder.before(n.Node, data); err != nil {
+		return err
+	}
+	return n.Node.DeleteStruct(data)
+}
+
+// History is the stack of actions taken, most recent last, that can be undone
+// and redone in turn.
+type History struct {
+	mu     sync.Mutex
+	done   []Action
+	undone []Action
+	// Limit caps the actions kept, dropping the oldest; unlimited if zero.
+	Limit int
+}
+
+// Push an action taken, which can no longer be redone past.
+func (h *History) Push(a Action) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.done = append(h.done, a)
+	if h.Limit > 0 && len(h.done) > h.Limit {
+		h.done = h.done[len(h.done)-h.Limit:]
+	}
+	h.undone = nil
+}
+
+// Undo the most recent action, returning it.
+// False is returned if there is nothing to undo.
+func (h *History) Undo(app App) (Action, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.done) == 0 {
+		return Action{}, false, nil
+	}
+	a := h.done[len(h.done)-1]
+	if err := app.Undo(a); err != nil {
+		return a, true, fmt.Errorf("undoing %s: %w", a.Name, err)
+	}
+	h.done = h.done[:len(h.done)-1]
+	h.undone = append(h.undone, a)
+	return a, true, nil
+}
+
+// Redo the most recently undone action, returning it.
+// False is returned if there is nothing to redo.
+func (h *History) Redo(app App) (Action, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.undone) == 0 {
+		return Action{}, false, nil
+	}
+	a := h.undone[len(h.undone)-1]
+	if err := app.Redo(a); err != nil {
+		return a, true, fmt.Errorf("redoing %s: %w", a.Name, err)
+	}
+	h.undone = h.undone[:len(h.undone)-1]
+	h.done = append(h.done, a)
+	return a, true, nil
+}
@@ -0,0 +1,245 @@
+package avisha
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestUndoEvents checks that undoing and redoing an action publish events,
+// moving the revision on for anything derived from the records.
+func TestUndoEvents(t *testing.T) {
+	app := open(t)
+	a, err := app.Record("Register tenant", func(app App) error {
+		return app.RegisterTenant(&Tenant{Name: "Stark"})
+	})
+	if err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	var got []string
+	app.Events.Subscribe(func(e Event) {
+		got = append(got, fmt.Sprintf("%T%+v", e, e))
+	})
+	revision := app.Events.Revision()
+	if err := app.Undo(a); err != nil {
+		t.Fatalf("undoing: %v", err)
+	}
+	if err := app.Redo(a); err != nil {
+		t.Fatalf("redoing: %v", err)
+	}
+	want := []string{"avisha.ActionUndone{Action:Register tenant}", "avisha.ActionRedone{Action:Register tenant}"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	if app.Events.Revision() != revision+2 {
+		t.Errorf("got revision %d, want %d", app.Events.Revision(), revision+2)
+	}
+}
+
+// TestUndoPayment checks that undoing a payment takes it back off the ledger
+// and unmarks the invoice it paid, unless the records have changed since.
+func TestUndoPayment(t *testing.T) {
+	start := date(2023, time.January, 1)
+	tests := []struct {
+		name string
+		// since changes the records after the payment, if not nil.
+		since func(app App, l Lease) error
+		err   bool
+		// credits left on the ledger once undone.
+		credits int
+	}{
+		{"undone", nil, false, 0},
+		{
+			"paid again since",
+			func(app App, l Lease) error {
+				return app.PayService(l.ID, ServiceRent, 100, start)
+			},
+			true,
+			2,
+		},
+		{
+			"tenant registered since",
+			func(app App, l Lease) error {
+				return app.RegisterTenant(&Tenant{Name: "Lannister"})
+			},
+			false,
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := open(t)
+			l := Lease{
+				Term:     Term{Start: start, Duration: 365 * 24 * time.Hour},
+				Rent:     700,
+				Services: map[ServiceKey]Service{ServiceRent: {}},
+			}
+			if err := app.Save(&l); err != nil {
+				t.Fatal(err)
+			}
+			inv, err := app.InvoiceRent(l.ID, Term{Start: start, Duration: 14 * 24 * time.Hour}, start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, err := app.Record("Pay Rent", func(app App) error {
+				return app.PayService(l.ID, ServiceRent, inv.Bill, start)
+			})
+			if err != nil {
+				t.Fatalf("paying: %v", err)
+			}
+			if tt.since != nil {
+				if err := tt.since(app, l); err != nil {
+					t.Fatalf("changing: %v", err)
+				}
+			}
+			if err := app.Undo(a); (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			if err := app.One("ID", l.ID, &l); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(l.Services[ServiceRent].Ledger.Credits); got != tt.credits {
+				t.Errorf("got %d credits, want %d", got, tt.credits)
+			}
+			if err := app.One("ID", inv.ID, &inv); err != nil {
+				t.Fatal(err)
+			}
+			if inv.IsPaid() != tt.err {
+				t.Errorf("got invoice paid %v, want %v", inv.IsPaid(), tt.err)
+			}
+			if tt.err {
+				return
+			}
+			if err := app.Redo(a); err != nil {
+				t.Fatalf("redoing: %v", err)
+			}
+			if err := app.One("ID", inv.ID, &inv); err != nil {
+				t.Fatal(err)
+			}
+			if !inv.IsPaid() {
+				t.Errorf("got invoice unpaid once redone")
+			}
+		})
+	}
+}
+
+// TestHistory checks that actions are undone and redone in turn, and that
+// taking an action drops those undone.
+func TestHistory(t *testing.T) {
+	app := open(t)
+	var h History
+	for _, name := range []string{"Stark", "Lannister"} {
+		name := name
+		a, err := app.Record("Register "+name, func(app App) error {
+			return app.RegisterTenant(&Tenant{Name: name})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Push(a)
+	}
+	tests := []struct {
+		step func() (Action, bool, error)
+		// want is the action stepped, empty if none.
+		want string
+		// tenants registered after the step.
+		tenants int
+	}{
+		{func() (Action, bool, error) { return h.Undo(app) }, "Register Lannister", 1},
+		{func() (Action, bool, error) { return h.Undo(app) }, "Register Stark", 0},
+		{func() (Action, bool, error) { return h.Undo(app) }, "", 0},
+		{func() (Action, bool, error) { return h.Redo(app) }, "Register Stark", 1},
+		{
+			func() (Action, bool, error) {
+				a, err := app.Record("Register Tyrell", func(app App) error {
+					return app.RegisterTenant(&Tenant{Name: "Tyrell"})
+				})
+				h.Push(a)
+				return a, true, err
+			},
+			"Register Tyrell",
+			2,
+		},
+		{func() (Action, bool, error) { return h.Redo(app) }, "", 2},
+	}
+	for ii, tt := range tests {
+		a, ok, err := tt.step()
+		if err != nil {
+			t.Fatalf("step %d: %v", ii, err)
+		}
+		var got string
+		if ok {
+			got = a.Name
+		}
+		if got != tt.want {
+			t.Errorf("step %d: got %q, want %q", ii, got, tt.want)
+		}
+		n, err := app.Count(&Tenant{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.tenants {
+			t.Errorf("step %d: got %d tenants, want %d", ii, n, tt.tenants)
+		}
+	}
+}
+
+// TestUndoInvoiceReading checks that invoicing from a reading is undone as one
+// action, and that a reading is not kept when its invoice can't be issued.
+func TestUndoInvoiceReading(t *testing.T) {
+	start := date(2023, time.January, 1)
+	tests := []struct {
+		name    string
+		service ServiceKey
+		err     bool
+	}{
+		{"invoiced", ServiceElectricity, false},
+		{"not subscribed", ServiceWater, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := open(t)
+			l := Lease{
+				Site:     1,
+				Term:     Term{Start: start, Duration: 365 * 24 * time.Hour},
+				Services: map[ServiceKey]Service{ServiceElectricity: {}},
+			}
+			if err := app.Save(&l); err != nil {
+				t.Fatal(err)
+			}
+			meter := Meter{Site: 1, Service: tt.service, Installed: start}
+			if err := app.InstallMeter(&meter, Reading{Value: 100}); err != nil {
+				t.Fatal(err)
+			}
+			var (
+				reading = Reading{Meter: meter.ID, Date: date(2023, time.February, 1), Value: 150}
+				inv     = UtilityInvoice{Invoice: Invoice{Issued: reading.Date}}
+			)
+			a, err := app.Record("Invoice", func(app App) error {
+				return app.InvoiceReading(l.ID, tt.service, &reading, &inv)
+			})
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			if !tt.err {
+				if err := app.Undo(a); err != nil {
+					t.Fatalf("undoing: %v", err)
+				}
+			}
+			readings, err := app.Readings(meter.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(readings) != 1 {
+				t.Errorf("got %d readings, want the opening reading alone", len(readings))
+			}
+			invoices, err := app.UtilityInvoices(l.ID, tt.service)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(invoices) != 0 {
+				t.Errorf("got %d invoices, want none", len(invoices))
+			}
+		})
+	}
+}
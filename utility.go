@@ -0,0 +1,226 @@
+package avisha
+
+import (
+	"fmt"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/jackmordaunt/avisha.go/currency"
+)
+
+// Calculate the charges for the units consumed.
+// Invoices with a tariff are itemised by it over the days in the period,
+// otherwise the units are charged at the unit cost.
+// Recurring charges are added on top.
+func (inv *UtilityInvoice) Calculate() {
+	if inv.Tariff.IsZero() {
+		inv.Lines = nil
+		inv.Charges.Activity = inv.UnitCost * currency.Currency(inv.UnitsConsumed+inv.OffPeak.UnitsConsumed)
+	} else {
+		inv.Lines = inv.Tariff.Lines(inv.UnitsConsumed, inv.OffPeak.UnitsConsumed, inv.Period.Days())
+		inv.Charges.Activity = 0
+		for _, line := range inv.Lines {
+			inv.Charges.Activity += line.Amount
+		}
+	}
+	inv.Charges.Recurring = 0
+	for _, line := range inv.Recurring {
+		inv.Charges.Recurring += line.Amount
+	}
+	total := inv.Charges.Activity + inv.Charges.LateFee + inv.Charges.LineCharge + inv.Charges.Recurring
+	inv.Charges.GST = gstOn(total, inv.GST)
+	inv.Bill = total + inv.Charges.GST
+}
+
+// UtilityInvoices loads the invoices issued for a metered or fixed service of
+// the lease, most recent first.
+func (app App) UtilityInvoices(leaseID int, key ServiceKey) ([]*UtilityInvoice, error) {
+	var invoices []*UtilityInvoice
+	if err := app.Select(
+		q.Eq("Lease", leaseID),
+		q.Eq("Service", key),
+	).OrderBy("ID").Reverse().Find(&invoices); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+// PreviousReading returns the reading the next invoice for a metered service
+// of the lease is calculated from.
+// That is the reading of the last invoice, or if none have been issued, the
+// last reading at or before the start of the lease.
+// The error wraps storm.ErrNotFound when the site has no readings for the
+// service.
+func (app App) PreviousReading(leaseID int, key ServiceKey) (r Reading, err error) {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return r, fmt.Errorf("finding lease: %w", err)
+	}
+	invoices, err := app.UtilityInvoices(leaseID, key)
+	if err != nil {
+		return r, fmt.Errorf("loading invoices: %w", err)
+	}
+	if len(invoices) > 0 && invoices[0].Readings.Current != 0 {
+		if err := app.One("ID", invoices[0].Readings.Current, &r); err != nil {
+			return r, fmt.Errorf("finding reading: %w", err)
+		}
+		return r, nil
+	}
+	meters, err := app.Meters(l.Site)
+	if err != nil {
+		return r, fmt.Errorf("loading meters: %w", err)
+	}
+	var found bool
+	for _, m := range meters {
+		if m.Service != key {
+			continue
+		}
+		readings, err := app.Readings(m.ID)
+		if err != nil {
+			return r, fmt.Errorf("loading readings: %w", err)
+		}
+		for _, reading := range readings {
+			// Take the first reading if none precede the lease.
+			if !found || !reading.Date.After(l.Term.Start) {
+				r, found = *reading, true
+			}
+		}
+	}
+	if !found {
+		return r, fmt.Errorf("no %s readings: %w", key, storm.ErrNotFound)
+	}
+	return r, nil
+}
+
+// MeterBaseline returns the meter in service for a metered service of the
+// lease, along with the reading on that meter the next invoice is calculated
+// from.
+// If the meter was replaced since the previous invoice the baseline is its
+// opening reading, and the units consumed on the replaced meters are carried.
+func (app App) MeterBaseline(leaseID int, key ServiceKey) (meter Meter, baseline Reading, carried int, err error) {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return meter, baseline, carried, fmt.Errorf("finding lease: %w", err)
+	}
+	if meter, err = app.ActiveMeter(l.Site, key); err != nil {
+		return meter, baseline, carried, err
+	}
+	previous, err := app.PreviousReading(leaseID, key)
+	if err != nil {
+		return meter, baseline, carried, fmt.Errorf("finding previous reading: %w", err)
+	}
+	if previous.Meter == meter.ID {
+		return meter, previous, carried, nil
+	}
+	readings, err := app.Readings(meter.ID)
+	if err != nil {
+		return meter, baseline, carried, fmt.Errorf("loading readings: %w", err)
+	}
+	if len(readings) == 0 {
+		return meter, baseline, carried, fmt.Errorf("%s has no opening reading", meter)
+	}
+	baseline = *readings[0]
+	if carried, err = app.Consumption(previous, baseline); err != nil {
+		return meter, baseline, carried, fmt.Errorf("calculating consumption: %w", err)
+	}
+	return meter, baseline, carried, nil
+}
+
+// IssueUtilityInvoice saves the invoice against the lease and bills the
+// metered service for it.
+// The units consumed are calculated from the previous reading to the current
+// reading identified by the invoice, and priced by the tariff in effect on the
+// date of the current reading.
+// If the service has been closed the invoice is flagged as the final invoice.
+// The invoice is issued in a single transaction.
+func (app App) IssueUtilityInvoice(leaseID int, key ServiceKey, inv *UtilityInvoice) error {
+	return app.Transaction(func(tx App) error {
+		return tx.issueUtilityInvoice(leaseID, key, inv)
+	})
+}
+
+// InvoiceReading records the reading and issues the invoice from it, in a
+// single transaction: if the invoice can't be issued the reading isn't kept.
+func (app App) InvoiceReading(leaseID int, key ServiceKey, reading *Reading, inv *UtilityInvoice) error {
+	return app.Transaction(func(tx App) error {
+		if err := tx.RecordReading(reading); err != nil {
+			return fmt.Errorf("recording reading: %w", err)
+		}
+		inv.Readings.Current = reading.ID
+		if err := tx.issueUtilityInvoice(leaseID, key, inv); err != nil {
+			return fmt.Errorf("issuing invoice: %w", err)
+		}
+		return nil
+	})
+}
+
+// issueUtilityInvoice issues the invoice within the transaction of the app.
+func (app App) issueUtilityInvoice(leaseID int, key ServiceKey, inv *UtilityInvoice) error {
+	var l Lease
+	if err := app.One("ID", leaseID, &l); err != nil {
+		return fmt.Errorf("finding lease: %w", err)
+	}
+	settings, err := app.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	def, _ := settings.Service(key)
+	if def.Kind != Metered {
+		return fmt.Errorf("service %q is not metered", key)
+	}
+	s, ok := l.Services[key]
+	if !ok {
+		return fmt.Errorf("lease is not subscribed to %q", key)
+	}
+	var current Reading
+	if err := app.One("ID", inv.Readings.Current, &current); err != nil {
+		return fmt.Errorf("finding current reading: %w", err)
+	}
+	previous, err := app.PreviousReading(leaseID, key)
+	if err != nil {
+		return fmt.Errorf("finding previous reading: %w", err)
+	}
+	units, err := app.Consumption(previous, current)
+	if err != nil {
+		return fmt.Errorf("calculating consumption: %w", err)
+	}
+	offPeak, err := app.OffPeakConsumption(previous, current)
+	if err != nil {
+		return fmt.Errorf("calculating off-peak consumption: %w", err)
+	}
+	inv.Readings.Previous = previous.ID
+	inv.PreviousReading = previous.Value
+	inv.Reading = current.Value
+	inv.UnitsConsumed = units
+	inv.OffPeak.PreviousReading = previous.OffPeak
+	inv.OffPeak.Reading = current.OffPeak
+	inv.OffPeak.UnitsConsumed = offPeak
+	if inv.Period.Duration == 0 {
+		inv.Period = Term{
+			Start:    previous.Date,
+			Duration: current.Date.Sub(previous.Date),
+		}
+	}
+	inv.Tariff, _ = def.TariffAt(current.Date)
+	inv.Recurring = s.ChargeLines(inv.Period)
+	inv.Calculate()
+	if s.FinalOver(inv.Period) {
+		inv.Final = true
+		s.Final = false
+		l.Services[key] = s
+		if err := app.Save(&l); err != nil {
+			return fmt.Errorf("updating lease: %w", err)
+		}
+	}
+	inv.Lease = leaseID
+	inv.Service = key
+	inv.Balance.Debit(Payment{
+		Amount: inv.Bill,
+		Time:   inv.Issued,
+	})
+	if err := app.Save(inv); err != nil {
+		return fmt.Errorf("saving invoice: %w", err)
+	}
+	app.emit(InvoiceIssued{Lease: leaseID, Service: key, Invoice: inv.ID, Bill: inv.Bill, Due: inv.Due})
+	return app.BillService(leaseID, key, inv.Bill, inv.Issued)
+}